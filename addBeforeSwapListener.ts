@@ -2,7 +2,8 @@ document.addEventListener("DOMContentLoaded", () => {
   console.log("Adding htmx:beforeSwap event listener ...");
 
   document.body.addEventListener("htmx:beforeSwap", function(evt) {
-    if (evt.detail.xhr.status === 422 || evt.detail.xhr.status === 409) {
+    // These responses carry a message for the user, e.g. a form with validation errors or what went wrong
+    if (evt.detail.xhr.status === 422 || evt.detail.xhr.status === 409 || evt.detail.xhr.status === 500 || evt.detail.xhr.status === 502) {
       evt.detail.shouldSwap = true;
       evt.detail.isError = false;
     }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -12,8 +13,24 @@ import (
 
 	"nerdmoney/pkg/accounts"
 	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/balancerefresh"
 	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/blobstore"
+	"nerdmoney/pkg/budgets"
+	"nerdmoney/pkg/categories"
+	"nerdmoney/pkg/categorization"
+	"nerdmoney/pkg/common/secrets"
+	"nerdmoney/pkg/exchangerates"
 	"nerdmoney/pkg/home"
+	"nerdmoney/pkg/imports"
+	"nerdmoney/pkg/networth"
+	"nerdmoney/pkg/recurring"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/transactionsync"
+	"nerdmoney/pkg/transfers"
+	"nerdmoney/pkg/unitofwork"
+	"nerdmoney/pkg/users"
+	"nerdmoney/pkg/webhooks"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
@@ -21,6 +38,7 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/shopspring/decimal"
 )
 
 var (
@@ -30,7 +48,28 @@ var (
 	PLAID_PRODUCTS      = ""
 	PLAID_COUNTRY_CODES = ""
 	PLAID_REDIRECT_URI  = ""
+	PLAID_WEBHOOK_URL   = ""
+	PLAID_BASE_URL      = ""
+	PLAID_TIMEOUT       = ""
 	DATABASE_URL        = ""
+
+	TOKEN_ENCRYPTION_KEYS   = ""
+	TOKEN_ENCRYPTION_KEY_ID = ""
+
+	TRANSACTION_SYNC_INTERVAL = ""
+	BALANCE_REFRESH_INTERVAL  = ""
+
+	SESSION_COOKIE_SECURE = ""
+
+	BLOB_STORE           = ""
+	BLOB_STORE_DIR       = ""
+	S3_ENDPOINT          = ""
+	S3_REGION            = ""
+	S3_BUCKET            = ""
+	S3_ACCESS_KEY_ID     = ""
+	S3_SECRET_ACCESS_KEY = ""
+
+	MISSING_RECEIPT_ABOVE = ""
 )
 
 func main() {
@@ -53,7 +92,23 @@ func main() {
 	PLAID_PRODUCTS = os.Getenv("PLAID_PRODUCTS")
 	PLAID_COUNTRY_CODES = os.Getenv("PLAID_COUNTRY_CODES")
 	PLAID_REDIRECT_URI = os.Getenv("PLAID_REDIRECT_URI")
+	PLAID_WEBHOOK_URL = os.Getenv("PLAID_WEBHOOK_URL")
+	PLAID_BASE_URL = os.Getenv("PLAID_BASE_URL")
+	PLAID_TIMEOUT = os.Getenv("PLAID_TIMEOUT")
 	DATABASE_URL = os.Getenv("DATABASE_URL")
+	TOKEN_ENCRYPTION_KEYS = os.Getenv("TOKEN_ENCRYPTION_KEYS")
+	TOKEN_ENCRYPTION_KEY_ID = os.Getenv("TOKEN_ENCRYPTION_KEY_ID")
+	TRANSACTION_SYNC_INTERVAL = os.Getenv("TRANSACTION_SYNC_INTERVAL")
+	BALANCE_REFRESH_INTERVAL = os.Getenv("BALANCE_REFRESH_INTERVAL")
+	SESSION_COOKIE_SECURE = os.Getenv("SESSION_COOKIE_SECURE")
+	BLOB_STORE = os.Getenv("BLOB_STORE")
+	BLOB_STORE_DIR = os.Getenv("BLOB_STORE_DIR")
+	S3_ENDPOINT = os.Getenv("S3_ENDPOINT")
+	S3_REGION = os.Getenv("S3_REGION")
+	S3_BUCKET = os.Getenv("S3_BUCKET")
+	S3_ACCESS_KEY_ID = os.Getenv("S3_ACCESS_KEY_ID")
+	S3_SECRET_ACCESS_KEY = os.Getenv("S3_SECRET_ACCESS_KEY")
+	MISSING_RECEIPT_ABOVE = os.Getenv("MISSING_RECEIPT_ABOVE")
 
 	dbPool, err := pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
 	if err != nil {
@@ -66,25 +121,112 @@ func main() {
 		e.Logger.Fatalf("Faied to run migrations: %v\n", err)
 	}
 
+	plaidTimeout, err := parseDurationOrDefault(PLAID_TIMEOUT, 30*time.Second)
+	if err != nil {
+		e.Logger.Fatalf("Invalid PLAID_TIMEOUT: %v", err)
+	}
+
 	plaidClient, err := banking.NewPlaidClient(banking.PlaidClientConfig{
-		ClientId:     PLAID_CLIENT_ID,
-		Secret:       PLAID_SECRET,
-		Env:          PLAID_ENV,
-		Products:     PLAID_PRODUCTS,
-		CountryCodes: PLAID_COUNTRY_CODES,
-		RedirectUri:  PLAID_REDIRECT_URI,
+		ClientId:       PLAID_CLIENT_ID,
+		Secret:         PLAID_SECRET,
+		Env:            PLAID_ENV,
+		Products:       PLAID_PRODUCTS,
+		CountryCodes:   PLAID_COUNTRY_CODES,
+		RedirectUri:    PLAID_REDIRECT_URI,
+		WebhookUrl:     PLAID_WEBHOOK_URL,
+		BaseUrl:        PLAID_BASE_URL,
+		RequestTimeout: plaidTimeout,
 	})
 	if err != nil {
 		e.Logger.Fatalf("Error initializing PlaidClient: %v", err)
 	}
 
+	keyring, err := secrets.ParseKeyring(TOKEN_ENCRYPTION_KEYS, TOKEN_ENCRYPTION_KEY_ID)
+	if err != nil {
+		e.Logger.Fatalf("Error initializing token encryption keys: %v", err)
+	}
+
+	blobStore, err := newBlobStore()
+	if err != nil {
+		e.Logger.Fatalf("Error initializing blob store: %v", err)
+	}
+
+	missingReceiptAbove, err := parseDecimalOrDefault(MISSING_RECEIPT_ABOVE, decimal.NewFromInt(75))
+	if err != nil {
+		e.Logger.Fatalf("Invalid MISSING_RECEIPT_ABOVE: %v", err)
+	}
+
 	// Instantiate repositories
-	bankConnectionRepository := repositories.NewBankConnectionRepository(dbPool, e.Logger)
+	bankConnectionRepository := repositories.NewBankConnectionRepository(dbPool, keyring, e.Logger)
 	bankAccountRepository := repositories.NewBankAccountRepository(dbPool, e.Logger)
+	bankAccountNumberRepository := repositories.NewBankAccountNumberRepository(dbPool, e.Logger)
+	transactionRepository := transactions.NewTransactionRepository(dbPool, e.Logger)
+	syncRunRepository := transactions.NewSyncRunRepository(dbPool, e.Logger)
+	importRepository := transactions.NewImportRepository(dbPool, e.Logger)
+	balanceSnapshotRepository := networth.NewBalanceSnapshotRepository(dbPool, e.Logger)
+	categoryRepository := categories.NewCategoryRepository(dbPool, e.Logger)
+	ruleRepository := categorization.NewRuleRepository(dbPool, e.Logger)
+	budgetRepository := budgets.NewBudgetRepository(dbPool, e.Logger)
+	exchangeRateRepository := exchangerates.NewExchangeRateRepository(dbPool, e.Logger)
+	userRepository := users.NewUserRepository(dbPool, e.Logger)
+	sessionRepository := users.NewSessionRepository(dbPool, e.Logger)
+	importProfileRepository := imports.NewProfileRepository(dbPool, e.Logger)
+
+	unitOfWork := unitofwork.New(dbPool, unitofwork.Repos{
+		BankConnections:     bankConnectionRepository,
+		BankAccounts:        bankAccountRepository,
+		BankAccountNumbers:  bankAccountNumberRepository,
+		Transactions:        transactionRepository,
+		SyncRuns:            syncRunRepository,
+		Imports:             importRepository,
+		BalanceSnapshots:    balanceSnapshotRepository,
+		CategorizationRules: ruleRepository,
+		ExchangeRates:       exchangeRateRepository,
+	}, e.Logger)
+
+	// Start background workers
+	transactionSyncInterval, err := parseDurationOrDefault(TRANSACTION_SYNC_INTERVAL, time.Hour)
+	if err != nil {
+		e.Logger.Fatalf("Invalid TRANSACTION_SYNC_INTERVAL: %v", err)
+	}
+
+	syncer := transactionsync.NewSyncer(plaidClient, unitOfWork, e.Logger)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	go syncer.RunScheduled(workersCtx, transactionSyncInterval)
+
+	balanceRefreshInterval, err := parseDurationOrDefault(BALANCE_REFRESH_INTERVAL, 6*time.Hour)
+	if err != nil {
+		e.Logger.Fatalf("Invalid BALANCE_REFRESH_INTERVAL: %v", err)
+	}
+
+	balanceRefresher := balancerefresh.NewRefresher(plaidClient, unitOfWork, e.Logger)
+
+	if balanceRefreshInterval > 0 {
+		go balanceRefresher.RunScheduled(workersCtx, balanceRefreshInterval)
+	}
 
 	// Register routes
+	e.Use(users.RequireSession(sessionRepository))
+
+	users.RegisterUserRoutes(e, userRepository, sessionRepository, SESSION_COOKIE_SECURE != "false")
 	home.RegisterHomeRoutes(e, plaidClient)
-	accounts.RegisterAccountRoutes(e, plaidClient, bankConnectionRepository, bankAccountRepository)
+	accounts.RegisterAccountRoutes(e, plaidClient, unitOfWork, balanceRefresher)
+	accounts.RegisterConnectionRoutes(e, plaidClient, unitOfWork)
+	networth.RegisterNetWorthRoutes(e, balanceSnapshotRepository)
+	transactions.RegisterTransactionRoutes(e, transactionRepository, bankAccountRepository, categoryRepository, missingReceiptAbove)
+	transactions.RegisterTransactionDetailRoutes(e, transactionRepository, blobStore)
+	transactions.RegisterSplitRoutes(e, transactionRepository, categoryRepository)
+	categories.RegisterCategoryRoutes(e, categoryRepository)
+	categorization.RegisterRuleRoutes(e, ruleRepository, transactionRepository, categoryRepository, bankAccountRepository)
+	transfers.RegisterTransferRoutes(e, transactionRepository, bankAccountRepository, exchangeRateRepository)
+	budgets.RegisterBudgetRoutes(e, budgetRepository, transactionRepository, categoryRepository, exchangeRateRepository)
+	exchangerates.RegisterExchangeRateRoutes(e, exchangeRateRepository, userRepository)
+	recurring.RegisterSubscriptionRoutes(e, plaidClient, unitOfWork, exchangeRateRepository)
+	imports.RegisterImportRoutes(e, unitOfWork, importProfileRepository)
+	webhooks.RegisterWebhookRoutes(e, banking.NewPlaidWebhookVerifier(plaidClient), bankConnectionRepository, syncer)
 
 	e.Logger.Fatal(e.Start(":42069"))
 }
@@ -125,3 +267,43 @@ func runMigrations(dbpool *pgxpool.Pool, log echo.Logger) error {
 
 	return nil
 }
+
+func parseDurationOrDefault(value string, defaultDuration time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultDuration, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+func parseDecimalOrDefault(value string, defaultDecimal decimal.Decimal) (decimal.Decimal, error) {
+	if value == "" {
+		return defaultDecimal, nil
+	}
+
+	return decimal.NewFromString(value)
+}
+
+// newBlobStore keeps attachments on the local filesystem unless BLOB_STORE is set to s3
+func newBlobStore() (blobstore.BlobStore, error) {
+	switch BLOB_STORE {
+	case "", "local":
+		dir := BLOB_STORE_DIR
+
+		if dir == "" {
+			dir = "data/blobs"
+		}
+
+		return blobstore.NewLocalBlobStore(dir)
+	case "s3":
+		return blobstore.NewS3BlobStore(blobstore.S3Config{
+			Endpoint:        S3_ENDPOINT,
+			Region:          S3_REGION,
+			Bucket:          S3_BUCKET,
+			AccessKeyID:     S3_ACCESS_KEY_ID,
+			SecretAccessKey: S3_SECRET_ACCESS_KEY,
+		})
+	default:
+		return nil, fmt.Errorf("Incorrect value for BLOB_STORE: '%s'", BLOB_STORE)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"nerdmoney/pkg/banking/plaidfake"
+)
+
+// Runs the fake Plaid API for offline development.
+// Set PLAID_BASE_URL=http://localhost:4010 in .env to point the app at it.
+func main() {
+	addr := flag.String("addr", ":4010", "address to listen on")
+	fixturesPath := flag.String("fixtures", "", "path to a JSON fixtures file, the built-in fixtures are used when empty")
+	flag.Parse()
+
+	fixtures := plaidfake.DefaultFixtures()
+
+	if *fixturesPath != "" {
+		loaded, err := plaidfake.LoadFixtures(*fixturesPath)
+		if err != nil {
+			log.Fatalf("Failed to load fixtures: %v", err)
+		}
+		fixtures = loaded
+	}
+
+	log.Printf("Fake Plaid API listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, plaidfake.NewServer(fixtures)))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"github.com/labstack/gommon/log"
+
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/common/secrets"
+)
+
+// Re-encrypts all stored Plaid access tokens with the key named by TOKEN_ENCRYPTION_KEY_ID.
+// Run it after adding a new key to TOKEN_ENCRYPTION_KEYS and before removing the old one.
+// With -decrypt it stores the tokens in plaintext again instead, which migrating the database
+// below the token encryption requires.
+func main() {
+	decrypt := flag.Bool("decrypt", false, "store the access tokens in plaintext again")
+	flag.Parse()
+
+	logger := log.New("reencrypttokens")
+	logger.SetLevel(log.INFO)
+
+	err := godotenv.Load()
+	if err != nil {
+		logger.Fatalf("Error loading .env file: %v", err)
+	}
+
+	keyring, err := secrets.ParseKeyring(os.Getenv("TOKEN_ENCRYPTION_KEYS"), os.Getenv("TOKEN_ENCRYPTION_KEY_ID"))
+	if err != nil {
+		logger.Fatalf("Error initializing token encryption keys: %v", err)
+	}
+
+	dbPool, err := pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		logger.Fatalf("Unable to connect to database: %v\n", err)
+	}
+	defer dbPool.Close()
+
+	bankConnectionRepository := repositories.NewBankConnectionRepository(dbPool, keyring, logger)
+
+	if *decrypt {
+		count, err := bankConnectionRepository.DecryptAccessTokens(context.Background())
+		if err != nil {
+			logger.Fatalf("Failed to decrypt access tokens: %v", err)
+		}
+
+		logger.Infof("Done, %d access tokens decrypted", count)
+		return
+	}
+
+	count, err := bankConnectionRepository.ReencryptAccessTokens(context.Background())
+	if err != nil {
+		logger.Fatalf("Failed to re-encrypt access tokens: %v", err)
+	}
+
+	logger.Infof("Done, %d access tokens re-encrypted with key id='%s'", count, keyring.ActiveKeyID())
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS transaction_plaid_transaction_id_idx;
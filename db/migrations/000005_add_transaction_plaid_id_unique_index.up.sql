@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_plaid_transaction_id_idx ON transaction(plaid_transaction_id);
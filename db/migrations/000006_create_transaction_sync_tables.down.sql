@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS transaction_sync_run;
+
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS next_cursor VARCHAR(255);
+
+ALTER TABLE bank_connection DROP COLUMN IF EXISTS transactions_cursor;
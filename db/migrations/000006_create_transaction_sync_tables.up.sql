@@ -0,0 +1,16 @@
+ALTER TABLE bank_connection ADD COLUMN IF NOT EXISTS transactions_cursor TEXT;
+
+ALTER TABLE transaction DROP COLUMN IF EXISTS next_cursor;
+
+CREATE TABLE IF NOT EXISTS transaction_sync_run(
+	id bigserial PRIMARY KEY,
+	bank_connection_id INTEGER not null,
+	started_at TIMESTAMP WITH TIME ZONE not null,
+	finished_at TIMESTAMP WITH TIME ZONE,
+	added_count INTEGER not null DEFAULT 0,
+	modified_count INTEGER not null DEFAULT 0,
+	removed_count INTEGER not null DEFAULT 0,
+	error TEXT,
+
+	FOREIGN KEY(bank_connection_id) REFERENCES bank_connection(id)
+);
@@ -0,0 +1 @@
+ALTER TABLE bank_connection DROP COLUMN IF EXISTS disabled;
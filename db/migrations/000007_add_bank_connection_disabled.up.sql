@@ -0,0 +1 @@
+ALTER TABLE bank_connection ADD COLUMN IF NOT EXISTS disabled BOOLEAN not null DEFAULT false;
@@ -0,0 +1,12 @@
+-- Dropping the key columns would leave encrypted tokens nobody can decrypt anymore.
+-- Run `go run ./cmd/reencrypttokens -decrypt` to store them in plaintext first.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM bank_connection WHERE access_token_key_id IS NOT NULL) THEN
+        RAISE EXCEPTION 'bank_connection still holds encrypted access tokens, run `go run ./cmd/reencrypttokens -decrypt` first';
+    END IF;
+END $$;
+
+ALTER TABLE bank_connection DROP COLUMN IF EXISTS access_token_key_id;
+ALTER TABLE bank_connection DROP COLUMN IF EXISTS access_token_data_key;
+ALTER TABLE bank_connection ALTER COLUMN access_token TYPE VARCHAR(255);
@@ -0,0 +1,5 @@
+-- Rows with a NULL access_token_key_id still hold a plaintext token.
+-- Run `go run ./cmd/reencrypttokens` to encrypt them.
+ALTER TABLE bank_connection ALTER COLUMN access_token TYPE TEXT;
+ALTER TABLE bank_connection ADD COLUMN IF NOT EXISTS access_token_data_key TEXT;
+ALTER TABLE bank_connection ADD COLUMN IF NOT EXISTS access_token_key_id VARCHAR(64);
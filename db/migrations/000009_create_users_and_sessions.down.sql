@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS bank_account_user_id_idx;
+DROP INDEX IF EXISTS bank_connection_user_id_idx;
+
+ALTER TABLE bank_account DROP COLUMN IF EXISTS user_id;
+ALTER TABLE bank_connection DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS user_session;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS users(
+	id serial PRIMARY KEY,
+	email VARCHAR(255) unique not null,
+	password_hash TEXT not null,
+	created_at TIMESTAMP WITH TIME ZONE not null DEFAULT now()
+);
+
+-- Only a SHA-256 hash of the session token is stored, the token itself lives in the session cookie
+CREATE TABLE IF NOT EXISTS user_session(
+	id serial PRIMARY KEY,
+	user_id INTEGER not null,
+	token_hash VARCHAR(64) unique not null,
+	created_at TIMESTAMP WITH TIME ZONE not null DEFAULT now(),
+	expires_at TIMESTAMP WITH TIME ZONE not null,
+
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Connections and accounts linked before users existed have no owner and are not shown to anyone.
+-- Assign them once the first user registered, e.g.
+-- UPDATE bank_connection SET user_id = 1; UPDATE bank_account SET user_id = 1;
+ALTER TABLE bank_connection ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id);
+ALTER TABLE bank_account ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id);
+
+CREATE INDEX IF NOT EXISTS bank_connection_user_id_idx ON bank_connection(user_id);
+CREATE INDEX IF NOT EXISTS bank_account_user_id_idx ON bank_account(user_id);
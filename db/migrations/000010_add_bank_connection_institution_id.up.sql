@@ -0,0 +1 @@
+ALTER TABLE bank_connection ADD COLUMN IF NOT EXISTS institution_id VARCHAR(255);
@@ -0,0 +1 @@
+ALTER TABLE bank_account DROP COLUMN IF EXISTS balance_updated_at;
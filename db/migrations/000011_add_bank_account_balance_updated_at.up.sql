@@ -0,0 +1,2 @@
+-- NULL for balances which were never refreshed since the account was linked
+ALTER TABLE bank_account ADD COLUMN IF NOT EXISTS balance_updated_at TIMESTAMP WITH TIME ZONE;
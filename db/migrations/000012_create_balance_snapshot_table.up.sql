@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS balance_snapshot(
+	id bigserial PRIMARY KEY,
+	bank_account_id INTEGER not null,
+	current_balance NUMERIC(15,3),
+	available_balance NUMERIC(15,3),
+	currency VARCHAR(255) not null,
+	taken_at TIMESTAMP WITH TIME ZONE not null DEFAULT now(),
+
+	FOREIGN KEY(bank_account_id) REFERENCES bank_account(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS balance_snapshot_bank_account_id_taken_at_idx ON balance_snapshot(bank_account_id, taken_at);
+
+-- Start the history with the balances we already have
+INSERT INTO balance_snapshot (bank_account_id, current_balance, available_balance, currency, taken_at)
+SELECT id, current_balance, available_balance, currency, COALESCE(balance_updated_at, now())
+FROM bank_account;
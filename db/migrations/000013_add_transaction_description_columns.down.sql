@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS transaction_bank_account_id_date_posted_idx;
+
+ALTER TABLE transaction DROP COLUMN IF EXISTS category;
+ALTER TABLE transaction DROP COLUMN IF EXISTS merchant_name;
+ALTER TABLE transaction DROP COLUMN IF EXISTS name;
@@ -0,0 +1,8 @@
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS name TEXT not null DEFAULT '';
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS merchant_name TEXT;
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS category VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS transaction_bank_account_id_date_posted_idx ON transaction(bank_account_id, date_posted, id);
+
+-- Sync every connection from the start again, so that transactions we already have get their new columns filled in
+UPDATE bank_connection SET transactions_cursor = NULL;
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS transaction_merchant_entity_id_idx;
+
+ALTER TABLE transaction DROP COLUMN IF EXISTS raw;
+ALTER TABLE transaction DROP COLUMN IF EXISTS counterparties;
+ALTER TABLE transaction DROP COLUMN IF EXISTS location;
+ALTER TABLE transaction DROP COLUMN IF EXISTS website;
+ALTER TABLE transaction DROP COLUMN IF EXISTS logo_url;
+ALTER TABLE transaction DROP COLUMN IF EXISTS merchant_entity_id;
+ALTER TABLE transaction DROP COLUMN IF EXISTS payment_channel;
+ALTER TABLE transaction DROP COLUMN IF EXISTS pending_transaction_id;
+ALTER TABLE transaction DROP COLUMN IF EXISTS pending;
+ALTER TABLE transaction DROP COLUMN IF EXISTS category_detailed;
@@ -0,0 +1,16 @@
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS category_detailed VARCHAR(255);
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS pending BOOLEAN not null DEFAULT false;
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS pending_transaction_id VARCHAR(255);
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS payment_channel VARCHAR(255);
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS merchant_entity_id VARCHAR(255);
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS logo_url TEXT;
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS website TEXT;
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS location JSONB;
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS counterparties JSONB not null DEFAULT '[]';
+-- The transaction as Plaid sent it, so that it can be processed again without fetching it
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS raw JSONB;
+
+CREATE INDEX IF NOT EXISTS transaction_merchant_entity_id_idx ON transaction(merchant_entity_id);
+
+-- Sync every connection from the start again, so that transactions we already have get their new columns filled in
+UPDATE bank_connection SET transactions_cursor = NULL;
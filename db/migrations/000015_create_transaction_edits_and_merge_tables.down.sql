@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS transaction_merge;
+DROP TABLE IF EXISTS transaction_tag;
+DROP TABLE IF EXISTS tag;
+
+ALTER TABLE transaction DROP COLUMN IF EXISTS notes;
+ALTER TABLE transaction DROP COLUMN IF EXISTS user_category;
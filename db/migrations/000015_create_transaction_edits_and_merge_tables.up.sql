@@ -0,0 +1,41 @@
+-- Edits made by the user. They are never touched by a sync.
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS user_category VARCHAR(255);
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS notes TEXT;
+
+CREATE TABLE IF NOT EXISTS tag(
+	id serial PRIMARY KEY,
+	user_id INTEGER not null,
+	name VARCHAR(255) not null,
+
+	UNIQUE(user_id, name),
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS transaction_tag(
+	transaction_id BIGINT not null,
+	tag_id INTEGER not null,
+
+	PRIMARY KEY(transaction_id, tag_id),
+	FOREIGN KEY(transaction_id) REFERENCES transaction(id) ON DELETE CASCADE,
+	FOREIGN KEY(tag_id) REFERENCES tag(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS transaction_tag_tag_id_idx ON transaction_tag(tag_id);
+
+-- Audit of pending transactions which were replaced by their posted transaction, along with the edits carried over
+CREATE TABLE IF NOT EXISTS transaction_merge(
+	id bigserial PRIMARY KEY,
+	posted_transaction_id BIGINT not null,
+	pending_plaid_transaction_id VARCHAR(255) not null,
+	pending_amount NUMERIC(15,3) not null,
+	pending_date_posted DATE not null,
+	pending_name TEXT not null,
+	user_category VARCHAR(255),
+	notes TEXT,
+	tags TEXT[] not null DEFAULT '{}',
+	merged_at TIMESTAMP WITH TIME ZONE not null DEFAULT now(),
+
+	FOREIGN KEY(posted_transaction_id) REFERENCES transaction(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS transaction_merge_posted_transaction_id_idx ON transaction_merge(posted_transaction_id);
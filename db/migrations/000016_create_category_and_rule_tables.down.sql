@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS categorization_rule;
+
+ALTER TABLE transaction_merge ADD COLUMN IF NOT EXISTS user_category VARCHAR(255);
+ALTER TABLE transaction_merge DROP COLUMN IF EXISTS payee;
+ALTER TABLE transaction_merge DROP COLUMN IF EXISTS category_source;
+ALTER TABLE transaction_merge DROP COLUMN IF EXISTS category_id;
+
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS user_category VARCHAR(255);
+
+UPDATE transaction SET user_category = category.name
+FROM category
+WHERE category.id = transaction.category_id AND transaction.category_source = 'user';
+
+DROP INDEX IF EXISTS transaction_category_id_idx;
+
+ALTER TABLE transaction DROP COLUMN IF EXISTS payee;
+ALTER TABLE transaction DROP COLUMN IF EXISTS category_source;
+ALTER TABLE transaction DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS category;
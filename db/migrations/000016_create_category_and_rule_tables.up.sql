@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS category(
+	id serial PRIMARY KEY,
+	user_id INTEGER not null,
+	parent_id INTEGER,
+	name VARCHAR(255) not null,
+
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(parent_id) REFERENCES category(id) ON DELETE CASCADE
+);
+
+-- Top level categories have no parent, which a UNIQUE constraint would treat as all different
+CREATE UNIQUE INDEX IF NOT EXISTS category_user_id_parent_id_name_idx ON category(user_id, COALESCE(parent_id, 0), name);
+
+-- category_source is 'user' when the user picked the category and 'rule' when a rule did. Rules never
+-- override a category picked by the user.
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES category(id) ON DELETE SET NULL;
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS category_source VARCHAR(16);
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS payee VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS transaction_category_id_idx ON transaction(category_id);
+
+-- Categories the user picked so far become top level categories
+INSERT INTO category (user_id, name)
+SELECT DISTINCT bank_account.user_id, transaction.user_category
+FROM transaction
+JOIN bank_account ON bank_account.id = transaction.bank_account_id
+WHERE transaction.user_category IS NOT NULL AND bank_account.user_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+UPDATE transaction SET category_id = category.id, category_source = 'user'
+FROM bank_account, category
+WHERE bank_account.id = transaction.bank_account_id
+	AND category.user_id = bank_account.user_id
+	AND category.parent_id IS NULL
+	AND category.name = transaction.user_category;
+
+ALTER TABLE transaction DROP COLUMN IF EXISTS user_category;
+
+ALTER TABLE transaction_merge ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES category(id) ON DELETE SET NULL;
+ALTER TABLE transaction_merge ADD COLUMN IF NOT EXISTS category_source VARCHAR(16);
+ALTER TABLE transaction_merge ADD COLUMN IF NOT EXISTS payee VARCHAR(255);
+ALTER TABLE transaction_merge DROP COLUMN IF EXISTS user_category;
+
+-- Rules are applied in ascending priority. Every condition that is set has to match.
+CREATE TABLE IF NOT EXISTS categorization_rule(
+	id serial PRIMARY KEY,
+	user_id INTEGER not null,
+	name VARCHAR(255) not null,
+	priority INTEGER not null DEFAULT 0,
+	merchant VARCHAR(255),
+	description_regex TEXT,
+	min_amount NUMERIC(15,3),
+	max_amount NUMERIC(15,3),
+	bank_account_id INTEGER,
+	provider_category VARCHAR(255),
+	set_category_id INTEGER,
+	add_tags TEXT[] not null DEFAULT '{}',
+	set_payee VARCHAR(255),
+	created_at TIMESTAMP WITH TIME ZONE not null DEFAULT now(),
+
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(bank_account_id) REFERENCES bank_account(id) ON DELETE CASCADE,
+	FOREIGN KEY(set_category_id) REFERENCES category(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS categorization_rule_user_id_idx ON categorization_rule(user_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS budget;
+DROP TABLE IF EXISTS exchange_rate;
+
+ALTER TABLE users DROP COLUMN IF EXISTS base_currency;
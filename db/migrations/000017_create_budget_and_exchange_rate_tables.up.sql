@@ -0,0 +1,31 @@
+-- Reports and budgets convert all amounts into this currency
+ALTER TABLE users ADD COLUMN IF NOT EXISTS base_currency VARCHAR(3) not null DEFAULT 'USD';
+
+-- 1 unit of from_currency is worth `rate` units of to_currency, from valid_on until the next rate
+CREATE TABLE IF NOT EXISTS exchange_rate(
+	id serial PRIMARY KEY,
+	user_id INTEGER not null,
+	from_currency VARCHAR(255) not null,
+	to_currency VARCHAR(255) not null,
+	rate NUMERIC(20,10) not null,
+	valid_on DATE not null,
+
+	UNIQUE(user_id, from_currency, to_currency, valid_on),
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- month is always the first day of the month
+CREATE TABLE IF NOT EXISTS budget(
+	id serial PRIMARY KEY,
+	user_id INTEGER not null,
+	category_id INTEGER not null,
+	month DATE not null,
+	amount NUMERIC(15,3) not null,
+	rollover BOOLEAN not null DEFAULT false,
+
+	UNIQUE(category_id, month),
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(category_id) REFERENCES category(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS budget_user_id_month_idx ON budget(user_id, month);
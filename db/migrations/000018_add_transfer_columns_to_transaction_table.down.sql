@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS transaction_transfer_transaction_id_idx;
+
+ALTER TABLE transaction DROP COLUMN IF EXISTS transfer_source;
+ALTER TABLE transaction DROP COLUMN IF EXISTS transfer_transaction_id;
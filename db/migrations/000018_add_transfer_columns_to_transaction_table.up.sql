@@ -0,0 +1,7 @@
+-- Both transactions of a transfer between the user's own accounts point at each other. transfer_source is
+-- 'match' when the matcher paired them and 'user' when the user linked or unlinked them. The matcher never
+-- touches transactions the user decided about.
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS transfer_transaction_id BIGINT REFERENCES transaction(id) ON DELETE SET NULL;
+ALTER TABLE transaction ADD COLUMN IF NOT EXISTS transfer_source VARCHAR(16);
+
+CREATE INDEX IF NOT EXISTS transaction_transfer_transaction_id_idx ON transaction(transfer_transaction_id);
@@ -0,0 +1,17 @@
+-- The parts of a split transaction. Their amounts add up to the amount of the transaction and use the same
+-- sign, positive amounts are money leaving the account. Syncs update transactions in place, so the
+-- allocations are kept.
+CREATE TABLE IF NOT EXISTS transaction_allocation(
+	id bigserial PRIMARY KEY,
+	transaction_id BIGINT not null,
+	position INTEGER not null,
+	amount NUMERIC(15,3) not null,
+	category_id INTEGER,
+	notes TEXT,
+
+	UNIQUE(transaction_id, position),
+	FOREIGN KEY(transaction_id) REFERENCES transaction(id) ON DELETE CASCADE,
+	FOREIGN KEY(category_id) REFERENCES category(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS transaction_allocation_category_id_idx ON transaction_allocation(category_id);
@@ -0,0 +1,15 @@
+-- Files attached to transactions, e.g. receipts. The content lives in the blob store under blob_key, the blobs
+-- of transactions removed by a sync are left behind in the store.
+CREATE TABLE IF NOT EXISTS transaction_attachment(
+	id bigserial PRIMARY KEY,
+	transaction_id BIGINT not null,
+	blob_key VARCHAR(255) not null UNIQUE,
+	file_name VARCHAR(255) not null,
+	content_type VARCHAR(255) not null,
+	size BIGINT not null,
+	created_at TIMESTAMP WITH TIME ZONE not null DEFAULT now(),
+
+	FOREIGN KEY(transaction_id) REFERENCES transaction(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS transaction_attachment_transaction_id_idx ON transaction_attachment(transaction_id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS transaction_import;
+DROP TABLE IF EXISTS import_profile;
+
+DELETE FROM transaction WHERE bank_account_id IN (SELECT id FROM bank_account WHERE bank_connection_id IS NULL);
+DELETE FROM bank_account WHERE bank_connection_id IS NULL;
+UPDATE bank_account SET mask = '' WHERE mask IS NULL;
+
+ALTER TABLE bank_account ALTER COLUMN mask SET NOT NULL;
+ALTER TABLE bank_account ALTER COLUMN bank_connection_id SET NOT NULL;
+ALTER TABLE bank_account ALTER COLUMN plaid_account_id SET NOT NULL;
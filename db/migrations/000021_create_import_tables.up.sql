@@ -0,0 +1,49 @@
+-- Accounts of banks Plaid does not support have neither a Plaid account nor a connection, their transactions are
+-- imported from files. They have no mask either, which Plaid leaves out for some accounts as well.
+ALTER TABLE bank_account ALTER COLUMN plaid_account_id DROP NOT NULL;
+ALTER TABLE bank_account ALTER COLUMN bank_connection_id DROP NOT NULL;
+ALTER TABLE bank_account ALTER COLUMN mask DROP NOT NULL;
+
+-- How the columns of a bank's CSV export map to transactions. Columns are named by their header, or by their
+-- position starting at 1 in files without a header. Either amount_column or debit_column and credit_column are set.
+CREATE TABLE IF NOT EXISTS import_profile(
+	id serial PRIMARY KEY,
+	user_id INTEGER not null,
+	name VARCHAR(255) not null,
+	delimiter VARCHAR(1) not null DEFAULT ',',
+	encoding VARCHAR(32) not null DEFAULT 'utf-8',
+	skip_lines INTEGER not null DEFAULT 0,
+	has_header BOOLEAN not null DEFAULT true,
+	date_column VARCHAR(255) not null,
+	date_format VARCHAR(16) not null,
+	description_column VARCHAR(255) not null,
+	payee_column VARCHAR(255),
+	amount_column VARCHAR(255),
+	debit_column VARCHAR(255),
+	credit_column VARCHAR(255),
+	currency_column VARCHAR(255),
+	decimal_comma BOOLEAN not null DEFAULT false,
+	-- For exports which show money leaving the account as positive amounts
+	invert_amounts BOOLEAN not null DEFAULT false,
+
+	UNIQUE(user_id, name),
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Every uploaded file. The transactions read from it wait in pending_rows until the user confirms the preview.
+CREATE TABLE IF NOT EXISTS transaction_import(
+	id bigserial PRIMARY KEY,
+	bank_account_id INTEGER not null,
+	file_name VARCHAR(255) not null,
+	format VARCHAR(16) not null,
+	status VARCHAR(16) not null DEFAULT 'pending',
+	pending_rows JSONB,
+	imported_count INTEGER not null DEFAULT 0,
+	duplicate_count INTEGER not null DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE not null DEFAULT now(),
+	committed_at TIMESTAMP WITH TIME ZONE,
+
+	FOREIGN KEY(bank_account_id) REFERENCES bank_account(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS transaction_import_bank_account_id_idx ON transaction_import(bank_account_id);
@@ -0,0 +1 @@
+ALTER TABLE transaction_import DROP COLUMN IF EXISTS statements;
@@ -0,0 +1,3 @@
+-- The statements of camt.053 and MT940 files with their opening and closing balances, to reconcile the import
+-- against the balance of the account
+ALTER TABLE transaction_import ADD COLUMN IF NOT EXISTS statements JSONB;
@@ -2,24 +2,35 @@ package accounts
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"nerdmoney/pkg/accounts/models"
-	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/balancerefresh"
 	"nerdmoney/pkg/banking"
 	"nerdmoney/pkg/common/layout"
-	"nerdmoney/pkg/common/utils"
+	"nerdmoney/pkg/unitofwork"
+	"nerdmoney/pkg/users"
+	"strconv"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterAccountRoutes(e *echo.Echo, plaidClient *banking.PlaidClient, bankConnectionRepostiory repositories.BankConnectionRepository, bankAccountRepository repositories.BankAccountRepository) {
+func RegisterAccountRoutes(
+	e *echo.Echo,
+	bankingProvider banking.BankingProvider,
+	unitOfWork unitofwork.UnitOfWork,
+	balanceRefresher *balancerefresh.Refresher,
+) {
 
 	log := e.Logger
 
 	e.GET("/bank-accounts", func(c echo.Context) error {
 
-		bankAccounts, err := bankAccountRepository.ListAll()
+		user := users.CurrentUser(c)
+
+		bankAccounts, err := unitOfWork.Repos().BankAccounts.ListAll(c.Request().Context(), user.ID)
 
 		if err != nil {
 			log.Errorf("Failed to list bank accounts: %w", err)
@@ -33,106 +44,233 @@ func RegisterAccountRoutes(e *echo.Echo, plaidClient *banking.PlaidClient, bankC
 		)
 	})
 
-	e.POST("/banks", func(c echo.Context) error {
-		publicToken := c.FormValue("publicToken")
+	e.POST("/bank-accounts/refresh", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		user := users.CurrentUser(c)
 
-		if len(publicToken) < 1 {
-			return c.String(400, "'publicToken' missing in the request")
+		// Accounts which could not be refreshed still show their last known balances
+		if err := balanceRefresher.RefreshAllForUser(ctx, user.ID); err != nil {
+			log.Errorf("Failed to refresh all balances: %v", err)
 		}
 
-		itemAccessToken, err := plaidClient.GetAccessToken(publicToken)
+		bankAccounts, err := unitOfWork.Repos().BankAccounts.ListAll(ctx, user.ID)
 
 		if err != nil {
-			return c.String(500, "Failed to get item access token from Plaid")
+			log.Errorf("Failed to list bank accounts: %v", err)
+			return c.String(500, "Something went wrong when listing bank accounts...")
 		}
 
-		authGetResponse, err := plaidClient.AuthGet(itemAccessToken.AccessToken)
+		return layout.RenderComponent(
+			c,
+			200,
+			BankAccountList(bankAccounts),
+		)
+	})
+
+	e.POST("/bank-accounts/:id/refresh", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
 
 		if err != nil {
-			return c.String(500, "Failed to get account data from Plaid")
+			return c.String(400, "Invalid bank account id")
 		}
 
-		e.Logger.Info("Auth get response", authGetResponse.Item)
+		ctx := c.Request().Context()
+		user := users.CurrentUser(c)
 
-		bankConnectionWriteModel := models.BankConnectionWriteModel{
-			PlaidItemID:                itemAccessToken.ItemId,
-			AccessToken:                itemAccessToken.AccessToken,
-			ConsentExpirationTimestamp: authGetResponse.Item.ConsentExpirationTime.Get(),
-			LoginRequired:              false,
+		bankAccount, err := unitOfWork.Repos().BankAccounts.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Bank account not found")
 		}
 
+		if err != nil {
+			log.Errorf("Failed to find bank account: %v", err)
+			return c.String(500, "Something went wrong when refreshing the bank account...")
+		}
+
+		// Balances of manual accounts come with their imported statements
+		if bankAccount.IsManual() {
+			return layout.RenderComponent(c, 200, bankAccountListItem(bankAccount, false))
+		}
+
+		connection, err := unitOfWork.Repos().BankConnections.FindByID(ctx, user.ID, *bankAccount.BankConnectionID)
+
+		if err != nil {
+			log.Errorf("Failed to find bank connection: %v", err)
+			return c.String(500, "Something went wrong when refreshing the bank account...")
+		}
+
+		// The balances of every account of the connection are refreshed, but only this one is swapped
+		refreshedAccounts, err := balanceRefresher.RefreshConnection(ctx, connection)
+
+		if err != nil && !errors.Is(err, balancerefresh.ErrRefreshedRecently) {
+			log.Errorf("Failed to refresh balances: %v", err)
+		}
+
+		for _, refreshedAccount := range refreshedAccounts {
+			if refreshedAccount.ID == bankAccount.ID {
+				bankAccount = refreshedAccount
+			}
+		}
+
+		return layout.RenderComponent(
+			c,
+			200,
+			bankAccountListItem(bankAccount, false),
+		)
+	})
+
+	e.GET("/bank-accounts/:id", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid bank account id")
+		}
+
+		ctx := c.Request().Context()
+		user := users.CurrentUser(c)
+
+		bankAccount, err := unitOfWork.Repos().BankAccounts.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Bank account not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find bank account: %v", err)
+			return c.String(500, "Something went wrong when loading the bank account...")
+		}
+
+		numbers, err := unitOfWork.Repos().BankAccountNumbers.ListAllForBankAccount(ctx, user.ID, id)
+
+		if err != nil {
+			log.Errorf("Failed to list bank account numbers: %v", err)
+			return c.String(500, "Something went wrong when loading the bank account...")
+		}
+
+		return layout.RenderPage(
+			c,
+			200,
+			BankAccountDetailsPage(bankAccount, numbers),
+		)
+	})
+
+	// Returns a single account number, masked unless the 'reveal' query param is set
+	e.GET("/bank-accounts/:id/numbers/:numberId", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid bank account id")
+		}
+
+		numberID, err := strconv.Atoi(c.Param("numberId"))
+
+		if err != nil {
+			return c.String(400, "Invalid bank account number id")
+		}
+
+		user := users.CurrentUser(c)
+
+		number, err := unitOfWork.Repos().BankAccountNumbers.FindByID(c.Request().Context(), user.ID, numberID)
+
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && number.BankAccountId != id) {
+			return c.String(404, "Bank account number not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find bank account number: %v", err)
+			return c.String(500, "Something went wrong when loading the bank account number...")
+		}
+
+		revealed := c.QueryParam("reveal") == "true"
+
+		if revealed {
+			c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+		}
+
+		return layout.RenderComponent(
+			c,
+			200,
+			BankAccountNumber(number, revealed),
+		)
+	})
+
+	e.POST("/banks", func(c echo.Context) error {
+		publicToken := c.FormValue("publicToken")
+
+		if len(publicToken) < 1 {
+			return c.String(400, "'publicToken' missing in the request")
+		}
+
+		user := users.CurrentUser(c)
+
 		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 
 		defer cancel()
 
-		e.Logger.Debugf("Starting transaction...")
-		tx, err := bankConnectionRepostiory.DbPool().Begin(ctx)
+		itemAccessToken, err := bankingProvider.GetAccessToken(ctx, publicToken)
 
 		if err != nil {
-			e.Logger.Errorf("Failed to start transaction: %w", err)
-			return c.String(500, fmt.Sprintf("Failed to start transaction: %+v", err))
+			log.Errorf("Failed to get item access token: %v", err)
+			return layout.RenderComponent(c, 502, LinkResult("We couldn't finish linking your bank. Please try again.", true))
 		}
 
-		bankConnection, err := bankConnectionRepostiory.Save(bankConnectionWriteModel)
+		accountsResponse, err := bankingProvider.Accounts(ctx, itemAccessToken.AccessToken)
 
 		if err != nil {
-			tx.Rollback(ctx)
-			e.Logger.Errorf("Failed to save bank connection: %w", err)
-			return c.String(500, fmt.Sprintf("Failed to save bank connection: %+v", err))
+			log.Errorf("Failed to get accounts: %v", err)
+			return layout.RenderComponent(c, 502, LinkResult("We couldn't load your accounts from the bank. Please try again.", true))
 		}
 
-		var bankAccounts []models.BankAccount
+		e.Logger.Info("Accounts response", accountsResponse.Connection)
 
-		for _, plaidAccount := range authGetResponse.Accounts {
+		// Account numbers are nice to have, linking the bank should not fail without them
+		accountNumbers, err := bankingProvider.AccountNumbers(ctx, itemAccessToken.AccessToken)
 
-			var currency string
+		if errors.Is(err, banking.ErrAccountNumbersUnavailable) {
+			e.Logger.Infof("Bank did not share account numbers: %v", err)
+		} else if err != nil {
+			e.Logger.Errorf("Failed to get account numbers from the banking provider: %v", err)
+			accountNumbers = nil
+		}
 
-			if plaidAccount.Balances.IsoCurrencyCode.IsSet() {
-				currency = *plaidAccount.Balances.IsoCurrencyCode.Get()
-			} else if plaidAccount.Balances.UnofficialCurrencyCode.IsSet() {
-				currency = *plaidAccount.Balances.UnofficialCurrencyCode.Get()
-			} else {
-				// Should never happen as Plaid states in the docs that one of the two is alway defined: https://plaid.com/docs/api/products/auth/#authget
-				currency = "Unknown"
-			}
+		bankConnectionWriteModel := models.BankConnectionWriteModel{
+			UserID:                     user.ID,
+			PlaidItemID:                itemAccessToken.ItemId,
+			InstitutionID:              accountsResponse.Connection.InstitutionID,
+			AccessToken:                itemAccessToken.AccessToken,
+			ConsentExpirationTimestamp: accountsResponse.Connection.ConsentExpirationTime,
+			LoginRequired:              false,
+		}
 
-			accountWriteModel := models.BankAccountWriteModel{
-				PlaidAccountId:   plaidAccount.AccountId,
-				BankConnectionID: bankConnection.ID,
-				Name:             plaidAccount.Name,
-				Mask:             plaidAccount.Mask.Get(),
-				AccountType:      string(plaidAccount.Type),
-				CurrentBalance:   utils.NullDecimalFromFloat64(plaidAccount.Balances.Current.Get()),
-				AvailableBalance: utils.NullDecimalFromFloat64(plaidAccount.Balances.Available.Get()),
-				Currency:         currency,
-			}
+		var linkedAccounts []LinkedAccount
+		var replacedConnections []models.BankConnection
 
-			savedBankAccount, err := bankAccountRepository.Save(accountWriteModel)
+		err = unitOfWork.WithTx(ctx, func(tx unitofwork.Repos) error {
+			bankConnection, err := tx.BankConnections.Save(ctx, bankConnectionWriteModel)
 
 			if err != nil {
-				tx.Rollback(ctx)
-				e.Logger.Errorf("Failed to save Plaid Account - %+v. Error was: %w", accountWriteModel, err)
-				return c.String(500, fmt.Sprintf("Failed to save Plaid Account - %+v. Error was: %+v", accountWriteModel, err))
+				return fmt.Errorf("Failed to save bank connection: %w", err)
 			}
 
-			bankAccounts = append(bankAccounts, savedBankAccount)
-		}
+			linkedAccounts, replacedConnections, err = saveLinkedAccounts(ctx, tx, user.ID, bankConnection, accountsResponse.Accounts, accountNumbers)
 
-		e.Logger.Debugf("Comitting transaction...")
-		err = tx.Commit(ctx)
+			return err
+		})
 
 		if err != nil {
-			tx.Rollback(ctx)
-			e.Logger.Errorf("Failed to commit transaction: %w", err)
-			return c.String(500, fmt.Sprintf("Failed to commit transaction: %+v", err))
+			e.Logger.Errorf("Failed to save new bank connection: %v", err)
+			return layout.RenderComponent(c, 500, LinkResult("Something went wrong when saving your accounts. Please try again.", true))
 		}
 
-		e.Logger.Infof("Successfully saved new bank connection with %d accounts", len(bankAccounts))
+		removeReplacedConnections(ctx, bankingProvider, replacedConnections, e.Logger)
+
+		e.Logger.Infof("Successfully saved bank connection with %d accounts", len(linkedAccounts))
 
 		return layout.RenderComponent(
 			c,
 			200,
-			AddAcounts(bankAccounts),
+			AddAcounts(linkedAccounts),
 		)
 	})
 }
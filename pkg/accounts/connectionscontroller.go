@@ -0,0 +1,182 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/unitofwork"
+	"nerdmoney/pkg/users"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterConnectionRoutes(e *echo.Echo, bankingProvider banking.BankingProvider, unitOfWork unitofwork.UnitOfWork) {
+
+	log := e.Logger
+
+	e.GET("/connections", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		user := users.CurrentUser(c)
+
+		connections, err := unitOfWork.Repos().BankConnections.ListAllForUser(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list bank connections: %v", err)
+			return c.String(500, "Something went wrong when listing bank connections...")
+		}
+
+		bankAccounts, err := unitOfWork.Repos().BankAccounts.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list bank accounts: %v", err)
+			return c.String(500, "Something went wrong when listing bank connections...")
+		}
+
+		accountsByConnection := map[int][]models.BankAccount{}
+
+		for _, bankAccount := range bankAccounts {
+			if bankAccount.IsManual() {
+				continue
+			}
+
+			accountsByConnection[*bankAccount.BankConnectionID] = append(accountsByConnection[*bankAccount.BankConnectionID], bankAccount)
+		}
+
+		views := make([]ConnectionView, 0, len(connections))
+
+		for _, connection := range connections {
+			views = append(views, ConnectionView{
+				Connection: connection,
+				Accounts:   accountsByConnection[connection.ID],
+			})
+		}
+
+		return layout.RenderPage(
+			c,
+			200,
+			ConnectionsPage(views),
+		)
+	})
+
+	// Called when the user clicks repair, returns the button which opens update mode
+	e.POST("/connections/:id/link-token", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid bank connection id")
+		}
+
+		user := users.CurrentUser(c)
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+
+		defer cancel()
+
+		connection, err := unitOfWork.Repos().BankConnections.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Bank connection not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find bank connection: %v", err)
+			return c.String(500, "Something went wrong when repairing the bank connection...")
+		}
+
+		if !connection.NeedsReconnect(time.Now()) {
+			return c.String(409, "The bank connection does not need to be repaired")
+		}
+
+		linkTokenResponse, err := bankingProvider.CreateUpdateLinkToken(ctx, strconv.Itoa(user.ID), connection.AccessToken)
+
+		if err != nil {
+			log.Errorf("Failed to create update link token for bank connection with id='%d': %v", connection.ID, err)
+			return c.String(502, "Failed to start repairing the bank connection, please try again")
+		}
+
+		return layout.RenderComponent(c, 200, banking.PlaidReconnectButton(connection.ID, linkTokenResponse.LinkToken))
+	})
+
+	// Called by the frontend once the user went through update mode successfully
+	e.POST("/connections/:id/reconnected", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid bank connection id")
+		}
+
+		user := users.CurrentUser(c)
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+
+		defer cancel()
+
+		connection, err := unitOfWork.Repos().BankConnections.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Bank connection not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find bank connection: %v", err)
+			return c.String(500, "Something went wrong when reconnecting the bank...")
+		}
+
+		// Update mode keeps the access token, but the user may have shared more accounts or renewed their consent
+		accountsResponse, err := bankingProvider.Accounts(ctx, connection.AccessToken)
+
+		if err != nil {
+			log.Errorf("Failed to get accounts of bank connection with id='%d': %v", connection.ID, err)
+			return c.String(500, "Failed to get account data from the banking provider")
+		}
+
+		var bankAccounts []models.BankAccount
+		var replacedConnections []models.BankConnection
+
+		err = unitOfWork.WithTx(ctx, func(tx unitofwork.Repos) error {
+			if err := tx.BankConnections.UpdateLoginRequired(ctx, connection.ID, false); err != nil {
+				return err
+			}
+
+			if err := tx.BankConnections.UpdateConsentExpiration(ctx, connection.ID, accountsResponse.Connection.ConsentExpirationTime); err != nil {
+				return err
+			}
+
+			linkedAccounts, replaced, err := saveLinkedAccounts(ctx, tx, user.ID, connection, accountsResponse.Accounts, nil)
+			replacedConnections = replaced
+
+			for _, linkedAccount := range linkedAccounts {
+				bankAccounts = append(bankAccounts, linkedAccount.Account)
+			}
+
+			return err
+		})
+
+		if err != nil {
+			log.Errorf("Failed to save reconnected bank connection with id='%d': %v", connection.ID, err)
+			return c.String(500, "Something went wrong when reconnecting the bank...")
+		}
+
+		removeReplacedConnections(ctx, bankingProvider, replacedConnections, log)
+
+		connection, err = unitOfWork.Repos().BankConnections.FindByID(ctx, user.ID, id)
+
+		if err != nil {
+			log.Errorf("Failed to find bank connection: %v", err)
+			return c.String(500, "Something went wrong when reconnecting the bank...")
+		}
+
+		log.Infof("Bank connection with id='%d' was reconnected", connection.ID)
+
+		return layout.RenderComponent(
+			c,
+			200,
+			ConnectionRow(ConnectionView{Connection: connection, Accounts: bankAccounts}, true),
+		)
+	})
+}
@@ -0,0 +1,219 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/unitofwork"
+
+	"github.com/labstack/echo/v4"
+)
+
+// LinkedAccount is an account returned by the banking provider after linking a bank, along with
+// whether we saw it for the first time or refreshed an account we already had.
+type LinkedAccount struct {
+	Account models.BankAccount
+	New     bool
+}
+
+// saveLinkedAccounts saves the provider's accounts for the connection. Accounts we already know are
+// updated in place, so that linking the same bank again never creates duplicates. When a bank is linked
+// again the provider may issue new account ids for the same accounts. These are recognized by their mask,
+// name and type, moved over to the new connection and the old connection is disabled once it has no
+// accounts left. The disabled connections are returned, so that they can be removed at the provider once
+// the transaction is committed. Pass nil accountNumbers to keep the numbers which are already saved.
+func saveLinkedAccounts(
+	ctx context.Context,
+	tx unitofwork.Repos,
+	userID int,
+	connection models.BankConnection,
+	accounts []banking.Account,
+	accountNumbers []banking.AccountNumber,
+) ([]LinkedAccount, []models.BankConnection, error) {
+	existingAccounts, err := tx.BankAccounts.ListAll(ctx, userID)
+
+	if err != nil {
+		return []LinkedAccount{}, nil, err
+	}
+
+	existingConnections, err := tx.BankConnections.ListAllForUser(ctx, userID)
+
+	if err != nil {
+		return []LinkedAccount{}, nil, err
+	}
+
+	connectionsByID := map[int]models.BankConnection{}
+
+	for _, existingConnection := range existingConnections {
+		connectionsByID[existingConnection.ID] = existingConnection
+	}
+
+	accountsByPlaidID := map[string]models.BankAccount{}
+	remainingAccountsByConnection := map[int]int{}
+
+	for _, existingAccount := range existingAccounts {
+		if existingAccount.IsManual() {
+			continue
+		}
+
+		accountsByPlaidID[*existingAccount.PlaidAccountId] = existingAccount
+		remainingAccountsByConnection[*existingAccount.BankConnectionID]++
+	}
+
+	// Ids of existing accounts which were already matched to one of the provider's accounts
+	claimedAccountIDs := map[int]bool{}
+	linkedAccounts := make([]LinkedAccount, 0, len(accounts))
+	savedAccounts := make([]models.BankAccount, 0, len(accounts))
+
+	for _, account := range accounts {
+		writeModel := models.BankAccountWriteModel{
+			UserID:           userID,
+			PlaidAccountId:   account.ProviderAccountID,
+			BankConnectionID: connection.ID,
+			Name:             account.Name,
+			Mask:             account.Mask,
+			AccountType:      account.Type,
+			CurrentBalance:   account.CurrentBalance,
+			AvailableBalance: account.AvailableBalance,
+			Currency:         account.Currency,
+		}
+
+		existingAccount, known := accountsByPlaidID[account.ProviderAccountID]
+		relinked := false
+
+		if !known {
+			existingAccount, relinked = findRelinkedAccount(existingAccounts, claimedAccountIDs, connectionsByID, connection, account)
+		}
+
+		var savedAccount models.BankAccount
+
+		if relinked {
+			savedAccount, err = tx.BankAccounts.Update(ctx, existingAccount.ID, writeModel)
+		} else {
+			savedAccount, err = tx.BankAccounts.Save(ctx, writeModel)
+		}
+
+		if err != nil {
+			return []LinkedAccount{}, nil, fmt.Errorf("Failed to save Plaid Account - %+v. Error was: %w", writeModel, err)
+		}
+
+		if known || relinked {
+			claimedAccountIDs[existingAccount.ID] = true
+
+			if *existingAccount.BankConnectionID != connection.ID {
+				remainingAccountsByConnection[*existingAccount.BankConnectionID]--
+			}
+		}
+
+		if accountNumbers != nil {
+			numberWriteModels := accountNumberWriteModels(accountNumbers, account.ProviderAccountID)
+
+			if _, err := tx.BankAccountNumbers.ReplaceAllForBankAccount(ctx, savedAccount.ID, numberWriteModels); err != nil {
+				return []LinkedAccount{}, nil, fmt.Errorf("Failed to save numbers of bank account with id='%d': %w", savedAccount.ID, err)
+			}
+		}
+
+		linkedAccounts = append(linkedAccounts, LinkedAccount{Account: savedAccount, New: !known && !relinked})
+		savedAccounts = append(savedAccounts, savedAccount)
+	}
+
+	if err := tx.BalanceSnapshots.RecordAll(ctx, savedAccounts); err != nil {
+		return []LinkedAccount{}, nil, err
+	}
+
+	var replacedConnections []models.BankConnection
+
+	// The old connection is a duplicate of the new one now, keeping it would sync the same accounts twice
+	for connectionID, remainingAccounts := range remainingAccountsByConnection {
+		oldConnection, ok := connectionsByID[connectionID]
+
+		if !ok || connectionID == connection.ID || remainingAccounts > 0 || oldConnection.Disabled {
+			continue
+		}
+
+		if err := tx.BankConnections.Disable(ctx, connectionID); err != nil {
+			return []LinkedAccount{}, nil, fmt.Errorf("Failed to disable bank connection with id='%d' replaced by bank connection with id='%d': %w", connectionID, connection.ID, err)
+		}
+
+		replacedConnections = append(replacedConnections, oldConnection)
+	}
+
+	return linkedAccounts, replacedConnections, nil
+}
+
+// removeReplacedConnections removes the connections replaced by a new one at the provider, which would
+// otherwise keep billing for them and sending their webhooks. They are disabled already, so a failure
+// is only logged.
+func removeReplacedConnections(ctx context.Context, bankingProvider banking.BankingProvider, replacedConnections []models.BankConnection, log echo.Logger) {
+	for _, replacedConnection := range replacedConnections {
+		if err := bankingProvider.RemoveConnection(ctx, replacedConnection.AccessToken); err != nil {
+			log.Errorf("Failed to remove replaced bank connection with id='%d' at the banking provider: %v", replacedConnection.ID, err)
+			continue
+		}
+
+		log.Infof("Removed replaced bank connection with id='%d' at the banking provider", replacedConnection.ID)
+	}
+}
+
+// findRelinkedAccount looks for an account of another connection to the same institution with the same mask,
+// name and type. Connections linked before institutions were stored match any institution.
+func findRelinkedAccount(
+	existingAccounts []models.BankAccount,
+	claimedAccountIDs map[int]bool,
+	connectionsByID map[int]models.BankConnection,
+	connection models.BankConnection,
+	account banking.Account,
+) (models.BankAccount, bool) {
+	if account.Mask == nil {
+		return models.BankAccount{}, false
+	}
+
+	for _, existingAccount := range existingAccounts {
+		if existingAccount.IsManual() || claimedAccountIDs[existingAccount.ID] || *existingAccount.BankConnectionID == connection.ID {
+			continue
+		}
+
+		if existingAccount.Mask == nil || *existingAccount.Mask != *account.Mask {
+			continue
+		}
+
+		if existingAccount.Name != account.Name || string(existingAccount.AccountType) != account.Type {
+			continue
+		}
+
+		existingInstitutionID := connectionsByID[*existingAccount.BankConnectionID].InstitutionID
+
+		if existingInstitutionID != nil && (connection.InstitutionID == nil || *existingInstitutionID != *connection.InstitutionID) {
+			continue
+		}
+
+		return existingAccount, true
+	}
+
+	return models.BankAccount{}, false
+}
+
+func accountNumberWriteModels(accountNumbers []banking.AccountNumber, providerAccountID string) []models.BankAccountNumberWriteModel {
+	var writeModels []models.BankAccountNumberWriteModel
+
+	for _, number := range accountNumbers {
+		if number.ProviderAccountID != providerAccountID {
+			continue
+		}
+
+		writeModels = append(writeModels, models.BankAccountNumberWriteModel{
+			AccountNumberType: number.Type,
+			Account:           number.Account,
+			Routing:           number.Routing,
+			WireRouting:       number.WireRouting,
+			Institution:       number.Institution,
+			Branch:            number.Branch,
+			Bic:               number.Bic,
+			Iban:              number.Iban,
+			SortCode:          number.SortCode,
+		})
+	}
+
+	return writeModels
+}
@@ -0,0 +1,231 @@
+package accounts
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/banking/plaidfake"
+	"nerdmoney/pkg/common/database/databasetest"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/transactionsync"
+	"nerdmoney/pkg/unitofwork"
+
+	"github.com/labstack/echo/v4"
+	plaid "github.com/plaid/plaid-go/v21/plaid"
+)
+
+// linkBank does what POST /banks does once the user finished Plaid Link
+func linkBank(t *testing.T, plaidClient *banking.PlaidClient, unitOfWork unitofwork.UnitOfWork, userID int, publicToken string) (models.BankConnection, []LinkedAccount) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	itemAccessToken, err := plaidClient.GetAccessToken(ctx, publicToken)
+
+	if err != nil {
+		t.Fatalf("Failed to exchange public token: %v", err)
+	}
+
+	accountsResponse, err := plaidClient.Accounts(ctx, itemAccessToken.AccessToken)
+
+	if err != nil {
+		t.Fatalf("Failed to get accounts: %v", err)
+	}
+
+	accountNumbers, err := plaidClient.AccountNumbers(ctx, itemAccessToken.AccessToken)
+
+	if err != nil {
+		t.Fatalf("Failed to get account numbers: %v", err)
+	}
+
+	var connection models.BankConnection
+	var linkedAccounts []LinkedAccount
+	var replacedConnections []models.BankConnection
+
+	err = unitOfWork.WithTx(ctx, func(tx unitofwork.Repos) error {
+		connection, err = tx.BankConnections.Save(ctx, models.BankConnectionWriteModel{
+			UserID:        userID,
+			PlaidItemID:   itemAccessToken.ItemId,
+			InstitutionID: accountsResponse.Connection.InstitutionID,
+			AccessToken:   itemAccessToken.AccessToken,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		linkedAccounts, replacedConnections, err = saveLinkedAccounts(ctx, tx, userID, connection, accountsResponse.Accounts, accountNumbers)
+
+		return err
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to save linked accounts: %v", err)
+	}
+
+	removeReplacedConnections(ctx, plaidClient, replacedConnections, echo.New().Logger)
+
+	return connection, linkedAccounts
+}
+
+func TestLinkAndSyncWithPlaidFake(t *testing.T) {
+	pool := databasetest.Open(t)
+	unitOfWork := databasetest.NewUnitOfWork(t, pool)
+	user := databasetest.CreateUser(t, pool, "linking@example.com")
+	ctx := context.Background()
+
+	fake, server := plaidfake.Start(plaidfake.DefaultFixtures())
+	defer server.Close()
+
+	plaidClient, err := banking.NewPlaidClient(banking.PlaidClientConfig{ClientId: "test-client", Secret: "test-secret", Env: "sandbox", BaseUrl: server.URL})
+
+	if err != nil {
+		t.Fatalf("Failed to create PlaidClient: %v", err)
+	}
+
+	connection, linkedAccounts := linkBank(t, plaidClient, unitOfWork, user.ID, "public-fake-1")
+
+	if len(linkedAccounts) != 2 || !linkedAccounts[0].New || !linkedAccounts[1].New {
+		t.Fatalf("Expected 2 new accounts, got %+v", linkedAccounts)
+	}
+
+	checking := linkedAccounts[0].Account
+
+	numbers, err := unitOfWork.Repos().BankAccountNumbers.ListAllForBankAccount(ctx, user.ID, checking.ID)
+
+	if err != nil || len(numbers) != 1 {
+		t.Errorf("Expected the checking account's ACH numbers to be saved, got %+v, %v", numbers, err)
+	}
+
+	syncer := transactionsync.NewSyncer(plaidClient, unitOfWork, echo.New().Logger)
+
+	run, err := syncer.SyncConnection(ctx, connection)
+
+	if err != nil {
+		t.Fatalf("First sync failed: %v", err)
+	}
+
+	if run.AddedCount != 8 {
+		t.Errorf("Expected 8 added transactions, got %d", run.AddedCount)
+	}
+
+	checkingTransactions, err := unitOfWork.Repos().Transactions.ListAllForAccount(ctx, user.ID, checking.ID)
+
+	if err != nil || len(checkingTransactions) != 2 {
+		t.Fatalf("Expected 2 transactions in the checking account, got %d, %v", len(checkingTransactions), err)
+	}
+
+	// The next sync continues from the stored cursor
+	fake.AddTransactions("access-fake-1", plaidfake.NewTransaction("fake-tx-9", "fake-checking", "Groceries", 52.3, "USD", time.Now().AddDate(0, 0, -1)))
+	fake.RemoveTransactions("access-fake-1", "fake-tx-2")
+
+	connection, err = unitOfWork.Repos().BankConnections.FindByID(ctx, user.ID, connection.ID)
+
+	if err != nil {
+		t.Fatalf("Failed to reload the connection: %v", err)
+	}
+
+	run, err = syncer.SyncConnection(ctx, connection)
+
+	if err != nil {
+		t.Fatalf("Second sync failed: %v", err)
+	}
+
+	if run.AddedCount != 1 || run.RemovedCount != 1 {
+		t.Errorf("Expected 1 added and 1 removed transaction, got %+v", run)
+	}
+
+	checkingTransactions, err = unitOfWork.Repos().Transactions.ListAllForAccount(ctx, user.ID, checking.ID)
+
+	if err != nil || len(checkingTransactions) != 2 {
+		t.Fatalf("Expected 2 transactions in the checking account after the second sync, got %d, %v", len(checkingTransactions), err)
+	}
+
+	for _, transaction := range checkingTransactions {
+		if transaction.PlaidTransactionID == "fake-tx-2" {
+			t.Errorf("Expected the removed transaction to be deleted")
+		}
+	}
+
+	// Linking the same item again updates the accounts in place
+	_, relinkedAccounts := linkBank(t, plaidClient, unitOfWork, user.ID, "public-fake-1")
+
+	if len(relinkedAccounts) != 2 || relinkedAccounts[0].New || relinkedAccounts[0].Account.ID != checking.ID {
+		t.Errorf("Expected the accounts to be updated in place, got %+v", relinkedAccounts)
+	}
+
+	allAccounts, err := unitOfWork.Repos().BankAccounts.ListAll(ctx, user.ID)
+
+	if err != nil || len(allAccounts) != 2 {
+		t.Errorf("Expected 2 accounts after linking again, got %d, %v", len(allAccounts), err)
+	}
+
+	// Linking the bank as a new item brings new account and transaction ids for the same history
+	fake.AddItem(plaidfake.ItemFixture{
+		ItemID:        "fake-item-2",
+		AccessToken:   "access-fake-2",
+		PublicToken:   "public-fake-2",
+		InstitutionID: "ins_fake_1",
+		Accounts: []plaid.AccountBase{
+			plaidfake.NewAccount("fake-checking-2", "Fake Checking", "0000", plaid.ACCOUNTTYPE_DEPOSITORY, 1250.40, "USD"),
+			plaidfake.NewAccount("fake-credit-2", "Fake Credit Card", "3333", plaid.ACCOUNTTYPE_CREDIT, 410.12, "USD"),
+		},
+		Transactions: relinkedTransactions(checkingTransactions, "fake-checking-2"),
+	})
+
+	newConnection, relinkedAccounts := linkBank(t, plaidClient, unitOfWork, user.ID, "public-fake-2")
+
+	if len(relinkedAccounts) != 2 || relinkedAccounts[0].New || relinkedAccounts[0].Account.ID != checking.ID {
+		t.Fatalf("Expected the accounts to be moved to the new item, got %+v", relinkedAccounts)
+	}
+
+	if _, err := plaidClient.Accounts(ctx, "access-fake-1"); err == nil {
+		t.Errorf("Expected the replaced item to be removed at Plaid")
+	}
+
+	run, err = syncer.SyncConnection(ctx, newConnection)
+
+	if err != nil {
+		t.Fatalf("Sync of the new item failed: %v", err)
+	}
+
+	if run.AddedCount != 2 {
+		t.Errorf("Expected 2 added transactions, got %d", run.AddedCount)
+	}
+
+	relinkedTransactions, err := unitOfWork.Repos().Transactions.ListAllForAccount(ctx, user.ID, checking.ID)
+
+	if err != nil || len(relinkedTransactions) != 2 {
+		t.Fatalf("Expected the history to not be duplicated, got %d transactions, %v", len(relinkedTransactions), err)
+	}
+
+	for _, transaction := range relinkedTransactions {
+		if !strings.HasPrefix(transaction.PlaidTransactionID, "relinked-") {
+			t.Errorf("Expected the transaction to adopt the new id, got '%s'", transaction.PlaidTransactionID)
+		}
+	}
+}
+
+// relinkedTransactions returns the saved transactions the way the provider returns them for a new item
+func relinkedTransactions(savedTransactions []transactions.DbTransaction, accountID string) []plaid.Transaction {
+	var relinked []plaid.Transaction
+
+	for _, transaction := range savedTransactions {
+		amount, _ := transaction.Amount.Float64()
+
+		relinked = append(relinked, plaidfake.NewTransaction(
+			"relinked-"+transaction.PlaidTransactionID,
+			accountID,
+			transaction.Name,
+			amount,
+			transaction.Currency,
+			transaction.DatePosted,
+		))
+	}
+
+	return relinked
+}
@@ -2,23 +2,35 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 type BankAccount struct {
-	ID               int
-	PlaidAccountId   string
-	BankConnectionID int
+	ID int
+	// Nil for accounts linked before users were introduced
+	UserID *int
+	// Both nil for manual accounts, whose transactions are imported from files
+	PlaidAccountId   *string
+	BankConnectionID *int
 	Name             string
 	Mask             *string
 	AccountType      AccountType
 	CurrentBalance   decimal.NullDecimal
 	AvailableBalance decimal.NullDecimal
 	Currency         string
+	// When the balances were last fetched from the bank, nil if never
+	BalanceUpdatedAt *time.Time
+}
+
+// IsManual tells whether the account's transactions are imported from files rather than synced with Plaid
+func (a BankAccount) IsManual() bool {
+	return a.BankConnectionID == nil
 }
 
 type BankAccountWriteModel struct {
+	UserID           int
 	PlaidAccountId   string
 	BankConnectionID int
 	Name             string
@@ -29,6 +41,14 @@ type BankAccountWriteModel struct {
 	Currency         string
 }
 
+// ManualBankAccountWriteModel is an account of a bank Plaid does not support
+type ManualBankAccountWriteModel struct {
+	UserID      int
+	Name        string
+	AccountType AccountType
+	Currency    string
+}
+
 type AccountType string
 
 const (
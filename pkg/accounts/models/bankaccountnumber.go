@@ -1,8 +1,22 @@
 package models
 
+import "fmt"
+
 type BankAccountNumber struct {
 	ID                int
 	BankAccountId     int
+	AccountNumberType AccountNumberType
+	Account           *string
+	Routing           *string
+	WireRouting       *string
+	Institution       *string
+	Branch            *string
+	Bic               *string
+	Iban              *string
+	SortCode          *string
+}
+
+type BankAccountNumberWriteModel struct {
 	AccountNumberType string
 	Account           *string
 	Routing           *string
@@ -13,3 +27,40 @@ type BankAccountNumber struct {
 	Iban              *string
 	SortCode          *string
 }
+
+// String keeps the account numbers out of log output
+func (m BankAccountNumberWriteModel) String() string {
+	return fmt.Sprintf("{AccountNumberType:%s}", m.AccountNumberType)
+}
+
+func (m BankAccountNumberWriteModel) GoString() string {
+	return m.String()
+}
+
+type AccountNumberType string
+
+const (
+	// US account and routing numbers
+	ACH AccountNumberType = "ach"
+	// Canadian account, institution and branch numbers
+	EFT AccountNumberType = "eft"
+	// IBAN and BIC
+	International AccountNumberType = "international"
+	// UK account number and sort code
+	BACS AccountNumberType = "bacs"
+)
+
+func ParseAccountNumberType(source string) (AccountNumberType, error) {
+	switch source {
+	case string(ACH):
+		return ACH, nil
+	case string(EFT):
+		return EFT, nil
+	case string(International):
+		return International, nil
+	case string(BACS):
+		return BACS, nil
+	default:
+		return "", fmt.Errorf("Invalid AccountNumberType: '%s'", source)
+	}
+}
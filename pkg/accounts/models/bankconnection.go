@@ -1,18 +1,79 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"nerdmoney/pkg/common/secrets"
+	"time"
+)
 
 type BankConnection struct {
-	ID                         int
+	ID int
+	// Nil for connections linked before users were introduced
+	UserID                     *int
 	PlaidItemID                string
+	InstitutionID              *string
 	AccessToken                string
 	ConsentExpirationTimestamp *time.Time
 	LoginRequired              bool
+	TransactionsCursor         *string
+	Disabled                   bool
+}
+
+// String keeps the access token out of log output
+func (c BankConnection) String() string {
+	return fmt.Sprintf(
+		"{ID:%d PlaidItemID:%s AccessToken:%s ConsentExpirationTimestamp:%v LoginRequired:%t Disabled:%t}",
+		c.ID,
+		c.PlaidItemID,
+		secrets.Redacted,
+		c.ConsentExpirationTimestamp,
+		c.LoginRequired,
+		c.Disabled,
+	)
+}
+
+func (c BankConnection) GoString() string {
+	return c.String()
+}
+
+// How long before the consent expires users are asked to reconnect
+const ConsentExpiryWarning = 7 * 24 * time.Hour
+
+// NeedsReconnect reports whether the user has to go through the banking provider's update flow to keep
+// the connection working. Disabled connections can't be repaired, they have to be linked again.
+func (c BankConnection) NeedsReconnect(now time.Time) bool {
+	if c.Disabled {
+		return false
+	}
+
+	if c.LoginRequired {
+		return true
+	}
+
+	return c.ConsentExpirationTimestamp != nil && c.ConsentExpirationTimestamp.Sub(now) < ConsentExpiryWarning
 }
 
 type BankConnectionWriteModel struct {
+	UserID                     int
 	PlaidItemID                string
+	InstitutionID              *string
 	AccessToken                string
 	ConsentExpirationTimestamp *time.Time
 	LoginRequired              bool
 }
+
+// String keeps the access token out of log output
+func (m BankConnectionWriteModel) String() string {
+	return fmt.Sprintf(
+		"{UserID:%d PlaidItemID:%s AccessToken:%s ConsentExpirationTimestamp:%v LoginRequired:%t}",
+		m.UserID,
+		m.PlaidItemID,
+		secrets.Redacted,
+		m.ConsentExpirationTimestamp,
+		m.LoginRequired,
+	)
+}
+
+func (m BankConnectionWriteModel) GoString() string {
+	return m.String()
+}
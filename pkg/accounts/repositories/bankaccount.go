@@ -4,98 +4,135 @@ import (
 	"context"
 	"fmt"
 	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/common/database"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 	"github.com/shopspring/decimal"
 )
 
 type BankAccountRepository interface {
-	ListAll() ([]models.BankAccount, error)
-	Save(models.BankAccountWriteModel) (models.BankAccount, error)
-	DbPool() *pgxpool.Pool
+	ListAll(ctx context.Context, userID int) ([]models.BankAccount, error)
+	ListAllForConnection(ctx context.Context, userID int, bankConnectionID int) ([]models.BankAccount, error)
+	FindByID(ctx context.Context, userID int, id int) (models.BankAccount, error)
+	// Save inserts a new account or, if an account with the same plaid_account_id exists, updates it.
+	// Accounts owned by another user are never updated.
+	Save(ctx context.Context, writeModel models.BankAccountWriteModel) (models.BankAccount, error)
+	// CreateManual creates an account whose transactions are imported from files
+	CreateManual(ctx context.Context, writeModel models.ManualBankAccountWriteModel) (models.BankAccount, error)
+	// UpdateBalances stores freshly fetched balances of the account with the given plaid_account_id
+	UpdateBalances(ctx context.Context, plaidAccountID string, currentBalance, availableBalance decimal.NullDecimal) (models.BankAccount, error)
+	// UpdateManualBalance stores the balance of a manual account of the user as stated by a bank statement on asOf
+	UpdateManualBalance(ctx context.Context, userID int, id int, balance decimal.Decimal, asOf time.Time) (models.BankAccount, error)
+	// Update overwrites the account with the given id, including its plaid_account_id and bank_connection_id
+	Update(ctx context.Context, id int, writeModel models.BankAccountWriteModel) (models.BankAccount, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) BankAccountRepository
 }
 
 type bankAccountRepositoryImpl struct {
-	pool *pgxpool.Pool
-	log  echo.Logger
+	db  database.Querier
+	log echo.Logger
 }
 
 func NewBankAccountRepository(pool *pgxpool.Pool, log echo.Logger) BankAccountRepository {
 	return &bankAccountRepositoryImpl{pool, log}
 }
 
-func (r *bankAccountRepositoryImpl) DbPool() *pgxpool.Pool {
-	return r.pool
+func (r *bankAccountRepositoryImpl) WithTx(tx pgx.Tx) BankAccountRepository {
+	return &bankAccountRepositoryImpl{tx, r.log}
 }
 
-func (r *bankAccountRepositoryImpl) ListAll() ([]models.BankAccount, error) {
-	r.log.Debugf("Attempting to list all bank accounts")
+const bankAccountColumns = `id, user_id, plaid_account_id, bank_connection_id, name, mask, account_type, current_balance, available_balance, currency, balance_updated_at`
 
-	query := `SELECT * FROM bank_account`
+func (r *bankAccountRepositoryImpl) ListAll(ctx context.Context, userID int) ([]models.BankAccount, error) {
+	r.log.Debugf("Attempting to list all bank accounts of User with id='%d'", userID)
 
-	rows, err := r.pool.Query(context.Background(), query)
+	query := `SELECT ` + bankAccountColumns + ` FROM bank_account WHERE user_id = $1 ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+
+	if err != nil {
+		return []models.BankAccount{}, fmt.Errorf("Failed to list all bank accounts of User with id='%d': %w", userID, err)
+	}
+
+	return collectBankAccounts(rows)
+}
+
+func (r *bankAccountRepositoryImpl) ListAllForConnection(ctx context.Context, userID int, bankConnectionID int) ([]models.BankAccount, error) {
+	r.log.Debugf("Attempting to list all bank accounts of BankConnection with id='%d'", bankConnectionID)
+
+	query := `SELECT ` + bankAccountColumns + ` FROM bank_account WHERE bank_connection_id = $1 AND user_id = $2 ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, bankConnectionID, userID)
 
 	if err != nil {
-		return []models.BankAccount{}, fmt.Errorf("Failed to list all bank accounts: %w", err)
+		return []models.BankAccount{}, fmt.Errorf("Failed to list all bank accounts of BankConnection with id='%d': %w", bankConnectionID, err)
 	}
 
+	return collectBankAccounts(rows)
+}
+
+func collectBankAccounts(rows pgx.Rows) ([]models.BankAccount, error) {
+	defer rows.Close()
+
 	var allAccounts []models.BankAccount
 
 	for rows.Next() {
-		var bankAccount = models.BankAccount{}
-		var accountTypeStr string
-
-		rows.Scan(
-			&bankAccount.ID,
-			&bankAccount.PlaidAccountId,
-			&bankAccount.BankConnectionID,
-			&bankAccount.Name,
-			&bankAccount.Mask,
-			&accountTypeStr,
-			&bankAccount.CurrentBalance,
-			&bankAccount.AvailableBalance,
-			&bankAccount.Currency,
-		)
-
-		accountType, err := models.ParseAccountType(accountTypeStr)
+		bankAccount, err := scanBankAccount(rows)
 
 		if err != nil {
-			return []models.BankAccount{}, fmt.Errorf("Failed to parse bank account type for bank account with ID='%d': %w", bankAccount.ID, err)
+			return []models.BankAccount{}, fmt.Errorf("Failed to read bank account row: %w", err)
 		}
 
-		bankAccount.AccountType = accountType
 		allAccounts = append(allAccounts, bankAccount)
 	}
 
 	if err := rows.Err(); err != nil {
-		return []models.BankAccount{}, fmt.Errorf("Failed to read rows when trying to list all bank accounts: %w", err)
+		return []models.BankAccount{}, fmt.Errorf("Failed to read rows when trying to list bank accounts: %w", err)
 	}
 
 	return allAccounts, nil
 }
 
-func (r *bankAccountRepositoryImpl) Save(writeModel models.BankAccountWriteModel) (models.BankAccount, error) {
-	r.log.Debugf("Attempting to save a new BankAccount: %+v", writeModel)
+func (r *bankAccountRepositoryImpl) FindByID(ctx context.Context, userID int, id int) (models.BankAccount, error) {
+	r.log.Debugf("Attempting to find BankAccount with id='%d'", id)
+
+	query := `SELECT ` + bankAccountColumns + ` FROM bank_account WHERE id = $1 AND user_id = $2`
+
+	bankAccount, err := scanBankAccount(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return models.BankAccount{}, fmt.Errorf("Failed to find BankAccount with id='%d': %w", id, err)
+	}
+
+	return bankAccount, nil
+}
+
+func (r *bankAccountRepositoryImpl) Save(ctx context.Context, writeModel models.BankAccountWriteModel) (models.BankAccount, error) {
+	r.log.Debugf("Attempting to save a BankAccount: %+v", writeModel)
 
 	query := `
-	INSERT INTO bank_account (plaid_account_id, bank_connection_id, name, mask, account_type, current_balance, available_balance, currency) 
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
-	RETURNING id, plaid_account_id, bank_connection_id, name, mask, account_type, current_balance, available_balance, currency`
-
-	var id int
-	var plaidAccountID string
-	var bankConnectionID int
-	var name string
-	var mask *string
-	var accountTypeStr string
-	var currentBalance decimal.NullDecimal
-	var availableBalance decimal.NullDecimal
-	var currency string
+	INSERT INTO bank_account (user_id, plaid_account_id, bank_connection_id, name, mask, account_type, current_balance, available_balance, currency, balance_updated_at) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now()) 
+	ON CONFLICT (plaid_account_id) DO UPDATE SET
+		bank_connection_id = EXCLUDED.bank_connection_id,
+		name = EXCLUDED.name,
+		mask = EXCLUDED.mask,
+		account_type = EXCLUDED.account_type,
+		current_balance = EXCLUDED.current_balance,
+		available_balance = EXCLUDED.available_balance,
+		currency = EXCLUDED.currency,
+		balance_updated_at = EXCLUDED.balance_updated_at
+	WHERE bank_account.user_id = EXCLUDED.user_id
+	RETURNING ` + bankAccountColumns
 
-	err := r.pool.QueryRow(
-		context.Background(),
+	bankAccount, err := scanBankAccount(r.db.QueryRow(
+		ctx,
 		query,
+		writeModel.UserID,
 		writeModel.PlaidAccountId,
 		writeModel.BankConnectionID,
 		writeModel.Name,
@@ -104,36 +141,136 @@ func (r *bankAccountRepositoryImpl) Save(writeModel models.BankAccountWriteModel
 		writeModel.CurrentBalance,
 		writeModel.AvailableBalance,
 		writeModel.Currency,
-	).Scan(
-		&id,
-		&plaidAccountID,
-		&bankConnectionID,
-		&name,
-		&mask,
+	))
+
+	if err != nil {
+		return models.BankAccount{}, fmt.Errorf("Failed to save BankAccount with plaid_account_id='%s': %w", writeModel.PlaidAccountId, err)
+	}
+
+	r.log.Debugf("Saved BankAccount with id='%d'", bankAccount.ID)
+
+	return bankAccount, nil
+}
+
+func (r *bankAccountRepositoryImpl) CreateManual(ctx context.Context, writeModel models.ManualBankAccountWriteModel) (models.BankAccount, error) {
+	r.log.Debugf("Attempting to create a manual BankAccount: %+v", writeModel)
+
+	query := `
+	INSERT INTO bank_account (user_id, name, account_type, currency)
+	VALUES ($1, $2, $3, $4)
+	RETURNING ` + bankAccountColumns
+
+	bankAccount, err := scanBankAccount(r.db.QueryRow(ctx, query, writeModel.UserID, writeModel.Name, writeModel.AccountType, writeModel.Currency))
+
+	if err != nil {
+		return models.BankAccount{}, fmt.Errorf("Failed to create manual BankAccount: %w", err)
+	}
+
+	return bankAccount, nil
+}
+
+func (r *bankAccountRepositoryImpl) UpdateBalances(ctx context.Context, plaidAccountID string, currentBalance, availableBalance decimal.NullDecimal) (models.BankAccount, error) {
+	r.log.Debugf("Attempting to update balances of BankAccount with plaid_account_id='%s'", plaidAccountID)
+
+	query := `
+	UPDATE bank_account SET current_balance = $2, available_balance = $3, balance_updated_at = now()
+	WHERE plaid_account_id = $1
+	RETURNING ` + bankAccountColumns
+
+	bankAccount, err := scanBankAccount(r.db.QueryRow(ctx, query, plaidAccountID, currentBalance, availableBalance))
+
+	if err != nil {
+		return models.BankAccount{}, fmt.Errorf("Failed to update balances of BankAccount with plaid_account_id='%s': %w", plaidAccountID, err)
+	}
+
+	return bankAccount, nil
+}
+
+func (r *bankAccountRepositoryImpl) UpdateManualBalance(ctx context.Context, userID int, id int, balance decimal.Decimal, asOf time.Time) (models.BankAccount, error) {
+	r.log.Debugf("Attempting to update balance of manual BankAccount with id='%d'", id)
+
+	query := `
+	UPDATE bank_account SET current_balance = $3, available_balance = NULL, balance_updated_at = $4
+	WHERE id = $1 AND user_id = $2 AND bank_connection_id IS NULL
+	RETURNING ` + bankAccountColumns
+
+	bankAccount, err := scanBankAccount(r.db.QueryRow(ctx, query, id, userID, balance, asOf))
+
+	if err != nil {
+		return models.BankAccount{}, fmt.Errorf("Failed to update balance of manual BankAccount with id='%d': %w", id, err)
+	}
+
+	return bankAccount, nil
+}
+
+func (r *bankAccountRepositoryImpl) Update(ctx context.Context, id int, writeModel models.BankAccountWriteModel) (models.BankAccount, error) {
+	r.log.Debugf("Attempting to update BankAccount with id='%d': %+v", id, writeModel)
+
+	query := `
+	UPDATE bank_account SET
+		plaid_account_id = $3,
+		bank_connection_id = $4,
+		name = $5,
+		mask = $6,
+		account_type = $7,
+		current_balance = $8,
+		available_balance = $9,
+		currency = $10,
+		balance_updated_at = now()
+	WHERE id = $1 AND user_id = $2
+	RETURNING ` + bankAccountColumns
+
+	bankAccount, err := scanBankAccount(r.db.QueryRow(
+		ctx,
+		query,
+		id,
+		writeModel.UserID,
+		writeModel.PlaidAccountId,
+		writeModel.BankConnectionID,
+		writeModel.Name,
+		writeModel.Mask,
+		writeModel.AccountType,
+		writeModel.CurrentBalance,
+		writeModel.AvailableBalance,
+		writeModel.Currency,
+	))
+
+	if err != nil {
+		return models.BankAccount{}, fmt.Errorf("Failed to update BankAccount with id='%d': %w", id, err)
+	}
+
+	return bankAccount, nil
+}
+
+func scanBankAccount(row pgx.Row) (models.BankAccount, error) {
+	var bankAccount models.BankAccount
+	var accountTypeStr string
+
+	err := row.Scan(
+		&bankAccount.ID,
+		&bankAccount.UserID,
+		&bankAccount.PlaidAccountId,
+		&bankAccount.BankConnectionID,
+		&bankAccount.Name,
+		&bankAccount.Mask,
 		&accountTypeStr,
-		&currentBalance,
-		&availableBalance,
-		&currency,
+		&bankAccount.CurrentBalance,
+		&bankAccount.AvailableBalance,
+		&bankAccount.Currency,
+		&bankAccount.BalanceUpdatedAt,
 	)
 
 	if err != nil {
-		return models.BankAccount{}, fmt.Errorf("Failed to save new BankAccount: %w", err)
+		return models.BankAccount{}, err
 	}
 
 	accountType, err := models.ParseAccountType(accountTypeStr)
 
 	if err != nil {
-		return models.BankAccount{}, fmt.Errorf("Failed to save new BankAccount: %w", err)
+		return models.BankAccount{}, fmt.Errorf("Failed to parse bank account type for bank account with ID='%d': %w", bankAccount.ID, err)
 	}
 
-	r.log.Debugf("Saved new BankAccount with id='%d'", id)
+	bankAccount.AccountType = accountType
 
-	return models.BankAccount{
-		ID:               id,
-		PlaidAccountId:   plaidAccountID,
-		BankConnectionID: bankConnectionID,
-		Name:             name,
-		Mask:             mask,
-		AccountType:      accountType,
-	}, nil
+	return bankAccount, nil
 }
@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/common/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type BankAccountNumberRepository interface {
+	ListAllForBankAccount(ctx context.Context, userID int, bankAccountID int) ([]models.BankAccountNumber, error)
+	FindByID(ctx context.Context, userID int, id int) (models.BankAccountNumber, error)
+	// ReplaceAllForBankAccount deletes all numbers saved for the bank account and saves the given ones instead.
+	ReplaceAllForBankAccount(ctx context.Context, bankAccountID int, writeModels []models.BankAccountNumberWriteModel) ([]models.BankAccountNumber, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) BankAccountNumberRepository
+}
+
+type bankAccountNumberRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewBankAccountNumberRepository(pool *pgxpool.Pool, log echo.Logger) BankAccountNumberRepository {
+	return &bankAccountNumberRepositoryImpl{pool, log}
+}
+
+func (r *bankAccountNumberRepositoryImpl) WithTx(tx pgx.Tx) BankAccountNumberRepository {
+	return &bankAccountNumberRepositoryImpl{tx, r.log}
+}
+
+const bankAccountNumberColumns = `id, bank_account_id, account_number_type, account, routing, wire_routing, institution, branch, bic, iban, sort_code`
+
+func (r *bankAccountNumberRepositoryImpl) ListAllForBankAccount(ctx context.Context, userID int, bankAccountID int) ([]models.BankAccountNumber, error) {
+	r.log.Debugf("Attempting to list all numbers of bank account with id='%d'", bankAccountID)
+
+	query := `
+	SELECT ` + bankAccountNumberColumns + ` FROM bank_account_number
+	WHERE bank_account_id = $1 AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)
+	ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, bankAccountID, userID)
+
+	if err != nil {
+		return []models.BankAccountNumber{}, fmt.Errorf("Failed to list numbers of bank account with id='%d': %w", bankAccountID, err)
+	}
+
+	defer rows.Close()
+
+	var allNumbers []models.BankAccountNumber
+
+	for rows.Next() {
+		number, err := scanBankAccountNumber(rows)
+
+		if err != nil {
+			return []models.BankAccountNumber{}, fmt.Errorf("Failed to read numbers of bank account with id='%d': %w", bankAccountID, err)
+		}
+
+		allNumbers = append(allNumbers, number)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []models.BankAccountNumber{}, fmt.Errorf("Failed to read rows when trying to list numbers of bank account with id='%d': %w", bankAccountID, err)
+	}
+
+	return allNumbers, nil
+}
+
+func (r *bankAccountNumberRepositoryImpl) FindByID(ctx context.Context, userID int, id int) (models.BankAccountNumber, error) {
+	r.log.Debugf("Attempting to find BankAccountNumber with id='%d'", id)
+
+	query := `
+	SELECT ` + bankAccountNumberColumns + ` FROM bank_account_number
+	WHERE id = $1 AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)`
+
+	number, err := scanBankAccountNumber(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return models.BankAccountNumber{}, fmt.Errorf("Failed to find BankAccountNumber with id='%d': %w", id, err)
+	}
+
+	return number, nil
+}
+
+func (r *bankAccountNumberRepositoryImpl) ReplaceAllForBankAccount(ctx context.Context, bankAccountID int, writeModels []models.BankAccountNumberWriteModel) ([]models.BankAccountNumber, error) {
+	r.log.Debugf("Attempting to replace the numbers of bank account with id='%d' with %d new ones", bankAccountID, len(writeModels))
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return []models.BankAccountNumber{}, fmt.Errorf("Failed to start database transaction when saving bank account numbers: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM bank_account_number WHERE bank_account_id = $1`, bankAccountID); err != nil {
+		return []models.BankAccountNumber{}, fmt.Errorf("Failed to delete numbers of bank account with id='%d': %w", bankAccountID, err)
+	}
+
+	query := `
+	INSERT INTO bank_account_number (bank_account_id, account_number_type, account, routing, wire_routing, institution, branch, bic, iban, sort_code)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING ` + bankAccountNumberColumns
+
+	savedNumbers := make([]models.BankAccountNumber, 0, len(writeModels))
+
+	for _, writeModel := range writeModels {
+		number, err := scanBankAccountNumber(tx.QueryRow(
+			ctx,
+			query,
+			bankAccountID,
+			writeModel.AccountNumberType,
+			writeModel.Account,
+			writeModel.Routing,
+			writeModel.WireRouting,
+			writeModel.Institution,
+			writeModel.Branch,
+			writeModel.Bic,
+			writeModel.Iban,
+			writeModel.SortCode,
+		))
+
+		if err != nil {
+			return []models.BankAccountNumber{}, fmt.Errorf("Failed to save %s number of bank account with id='%d': %w", writeModel.AccountNumberType, bankAccountID, err)
+		}
+
+		savedNumbers = append(savedNumbers, number)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return []models.BankAccountNumber{}, fmt.Errorf("Failed to commit numbers of bank account with id='%d': %w", bankAccountID, err)
+	}
+
+	r.log.Debugf("Saved %d numbers for bank account with id='%d'", len(savedNumbers), bankAccountID)
+
+	return savedNumbers, nil
+}
+
+func scanBankAccountNumber(row pgx.Row) (models.BankAccountNumber, error) {
+	var number models.BankAccountNumber
+	var accountNumberTypeStr string
+
+	err := row.Scan(
+		&number.ID,
+		&number.BankAccountId,
+		&accountNumberTypeStr,
+		&number.Account,
+		&number.Routing,
+		&number.WireRouting,
+		&number.Institution,
+		&number.Branch,
+		&number.Bic,
+		&number.Iban,
+		&number.SortCode,
+	)
+
+	if err != nil {
+		return models.BankAccountNumber{}, err
+	}
+
+	accountNumberType, err := models.ParseAccountNumberType(accountNumberTypeStr)
+
+	if err != nil {
+		return models.BankAccountNumber{}, err
+	}
+
+	number.AccountNumberType = accountNumberType
+
+	return number, nil
+}
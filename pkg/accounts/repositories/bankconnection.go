@@ -4,57 +4,433 @@ import (
 	"context"
 	"fmt"
 	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/common/database"
+	"nerdmoney/pkg/common/secrets"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 )
 
 type BankConnectionRepository interface {
-	Save(writeModel models.BankConnectionWriteModel) (models.BankConnection, error)
-	DbPool() *pgxpool.Pool
+	// ListAll lists the connections of all users, it is meant for background jobs
+	ListAll(ctx context.Context) ([]models.BankConnection, error)
+	ListAllForUser(ctx context.Context, userID int) ([]models.BankConnection, error)
+	FindByID(ctx context.Context, userID int, id int) (models.BankConnection, error)
+	FindByPlaidItemID(ctx context.Context, plaidItemID string) (models.BankConnection, error)
+	// Save inserts a new connection or, if the item was linked before, updates the existing one.
+	// Connections owned by another user are never updated.
+	Save(ctx context.Context, writeModel models.BankConnectionWriteModel) (models.BankConnection, error)
+	UpdateTransactionsCursor(ctx context.Context, id int, cursor string) error
+	UpdateLoginRequired(ctx context.Context, id int, loginRequired bool) error
+	UpdateConsentExpiration(ctx context.Context, id int, consentExpiration *time.Time) error
+	Disable(ctx context.Context, id int) error
+	// ReencryptAccessTokens encrypts all access tokens which are not yet encrypted with the active key
+	// and returns the number of updated rows.
+	ReencryptAccessTokens(ctx context.Context) (int, error)
+	// DecryptAccessTokens stores all encrypted access tokens in plaintext again and returns the number of updated
+	// rows. It is only meant to be run before migrating the database below the token encryption.
+	DecryptAccessTokens(ctx context.Context) (int, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) BankConnectionRepository
 }
 
+// bankConnectionRepositoryImpl encrypts access tokens with the keyring before writing them
+// and decrypts them when reading, so callers only ever deal with plaintext tokens.
 type bankConnectionRepositoryImpl struct {
-	pool *pgxpool.Pool
-	log  echo.Logger
+	db      database.Querier
+	keyring *secrets.Keyring
+	log     echo.Logger
 }
 
-func NewBankConnectionRepository(pool *pgxpool.Pool, log echo.Logger) BankConnectionRepository {
-	return &bankConnectionRepositoryImpl{pool, log}
+func NewBankConnectionRepository(pool *pgxpool.Pool, keyring *secrets.Keyring, log echo.Logger) BankConnectionRepository {
+	return &bankConnectionRepositoryImpl{pool, keyring, log}
 }
 
-func (r *bankConnectionRepositoryImpl) DbPool() *pgxpool.Pool {
-	return r.pool
+func (r *bankConnectionRepositoryImpl) WithTx(tx pgx.Tx) BankConnectionRepository {
+	return &bankConnectionRepositoryImpl{tx, r.keyring, r.log}
 }
 
-func (r *bankConnectionRepositoryImpl) Save(writeModel models.BankConnectionWriteModel) (models.BankConnection, error) {
-	r.log.Debugf("Attempting to save a new BankConnection: %+v", writeModel)
+const bankConnectionColumns = `id, user_id, plaid_item_id, institution_id, access_token, access_token_data_key, access_token_key_id, consent_expiration_time, login_required, transactions_cursor, disabled`
 
-	query := `
-        INSERT INTO bank_connection (plaid_item_id, access_token, consent_expiration_time, login_required) 
-        VALUES ($1, $2, $3, $4) 
-        RETURNING id, plaid_item_id, access_token, consent_expiration_time, login_required`
+func (r *bankConnectionRepositoryImpl) ListAll(ctx context.Context) ([]models.BankConnection, error) {
+	r.log.Debugf("Attempting to list all bank connections")
+
+	query := `SELECT ` + bankConnectionColumns + ` FROM bank_connection ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query)
+
+	if err != nil {
+		return []models.BankConnection{}, fmt.Errorf("Failed to list all bank connections: %w", err)
+	}
+
+	return r.collect(rows)
+}
+
+func (r *bankConnectionRepositoryImpl) ListAllForUser(ctx context.Context, userID int) ([]models.BankConnection, error) {
+	r.log.Debugf("Attempting to list all bank connections of User with id='%d'", userID)
+
+	query := `SELECT ` + bankConnectionColumns + ` FROM bank_connection WHERE user_id = $1 ORDER BY id`
 
-	var savedConnection models.BankConnection
+	rows, err := r.db.Query(ctx, query, userID)
+
+	if err != nil {
+		return []models.BankConnection{}, fmt.Errorf("Failed to list all bank connections of User with id='%d': %w", userID, err)
+	}
+
+	return r.collect(rows)
+}
+
+func (r *bankConnectionRepositoryImpl) FindByID(ctx context.Context, userID int, id int) (models.BankConnection, error) {
+	r.log.Debugf("Attempting to find BankConnection with id='%d'", id)
+
+	query := `SELECT ` + bankConnectionColumns + ` FROM bank_connection WHERE id = $1 AND user_id = $2`
+
+	connection, err := r.scan(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return models.BankConnection{}, fmt.Errorf("Failed to find BankConnection with id='%d': %w", id, err)
+	}
+
+	return connection, nil
+}
+
+func (r *bankConnectionRepositoryImpl) collect(rows pgx.Rows) ([]models.BankConnection, error) {
+	defer rows.Close()
+
+	var allConnections []models.BankConnection
+
+	for rows.Next() {
+		connection, err := r.scan(rows)
+
+		if err != nil {
+			return []models.BankConnection{}, fmt.Errorf("Failed to read bank connection row: %w", err)
+		}
+
+		allConnections = append(allConnections, connection)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []models.BankConnection{}, fmt.Errorf("Failed to read rows when trying to list bank connections: %w", err)
+	}
+
+	return allConnections, nil
+}
+
+func (r *bankConnectionRepositoryImpl) FindByPlaidItemID(ctx context.Context, plaidItemID string) (models.BankConnection, error) {
+	r.log.Debugf("Attempting to find BankConnection with plaid_item_id='%s'", plaidItemID)
+
+	query := `SELECT ` + bankConnectionColumns + ` FROM bank_connection WHERE plaid_item_id = $1`
+
+	connection, err := r.scan(r.db.QueryRow(ctx, query, plaidItemID))
+
+	if err != nil {
+		return models.BankConnection{}, fmt.Errorf("Failed to find BankConnection with plaid_item_id='%s': %w", plaidItemID, err)
+	}
 
-	err := r.pool.QueryRow(
-		context.Background(),
+	return connection, nil
+}
+
+func (r *bankConnectionRepositoryImpl) Save(ctx context.Context, writeModel models.BankConnectionWriteModel) (models.BankConnection, error) {
+	r.log.Debugf("Attempting to save a BankConnection: %+v", writeModel)
+
+	encryptedAccessToken, err := r.keyring.Encrypt(writeModel.AccessToken)
+
+	if err != nil {
+		return models.BankConnection{}, fmt.Errorf("Failed to encrypt access token of a BankConnection: %w", err)
+	}
+
+	query := `
+        INSERT INTO bank_connection (user_id, plaid_item_id, institution_id, access_token, access_token_data_key, access_token_key_id, consent_expiration_time, login_required) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
+        ON CONFLICT (plaid_item_id) DO UPDATE SET
+                institution_id = EXCLUDED.institution_id,
+                access_token = EXCLUDED.access_token,
+                access_token_data_key = EXCLUDED.access_token_data_key,
+                access_token_key_id = EXCLUDED.access_token_key_id,
+                consent_expiration_time = EXCLUDED.consent_expiration_time,
+                login_required = EXCLUDED.login_required,
+                disabled = false
+        WHERE bank_connection.user_id = EXCLUDED.user_id
+        RETURNING ` + bankConnectionColumns
+
+	savedConnection, err := r.scan(r.db.QueryRow(
+		ctx,
 		query,
+		writeModel.UserID,
 		writeModel.PlaidItemID,
-		writeModel.AccessToken,
+		writeModel.InstitutionID,
+		encryptedAccessToken.Ciphertext,
+		encryptedAccessToken.EncryptedDataKey,
+		encryptedAccessToken.KeyID,
 		writeModel.ConsentExpirationTimestamp,
 		writeModel.LoginRequired,
-	).Scan(
-		&savedConnection.ID,
-		&savedConnection.PlaidItemID,
-		&savedConnection.AccessToken,
-		&savedConnection.ConsentExpirationTimestamp,
-		&savedConnection.LoginRequired,
-	)
+	))
 
 	if err != nil {
-		return models.BankConnection{}, fmt.Errorf("Failed to save a new BankConnection: %w", err)
+		return models.BankConnection{}, fmt.Errorf("Failed to save BankConnection with plaid_item_id='%s': %w", writeModel.PlaidItemID, err)
 	}
 
 	return savedConnection, nil
 }
+
+func (r *bankConnectionRepositoryImpl) scan(row pgx.Row) (models.BankConnection, error) {
+	var connection models.BankConnection
+	var accessToken string
+	var accessTokenDataKey *string
+	var accessTokenKeyID *string
+
+	err := row.Scan(
+		&connection.ID,
+		&connection.UserID,
+		&connection.PlaidItemID,
+		&connection.InstitutionID,
+		&accessToken,
+		&accessTokenDataKey,
+		&accessTokenKeyID,
+		&connection.ConsentExpirationTimestamp,
+		&connection.LoginRequired,
+		&connection.TransactionsCursor,
+		&connection.Disabled,
+	)
+
+	if err != nil {
+		return models.BankConnection{}, err
+	}
+
+	// Tokens saved before encryption was introduced stay in plaintext until they are re-encrypted
+	if accessTokenKeyID == nil || accessTokenDataKey == nil {
+		connection.AccessToken = accessToken
+		return connection, nil
+	}
+
+	connection.AccessToken, err = r.keyring.Decrypt(secrets.EncryptedValue{
+		Ciphertext:       accessToken,
+		EncryptedDataKey: *accessTokenDataKey,
+		KeyID:            *accessTokenKeyID,
+	})
+
+	if err != nil {
+		return models.BankConnection{}, fmt.Errorf("Failed to decrypt access token of BankConnection with id='%d': %w", connection.ID, err)
+	}
+
+	return connection, nil
+}
+
+func (r *bankConnectionRepositoryImpl) UpdateTransactionsCursor(ctx context.Context, id int, cursor string) error {
+	r.log.Debugf("Updating transactions cursor for BankConnection with id='%d'", id)
+
+	_, err := r.db.Exec(ctx, `UPDATE bank_connection SET transactions_cursor = $1 WHERE id = $2`, cursor, id)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update transactions cursor for BankConnection with id='%d': %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *bankConnectionRepositoryImpl) UpdateLoginRequired(ctx context.Context, id int, loginRequired bool) error {
+	r.log.Debugf("Setting login_required='%t' for BankConnection with id='%d'", loginRequired, id)
+
+	_, err := r.db.Exec(ctx, `UPDATE bank_connection SET login_required = $1 WHERE id = $2`, loginRequired, id)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update login_required for BankConnection with id='%d': %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *bankConnectionRepositoryImpl) UpdateConsentExpiration(ctx context.Context, id int, consentExpiration *time.Time) error {
+	r.log.Debugf("Setting consent expiration for BankConnection with id='%d'", id)
+
+	_, err := r.db.Exec(ctx, `UPDATE bank_connection SET consent_expiration_time = $1 WHERE id = $2`, consentExpiration, id)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update consent expiration for BankConnection with id='%d': %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *bankConnectionRepositoryImpl) Disable(ctx context.Context, id int) error {
+	r.log.Debugf("Disabling BankConnection with id='%d'", id)
+
+	_, err := r.db.Exec(ctx, `UPDATE bank_connection SET disabled = true WHERE id = $1`, id)
+
+	if err != nil {
+		return fmt.Errorf("Failed to disable BankConnection with id='%d': %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *bankConnectionRepositoryImpl) ReencryptAccessTokens(ctx context.Context) (int, error) {
+	r.log.Infof("Re-encrypting access tokens with key id='%s'", r.keyring.ActiveKeyID())
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to start database transaction when re-encrypting access tokens: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT id, access_token, access_token_data_key, access_token_key_id
+		FROM bank_connection
+		WHERE access_token_key_id IS NULL OR access_token_key_id <> $1
+		FOR UPDATE`,
+		r.keyring.ActiveKeyID(),
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to list access tokens to re-encrypt: %w", err)
+	}
+
+	type reencryptedToken struct {
+		id    int
+		value secrets.EncryptedValue
+	}
+
+	var reencrypted []reencryptedToken
+
+	for rows.Next() {
+		var id int
+		var accessToken string
+		var accessTokenDataKey *string
+		var accessTokenKeyID *string
+
+		if err := rows.Scan(&id, &accessToken, &accessTokenDataKey, &accessTokenKeyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("Failed to read access token row: %w", err)
+		}
+
+		var value secrets.EncryptedValue
+
+		if accessTokenKeyID == nil || accessTokenDataKey == nil {
+			value, err = r.keyring.Encrypt(accessToken)
+		} else {
+			value, err = r.keyring.Rewrap(secrets.EncryptedValue{
+				Ciphertext:       accessToken,
+				EncryptedDataKey: *accessTokenDataKey,
+				KeyID:            *accessTokenKeyID,
+			})
+		}
+
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("Failed to re-encrypt access token of BankConnection with id='%d': %w", id, err)
+		}
+
+		reencrypted = append(reencrypted, reencryptedToken{id, value})
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("Failed to read access token rows: %w", err)
+	}
+
+	for _, token := range reencrypted {
+		_, err := tx.Exec(
+			ctx,
+			`UPDATE bank_connection SET access_token = $1, access_token_data_key = $2, access_token_key_id = $3 WHERE id = $4`,
+			token.value.Ciphertext,
+			token.value.EncryptedDataKey,
+			token.value.KeyID,
+			token.id,
+		)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to save re-encrypted access token of BankConnection with id='%d': %w", token.id, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("Failed to commit re-encrypted access tokens: %w", err)
+	}
+
+	r.log.Infof("Re-encrypted %d access tokens", len(reencrypted))
+
+	return len(reencrypted), nil
+}
+
+func (r *bankConnectionRepositoryImpl) DecryptAccessTokens(ctx context.Context) (int, error) {
+	r.log.Infof("Decrypting access tokens")
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to start database transaction when decrypting access tokens: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT id, access_token, access_token_data_key, access_token_key_id
+		FROM bank_connection
+		WHERE access_token_key_id IS NOT NULL AND access_token_data_key IS NOT NULL
+		FOR UPDATE`,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to list access tokens to decrypt: %w", err)
+	}
+
+	type decryptedToken struct {
+		id    int
+		value string
+	}
+
+	var decrypted []decryptedToken
+
+	for rows.Next() {
+		var id int
+		var value secrets.EncryptedValue
+
+		if err := rows.Scan(&id, &value.Ciphertext, &value.EncryptedDataKey, &value.KeyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("Failed to read access token row: %w", err)
+		}
+
+		accessToken, err := r.keyring.Decrypt(value)
+
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("Failed to decrypt access token of BankConnection with id='%d': %w", id, err)
+		}
+
+		decrypted = append(decrypted, decryptedToken{id, accessToken})
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("Failed to read access token rows: %w", err)
+	}
+
+	for _, token := range decrypted {
+		_, err := tx.Exec(
+			ctx,
+			`UPDATE bank_connection SET access_token = $1, access_token_data_key = NULL, access_token_key_id = NULL WHERE id = $2`,
+			token.value,
+			token.id,
+		)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to save decrypted access token of BankConnection with id='%d': %w", token.id, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("Failed to commit decrypted access tokens: %w", err)
+	}
+
+	r.log.Infof("Decrypted %d access tokens", len(decrypted))
+
+	return len(decrypted), nil
+}
@@ -0,0 +1,159 @@
+package balancerefresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/common/ratelimit"
+	"nerdmoney/pkg/unitofwork"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// Plaid allows more, but balance requests are billed and shared with everything else we call
+	balanceRequestsPerMinute = 100
+	// Plaid rate limits balance requests per item too. Balances of a connection are not fetched again
+	// within this time, no matter if the scheduler or a user asks for them.
+	connectionCooldown = time.Minute
+)
+
+var ErrRefreshedRecently = errors.New("The balances of this bank connection were refreshed moments ago")
+
+// Refresher fetches real-time balances from the banking provider and stores them with the bank accounts.
+type Refresher struct {
+	bankingProvider banking.BankingProvider
+	unitOfWork      unitofwork.UnitOfWork
+	limiter         *ratelimit.Limiter
+	log             echo.Logger
+
+	mu sync.Mutex
+	// When balances were last requested, by bank connection id
+	lastRefreshed map[int]time.Time
+}
+
+func NewRefresher(
+	bankingProvider banking.BankingProvider,
+	unitOfWork unitofwork.UnitOfWork,
+	log echo.Logger,
+) *Refresher {
+	return &Refresher{
+		bankingProvider: bankingProvider,
+		unitOfWork:      unitOfWork,
+		limiter:         ratelimit.New(balanceRequestsPerMinute, time.Minute),
+		log:             log,
+		lastRefreshed:   map[int]time.Time{},
+	}
+}
+
+// RefreshAll refreshes the balances of every bank connection one after another.
+func (r *Refresher) RefreshAll(ctx context.Context) error {
+	connections, err := r.unitOfWork.Repos().BankConnections.ListAll(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to list bank connections to refresh balances of: %w", err)
+	}
+
+	return r.refreshAll(ctx, connections)
+}
+
+// RefreshAllForUser refreshes the balances of every bank connection of the user one after another.
+func (r *Refresher) RefreshAllForUser(ctx context.Context, userID int) error {
+	connections, err := r.unitOfWork.Repos().BankConnections.ListAllForUser(ctx, userID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to list bank connections to refresh balances of: %w", err)
+	}
+
+	return r.refreshAll(ctx, connections)
+}
+
+// A failure of one connection does not stop the others from being refreshed
+func (r *Refresher) refreshAll(ctx context.Context, connections []models.BankConnection) error {
+	var refreshErrors []error
+
+	for _, connection := range connections {
+		if ctx.Err() != nil {
+			refreshErrors = append(refreshErrors, ctx.Err())
+			break
+		}
+
+		if connection.Disabled || connection.LoginRequired {
+			r.log.Debugf("Skipping balance refresh for bank connection with id='%d' which needs user action", connection.ID)
+			continue
+		}
+
+		if _, err := r.RefreshConnection(ctx, connection); err != nil && !errors.Is(err, ErrRefreshedRecently) {
+			refreshErrors = append(refreshErrors, err)
+		}
+	}
+
+	return errors.Join(refreshErrors...)
+}
+
+// RefreshConnection fetches the balances of all accounts of the connection and returns the updated accounts.
+// It returns ErrRefreshedRecently instead of asking the provider again within a minute.
+func (r *Refresher) RefreshConnection(ctx context.Context, connection models.BankConnection) ([]models.BankAccount, error) {
+	if !r.claim(connection.ID) {
+		return []models.BankAccount{}, ErrRefreshedRecently
+	}
+
+	if err := r.limiter.Wait(ctx); err != nil {
+		return []models.BankAccount{}, err
+	}
+
+	r.log.Infof("Refreshing balances for bank connection with id='%d'", connection.ID)
+
+	// A rate limited connection is not retried before the cooldown is over, as it was claimed already
+	balancesResponse, err := r.bankingProvider.Balances(ctx, connection.AccessToken)
+
+	if err != nil {
+		return []models.BankAccount{}, fmt.Errorf("Failed to fetch balances for bank connection with id='%d': %w", connection.ID, err)
+	}
+
+	var updatedAccounts []models.BankAccount
+
+	err = r.unitOfWork.WithTx(ctx, func(tx unitofwork.Repos) error {
+		for _, account := range balancesResponse.Accounts {
+			updatedAccount, err := tx.BankAccounts.UpdateBalances(ctx, account.ProviderAccountID, account.CurrentBalance, account.AvailableBalance)
+
+			// The user did not share this account with us when linking the bank
+			if errors.Is(err, pgx.ErrNoRows) {
+				r.log.Debugf("Skipping balances of unknown account with plaid_account_id='%s'", account.ProviderAccountID)
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			updatedAccounts = append(updatedAccounts, updatedAccount)
+		}
+
+		return tx.BalanceSnapshots.RecordAll(ctx, updatedAccounts)
+	})
+
+	if err != nil {
+		return []models.BankAccount{}, fmt.Errorf("Failed to save balances for bank connection with id='%d': %w", connection.ID, err)
+	}
+
+	return updatedAccounts, nil
+}
+
+func (r *Refresher) claim(bankConnectionID int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastRefreshed[bankConnectionID]) < connectionCooldown {
+		return false
+	}
+
+	r.lastRefreshed[bankConnectionID] = time.Now()
+
+	return true
+}
@@ -0,0 +1,28 @@
+package balancerefresh
+
+import (
+	"context"
+	"time"
+)
+
+// RunScheduled refreshes the balances of all bank connections once every interval, until the context
+// is cancelled. Unlike the transaction sync it waits for the first interval, as balance requests are billed.
+func (r *Refresher) RunScheduled(ctx context.Context, interval time.Duration) {
+	r.log.Infof("Scheduling balance refresh every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.log.Infof("Stopping scheduled balance refresh")
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.RefreshAll(ctx); err != nil {
+			r.log.Errorf("Scheduled balance refresh failed: %v", err)
+		}
+	}
+}
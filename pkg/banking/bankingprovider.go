@@ -0,0 +1,188 @@
+package banking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/common/secrets"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BankingProvider is implemented by every service we can link bank accounts through.
+// Controllers and background workers only depend on this interface and the domain types
+// below, so that adding a provider does not require touching them.
+type BankingProvider interface {
+	// CreateLinkToken creates a token used by the provider's frontend widget to connect a bank.
+	// userID identifies the user in the provider's systems and has to stay the same for every call.
+	CreateLinkToken(ctx context.Context, userID string) (LinkTokenResponse, error)
+	// CreateUpdateLinkToken creates a token which opens the frontend widget in update mode, letting the user
+	// repair the connection behind the access token, e.g. after their bank credentials changed.
+	CreateUpdateLinkToken(ctx context.Context, userID string, accessToken string) (LinkTokenResponse, error)
+	// GetAccessToken exchanges the public token returned by the frontend widget for a long-lived access token.
+	GetAccessToken(ctx context.Context, publicToken string) (ItemAccessToken, error)
+	// Accounts returns the connection details and all accounts accessible with the access token.
+	Accounts(ctx context.Context, accessToken string) (AccountsResponse, error)
+	// Balances is like Accounts, but forces the provider to fetch real-time balances from the bank.
+	// It returns ErrRateLimited when balances were requested too often.
+	Balances(ctx context.Context, accessToken string) (AccountsResponse, error)
+	// Transactions returns all transaction changes since the given cursor.
+	Transactions(ctx context.Context, request GetTransactionsRequest, accessToken string) (LatestTransactionsResponse, error)
+	// AccountNumbers returns the account and routing numbers of all accounts accessible with the access token.
+	// It returns ErrAccountNumbersUnavailable when the bank does not share them.
+	AccountNumbers(ctx context.Context, accessToken string) ([]AccountNumber, error)
+	// RemoveConnection revokes the access token and removes the connection at the provider, which stops its
+	// billing and webhooks. The access token cannot be used afterwards.
+	RemoveConnection(ctx context.Context, accessToken string) error
+	// RecurringTransactions returns the series of recurring outgoing transactions the provider found in the
+	// history of the given accounts.
+	RecurringTransactions(ctx context.Context, accessToken string, providerAccountIDs []string) ([]RecurringStream, error)
+}
+
+// ErrAccountNumbersUnavailable is returned by AccountNumbers for connections without access to account numbers,
+// e.g. when the bank does not support it or the user did not consent to sharing them.
+var ErrAccountNumbersUnavailable = errors.New("Account numbers are not available for this connection")
+
+// ErrRateLimited is returned when the provider rejected a call because of too many requests
+var ErrRateLimited = errors.New("Too many requests to the banking provider")
+
+type ItemAccessToken struct {
+	AccessToken string
+	ItemId      string
+}
+
+// String keeps the access token out of log output
+func (t ItemAccessToken) String() string {
+	return fmt.Sprintf("{AccessToken:%s ItemId:%s}", secrets.Redacted, t.ItemId)
+}
+
+func (t ItemAccessToken) GoString() string {
+	return t.String()
+}
+
+type LinkTokenResponse struct {
+	LinkToken string
+}
+
+type Connection struct {
+	ItemID                string
+	InstitutionID         *string
+	ConsentExpirationTime *time.Time
+}
+
+type Account struct {
+	ProviderAccountID string
+	Name              string
+	Mask              *string
+	// One of investment, credit, depository, loan, brokerage or other
+	Type             string
+	CurrentBalance   decimal.NullDecimal
+	AvailableBalance decimal.NullDecimal
+	Currency         string
+}
+
+type AccountsResponse struct {
+	Connection Connection
+	Accounts   []Account
+}
+
+// AccountNumber holds the numbers needed to send money to an account. Which fields are set depends on Type:
+// ach has Account, Routing and WireRouting, eft has Account, Institution and Branch,
+// international has Iban and Bic and bacs has Account and SortCode.
+type AccountNumber struct {
+	ProviderAccountID string
+	// One of ach, eft, international or bacs
+	Type        string
+	Account     *string
+	Routing     *string
+	WireRouting *string
+	Institution *string
+	Branch      *string
+	Bic         *string
+	Iban        *string
+	SortCode    *string
+}
+
+type Transaction struct {
+	ProviderTransactionID string
+	ProviderAccountID     string
+	// Positive amounts are money leaving the account
+	Amount             decimal.Decimal
+	Currency           string
+	Date               time.Time
+	AuthorizedDate     *time.Time
+	Datetime           *time.Time
+	AuthorizedDatetime *time.Time
+	// The description of the transaction as it appears on the statement
+	Name         string
+	MerchantName *string
+	// The provider's category, e.g. "FOOD_AND_DRINK", and its subcategory, e.g. "FOOD_AND_DRINK_COFFEE"
+	Category         *string
+	CategoryDetailed *string
+	// Pending transactions are replaced by a posted transaction with PendingTransactionID set to their id
+	Pending              bool
+	PendingTransactionID *string
+	// How the transaction was made: "online", "in store" or "other"
+	PaymentChannel   string
+	MerchantEntityID *string
+	LogoURL          *string
+	Website          *string
+	Location         *TransactionLocation
+	Counterparties   []Counterparty
+	// The transaction exactly as the provider sent it, as JSON
+	Raw []byte
+}
+
+// TransactionLocation is where a transaction was made. Providers only know some of it for most transactions.
+type TransactionLocation struct {
+	Address     *string
+	City        *string
+	Region      *string
+	PostalCode  *string
+	Country     *string
+	Lat         *float64
+	Lon         *float64
+	StoreNumber *string
+}
+
+// Counterparty is a merchant, financial institution or other party involved in a transaction
+type Counterparty struct {
+	Name string
+	// e.g. "merchant", "financial_institution", "payment_app", "marketplace", "payment_terminal"
+	Type            string
+	EntityID        *string
+	Website         *string
+	LogoURL         *string
+	ConfidenceLevel *string
+}
+
+type GetTransactionsRequest struct {
+	Cursor *string
+}
+
+type LatestTransactionsResponse struct {
+	Added      []Transaction
+	Modified   []Transaction
+	Removed    []string
+	NextCursor string
+}
+
+// RecurringStream is a series of recurring transactions detected by the provider
+type RecurringStream struct {
+	ProviderStreamID  string
+	ProviderAccountID string
+	Description       string
+	MerchantName      *string
+	// One of WEEKLY, BIWEEKLY, SEMI_MONTHLY, MONTHLY, ANNUALLY or UNKNOWN
+	Frequency string
+	// Positive amounts are money leaving the account
+	AverageAmount decimal.Decimal
+	LastAmount    decimal.Decimal
+	Currency      string
+	FirstDate     time.Time
+	LastDate      time.Time
+	// Whether the provider expects the series to continue
+	Active                 bool
+	ProviderTransactionIDs []string
+}
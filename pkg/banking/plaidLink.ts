@@ -1,35 +1,62 @@
 import { getServerProps } from "../common/utils/utils";
 
-const props = getServerProps("plaidToken", window.Zod.object({ token: window.Zod.string() }));
-
-const PLAID_LINK_BUTTON_ID = "plaidLinkButton"
-
-const handler = window.Plaid.create({
-  token: props.token,
-  onSuccess: (publicToken, meta) => {
-    console.log("success");
-    console.log({ publicToken, meta });
-    window.htmx.ajax("POST", "http://localhost:42069/banks", {
-      values: {
-        publicToken
-      },
-      source: `#${PLAID_LINK_BUTTON_ID}`,
-      swap: "none"
-    });
-  },
-  onExit: (error, metadata) => {
-    console.log("exit");
-    console.log({ error, metadata });
-  },
-  onEvent: (eventName, metadata) => {
-    console.log("event");
-    console.log({ eventName, metadata });
-  }
+const propsSchema = window.Zod.object({
+  token: window.Zod.string(),
+  successUrl: window.Zod.string()
 });
 
-document.addEventListener("DOMContentLoaded", () => {
-  const plaidLinkButton = document.getElementById(PLAID_LINK_BUTTON_ID);
-  console.log("added on click listener to plaid button");
+// Every button with a data-plaid-link attribute opens Plaid Link. The attribute holds the id of the
+// JSON script with the link token and the URL the public token is posted to once the user is done.
+// Buttons with data-plaid-link-open="true" open Link as soon as they are set up.
+const initPlaidLinkButton = (button: HTMLElement) => {
+  const propsId = button.dataset.plaidLink;
+
+  // The script is included once per button, so make sure each button is only set up once
+  if (!propsId || button.dataset.plaidLinkReady) {
+    return;
+  }
+
+  button.dataset.plaidLinkReady = "true";
+
+  const props = getServerProps(propsId, propsSchema);
+
+  const handler = window.Plaid.create({
+    token: props.token,
+    onSuccess: (publicToken, meta) => {
+      console.log("success");
+      console.log({ publicToken, meta });
+      window.htmx.ajax("POST", props.successUrl, {
+        values: {
+          publicToken
+        },
+        source: button,
+        swap: "none"
+      });
+    },
+    onExit: (error, metadata) => {
+      console.log("exit");
+      console.log({ error, metadata });
+    },
+    onEvent: (eventName, metadata) => {
+      console.log("event");
+      console.log({ eventName, metadata });
+    }
+  });
+
+  button.addEventListener("click", () => { handler.open(); })
+
+  // Buttons rendered in response to the user asking for Link open it right away
+  if (button.dataset.plaidLinkOpen === "true") {
+    handler.open();
+  }
+}
+
+const initPlaidLinkButtons = () => {
+  document.querySelectorAll<HTMLElement>("[data-plaid-link]").forEach(initPlaidLinkButton);
+}
 
-  plaidLinkButton?.addEventListener("click", () => { handler.open(); })
-})
+if (document.readyState === "loading") {
+  document.addEventListener("DOMContentLoaded", initPlaidLinkButtons);
+} else {
+  initPlaidLinkButtons();
+}
@@ -16,6 +16,7 @@ var environments = map[string]plaid.Environment{
 	"development": plaid.Development,
 }
 
+// PlaidClient is the Plaid implementation of BankingProvider
 type PlaidClient struct {
 	client *plaid.APIClient
 	config PlaidClientConfig
@@ -28,8 +29,15 @@ type PlaidClientConfig struct {
 	Products     string
 	CountryCodes string
 	RedirectUri  string
+	WebhookUrl   string
+	// BaseUrl overrides the Plaid API URL picked by Env, e.g. to use the fake from the plaidfake package
+	BaseUrl string
+	// RequestTimeout bounds every single call to the Plaid API. Defaults to 30 seconds.
+	RequestTimeout time.Duration
 }
 
+var _ BankingProvider = (*PlaidClient)(nil)
+
 func NewPlaidClient(config PlaidClientConfig) (*PlaidClient, error) {
 	Env, isOk := environments[config.Env]
 
@@ -46,6 +54,10 @@ func NewPlaidClient(config PlaidClientConfig) (*PlaidClient, error) {
 		config.CountryCodes = "US"
 	}
 
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = 30 * time.Second
+	}
+
 	if config.ClientId == "" {
 		return nil, fmt.Errorf("PLAID_CLIENT_ID is not set. Make sure to fill out the .env file")
 	}
@@ -60,6 +72,10 @@ func NewPlaidClient(config PlaidClientConfig) (*PlaidClient, error) {
 	configuration.AddDefaultHeader("PLAID-SECRET", config.Secret)
 	configuration.UseEnvironment(Env)
 
+	if config.BaseUrl != "" {
+		configuration.UseEnvironment(plaid.Environment(config.BaseUrl))
+	}
+
 	plaidClient := PlaidClient{
 		client: plaid.NewAPIClient(configuration),
 		config: config,
@@ -76,13 +92,9 @@ var paymentID string
 var authorizationID string
 var accountID string
 
-type ItemAccessToken struct {
-	AccessToken string
-	ItemId      string
-}
-
-func (pc *PlaidClient) GetAccessToken(publicToken string) (ItemAccessToken, error) {
-	ctx := context.Background()
+func (pc *PlaidClient) GetAccessToken(ctx context.Context, publicToken string) (ItemAccessToken, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
 
 	// exchange the public_token for an access_token
 	exchangePublicTokenResp, _, err := pc.client.PlaidApi.ItemPublicTokenExchange(ctx).ItemPublicTokenExchangeRequest(
@@ -103,37 +115,73 @@ func (pc *PlaidClient) GetAccessToken(publicToken string) (ItemAccessToken, erro
 }
 
 // https://plaid.com/docs/api/products/auth/#authget
-func (pc *PlaidClient) AuthGet(accessToken string) (plaid.AuthGetResponse, error) {
-	ctx := context.Background()
+func (pc *PlaidClient) AccountNumbers(ctx context.Context, accessToken string) ([]AccountNumber, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
 
 	authGetResp, _, err := pc.client.PlaidApi.AuthGet(ctx).AuthGetRequest(
 		*plaid.NewAuthGetRequest(accessToken),
 	).Execute()
 
-	return authGetResp, err
+	if err != nil {
+		if plaidErr, convErr := plaid.ToPlaidError(err); convErr == nil && authUnavailableErrorCodes[plaidErr.ErrorCode] {
+			return []AccountNumber{}, fmt.Errorf("%w: %s", ErrAccountNumbersUnavailable, plaidErr.ErrorCode)
+		}
+
+		return []AccountNumber{}, err
+	}
 
+	return fromPlaidNumbers(authGetResp.GetNumbers()), nil
+}
+
+// Error codes returned by /auth/get for items which will never have account numbers
+var authUnavailableErrorCodes = map[string]bool{
+	"PRODUCTS_NOT_SUPPORTED":      true,
+	"NO_AUTH_ACCOUNTS":            true,
+	"INVALID_PRODUCT":             true,
+	"ADDITIONAL_CONSENT_REQUIRED": true,
 }
 
 // https://plaid.com/docs/api/accounts/#accountsget
-func (pc *PlaidClient) Accounts(accessToken string) (plaid.AccountsGetResponse, error) {
-	ctx := context.Background()
+func (pc *PlaidClient) Accounts(ctx context.Context, accessToken string) (AccountsResponse, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
 
 	accountsGetResp, _, err := pc.client.PlaidApi.AccountsGet(ctx).AccountsGetRequest(
 		*plaid.NewAccountsGetRequest(accessToken),
 	).Execute()
 
-	return accountsGetResp, err
+	if err != nil {
+		return AccountsResponse{}, err
+	}
+
+	return AccountsResponse{
+		Connection: fromPlaidItem(accountsGetResp.GetItem()),
+		Accounts:   fromPlaidAccounts(accountsGetResp.GetAccounts()),
+	}, nil
 }
 
 // https://plaid.com/docs/api/products/balance/#accountsbalanceget
-func (pc *PlaidClient) Balances(accessToken string) (plaid.AccountsGetResponse, error) {
-	ctx := context.Background()
+func (pc *PlaidClient) Balances(ctx context.Context, accessToken string) (AccountsResponse, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
 
 	balancesGetResp, _, err := pc.client.PlaidApi.AccountsBalanceGet(ctx).AccountsBalanceGetRequest(
 		*plaid.NewAccountsBalanceGetRequest(accessToken),
 	).Execute()
 
-	return balancesGetResp, err
+	if err != nil {
+		if plaidErr, convErr := plaid.ToPlaidError(err); convErr == nil && plaidErr.ErrorType == plaid.PLAIDERRORTYPE_RATE_LIMIT_EXCEEDED {
+			return AccountsResponse{}, fmt.Errorf("%w: %s", ErrRateLimited, plaidErr.ErrorCode)
+		}
+
+		return AccountsResponse{}, err
+	}
+
+	return AccountsResponse{
+		Connection: fromPlaidItem(balancesGetResp.GetItem()),
+		Accounts:   fromPlaidAccounts(balancesGetResp.GetAccounts()),
+	}, nil
 }
 
 type GetItemResponse struct {
@@ -143,8 +191,9 @@ type GetItemResponse struct {
 
 // https://plaid.com/docs/api/items/#itemget
 // https://plaid.com/docs/api/institutions/#institutionsget_by_id
-func (pc *PlaidClient) Item(accessToken string) (GetItemResponse, error) {
-	ctx := context.Background()
+func (pc *PlaidClient) Item(ctx context.Context, accessToken string) (GetItemResponse, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
 
 	itemGetResp, _, err := pc.client.PlaidApi.ItemGet(ctx).ItemGetRequest(
 		*plaid.NewItemGetRequest(accessToken),
@@ -171,34 +220,25 @@ func (pc *PlaidClient) Item(accessToken string) (GetItemResponse, error) {
 	}, nil
 }
 
-type GetTransactionsRequest struct {
-	Cursor *string
-}
-
-type LatestTransactionsResponse struct {
-	Added    []plaid.Transaction
-	Modified []plaid.Transaction
-	Removed  []plaid.RemovedTransaction
-}
-
 // https://plaid.com/docs/api/products/transactions/#transactionssync
-func (pc *PlaidClient) Transactions(request GetTransactionsRequest, accessToken string) (LatestTransactionsResponse, error) {
-	ctx := context.Background()
-
+func (pc *PlaidClient) Transactions(ctx context.Context, request GetTransactionsRequest, accessToken string) (LatestTransactionsResponse, error) {
 	// New transaction updates since "cursor"
 	var added []plaid.Transaction
 	var modified []plaid.Transaction
 	var removed []plaid.RemovedTransaction // Removed transaction ids
+	cursor := request.Cursor
 	hasMore := true
 	// Iterate through each page of new transaction updates for item
 	for hasMore {
-		request := plaid.NewTransactionsSyncRequest(accessToken)
-		if request.Cursor != nil {
-			request.SetCursor(*request.Cursor)
+		syncRequest := plaid.NewTransactionsSyncRequest(accessToken)
+		if cursor != nil {
+			syncRequest.SetCursor(*cursor)
 		}
+		pageCtx, cancel := pc.withDeadline(ctx)
 		resp, _, err := pc.client.PlaidApi.TransactionsSync(
-			ctx,
-		).TransactionsSyncRequest(*request).Execute()
+			pageCtx,
+		).TransactionsSyncRequest(*syncRequest).Execute()
+		cancel()
 		if err != nil {
 			return LatestTransactionsResponse{}, err
 		}
@@ -206,17 +246,14 @@ func (pc *PlaidClient) Transactions(request GetTransactionsRequest, accessToken
 		// Update cursor to the next cursor
 		nextCursor := resp.GetNextCursor()
 
-		// If no transactions are available yet, wait and poll the endpoint.
-		// Normally, we would listen for a webhook, but the Quickstart doesn't
-		// support webhooks. For a webhook example, see
-		// https://github.com/plaid/tutorial-resources or
-		// https://github.com/plaid/pattern
-
+		// No transactions are available yet for a freshly linked item.
+		// Plaid sends a SYNC_UPDATES_AVAILABLE webhook once they are ready.
 		if nextCursor == "" {
-			time.Sleep(2 * time.Second)
-			continue
+			break
 		}
 
+		cursor = &nextCursor
+
 		// Add this page of results
 		added = append(added, resp.GetAdded()...)
 		modified = append(modified, resp.GetModified()...)
@@ -228,15 +265,68 @@ func (pc *PlaidClient) Transactions(request GetTransactionsRequest, accessToken
 		return added[i].GetDate() < added[j].GetDate()
 	})
 
-	return LatestTransactionsResponse{
-		Added:    added,
-		Modified: modified,
-		Removed:  removed,
-	}, nil
+	addedTransactions, err := fromPlaidTransactions(added)
+
+	if err != nil {
+		return LatestTransactionsResponse{}, err
+	}
+
+	modifiedTransactions, err := fromPlaidTransactions(modified)
+
+	if err != nil {
+		return LatestTransactionsResponse{}, err
+	}
+
+	removedTransactionIDs := make([]string, 0, len(removed))
+
+	for _, removedTransaction := range removed {
+		removedTransactionIDs = append(removedTransactionIDs, removedTransaction.GetTransactionId())
+	}
+
+	response := LatestTransactionsResponse{
+		Added:    addedTransactions,
+		Modified: modifiedTransactions,
+		Removed:  removedTransactionIDs,
+	}
+
+	if cursor != nil {
+		response.NextCursor = *cursor
+	}
+
+	return response, nil
+}
+
+// https://plaid.com/docs/api/products/transactions/#transactionsrecurringget
+func (pc *PlaidClient) RecurringTransactions(ctx context.Context, accessToken string, providerAccountIDs []string) ([]RecurringStream, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
+
+	recurringGetResp, _, err := pc.client.PlaidApi.TransactionsRecurringGet(ctx).TransactionsRecurringGetRequest(
+		*plaid.NewTransactionsRecurringGetRequest(accessToken, providerAccountIDs),
+	).Execute()
+
+	if err != nil {
+		return []RecurringStream{}, err
+	}
+
+	return fromPlaidStreams(recurringGetResp.GetOutflowStreams())
+}
+
+// https://plaid.com/docs/api/items/#itemremove
+func (pc *PlaidClient) RemoveConnection(ctx context.Context, accessToken string) error {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
+
+	_, _, err := pc.client.PlaidApi.ItemRemove(ctx).ItemRemoveRequest(
+		*plaid.NewItemRemoveRequest(accessToken),
+	).Execute()
+
+	return err
 }
 
-func (pc *PlaidClient) CreatePublicToken(accessToken string) (plaid.ItemPublicTokenCreateResponse, error) {
-	ctx := context.Background()
+func (pc *PlaidClient) CreatePublicToken(ctx context.Context, accessToken string) (plaid.ItemPublicTokenCreateResponse, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
 
 	// Create a one-time use public_token for the Item.
 	// This public_token can be used to initialize Link in update mode for a user
@@ -247,18 +337,34 @@ func (pc *PlaidClient) CreatePublicToken(accessToken string) (plaid.ItemPublicTo
 	return publicTokenCreateResp, err
 }
 
-type LinkTokenResponse struct {
-	LinkToken string
+func (pc *PlaidClient) CreateLinkToken(ctx context.Context, userID string) (LinkTokenResponse, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
+
+	linkToken, err := pc.linkTokenCreate(ctx, userID, "")
+	if err != nil {
+		return LinkTokenResponse{}, err
+	}
+	return LinkTokenResponse{LinkToken: linkToken}, nil
 }
 
-func (pc *PlaidClient) CreateLinkToken() (LinkTokenResponse, error) {
-	linkToken, err := pc.linkTokenCreate()
+// https://plaid.com/docs/link/update-mode/
+func (pc *PlaidClient) CreateUpdateLinkToken(ctx context.Context, userID string, accessToken string) (LinkTokenResponse, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
+
+	linkToken, err := pc.linkTokenCreate(ctx, userID, accessToken)
 	if err != nil {
 		return LinkTokenResponse{}, err
 	}
 	return LinkTokenResponse{LinkToken: linkToken}, nil
 }
 
+// withDeadline bounds a single call to the Plaid API, so that a hung request can't pin the caller forever
+func (pc *PlaidClient) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, pc.config.RequestTimeout)
+}
+
 func convertCountryCodes(countryCodeStrs []string) []plaid.CountryCode {
 	countryCodes := []plaid.CountryCode{}
 
@@ -288,19 +394,17 @@ func containsProduct(products []plaid.Products, product plaid.Products) bool {
 	return false
 }
 
-// linkTokenCreate creates a link token using the specified parameters
-func (pc *PlaidClient) linkTokenCreate() (string, error) {
-	ctx := context.Background()
-
+// linkTokenCreate creates a link token using the specified parameters.
+// Passing an access token creates a token for update mode instead of linking a new item.
+func (pc *PlaidClient) linkTokenCreate(ctx context.Context, userID string, accessToken string) (string, error) {
 	// Institutions from all listed countries will be shown.
 	countryCodes := convertCountryCodes(strings.Split(pc.config.CountryCodes, ","))
 	redirectURI := pc.config.RedirectUri
 
-	// This should correspond to a unique id for the current user.
-	// Typically, this will be a user ID number from your application.
-	// Personally identifiable information, such as an email address or phone number, should not be used here.
+	// Plaid requires a stable id for the current user which must not be personally identifiable,
+	// such as an email address or phone number.
 	user := plaid.LinkTokenCreateRequestUser{
-		ClientUserId: time.Now().String(),
+		ClientUserId: userID,
 	}
 
 	request := plaid.NewLinkTokenCreateRequest(
@@ -311,9 +415,15 @@ func (pc *PlaidClient) linkTokenCreate() (string, error) {
 	)
 
 	products := convertProducts(strings.Split(pc.config.Products, ","))
-	request.SetProducts(products)
 
-	if containsProduct(products, plaid.PRODUCTS_STATEMENTS) {
+	// Update mode rejects requests with products, the item keeps the ones it was linked with
+	if accessToken != "" {
+		request.SetAccessToken(accessToken)
+	} else {
+		request.SetProducts(products)
+	}
+
+	if accessToken == "" && containsProduct(products, plaid.PRODUCTS_STATEMENTS) {
 		statementConfig := plaid.NewLinkTokenCreateRequestStatements()
 		statementConfig.SetStartDate(time.Now().Local().Add(-30 * 24 * time.Hour).Format("2006-01-02"))
 		statementConfig.SetEndDate(time.Now().Local().Format("2006-01-02"))
@@ -324,6 +434,10 @@ func (pc *PlaidClient) linkTokenCreate() (string, error) {
 		request.SetRedirectUri(redirectURI)
 	}
 
+	if pc.config.WebhookUrl != "" {
+		request.SetWebhook(pc.config.WebhookUrl)
+	}
+
 	linkTokenCreateResp, _, err := pc.client.PlaidApi.LinkTokenCreate(ctx).LinkTokenCreateRequest(*request).Execute()
 
 	if err != nil {
@@ -334,11 +448,29 @@ func (pc *PlaidClient) linkTokenCreate() (string, error) {
 }
 
 // https://plaid.com/docs/api/products/statements/#statementslist
-func (pc *PlaidClient) Statements(accessToken string) (plaid.StatementsListResponse, error) {
-	ctx := context.Background()
+func (pc *PlaidClient) Statements(ctx context.Context, accessToken string) (plaid.StatementsListResponse, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
+
 	statementsListResp, _, err := pc.client.PlaidApi.StatementsList(ctx).StatementsListRequest(
 		*plaid.NewStatementsListRequest(accessToken),
 	).Execute()
 
 	return statementsListResp, err
 }
+
+// https://plaid.com/docs/api/webhooks/webhook-verification/#webhook_verification_keyget
+func (pc *PlaidClient) WebhookVerificationKey(ctx context.Context, keyID string) (plaid.JWKPublicKey, error) {
+	ctx, cancel := pc.withDeadline(ctx)
+	defer cancel()
+
+	verificationKeyResp, _, err := pc.client.PlaidApi.WebhookVerificationKeyGet(ctx).WebhookVerificationKeyGetRequest(
+		*plaid.NewWebhookVerificationKeyGetRequest(keyID),
+	).Execute()
+
+	if err != nil {
+		return plaid.JWKPublicKey{}, err
+	}
+
+	return verificationKeyResp.GetKey(), nil
+}
@@ -0,0 +1,164 @@
+package banking_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/banking/plaidfake"
+
+	plaid "github.com/plaid/plaid-go/v21/plaid"
+)
+
+func newFakeClient(t *testing.T) (*plaidfake.Server, *banking.PlaidClient) {
+	t.Helper()
+
+	fake, server := plaidfake.Start(plaidfake.DefaultFixtures())
+	t.Cleanup(server.Close)
+
+	plaidClient, err := banking.NewPlaidClient(banking.PlaidClientConfig{
+		ClientId: "test-client",
+		Secret:   "test-secret",
+		Env:      "sandbox",
+		BaseUrl:  server.URL,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create PlaidClient: %v", err)
+	}
+
+	return fake, plaidClient
+}
+
+func TestPlaidClientLinksAccountsFromFake(t *testing.T) {
+	_, plaidClient := newFakeClient(t)
+	ctx := context.Background()
+
+	accessToken, err := plaidClient.GetAccessToken(ctx, "public-fake-1")
+
+	if err != nil {
+		t.Fatalf("GetAccessToken failed: %v", err)
+	}
+
+	if accessToken.AccessToken != "access-fake-1" || accessToken.ItemId != "fake-item-1" {
+		t.Fatalf("Unexpected access token %+v", accessToken)
+	}
+
+	accountsResponse, err := plaidClient.Accounts(ctx, accessToken.AccessToken)
+
+	if err != nil {
+		t.Fatalf("Accounts failed: %v", err)
+	}
+
+	if accountsResponse.Connection.ItemID != "fake-item-1" || accountsResponse.Connection.InstitutionID == nil || *accountsResponse.Connection.InstitutionID != "ins_fake_1" {
+		t.Errorf("Unexpected connection %+v", accountsResponse.Connection)
+	}
+
+	if len(accountsResponse.Accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %d", len(accountsResponse.Accounts))
+	}
+
+	checking := accountsResponse.Accounts[0]
+
+	if checking.ProviderAccountID != "fake-checking" || checking.Type != "depository" || checking.Currency != "USD" || checking.Mask == nil || *checking.Mask != "0000" {
+		t.Errorf("Unexpected checking account %+v", checking)
+	}
+
+	if !checking.CurrentBalance.Valid || checking.CurrentBalance.Decimal.StringFixed(2) != "1250.40" {
+		t.Errorf("Expected current balance 1250.40, got %v", checking.CurrentBalance)
+	}
+
+	numbers, err := plaidClient.AccountNumbers(ctx, accessToken.AccessToken)
+
+	if err != nil {
+		t.Fatalf("AccountNumbers failed: %v", err)
+	}
+
+	if len(numbers) != 1 || numbers[0].ProviderAccountID != "fake-checking" || numbers[0].Type != "ach" {
+		t.Errorf("Unexpected account numbers %+v", numbers)
+	}
+
+	if _, err := plaidClient.GetAccessToken(ctx, "public-unknown"); err == nil {
+		t.Errorf("Expected an unknown public token to be rejected")
+	}
+}
+
+func TestPlaidClientSyncsTransactionChangesFromFake(t *testing.T) {
+	fake, plaidClient := newFakeClient(t)
+	ctx := context.Background()
+
+	first, err := plaidClient.Transactions(ctx, banking.GetTransactionsRequest{}, "access-fake-1")
+
+	if err != nil {
+		t.Fatalf("First sync failed: %v", err)
+	}
+
+	if len(first.Added) != 8 || len(first.Modified) != 0 || len(first.Removed) != 0 {
+		t.Fatalf("Expected 8 added transactions, got added=%d modified=%d removed=%d", len(first.Added), len(first.Modified), len(first.Removed))
+	}
+
+	var rent *banking.Transaction
+
+	for i := range first.Added {
+		if first.Added[i].ProviderTransactionID == "fake-tx-2" {
+			rent = &first.Added[i]
+		}
+	}
+
+	if rent == nil || rent.ProviderAccountID != "fake-checking" || rent.Amount.StringFixed(2) != "1200.00" || rent.Currency != "USD" || rent.Name != "Rent" {
+		t.Errorf("Unexpected rent transaction %+v", rent)
+	}
+
+	// Nothing changed since the cursor
+	unchanged, err := plaidClient.Transactions(ctx, banking.GetTransactionsRequest{Cursor: &first.NextCursor}, "access-fake-1")
+
+	if err != nil {
+		t.Fatalf("Second sync failed: %v", err)
+	}
+
+	if len(unchanged.Added)+len(unchanged.Modified)+len(unchanged.Removed) != 0 || unchanged.NextCursor != first.NextCursor {
+		t.Errorf("Expected no changes, got %+v", unchanged)
+	}
+
+	modifiedRent := plaidfake.NewTransaction("fake-tx-2", "fake-checking", "Rent", 1250, "USD", time.Now().AddDate(0, 0, -18))
+	fake.ModifyTransactions("access-fake-1", modifiedRent)
+	fake.RemoveTransactions("access-fake-1", "fake-tx-4")
+
+	changes, err := plaidClient.Transactions(ctx, banking.GetTransactionsRequest{Cursor: &first.NextCursor}, "access-fake-1")
+
+	if err != nil {
+		t.Fatalf("Third sync failed: %v", err)
+	}
+
+	if len(changes.Modified) != 1 || changes.Modified[0].Amount.StringFixed(2) != "1250.00" {
+		t.Errorf("Expected the rent to be modified, got %+v", changes.Modified)
+	}
+
+	if len(changes.Removed) != 1 || changes.Removed[0] != "fake-tx-4" {
+		t.Errorf("Expected fake-tx-4 to be removed, got %v", changes.Removed)
+	}
+}
+
+func TestPlaidClientMapsErrorsFromFake(t *testing.T) {
+	fake, plaidClient := newFakeClient(t)
+	ctx := context.Background()
+
+	fake.InjectError("/accounts/balance/get", http.StatusTooManyRequests, plaid.PLAIDERRORTYPE_RATE_LIMIT_EXCEEDED, "BALANCE_LIMIT", 1)
+
+	if _, err := plaidClient.Balances(ctx, "access-fake-1"); !errors.Is(err, banking.ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+
+	if _, err := plaidClient.Balances(ctx, "access-fake-1"); err != nil {
+		t.Errorf("Expected the injected error to be used up, got %v", err)
+	}
+
+	fake.InjectError("/transactions/sync", http.StatusBadRequest, plaid.PLAIDERRORTYPE_ITEM_ERROR, "ITEM_LOGIN_REQUIRED", -1)
+
+	if _, err := plaidClient.Transactions(ctx, banking.GetTransactionsRequest{}, "access-fake-1"); err == nil {
+		t.Errorf("Expected the sync to fail while the login is required")
+	}
+}
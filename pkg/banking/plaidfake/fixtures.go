@@ -0,0 +1,147 @@
+package plaidfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v21/plaid"
+)
+
+// Fixtures is the data served by the fake. It can be built in code or loaded from a JSON file
+// which uses the same field names as the Plaid API.
+type Fixtures struct {
+	Institutions []plaid.Institution `json:"institutions"`
+	Items        []ItemFixture       `json:"items"`
+}
+
+// ItemFixture describes a single linked item. Transactions are returned as "added"
+// by the first /transactions/sync call made with an empty cursor.
+type ItemFixture struct {
+	ItemID                string                    `json:"item_id"`
+	AccessToken           string                    `json:"access_token"`
+	PublicToken           string                    `json:"public_token"`
+	InstitutionID         string                    `json:"institution_id"`
+	ConsentExpirationTime *time.Time                `json:"consent_expiration_time"`
+	Accounts              []plaid.AccountBase       `json:"accounts"`
+	Numbers               plaid.AuthGetNumbers      `json:"numbers"`
+	Transactions          []plaid.Transaction       `json:"transactions"`
+	Statements            []plaid.StatementsAccount `json:"statements"`
+	// Returned by /transactions/recurring/get
+	OutflowStreams []plaid.TransactionStream `json:"outflow_streams"`
+}
+
+func LoadFixtures(path string) (Fixtures, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return Fixtures{}, fmt.Errorf("Failed to read fixtures file '%s': %w", path, err)
+	}
+
+	var fixtures Fixtures
+
+	if err := json.Unmarshal(content, &fixtures); err != nil {
+		return Fixtures{}, fmt.Errorf("Failed to parse fixtures file '%s': %w", path, err)
+	}
+
+	return fixtures, nil
+}
+
+// DefaultFixtures returns a single item at a fake institution with a checking and a credit card
+// account and a handful of transactions, which is enough to click through the whole app.
+func DefaultFixtures() Fixtures {
+	institution := plaid.NewInstitution(
+		"ins_fake_1",
+		"Fake Bank",
+		[]plaid.Products{plaid.PRODUCTS_AUTH, plaid.PRODUCTS_TRANSACTIONS},
+		[]plaid.CountryCode{plaid.COUNTRYCODE_US},
+		[]string{"011401533"},
+		false,
+	)
+
+	checking := NewAccount("fake-checking", "Fake Checking", "0000", plaid.ACCOUNTTYPE_DEPOSITORY, 1250.40, "USD")
+	credit := NewAccount("fake-credit", "Fake Credit Card", "3333", plaid.ACCOUNTTYPE_CREDIT, 410.12, "USD")
+
+	today := time.Now()
+
+	return Fixtures{
+		Institutions: []plaid.Institution{*institution},
+		Items: []ItemFixture{
+			{
+				ItemID:        "fake-item-1",
+				AccessToken:   "access-fake-1",
+				PublicToken:   "public-fake-1",
+				InstitutionID: institution.InstitutionId,
+				Accounts:      []plaid.AccountBase{checking, credit},
+				Numbers: plaid.AuthGetNumbers{
+					Ach: []plaid.NumbersACH{
+						*plaid.NewNumbersACH("fake-checking", "1111222233330000", "011401533", *plaid.NewNullableString(nil)),
+					},
+					Eft:           []plaid.NumbersEFT{},
+					International: []plaid.NumbersInternational{},
+					Bacs:          []plaid.NumbersBACS{},
+				},
+				Transactions: []plaid.Transaction{
+					NewTransaction("fake-tx-1", "fake-checking", "Payroll", -2500, "USD", today.AddDate(0, 0, -20)),
+					NewTransaction("fake-tx-2", "fake-checking", "Rent", 1200, "USD", today.AddDate(0, 0, -18)),
+					NewTransaction("fake-tx-3", "fake-credit", "Grocery Store", 84.21, "USD", today.AddDate(0, 0, -10)),
+					NewTransaction("fake-tx-4", "fake-credit", "Coffee Shop", 4.5, "USD", today.AddDate(0, 0, -2)),
+					NewTransaction("fake-tx-5", "fake-credit", "Streaming Service", 15.49, "USD", today.AddDate(0, -3, -5)),
+					NewTransaction("fake-tx-6", "fake-credit", "Streaming Service", 15.49, "USD", today.AddDate(0, -2, -5)),
+					NewTransaction("fake-tx-7", "fake-credit", "Streaming Service", 17.99, "USD", today.AddDate(0, -1, -5)),
+					NewTransaction("fake-tx-8", "fake-credit", "Streaming Service", 17.99, "USD", today.AddDate(0, 0, -5)),
+				},
+				Statements: []plaid.StatementsAccount{},
+			},
+		},
+	}
+}
+
+// NewAccount builds an AccountBase with the current balance also used as the available one.
+func NewAccount(accountID, name, mask string, accountType plaid.AccountType, balance float64, currency string) plaid.AccountBase {
+	balances := plaid.NewAccountBalance(
+		*plaid.NewNullableFloat64(&balance),
+		*plaid.NewNullableFloat64(&balance),
+		*plaid.NewNullableFloat64(nil),
+		*plaid.NewNullableString(&currency),
+		*plaid.NewNullableString(nil),
+	)
+
+	return *plaid.NewAccountBase(
+		accountID,
+		*balances,
+		*plaid.NewNullableString(&mask),
+		name,
+		*plaid.NewNullableString(nil),
+		accountType,
+		*plaid.NewNullableAccountSubtype(nil),
+	)
+}
+
+// NewTransaction builds a posted transaction with only the fields the app reads set.
+func NewTransaction(transactionID, accountID, name string, amount float64, currency string, date time.Time) plaid.Transaction {
+	nullString := *plaid.NewNullableString(nil)
+
+	return *plaid.NewTransaction(
+		accountID,
+		amount,
+		*plaid.NewNullableString(&currency),
+		nullString,
+		[]string{},
+		nullString,
+		date.Format("2006-01-02"),
+		*plaid.NewLocation(nullString, nullString, nullString, nullString, nullString, *plaid.NewNullableFloat64(nil), *plaid.NewNullableFloat64(nil), nullString),
+		name,
+		*plaid.NewPaymentMeta(nullString, nullString, nullString, nullString, nullString, nullString, nullString, nullString),
+		false,
+		nullString,
+		nullString,
+		transactionID,
+		nullString,
+		*plaid.NewNullableTime(nil),
+		*plaid.NewNullableTime(nil),
+		"other",
+		*plaid.NewNullableTransactionCode(nil),
+	)
+}
@@ -0,0 +1,535 @@
+// Package plaidfake is an in-memory stand-in for the Plaid API, good enough to run the app
+// offline and to exercise PlaidClient in integration tests. Point PlaidClientConfig.BaseUrl
+// at a running Server to use it.
+package plaidfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v21/plaid"
+)
+
+const defaultSyncPageSize = 100
+
+// Server implements the subset of the Plaid API used by PlaidClient.
+type Server struct {
+	mu sync.Mutex
+
+	institutions map[string]plaid.Institution
+	// items by access token
+	items map[string]*item
+	// access tokens by public token
+	publicTokens map[string]string
+	// errors to return instead of a response, by endpoint path
+	injectedErrors map[string]*injectedError
+
+	requestCount int
+}
+
+type item struct {
+	fixture ItemFixture
+	// Every change made to the item's transactions, in order. A sync cursor is an index into this log.
+	changes []transactionChange
+}
+
+type transactionChange struct {
+	added    *plaid.Transaction
+	modified *plaid.Transaction
+	removed  string
+}
+
+type injectedError struct {
+	status    int
+	plaidErr  plaid.PlaidError
+	remaining int
+}
+
+func NewServer(fixtures Fixtures) *Server {
+	s := &Server{
+		institutions:   map[string]plaid.Institution{},
+		items:          map[string]*item{},
+		publicTokens:   map[string]string{},
+		injectedErrors: map[string]*injectedError{},
+	}
+
+	for _, institution := range fixtures.Institutions {
+		s.institutions[institution.InstitutionId] = institution
+	}
+
+	for _, itemFixture := range fixtures.Items {
+		s.AddItem(itemFixture)
+	}
+
+	return s
+}
+
+// Start runs the fake on a random local port. Close the returned server when done.
+func Start(fixtures Fixtures) (*Server, *httptest.Server) {
+	s := NewServer(fixtures)
+	return s, httptest.NewServer(s)
+}
+
+// AddItem registers an item. Its transactions become available to the next sync with an empty cursor.
+func (s *Server) AddItem(itemFixture ItemFixture) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newItem := &item{fixture: itemFixture}
+
+	for i := range itemFixture.Transactions {
+		newItem.changes = append(newItem.changes, transactionChange{added: &itemFixture.Transactions[i]})
+	}
+
+	s.items[itemFixture.AccessToken] = newItem
+
+	if itemFixture.PublicToken != "" {
+		s.publicTokens[itemFixture.PublicToken] = itemFixture.AccessToken
+	}
+}
+
+// AddTransactions makes transactions available as "added" to the next sync of the item.
+func (s *Server) AddTransactions(accessToken string, transactions ...plaid.Transaction) {
+	s.appendChanges(accessToken, transactions, func(t *plaid.Transaction) transactionChange {
+		return transactionChange{added: t}
+	})
+}
+
+// ModifyTransactions makes transactions available as "modified" to the next sync of the item.
+func (s *Server) ModifyTransactions(accessToken string, transactions ...plaid.Transaction) {
+	s.appendChanges(accessToken, transactions, func(t *plaid.Transaction) transactionChange {
+		return transactionChange{modified: t}
+	})
+}
+
+// RemoveTransactions makes transaction IDs available as "removed" to the next sync of the item.
+func (s *Server) RemoveTransactions(accessToken string, transactionIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.items[accessToken]; ok {
+		for _, transactionID := range transactionIDs {
+			existing.changes = append(existing.changes, transactionChange{removed: transactionID})
+		}
+	}
+}
+
+// SetBalance changes the current and available balance of an account.
+func (s *Server) SetBalance(accessToken, accountID string, current, available float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.items[accessToken]
+
+	if !ok {
+		return
+	}
+
+	for i := range existing.fixture.Accounts {
+		if existing.fixture.Accounts[i].AccountId == accountID {
+			existing.fixture.Accounts[i].Balances.SetCurrent(current)
+			existing.fixture.Accounts[i].Balances.SetAvailable(available)
+		}
+	}
+}
+
+// InjectError makes the next `times` calls to the endpoint (e.g. "/transactions/sync") fail
+// with the given Plaid error. A negative `times` makes the endpoint fail until ClearErrors is called.
+func (s *Server) InjectError(endpoint string, status int, errorType plaid.PlaidErrorType, errorCode string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plaidErr := newPlaidError(errorType, errorCode, fmt.Sprintf("injected %s error", errorCode))
+
+	s.injectedErrors[endpoint] = &injectedError{status: status, plaidErr: *plaidErr, remaining: times}
+}
+
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.injectedErrors = map[string]*injectedError{}
+}
+
+func (s *Server) appendChanges(accessToken string, transactions []plaid.Transaction, toChange func(*plaid.Transaction) transactionChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.items[accessToken]
+
+	if !ok {
+		return
+	}
+
+	for i := range transactions {
+		existing.changes = append(existing.changes, toChange(&transactions[i]))
+	}
+}
+
+// request has the union of all request fields the fake reads
+type request struct {
+	AccessToken   string   `json:"access_token"`
+	PublicToken   string   `json:"public_token"`
+	InstitutionID string   `json:"institution_id"`
+	Cursor        *string  `json:"cursor"`
+	Count         *int     `json:"count"`
+	AccountIDs    []string `json:"account_ids"`
+}
+
+type handlerFunc func(s *Server, req request) (any, *plaid.PlaidError, int)
+
+var routes = map[string]handlerFunc{
+	"/link/token/create":           (*Server).linkTokenCreate,
+	"/item/public_token/exchange":  (*Server).publicTokenExchange,
+	"/item/public_token/create":    (*Server).publicTokenCreate,
+	"/sandbox/public_token/create": (*Server).sandboxPublicTokenCreate,
+	"/auth/get":                    (*Server).authGet,
+	"/accounts/get":                (*Server).accountsGet,
+	"/accounts/balance/get":        (*Server).accountsGet,
+	"/item/get":                    (*Server).itemGet,
+	"/item/remove":                 (*Server).itemRemove,
+	"/institutions/get_by_id":      (*Server).institutionsGetByID,
+	"/transactions/sync":           (*Server).transactionsSync,
+	"/transactions/recurring/get":  (*Server).transactionsRecurringGet,
+	"/statements/list":             (*Server).statementsList,
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, ok := routes[r.URL.Path]
+
+	if r.Method != http.MethodPost || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requestCount++
+
+	if r.Header.Get("PLAID-CLIENT-ID") == "" || r.Header.Get("PLAID-SECRET") == "" {
+		writeError(w, http.StatusBadRequest, newPlaidError(plaid.PLAIDERRORTYPE_INVALID_REQUEST, "INVALID_API_KEYS", "missing client id or secret"))
+		return
+	}
+
+	if injected, ok := s.injectedErrors[r.URL.Path]; ok && injected.remaining != 0 {
+		injected.remaining--
+		writeError(w, injected.status, &injected.plaidErr)
+		return
+	}
+
+	var req request
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, newPlaidError(plaid.PLAIDERRORTYPE_INVALID_REQUEST, "INVALID_BODY", err.Error()))
+		return
+	}
+
+	response, plaidErr, status := handler(s, req)
+
+	if plaidErr != nil {
+		writeError(w, status, plaidErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) requestID() string {
+	return "fake-request-" + strconv.Itoa(s.requestCount)
+}
+
+func (s *Server) itemFor(accessToken string) (*item, *plaid.PlaidError, int) {
+	existing, ok := s.items[accessToken]
+
+	if !ok {
+		return nil, newPlaidError(plaid.PLAIDERRORTYPE_INVALID_INPUT, "INVALID_ACCESS_TOKEN", "unknown access token"), http.StatusBadRequest
+	}
+
+	return existing, nil, 0
+}
+
+func (s *Server) linkTokenCreate(req request) (any, *plaid.PlaidError, int) {
+	// An access token means the token is for update mode, which only works for known items
+	if req.AccessToken != "" {
+		if _, plaidErr, status := s.itemFor(req.AccessToken); plaidErr != nil {
+			return nil, plaidErr, status
+		}
+	}
+
+	return map[string]any{
+		"link_token": "link-fake-" + strconv.Itoa(s.requestCount),
+		"expiration": time.Now().Add(4 * time.Hour),
+		"request_id": s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) publicTokenExchange(req request) (any, *plaid.PlaidError, int) {
+	accessToken, ok := s.publicTokens[req.PublicToken]
+
+	if !ok {
+		return nil, newPlaidError(plaid.PLAIDERRORTYPE_INVALID_INPUT, "INVALID_PUBLIC_TOKEN", "unknown public token"), http.StatusBadRequest
+	}
+
+	return map[string]any{
+		"access_token": accessToken,
+		"item_id":      s.items[accessToken].fixture.ItemID,
+		"request_id":   s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) publicTokenCreate(req request) (any, *plaid.PlaidError, int) {
+	existing, plaidErr, status := s.itemFor(req.AccessToken)
+
+	if plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	publicToken := "public-fake-" + existing.fixture.ItemID + "-" + strconv.Itoa(s.requestCount)
+	s.publicTokens[publicToken] = req.AccessToken
+
+	return map[string]any{
+		"public_token": publicToken,
+		"expiration":   time.Now().Add(30 * time.Minute),
+		"request_id":   s.requestID(),
+	}, nil, 0
+}
+
+// sandboxPublicTokenCreate creates a new item at the institution with copies of the default accounts,
+// mirroring how Plaid's sandbox lets a test create items without going through Link.
+func (s *Server) sandboxPublicTokenCreate(req request) (any, *plaid.PlaidError, int) {
+	if _, ok := s.institutions[req.InstitutionID]; !ok {
+		return nil, newPlaidError(plaid.PLAIDERRORTYPE_INVALID_INPUT, "INVALID_INSTITUTION", "unknown institution"), http.StatusBadRequest
+	}
+
+	suffix := strconv.Itoa(s.requestCount)
+	template := DefaultFixtures().Items[0]
+
+	newItem := &item{fixture: ItemFixture{
+		ItemID:        "fake-item-" + suffix,
+		AccessToken:   "access-fake-" + suffix,
+		PublicToken:   "public-fake-" + suffix,
+		InstitutionID: req.InstitutionID,
+		Accounts:      template.Accounts,
+		Numbers:       template.Numbers,
+		Statements:    template.Statements,
+	}}
+
+	s.items[newItem.fixture.AccessToken] = newItem
+	s.publicTokens[newItem.fixture.PublicToken] = newItem.fixture.AccessToken
+
+	return map[string]any{
+		"public_token": newItem.fixture.PublicToken,
+		"request_id":   s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) authGet(req request) (any, *plaid.PlaidError, int) {
+	existing, plaidErr, status := s.itemFor(req.AccessToken)
+
+	if plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	return map[string]any{
+		"accounts":   existing.fixture.Accounts,
+		"numbers":    existing.fixture.Numbers,
+		"item":       existing.plaidItem(),
+		"request_id": s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) accountsGet(req request) (any, *plaid.PlaidError, int) {
+	existing, plaidErr, status := s.itemFor(req.AccessToken)
+
+	if plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	return map[string]any{
+		"accounts":   existing.fixture.Accounts,
+		"item":       existing.plaidItem(),
+		"request_id": s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) itemGet(req request) (any, *plaid.PlaidError, int) {
+	existing, plaidErr, status := s.itemFor(req.AccessToken)
+
+	if plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	return map[string]any{
+		"item":       existing.plaidItem(),
+		"request_id": s.requestID(),
+	}, nil, 0
+}
+
+// itemRemove forgets the item, so its access token is rejected from now on
+func (s *Server) itemRemove(req request) (any, *plaid.PlaidError, int) {
+	if _, plaidErr, status := s.itemFor(req.AccessToken); plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	delete(s.items, req.AccessToken)
+
+	return map[string]any{
+		"request_id": s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) institutionsGetByID(req request) (any, *plaid.PlaidError, int) {
+	institution, ok := s.institutions[req.InstitutionID]
+
+	if !ok {
+		return nil, newPlaidError(plaid.PLAIDERRORTYPE_INVALID_INPUT, "INVALID_INSTITUTION", "unknown institution"), http.StatusBadRequest
+	}
+
+	return map[string]any{
+		"institution": institution,
+		"request_id":  s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) transactionsSync(req request) (any, *plaid.PlaidError, int) {
+	existing, plaidErr, status := s.itemFor(req.AccessToken)
+
+	if plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	start := 0
+
+	if req.Cursor != nil && *req.Cursor != "" {
+		parsed, err := strconv.Atoi(*req.Cursor)
+
+		if err != nil || parsed < 0 || parsed > len(existing.changes) {
+			return nil, newPlaidError(plaid.PLAIDERRORTYPE_INVALID_INPUT, "INVALID_FIELD", "invalid cursor"), http.StatusBadRequest
+		}
+
+		start = parsed
+	}
+
+	pageSize := defaultSyncPageSize
+
+	if req.Count != nil && *req.Count > 0 {
+		pageSize = *req.Count
+	}
+
+	end := min(start+pageSize, len(existing.changes))
+
+	added := []plaid.Transaction{}
+	modified := []plaid.Transaction{}
+	removed := []plaid.RemovedTransaction{}
+
+	for _, change := range existing.changes[start:end] {
+		switch {
+		case change.added != nil:
+			added = append(added, *change.added)
+		case change.modified != nil:
+			modified = append(modified, *change.modified)
+		default:
+			removedTransaction := plaid.NewRemovedTransaction()
+			removedTransaction.SetTransactionId(change.removed)
+			removed = append(removed, *removedTransaction)
+		}
+	}
+
+	return map[string]any{
+		"added":       added,
+		"modified":    modified,
+		"removed":     removed,
+		"next_cursor": strconv.Itoa(end),
+		"has_more":    end < len(existing.changes),
+		"request_id":  s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) transactionsRecurringGet(req request) (any, *plaid.PlaidError, int) {
+	existing, plaidErr, status := s.itemFor(req.AccessToken)
+
+	if plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	requestedAccounts := map[string]bool{}
+
+	for _, accountID := range req.AccountIDs {
+		requestedAccounts[accountID] = true
+	}
+
+	outflowStreams := []plaid.TransactionStream{}
+
+	for _, stream := range existing.fixture.OutflowStreams {
+		if requestedAccounts[stream.AccountId] {
+			outflowStreams = append(outflowStreams, stream)
+		}
+	}
+
+	return map[string]any{
+		"inflow_streams":   []plaid.TransactionStream{},
+		"outflow_streams":  outflowStreams,
+		"updated_datetime": time.Now(),
+		"request_id":       s.requestID(),
+	}, nil, 0
+}
+
+func (s *Server) statementsList(req request) (any, *plaid.PlaidError, int) {
+	existing, plaidErr, status := s.itemFor(req.AccessToken)
+
+	if plaidErr != nil {
+		return nil, plaidErr, status
+	}
+
+	institutionName := ""
+
+	if institution, ok := s.institutions[existing.fixture.InstitutionID]; ok {
+		institutionName = institution.Name
+	}
+
+	return map[string]any{
+		"accounts":         existing.fixture.Statements,
+		"institution_id":   existing.fixture.InstitutionID,
+		"institution_name": institutionName,
+		"item_id":          existing.fixture.ItemID,
+		"request_id":       s.requestID(),
+	}, nil, 0
+}
+
+func (i *item) plaidItem() plaid.Item {
+	plaidItem := plaid.NewItem(
+		i.fixture.ItemID,
+		*plaid.NewNullableString(nil),
+		*plaid.NewNullablePlaidError(nil),
+		[]plaid.Products{},
+		[]plaid.Products{plaid.PRODUCTS_AUTH, plaid.PRODUCTS_TRANSACTIONS},
+		*plaid.NewNullableTime(i.fixture.ConsentExpirationTime),
+		"background",
+	)
+	plaidItem.SetInstitutionId(i.fixture.InstitutionID)
+
+	return *plaidItem
+}
+
+func newPlaidError(errorType plaid.PlaidErrorType, errorCode, message string) *plaid.PlaidError {
+	plaidErr := plaid.NewPlaidErrorWithDefaults()
+	plaidErr.SetErrorType(errorType)
+	plaidErr.SetErrorCode(errorCode)
+	plaidErr.SetErrorMessage(message)
+
+	return plaidErr
+}
+
+func writeError(w http.ResponseWriter, status int, plaidErr *plaid.PlaidError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(plaidErr)
+}
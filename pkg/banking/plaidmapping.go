@@ -0,0 +1,254 @@
+package banking
+
+import (
+	"fmt"
+	"nerdmoney/pkg/common/utils"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v21/plaid"
+	"github.com/shopspring/decimal"
+)
+
+const plaidDateLayout = "2006-01-02"
+
+func fromPlaidItem(item plaid.Item) Connection {
+	return Connection{
+		ItemID:                item.ItemId,
+		InstitutionID:         item.InstitutionId.Get(),
+		ConsentExpirationTime: item.ConsentExpirationTime.Get(),
+	}
+}
+
+func fromPlaidAccounts(plaidAccounts []plaid.AccountBase) []Account {
+	accounts := make([]Account, 0, len(plaidAccounts))
+
+	for _, plaidAccount := range plaidAccounts {
+		accounts = append(accounts, Account{
+			ProviderAccountID: plaidAccount.AccountId,
+			Name:              plaidAccount.Name,
+			Mask:              plaidAccount.Mask.Get(),
+			Type:              string(plaidAccount.Type),
+			CurrentBalance:    utils.NullDecimalFromFloat64(plaidAccount.Balances.Current.Get()),
+			AvailableBalance:  utils.NullDecimalFromFloat64(plaidAccount.Balances.Available.Get()),
+			// Plaid states in the docs that one of the two is alway defined: https://plaid.com/docs/api/accounts/#accountsget
+			Currency: plaidCurrency(plaidAccount.Balances.IsoCurrencyCode, plaidAccount.Balances.UnofficialCurrencyCode),
+		})
+	}
+
+	return accounts
+}
+
+func fromPlaidNumbers(numbers plaid.AuthGetNumbers) []AccountNumber {
+	accountNumbers := make([]AccountNumber, 0, len(numbers.Ach)+len(numbers.Eft)+len(numbers.International)+len(numbers.Bacs))
+
+	for _, ach := range numbers.Ach {
+		accountNumbers = append(accountNumbers, AccountNumber{
+			ProviderAccountID: ach.AccountId,
+			Type:              "ach",
+			Account:           &ach.Account,
+			Routing:           &ach.Routing,
+			WireRouting:       ach.WireRouting.Get(),
+		})
+	}
+
+	for _, eft := range numbers.Eft {
+		accountNumbers = append(accountNumbers, AccountNumber{
+			ProviderAccountID: eft.AccountId,
+			Type:              "eft",
+			Account:           &eft.Account,
+			Institution:       &eft.Institution,
+			Branch:            &eft.Branch,
+		})
+	}
+
+	for _, international := range numbers.International {
+		accountNumbers = append(accountNumbers, AccountNumber{
+			ProviderAccountID: international.AccountId,
+			Type:              "international",
+			Iban:              &international.Iban,
+			Bic:               &international.Bic,
+		})
+	}
+
+	for _, bacs := range numbers.Bacs {
+		accountNumbers = append(accountNumbers, AccountNumber{
+			ProviderAccountID: bacs.AccountId,
+			Type:              "bacs",
+			Account:           &bacs.Account,
+			SortCode:          &bacs.SortCode,
+		})
+	}
+
+	return accountNumbers
+}
+
+func fromPlaidTransactions(plaidTransactions []plaid.Transaction) ([]Transaction, error) {
+	transactions := make([]Transaction, 0, len(plaidTransactions))
+
+	for _, plaidTransaction := range plaidTransactions {
+		transaction, err := fromPlaidTransaction(plaidTransaction)
+
+		if err != nil {
+			return []Transaction{}, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+func fromPlaidTransaction(plaidTransaction plaid.Transaction) (Transaction, error) {
+	date, err := time.Parse(plaidDateLayout, plaidTransaction.GetDate())
+
+	if err != nil {
+		return Transaction{}, fmt.Errorf("Failed to parse date of transaction with id='%s': %w", plaidTransaction.TransactionId, err)
+	}
+
+	var authorizedDate *time.Time
+
+	if plaidAuthorizedDate := plaidTransaction.AuthorizedDate.Get(); plaidAuthorizedDate != nil {
+		parsed, err := time.Parse(plaidDateLayout, *plaidAuthorizedDate)
+
+		if err != nil {
+			return Transaction{}, fmt.Errorf("Failed to parse authorized date of transaction with id='%s': %w", plaidTransaction.TransactionId, err)
+		}
+
+		authorizedDate = &parsed
+	}
+
+	raw, err := plaidTransaction.MarshalJSON()
+
+	if err != nil {
+		return Transaction{}, fmt.Errorf("Failed to encode transaction with id='%s': %w", plaidTransaction.TransactionId, err)
+	}
+
+	return Transaction{
+		ProviderTransactionID: plaidTransaction.TransactionId,
+		ProviderAccountID:     plaidTransaction.AccountId,
+		Amount:                decimal.NewFromFloat(plaidTransaction.Amount),
+		Currency:              plaidCurrency(plaidTransaction.IsoCurrencyCode, plaidTransaction.UnofficialCurrencyCode),
+		Date:                  date,
+		AuthorizedDate:        authorizedDate,
+		Datetime:              plaidTransaction.Datetime.Get(),
+		AuthorizedDatetime:    plaidTransaction.AuthorizedDatetime.Get(),
+		Name:                  plaidTransaction.Name,
+		MerchantName:          plaidTransaction.MerchantName.Get(),
+		Category:              plaidCategory(plaidTransaction.PersonalFinanceCategory),
+		CategoryDetailed:      plaidCategoryDetailed(plaidTransaction.PersonalFinanceCategory),
+		Pending:               plaidTransaction.Pending,
+		PendingTransactionID:  plaidTransaction.PendingTransactionId.Get(),
+		PaymentChannel:        plaidTransaction.PaymentChannel,
+		MerchantEntityID:      plaidTransaction.MerchantEntityId.Get(),
+		LogoURL:               plaidTransaction.LogoUrl.Get(),
+		Website:               plaidTransaction.Website.Get(),
+		Location:              fromPlaidLocation(plaidTransaction.Location),
+		Counterparties:        fromPlaidCounterparties(plaidTransaction.GetCounterparties()),
+		Raw:                   raw,
+	}, nil
+}
+
+func plaidCategory(personalFinanceCategory plaid.NullablePersonalFinanceCategory) *string {
+	if !personalFinanceCategory.IsSet() || personalFinanceCategory.Get() == nil {
+		return nil
+	}
+
+	return &personalFinanceCategory.Get().Primary
+}
+
+func plaidCategoryDetailed(personalFinanceCategory plaid.NullablePersonalFinanceCategory) *string {
+	if !personalFinanceCategory.IsSet() || personalFinanceCategory.Get() == nil {
+		return nil
+	}
+
+	return &personalFinanceCategory.Get().Detailed
+}
+
+// fromPlaidLocation returns nil when Plaid does not know anything about the location
+func fromPlaidLocation(plaidLocation plaid.Location) *TransactionLocation {
+	location := TransactionLocation{
+		Address:     plaidLocation.Address.Get(),
+		City:        plaidLocation.City.Get(),
+		Region:      plaidLocation.Region.Get(),
+		PostalCode:  plaidLocation.PostalCode.Get(),
+		Country:     plaidLocation.Country.Get(),
+		Lat:         plaidLocation.Lat.Get(),
+		Lon:         plaidLocation.Lon.Get(),
+		StoreNumber: plaidLocation.StoreNumber.Get(),
+	}
+
+	if location == (TransactionLocation{}) {
+		return nil
+	}
+
+	return &location
+}
+
+func fromPlaidCounterparties(plaidCounterparties []plaid.TransactionCounterparty) []Counterparty {
+	counterparties := make([]Counterparty, 0, len(plaidCounterparties))
+
+	for _, plaidCounterparty := range plaidCounterparties {
+		counterparties = append(counterparties, Counterparty{
+			Name:            plaidCounterparty.Name,
+			Type:            string(plaidCounterparty.Type),
+			EntityID:        plaidCounterparty.EntityId.Get(),
+			Website:         plaidCounterparty.Website.Get(),
+			LogoURL:         plaidCounterparty.LogoUrl.Get(),
+			ConfidenceLevel: plaidCounterparty.ConfidenceLevel.Get(),
+		})
+	}
+
+	return counterparties
+}
+
+func plaidCurrency(isoCurrencyCode, unofficialCurrencyCode plaid.NullableString) string {
+	if isoCurrencyCode.IsSet() && isoCurrencyCode.Get() != nil {
+		return *isoCurrencyCode.Get()
+	}
+
+	if unofficialCurrencyCode.IsSet() && unofficialCurrencyCode.Get() != nil {
+		return *unofficialCurrencyCode.Get()
+	}
+
+	return "Unknown"
+}
+
+func fromPlaidStreams(plaidStreams []plaid.TransactionStream) ([]RecurringStream, error) {
+	streams := make([]RecurringStream, 0, len(plaidStreams))
+
+	for _, plaidStream := range plaidStreams {
+		// Tombstoned streams were merged into another stream, which is returned as well
+		if plaidStream.Status == plaid.TRANSACTIONSTREAMSTATUS_TOMBSTONED {
+			continue
+		}
+
+		firstDate, err := time.Parse(plaidDateLayout, plaidStream.FirstDate)
+
+		if err != nil {
+			return []RecurringStream{}, fmt.Errorf("Failed to parse first date of recurring stream with id='%s': %w", plaidStream.StreamId, err)
+		}
+
+		lastDate, err := time.Parse(plaidDateLayout, plaidStream.LastDate)
+
+		if err != nil {
+			return []RecurringStream{}, fmt.Errorf("Failed to parse last date of recurring stream with id='%s': %w", plaidStream.StreamId, err)
+		}
+
+		streams = append(streams, RecurringStream{
+			ProviderStreamID:       plaidStream.StreamId,
+			ProviderAccountID:      plaidStream.AccountId,
+			Description:            plaidStream.Description,
+			MerchantName:           plaidStream.MerchantName.Get(),
+			Frequency:              string(plaidStream.Frequency),
+			AverageAmount:          decimal.NewFromFloat(plaidStream.AverageAmount.GetAmount()),
+			LastAmount:             decimal.NewFromFloat(plaidStream.LastAmount.GetAmount()),
+			Currency:               plaidCurrency(plaidStream.LastAmount.IsoCurrencyCode, plaidStream.LastAmount.UnofficialCurrencyCode),
+			FirstDate:              firstDate,
+			LastDate:               lastDate,
+			Active:                 plaidStream.IsActive,
+			ProviderTransactionIDs: plaidStream.TransactionIds,
+		})
+	}
+
+	return streams, nil
+}
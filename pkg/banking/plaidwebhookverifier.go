@@ -0,0 +1,232 @@
+package banking
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"nerdmoney/pkg/common/ratelimit"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Plaid recommends rejecting webhooks that were signed more than 5 minutes ago
+const maxWebhookAge = 5 * time.Minute
+
+// Cached keys are fetched again after this long, so that keys Plaid has expired since stop being accepted
+const keyRefreshInterval = time.Hour
+
+// Key IDs Plaid did not return a usable key for are not looked up again for this long. They are forgotten
+// afterwards, which together with the limit on lookups keeps made up key IDs from piling up.
+const failedKeyRetryInterval = 10 * time.Minute
+
+// Anyone can send a webhook with a made up key ID, so key lookups at Plaid are limited
+const keyRequestsPerMinute = 10
+
+// Plaid key IDs are UUIDs, anything much longer is not worth a lookup
+const maxKeyIDLength = 64
+
+// PlaidWebhookVerifier checks the Plaid-Verification header sent with every Plaid webhook.
+// Verification keys are fetched from Plaid on first use and cached by their key ID, along with key IDs
+// which could not be fetched.
+//
+// https://plaid.com/docs/api/webhooks/webhook-verification/
+type PlaidWebhookVerifier struct {
+	plaidClient *PlaidClient
+	limiter     *ratelimit.Limiter
+
+	mu   sync.Mutex
+	keys map[string]verificationKey
+}
+
+type verificationKey struct {
+	// nil when the key could not be fetched
+	key       *ecdsa.PublicKey
+	err       error
+	expiredAt *time.Time
+	fetchedAt time.Time
+}
+
+func NewPlaidWebhookVerifier(plaidClient *PlaidClient) *PlaidWebhookVerifier {
+	return &PlaidWebhookVerifier{
+		plaidClient: plaidClient,
+		limiter:     ratelimit.New(keyRequestsPerMinute, time.Minute),
+		keys:        map[string]verificationKey{},
+	}
+}
+
+// Verify returns an error unless signedJWT is a valid, recent Plaid signature of body.
+func (v *PlaidWebhookVerifier) Verify(ctx context.Context, signedJWT string, body []byte) error {
+	if signedJWT == "" {
+		return fmt.Errorf("Missing Plaid-Verification header")
+	}
+
+	parser := jwt.Parser{ValidMethods: []string{jwt.SigningMethodES256.Alg()}}
+
+	token, err := parser.Parse(signedJWT, func(token *jwt.Token) (interface{}, error) {
+		keyID, ok := token.Header["kid"].(string)
+
+		if !ok || keyID == "" {
+			return nil, fmt.Errorf("Missing key ID in webhook JWT header")
+		}
+
+		return v.key(ctx, keyID)
+	})
+
+	if err != nil {
+		return fmt.Errorf("Invalid webhook signature: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+
+	if !ok {
+		return fmt.Errorf("Unexpected webhook JWT claims")
+	}
+
+	issuedAt, ok := claims["iat"].(float64)
+
+	if !ok {
+		return fmt.Errorf("Missing 'iat' claim in webhook JWT")
+	}
+
+	if time.Since(time.Unix(int64(issuedAt), 0)) > maxWebhookAge {
+		return fmt.Errorf("Webhook JWT is older than %s", maxWebhookAge)
+	}
+
+	expectedBodyHash, ok := claims["request_body_sha256"].(string)
+
+	if !ok {
+		return fmt.Errorf("Missing 'request_body_sha256' claim in webhook JWT")
+	}
+
+	bodyHash := sha256.Sum256(body)
+
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(bodyHash[:])), []byte(expectedBodyHash)) != 1 {
+		return fmt.Errorf("Webhook body does not match the signed body hash")
+	}
+
+	return nil
+}
+
+func (v *PlaidWebhookVerifier) key(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	if len(keyID) > maxKeyIDLength {
+		return nil, fmt.Errorf("Invalid webhook verification key id")
+	}
+
+	now := time.Now()
+
+	v.mu.Lock()
+	cached, ok := v.keys[keyID]
+	v.mu.Unlock()
+
+	switch {
+	case ok && cached.key == nil && now.Sub(cached.fetchedAt) < failedKeyRetryInterval:
+		return nil, cached.err
+	case ok && cached.key != nil && now.Sub(cached.fetchedAt) < keyRefreshInterval:
+		return cached.checkExpiry(keyID, now)
+	}
+
+	// The lock is not held while asking Plaid, so that a slow lookup does not hold up other webhooks
+	if !v.limiter.Allow() {
+		if ok && cached.key != nil {
+			return cached.checkExpiry(keyID, now)
+		}
+
+		return nil, fmt.Errorf("Too many webhook verification key lookups, not fetching key with id='%s'", keyID)
+	}
+
+	fetched := v.fetchKey(ctx, keyID)
+
+	// A lookup cut short by the request says nothing about the key
+	if fetched.key == nil && ctx.Err() != nil {
+		return nil, fetched.err
+	}
+
+	// Neither does Plaid being unreachable, a key fetched before is kept then
+	if fetched.key == nil && ok && cached.key != nil && !errors.Is(fetched.err, errInvalidVerificationKey) {
+		return cached.checkExpiry(keyID, now)
+	}
+
+	v.mu.Lock()
+	v.forgetFailedKeys(now)
+	v.keys[keyID] = fetched
+	v.mu.Unlock()
+
+	if fetched.key == nil {
+		return nil, fetched.err
+	}
+
+	return fetched.checkExpiry(keyID, now)
+}
+
+// forgetFailedKeys drops the key IDs whose lookup failed longer than failedKeyRetryInterval ago. v.mu has to be held.
+func (v *PlaidWebhookVerifier) forgetFailedKeys(now time.Time) {
+	for keyID, cached := range v.keys {
+		if cached.key == nil && now.Sub(cached.fetchedAt) >= failedKeyRetryInterval {
+			delete(v.keys, keyID)
+		}
+	}
+}
+
+// errInvalidVerificationKey marks keys which Plaid returned, but which cannot be used
+var errInvalidVerificationKey = errors.New("Invalid webhook verification key")
+
+func (v *PlaidWebhookVerifier) fetchKey(ctx context.Context, keyID string) verificationKey {
+	failed := func(err error) verificationKey {
+		return verificationKey{err: err, fetchedAt: time.Now()}
+	}
+
+	jwk, err := v.plaidClient.WebhookVerificationKey(ctx, keyID)
+
+	if err != nil {
+		return failed(fmt.Errorf("Failed to fetch webhook verification key with id='%s': %w", keyID, err))
+	}
+
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+		return failed(fmt.Errorf("%w: unsupported key type '%s/%s'", errInvalidVerificationKey, jwk.Kty, jwk.Crv))
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+
+	if err != nil {
+		return failed(fmt.Errorf("%w: %v", errInvalidVerificationKey, err))
+	}
+
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+
+	if err != nil {
+		return failed(fmt.Errorf("%w: %v", errInvalidVerificationKey, err))
+	}
+
+	fetched := verificationKey{
+		key: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		},
+		fetchedAt: time.Now(),
+	}
+
+	if jwk.ExpiredAt.IsSet() && jwk.ExpiredAt.Get() != nil {
+		expiredAt := time.Unix(int64(*jwk.ExpiredAt.Get()), 0)
+		fetched.expiredAt = &expiredAt
+	}
+
+	return fetched
+}
+
+func (k verificationKey) checkExpiry(keyID string, now time.Time) (*ecdsa.PublicKey, error) {
+	if k.expiredAt != nil && !now.Before(*k.expiredAt) {
+		return nil, fmt.Errorf("Webhook verification key with id='%s' has expired", keyID)
+	}
+
+	return k.key, nil
+}
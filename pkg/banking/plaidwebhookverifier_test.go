@@ -0,0 +1,34 @@
+package banking
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestForgetFailedKeys(t *testing.T) {
+	now := time.Now()
+	verifier := NewPlaidWebhookVerifier(nil)
+
+	verifier.keys = map[string]verificationKey{
+		"failed-long-ago":  {err: errors.New("not found"), fetchedAt: now.Add(-failedKeyRetryInterval)},
+		"failed-recently":  {err: errors.New("not found"), fetchedAt: now.Add(-time.Minute)},
+		"fetched-long-ago": {key: &ecdsa.PublicKey{}, fetchedAt: now.Add(-2 * keyRefreshInterval)},
+	}
+
+	verifier.forgetFailedKeys(now)
+
+	if _, ok := verifier.keys["failed-long-ago"]; ok {
+		t.Errorf("Expected the key ID which failed long ago to be forgotten")
+	}
+
+	if _, ok := verifier.keys["failed-recently"]; !ok {
+		t.Errorf("Expected the key ID which failed recently to be kept, so it is not looked up again")
+	}
+
+	// Fetched keys are kept as a fallback for when Plaid is unreachable
+	if _, ok := verifier.keys["fetched-long-ago"]; !ok {
+		t.Errorf("Expected the fetched key to be kept")
+	}
+}
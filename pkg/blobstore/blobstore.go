@@ -0,0 +1,19 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("Blob not found")
+
+// BlobStore keeps files, like receipts attached to transactions, outside of the database. Keys are paths
+// separated by slashes, e.g. attachments/1/2f9c.
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, content io.Reader) error
+	// Get returns ErrBlobNotFound if there is no blob with the key. The caller has to close the content.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete succeeds if there is no blob with the key
+	Delete(ctx context.Context, key string) error
+}
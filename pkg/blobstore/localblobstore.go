@@ -0,0 +1,105 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalBlobStore keeps blobs as files in a directory of the local filesystem
+type LocalBlobStore struct {
+	dir string
+}
+
+var _ BlobStore = (*LocalBlobStore)(nil)
+
+func NewLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("Failed to create blob directory '%s': %w", dir, err)
+	}
+
+	return &LocalBlobStore{dir: dir}, nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, contentType string, content io.Reader) error {
+	path, err := s.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("Failed to create directory of blob '%s': %w", key, err)
+	}
+
+	// Readers never see a partially written blob
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+
+	if err != nil {
+		return fmt.Errorf("Failed to create blob '%s': %w", key, err)
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("Failed to write blob '%s': %w", key, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Failed to write blob '%s': %w", key, err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("Failed to save blob '%s': %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open blob '%s': %w", key, err)
+	}
+
+	return file, nil
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("Failed to delete blob '%s': %w", key, err)
+	}
+
+	return nil
+}
+
+// path rejects keys which would point outside of the directory
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", fmt.Errorf("Invalid blob key '%s'", key)
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,178 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3BlobStore keeps blobs in a bucket of an S3 compatible object storage, e.g. AWS S3 or MinIO
+type S3BlobStore struct {
+	config S3Config
+	client *http.Client
+}
+
+type S3Config struct {
+	// Endpoint is the base URL of the storage, e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000.
+	// The bucket is part of the path, which every S3 compatible storage supports.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// RequestTimeout bounds every single request to the storage. Defaults to 30 seconds.
+	RequestTimeout time.Duration
+}
+
+var _ BlobStore = (*S3BlobStore)(nil)
+
+func NewS3BlobStore(config S3Config) (*S3BlobStore, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT is not set. Make sure to fill out the .env file")
+	}
+
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is not set. Make sure to fill out the .env file")
+	}
+
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY have to be set. Make sure to fill out the .env file")
+	}
+
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("Invalid S3_ENDPOINT '%s': %w", config.Endpoint, err)
+	}
+
+	// MinIO accepts any region, AWS needs the one of the bucket
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = 30 * time.Second
+	}
+
+	return &S3BlobStore{config: config, client: &http.Client{Timeout: config.RequestTimeout}}, nil
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, contentType string, content io.Reader) error {
+	// The signature covers the hash of the content, so it has to be read up front
+	body, err := io.ReadAll(content)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read blob '%s': %w", key, err)
+	}
+
+	request, err := s.newRequest(ctx, http.MethodPut, key, body)
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := s.client.Do(request)
+
+	if err != nil {
+		return fmt.Errorf("Failed to upload blob '%s': %w", key, err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to upload blob '%s': %w", key, responseError(response))
+	}
+
+	return nil
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	request, err := s.newRequest(ctx, http.MethodGet, key, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.client.Do(request)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to download blob '%s': %w", key, err)
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		return nil, fmt.Errorf("Failed to download blob '%s': %w", key, responseError(response))
+	}
+
+	return response.Body, nil
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	request, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := s.client.Do(request)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete blob '%s': %w", key, err)
+	}
+
+	defer response.Body.Close()
+
+	// Deleting a missing object succeeds as well
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to delete blob '%s': %w", key, responseError(response))
+	}
+
+	return nil
+}
+
+func (s *S3BlobStore) newRequest(ctx context.Context, method string, key string, body []byte) (*http.Request, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return nil, fmt.Errorf("Invalid blob key '%s'", key)
+	}
+
+	endpoint := strings.TrimSuffix(s.config.Endpoint, "/")
+	objectURL := endpoint + "/" + uriEncode(s.config.Bucket, false) + "/" + uriEncode(key, false)
+
+	request, err := http.NewRequestWithContext(ctx, method, objectURL, bytes.NewReader(body))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request for blob '%s': %w", key, err)
+	}
+
+	payloadHash := sha256.Sum256(body)
+	signV4(request, hex.EncodeToString(payloadHash[:]), s.config, time.Now())
+
+	return request, nil
+}
+
+// responseError reads the error code S3 sends back in an XML document, e.g. NoSuchBucket
+func responseError(response *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+
+	code := ""
+
+	if start := bytes.Index(body, []byte("<Code>")); start >= 0 {
+		if end := bytes.Index(body[start:], []byte("</Code>")); end >= 0 {
+			code = string(body[start+len("<Code>") : start+end])
+		}
+	}
+
+	return fmt.Errorf("storage responded with status %d %s", response.StatusCode, code)
+}
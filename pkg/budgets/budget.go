@@ -0,0 +1,50 @@
+package budgets
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Budget is the amount the user plans to spend on a category in a month, in their base currency
+type Budget struct {
+	ID         int
+	UserID     int
+	CategoryID int
+	// The first day of the month
+	Month  time.Time
+	Amount decimal.Decimal
+	// Whether what was left of the previous month's budget of the category is added to this one
+	Rollover bool
+}
+
+type BudgetWriteModel struct {
+	UserID     int
+	CategoryID int
+	Month      time.Time
+	Amount     decimal.Decimal
+	Rollover   bool
+}
+
+const monthLayout = "2006-01"
+
+// StartOfMonth returns midnight UTC of the first day of the month of t
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ParseMonth parses months like "2024-05" and falls back to the month of now
+func ParseMonth(value string, now time.Time) time.Time {
+	month, err := time.Parse(monthLayout, value)
+
+	if err != nil {
+		return StartOfMonth(now)
+	}
+
+	return month
+}
+
+// FormatMonth formats months the way ParseMonth expects them
+func FormatMonth(month time.Time) string {
+	return month.Format(monthLayout)
+}
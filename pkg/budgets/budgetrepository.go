@@ -0,0 +1,149 @@
+package budgets
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type BudgetRepository interface {
+	// ListUntil returns the user's budgets of the month and all months before it, oldest first
+	ListUntil(ctx context.Context, userID int, month time.Time) ([]Budget, error)
+	// Save creates the budget of the category and month, or replaces it if there is one already. It returns
+	// pgx.ErrNoRows if the category does not belong to the user.
+	Save(ctx context.Context, writeModel BudgetWriteModel) (Budget, error)
+	Delete(ctx context.Context, userID int, id int) error
+	// CopyMonth copies the budgets of one month into another, keeping the budgets the other month already has.
+	// It returns the number of copied budgets.
+	CopyMonth(ctx context.Context, userID int, from time.Time, to time.Time) (int, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) BudgetRepository
+}
+
+type budgetRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewBudgetRepository(pool *pgxpool.Pool, log echo.Logger) BudgetRepository {
+	return &budgetRepositoryImpl{pool, log}
+}
+
+func (r *budgetRepositoryImpl) WithTx(tx pgx.Tx) BudgetRepository {
+	return &budgetRepositoryImpl{tx, r.log}
+}
+
+const budgetColumns = `id, user_id, category_id, month, amount, rollover`
+
+func (r *budgetRepositoryImpl) ListUntil(ctx context.Context, userID int, month time.Time) ([]Budget, error) {
+	r.log.Debugf("Attempting to list budgets of User with id='%d' until %s", userID, FormatMonth(month))
+
+	query := `SELECT ` + budgetColumns + ` FROM budget WHERE user_id = $1 AND month <= $2 ORDER BY month, category_id`
+
+	rows, err := r.db.Query(ctx, query, userID, month)
+
+	if err != nil {
+		return []Budget{}, fmt.Errorf("Failed to list budgets of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var budgets []Budget
+
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+
+		if err != nil {
+			return []Budget{}, fmt.Errorf("Failed to read budget row: %w", err)
+		}
+
+		budgets = append(budgets, budget)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []Budget{}, fmt.Errorf("Failed to read budget rows: %w", err)
+	}
+
+	return budgets, nil
+}
+
+func (r *budgetRepositoryImpl) Save(ctx context.Context, writeModel BudgetWriteModel) (Budget, error) {
+	r.log.Debugf("Attempting to save Budget of Category with id='%d'", writeModel.CategoryID)
+
+	// The category has to belong to the same user
+	query := `
+	INSERT INTO budget (user_id, category_id, month, amount, rollover)
+	SELECT $1, $2, $3, $4, $5
+	WHERE EXISTS (SELECT 1 FROM category WHERE id = $2 AND user_id = $1)
+	ON CONFLICT (category_id, month) DO UPDATE SET amount = EXCLUDED.amount, rollover = EXCLUDED.rollover
+	RETURNING ` + budgetColumns
+
+	budget, err := scanBudget(r.db.QueryRow(
+		ctx,
+		query,
+		writeModel.UserID,
+		writeModel.CategoryID,
+		StartOfMonth(writeModel.Month),
+		writeModel.Amount,
+		writeModel.Rollover,
+	))
+
+	if err != nil {
+		return Budget{}, fmt.Errorf("Failed to save Budget of Category with id='%d': %w", writeModel.CategoryID, err)
+	}
+
+	return budget, nil
+}
+
+func (r *budgetRepositoryImpl) Delete(ctx context.Context, userID int, id int) error {
+	r.log.Debugf("Attempting to delete Budget with id='%d'", id)
+
+	commandTag, err := r.db.Exec(ctx, `DELETE FROM budget WHERE id = $1 AND user_id = $2`, id, userID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete Budget with id='%d': %w", id, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("Failed to delete Budget with id='%d': %w", id, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *budgetRepositoryImpl) CopyMonth(ctx context.Context, userID int, from time.Time, to time.Time) (int, error) {
+	r.log.Debugf("Attempting to copy budgets of User with id='%d' from %s to %s", userID, FormatMonth(from), FormatMonth(to))
+
+	query := `
+	INSERT INTO budget (user_id, category_id, month, amount, rollover)
+	SELECT user_id, category_id, $3, amount, rollover FROM budget WHERE user_id = $1 AND month = $2
+	ON CONFLICT (category_id, month) DO NOTHING`
+
+	commandTag, err := r.db.Exec(ctx, query, userID, StartOfMonth(from), StartOfMonth(to))
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to copy budgets of User with id='%d': %w", userID, err)
+	}
+
+	return int(commandTag.RowsAffected()), nil
+}
+
+func scanBudget(row pgx.Row) (Budget, error) {
+	var budget Budget
+
+	err := row.Scan(
+		&budget.ID,
+		&budget.UserID,
+		&budget.CategoryID,
+		&budget.Month,
+		&budget.Amount,
+		&budget.Rollover,
+	)
+
+	return budget, err
+}
@@ -0,0 +1,240 @@
+package budgets
+
+import (
+	"context"
+	"errors"
+	"nerdmoney/pkg/categories"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/users"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+func RegisterBudgetRoutes(
+	e *echo.Echo,
+	budgetRepository BudgetRepository,
+	transactionRepository transactions.TransactionRepository,
+	categoryRepository categories.CategoryRepository,
+	exchangeRateRepository exchangerates.ExchangeRateRepository,
+) {
+
+	log := e.Logger
+
+	loadView := func(ctx context.Context, user users.User, month time.Time, form BudgetForm) (budgetsView, error) {
+		budgets, err := budgetRepository.ListUntil(ctx, user.ID, month)
+
+		if err != nil {
+			return budgetsView{}, err
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			return budgetsView{}, err
+		}
+
+		// Budgets of earlier months may roll over into this one, their spending is needed as well
+		from := month
+
+		if len(budgets) > 0 && budgets[0].Month.Before(from) {
+			from = StartOfMonth(budgets[0].Month)
+		}
+
+		spendings, err := transactionRepository.SumSpendingByCategory(ctx, user.ID, from, month.AddDate(0, 1, 0))
+
+		if err != nil {
+			return budgetsView{}, err
+		}
+
+		rates, err := exchangeRateRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			return budgetsView{}, err
+		}
+
+		tree := categories.NewTree(allCategories)
+
+		return budgetsView{
+			BaseCurrency: user.BaseCurrency,
+			Progress:     ComputeProgress(month, budgets, spendings, tree, exchangerates.NewConverter(rates), user.BaseCurrency, time.Now()),
+			Categories:   tree,
+			Form:         form,
+		}, nil
+	}
+
+	e.GET("/budgets", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		month := ParseMonth(c.QueryParam("month"), time.Now())
+
+		view, err := loadView(c.Request().Context(), user, month, BudgetForm{})
+
+		if err != nil {
+			log.Errorf("Failed to load budgets: %v", err)
+			return c.String(500, "Something went wrong when listing budgets...")
+		}
+
+		return layout.RenderPage(c, 200, BudgetsPage(view))
+	})
+
+	e.POST("/budgets", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+		month := ParseMonth(c.FormValue("month"), time.Now())
+
+		form, writeModel := parseBudgetForm(c)
+		status := 200
+
+		if !form.hasErrors() {
+			writeModel.UserID = user.ID
+			writeModel.Month = month
+
+			_, err := budgetRepository.Save(ctx, writeModel)
+
+			if errors.Is(err, pgx.ErrNoRows) {
+				return c.String(404, "Category not found")
+			}
+
+			if err != nil {
+				log.Errorf("Failed to save budget: %v", err)
+				return c.String(500, "Something went wrong when saving the budget...")
+			}
+
+			form = BudgetForm{}
+		} else {
+			status = 422
+		}
+
+		view, err := loadView(ctx, user, month, form)
+
+		if err != nil {
+			log.Errorf("Failed to load budgets: %v", err)
+			return c.String(500, "Something went wrong when listing budgets...")
+		}
+
+		return layout.RenderComponent(c, status, BudgetsSection(view))
+	})
+
+	// Starts the month with the budgets of the month before, budgets the month already has are kept
+	e.POST("/budgets/copy", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+		month := ParseMonth(c.FormValue("month"), time.Now())
+
+		copied, err := budgetRepository.CopyMonth(ctx, user.ID, month.AddDate(0, -1, 0), month)
+
+		if err != nil {
+			log.Errorf("Failed to copy budgets: %v", err)
+			return c.String(500, "Something went wrong when copying the budgets...")
+		}
+
+		log.Debugf("Copied %d budgets into %s", copied, FormatMonth(month))
+
+		view, err := loadView(ctx, user, month, BudgetForm{})
+
+		if err != nil {
+			log.Errorf("Failed to load budgets: %v", err)
+			return c.String(500, "Something went wrong when listing budgets...")
+		}
+
+		return layout.RenderComponent(c, 200, BudgetsSection(view))
+	})
+
+	e.DELETE("/budgets/:id", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+		month := ParseMonth(c.QueryParam("month"), time.Now())
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid budget id")
+		}
+
+		err = budgetRepository.Delete(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Budget not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to delete budget: %v", err)
+			return c.String(500, "Something went wrong when deleting the budget...")
+		}
+
+		view, err := loadView(ctx, user, month, BudgetForm{})
+
+		if err != nil {
+			log.Errorf("Failed to load budgets: %v", err)
+			return c.String(500, "Something went wrong when listing budgets...")
+		}
+
+		return layout.RenderComponent(c, 200, BudgetsSection(view))
+	})
+}
+
+type budgetsView struct {
+	BaseCurrency string
+	Progress     MonthProgress
+	Categories   categories.Tree
+	Form         BudgetForm
+}
+
+func (v budgetsView) Month() string {
+	return FormatMonth(v.Progress.Month)
+}
+
+func (v budgetsView) PreviousMonth() string {
+	return FormatMonth(v.Progress.Month.AddDate(0, -1, 0))
+}
+
+func (v budgetsView) NextMonth() string {
+	return FormatMonth(v.Progress.Month.AddDate(0, 1, 0))
+}
+
+// BudgetForm holds what the user entered into the budget form, along with validation errors
+type BudgetForm struct {
+	Category string
+	Amount   string
+	Rollover bool
+
+	CategoryError string
+	AmountError   string
+}
+
+func (f BudgetForm) hasErrors() bool {
+	return f.CategoryError != "" || f.AmountError != ""
+}
+
+func parseBudgetForm(c echo.Context) (BudgetForm, BudgetWriteModel) {
+	form := BudgetForm{
+		Category: c.FormValue("category"),
+		Amount:   strings.TrimSpace(c.FormValue("amount")),
+		Rollover: c.FormValue("rollover") == "on",
+	}
+
+	var writeModel BudgetWriteModel
+	var err error
+
+	writeModel.CategoryID, err = strconv.Atoi(form.Category)
+
+	if err != nil {
+		form.CategoryError = "Pick a category"
+	}
+
+	writeModel.Amount, err = decimal.NewFromString(form.Amount)
+
+	if err != nil || writeModel.Amount.IsNegative() {
+		form.AmountError = "Enter an amount of 0 or more"
+	}
+
+	writeModel.Rollover = form.Rollover
+
+	return form, writeModel
+}
@@ -0,0 +1,158 @@
+package budgets
+
+import (
+	"nerdmoney/pkg/categories"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/transactions"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Progress compares what was spent on a category in a month with its budget. Spending on the categories
+// inside of it counts as well. All amounts are in the user's base currency.
+type Progress struct {
+	Budget       Budget
+	CategoryPath string
+	// Left over from the previous month if the budget rolls over
+	RolledOver decimal.Decimal
+	Spent      decimal.Decimal
+}
+
+// Available is the budget along with what rolled over into it
+func (p Progress) Available() decimal.Decimal {
+	return p.Budget.Amount.Add(p.RolledOver)
+}
+
+func (p Progress) Remaining() decimal.Decimal {
+	return p.Available().Sub(p.Spent)
+}
+
+func (p Progress) Overspent() bool {
+	return p.Remaining().IsNegative()
+}
+
+// Percent returns how much of the available amount was spent, capped at 100
+func (p Progress) Percent() int {
+	available := p.Available()
+
+	if !available.IsPositive() {
+		if p.Spent.IsPositive() {
+			return 100
+		}
+
+		return 0
+	}
+
+	percent := p.Spent.Div(available).Mul(decimal.NewFromInt(100)).IntPart()
+
+	return int(max(0, min(100, percent)))
+}
+
+// MonthProgress is the progress of every budget of a month
+type MonthProgress struct {
+	Month    time.Time
+	Progress []Progress
+	// Currencies of transactions which could not be converted into the base currency, they are left out
+	MissingRates []string
+}
+
+type categoryMonth struct {
+	categoryID int
+	month      time.Time
+}
+
+// ComputeProgress computes the progress of the budgets of the month. The budgets have to include the budgets of
+// previous months which roll over into it, and the spendings have to cover all of these months. Each month's
+// spending is converted with the rates valid on the last day of the month, or on now for the current month.
+func ComputeProgress(
+	month time.Time,
+	budgets []Budget,
+	spendings []transactions.CategorySpending,
+	tree categories.Tree,
+	converter exchangerates.Converter,
+	baseCurrency string,
+	now time.Time,
+) MonthProgress {
+	spent := map[categoryMonth]decimal.Decimal{}
+	missingRates := map[string]bool{}
+
+	for _, spending := range spendings {
+		convertedOn := StartOfMonth(spending.Month).AddDate(0, 1, -1)
+
+		if convertedOn.After(now) {
+			convertedOn = now
+		}
+
+		amount, ok := converter.Convert(spending.Amount, spending.Currency, baseCurrency, convertedOn)
+
+		if !ok {
+			missingRates[spending.Currency] = true
+			continue
+		}
+
+		spendingMonth := StartOfMonth(spending.Month)
+		key := categoryMonth{spending.CategoryID, spendingMonth}
+		spent[key] = spent[key].Add(amount)
+
+		for _, ancestor := range tree.Ancestors(spending.CategoryID) {
+			key := categoryMonth{ancestor.ID, spendingMonth}
+			spent[key] = spent[key].Add(amount)
+		}
+	}
+
+	budgetsByCategoryMonth := map[categoryMonth]Budget{}
+
+	for _, budget := range budgets {
+		budgetsByCategoryMonth[categoryMonth{budget.CategoryID, StartOfMonth(budget.Month)}] = budget
+	}
+
+	monthProgress := MonthProgress{Month: StartOfMonth(month)}
+
+	for _, budget := range budgets {
+		if !StartOfMonth(budget.Month).Equal(monthProgress.Month) {
+			continue
+		}
+
+		key := categoryMonth{budget.CategoryID, monthProgress.Month}
+
+		monthProgress.Progress = append(monthProgress.Progress, Progress{
+			Budget:       budget,
+			CategoryPath: tree.Path(budget.CategoryID),
+			RolledOver:   rolledOver(budget, budgetsByCategoryMonth, spent),
+			Spent:        spent[key],
+		})
+	}
+
+	sort.Slice(monthProgress.Progress, func(i, j int) bool {
+		return monthProgress.Progress[i].CategoryPath < monthProgress.Progress[j].CategoryPath
+	})
+
+	for currency := range missingRates {
+		monthProgress.MissingRates = append(monthProgress.MissingRates, currency)
+	}
+
+	sort.Strings(monthProgress.MissingRates)
+
+	return monthProgress
+}
+
+// rolledOver returns what was left of the budget of the previous month, which includes what rolled over into
+// that one. Overspending is not carried forward.
+func rolledOver(budget Budget, budgets map[categoryMonth]Budget, spent map[categoryMonth]decimal.Decimal) decimal.Decimal {
+	if !budget.Rollover {
+		return decimal.Zero
+	}
+
+	previousKey := categoryMonth{budget.CategoryID, StartOfMonth(budget.Month).AddDate(0, -1, 0)}
+	previous, ok := budgets[previousKey]
+
+	if !ok {
+		return decimal.Zero
+	}
+
+	left := previous.Amount.Add(rolledOver(previous, budgets, spent)).Sub(spent[previousKey])
+
+	return decimal.Max(decimal.Zero, left)
+}
@@ -0,0 +1,115 @@
+package categories
+
+import (
+	"errors"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/users"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const maxCategoryNameLength = 255
+
+func RegisterCategoryRoutes(e *echo.Echo, categoryRepository CategoryRepository) {
+
+	log := e.Logger
+
+	e.GET("/categories", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		allCategories, err := categoryRepository.ListAll(c.Request().Context(), user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list categories: %v", err)
+			return c.String(500, "Something went wrong when listing categories...")
+		}
+
+		return layout.RenderPage(c, 200, CategoriesPage(NewTree(allCategories), CategoryForm{}))
+	})
+
+	e.POST("/categories", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+		form := CategoryForm{Name: strings.TrimSpace(c.FormValue("name")), ParentID: c.FormValue("parent")}
+
+		var parentID *int
+
+		if form.ParentID != "" {
+			id, err := strconv.Atoi(form.ParentID)
+
+			if err != nil {
+				return c.String(400, "Invalid parent category id")
+			}
+
+			parentID = &id
+		}
+
+		status := 200
+
+		switch {
+		case form.Name == "":
+			form.NameError = "Enter a name"
+			status = 422
+		case len(form.Name) > maxCategoryNameLength:
+			form.NameError = "The name is too long"
+			status = 422
+		default:
+			_, err := categoryRepository.Create(ctx, user.ID, parentID, form.Name)
+
+			if errors.Is(err, ErrCategoryExists) {
+				form.NameError = err.Error()
+				status = 422
+			} else if errors.Is(err, pgx.ErrNoRows) {
+				return c.String(404, "Parent category not found")
+			} else if err != nil {
+				log.Errorf("Failed to create category: %v", err)
+				return c.String(500, "Something went wrong when creating the category...")
+			} else {
+				form = CategoryForm{}
+			}
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list categories: %v", err)
+			return c.String(500, "Something went wrong when listing categories...")
+		}
+
+		return layout.RenderComponent(c, status, CategoriesSection(NewTree(allCategories), form))
+	})
+
+	e.DELETE("/categories/:id", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid category id")
+		}
+
+		err = categoryRepository.Delete(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Category not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to delete category: %v", err)
+			return c.String(500, "Something went wrong when deleting the category...")
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list categories: %v", err)
+			return c.String(500, "Something went wrong when listing categories...")
+		}
+
+		return layout.RenderComponent(c, 200, CategoriesSection(NewTree(allCategories), CategoryForm{}))
+	})
+}
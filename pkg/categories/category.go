@@ -0,0 +1,97 @@
+package categories
+
+import (
+	"sort"
+	"strings"
+)
+
+// Category groups transactions. Categories can be nested, e.g. Groceries inside of Food.
+type Category struct {
+	ID       int
+	UserID   int
+	ParentID *int
+	Name     string
+}
+
+// Tree gives access to the hierarchy of a user's categories
+type Tree struct {
+	byID     map[int]Category
+	children map[int][]Category
+}
+
+// NewTree builds the hierarchy of the categories. Children are sorted by name.
+func NewTree(categories []Category) Tree {
+	tree := Tree{byID: map[int]Category{}, children: map[int][]Category{}}
+
+	for _, category := range categories {
+		tree.byID[category.ID] = category
+
+		// Top level categories are children of 0, which is never the id of a category
+		parentID := 0
+
+		if category.ParentID != nil {
+			parentID = *category.ParentID
+		}
+
+		tree.children[parentID] = append(tree.children[parentID], category)
+	}
+
+	for _, children := range tree.children {
+		sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	}
+
+	return tree
+}
+
+// Path returns the names of the category and its parents, e.g. "Food > Groceries", or "" for unknown ids
+func (t Tree) Path(id int) string {
+	var names []string
+
+	for category, ok := t.byID[id]; ok; {
+		names = append([]string{category.Name}, names...)
+
+		if category.ParentID == nil {
+			break
+		}
+
+		category, ok = t.byID[*category.ParentID]
+	}
+
+	return strings.Join(names, " > ")
+}
+
+// Ancestors returns the parents of the category, the closest one first
+func (t Tree) Ancestors(id int) []Category {
+	var ancestors []Category
+
+	for category, ok := t.byID[id]; ok && category.ParentID != nil; {
+		category, ok = t.byID[*category.ParentID]
+
+		if ok {
+			ancestors = append(ancestors, category)
+		}
+	}
+
+	return ancestors
+}
+
+// Flatten returns all categories in the order they appear in the tree, along with their depth
+func (t Tree) Flatten() []TreeEntry {
+	var entries []TreeEntry
+	t.flatten(0, 0, &entries)
+
+	return entries
+}
+
+type TreeEntry struct {
+	Category Category
+	Depth    int
+	Path     string
+}
+
+func (t Tree) flatten(parentID int, depth int, entries *[]TreeEntry) {
+	for _, category := range t.children[parentID] {
+		*entries = append(*entries, TreeEntry{Category: category, Depth: depth, Path: t.Path(category.ID)})
+		t.flatten(category.ID, depth+1, entries)
+	}
+}
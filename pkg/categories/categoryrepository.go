@@ -0,0 +1,143 @@
+package categories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrCategoryExists = errors.New("A category with this name already exists here")
+
+type CategoryRepository interface {
+	ListAll(ctx context.Context, userID int) ([]Category, error)
+	FindByID(ctx context.Context, userID int, id int) (Category, error)
+	// Create returns ErrCategoryExists if the parent already has a category with the same name
+	Create(ctx context.Context, userID int, parentID *int, name string) (Category, error)
+	// Delete removes the category along with all categories inside of it
+	Delete(ctx context.Context, userID int, id int) error
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) CategoryRepository
+}
+
+type categoryRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewCategoryRepository(pool *pgxpool.Pool, log echo.Logger) CategoryRepository {
+	return &categoryRepositoryImpl{pool, log}
+}
+
+func (r *categoryRepositoryImpl) WithTx(tx pgx.Tx) CategoryRepository {
+	return &categoryRepositoryImpl{tx, r.log}
+}
+
+const categoryColumns = `id, user_id, parent_id, name`
+
+func (r *categoryRepositoryImpl) ListAll(ctx context.Context, userID int) ([]Category, error) {
+	r.log.Debugf("Attempting to list all categories of User with id='%d'", userID)
+
+	query := `SELECT ` + categoryColumns + ` FROM category WHERE user_id = $1 ORDER BY name, id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+
+	if err != nil {
+		return []Category{}, fmt.Errorf("Failed to list all categories of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var allCategories []Category
+
+	for rows.Next() {
+		category, err := scanCategory(rows)
+
+		if err != nil {
+			return []Category{}, fmt.Errorf("Failed to read category row: %w", err)
+		}
+
+		allCategories = append(allCategories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []Category{}, fmt.Errorf("Failed to read category rows: %w", err)
+	}
+
+	return allCategories, nil
+}
+
+func (r *categoryRepositoryImpl) FindByID(ctx context.Context, userID int, id int) (Category, error) {
+	r.log.Debugf("Attempting to find Category with id='%d'", id)
+
+	query := `SELECT ` + categoryColumns + ` FROM category WHERE id = $1 AND user_id = $2`
+
+	category, err := scanCategory(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return Category{}, fmt.Errorf("Failed to find Category with id='%d': %w", id, err)
+	}
+
+	return category, nil
+}
+
+func (r *categoryRepositoryImpl) Create(ctx context.Context, userID int, parentID *int, name string) (Category, error) {
+	r.log.Debugf("Attempting to create a new Category")
+
+	// The parent has to belong to the same user
+	query := `
+	INSERT INTO category (user_id, parent_id, name)
+	SELECT $1, $2, $3
+	WHERE $2::int IS NULL OR EXISTS (SELECT 1 FROM category WHERE id = $2 AND user_id = $1)
+	RETURNING ` + categoryColumns
+
+	category, err := scanCategory(r.db.QueryRow(ctx, query, userID, parentID, name))
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return Category{}, ErrCategoryExists
+	}
+
+	if err != nil {
+		return Category{}, fmt.Errorf("Failed to create a new Category: %w", err)
+	}
+
+	r.log.Debugf("Created new Category with id='%d'", category.ID)
+
+	return category, nil
+}
+
+func (r *categoryRepositoryImpl) Delete(ctx context.Context, userID int, id int) error {
+	r.log.Debugf("Attempting to delete Category with id='%d'", id)
+
+	commandTag, err := r.db.Exec(ctx, `DELETE FROM category WHERE id = $1 AND user_id = $2`, id, userID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete Category with id='%d': %w", id, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("Failed to delete Category with id='%d': %w", id, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func scanCategory(row pgx.Row) (Category, error) {
+	var category Category
+
+	err := row.Scan(
+		&category.ID,
+		&category.UserID,
+		&category.ParentID,
+		&category.Name,
+	)
+
+	return category, err
+}
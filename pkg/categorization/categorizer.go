@@ -0,0 +1,78 @@
+package categorization
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/transactions"
+)
+
+// Categorizer applies the rules of a user to their transactions
+type Categorizer struct {
+	ruleRepository        RuleRepository
+	transactionRepository transactions.TransactionRepository
+}
+
+// NewCategorizer works with repositories bound to a transaction as well, e.g. during a sync
+func NewCategorizer(ruleRepository RuleRepository, transactionRepository transactions.TransactionRepository) *Categorizer {
+	return &Categorizer{ruleRepository, transactionRepository}
+}
+
+// Preview returns what Categorize would change about the transactions, without changing anything
+func (c *Categorizer) Preview(ctx context.Context, userID int, allTransactions []transactions.DbTransaction) ([]Change, error) {
+	if len(allTransactions) == 0 {
+		return []Change{}, nil
+	}
+
+	rules, err := c.ruleRepository.ListAll(ctx, userID)
+
+	if err != nil {
+		return []Change{}, err
+	}
+
+	if len(rules) == 0 {
+		return []Change{}, nil
+	}
+
+	engine, err := NewEngine(rules)
+
+	if err != nil {
+		return []Change{}, err
+	}
+
+	transactionIDs := make([]int64, 0, len(allTransactions))
+
+	for _, transaction := range allTransactions {
+		transactionIDs = append(transactionIDs, transaction.ID)
+	}
+
+	existingTags, err := c.transactionRepository.ListTags(ctx, transactionIDs)
+
+	if err != nil {
+		return []Change{}, err
+	}
+
+	return engine.Changes(allTransactions, existingTags), nil
+}
+
+// Categorize applies the user's rules to the transactions and returns what was changed
+func (c *Categorizer) Categorize(ctx context.Context, userID int, allTransactions []transactions.DbTransaction) ([]Change, error) {
+	changes, err := c.Preview(ctx, userID, allTransactions)
+
+	if err != nil {
+		return []Change{}, err
+	}
+
+	for _, change := range changes {
+		update := transactions.CategorizationUpdate{
+			CategoryID: change.CategoryID,
+			Payee:      change.Payee,
+			AddTags:    change.AddTags,
+		}
+
+		if err := c.transactionRepository.UpdateCategorization(ctx, change.Transaction.ID, update); err != nil {
+			return []Change{}, fmt.Errorf("Failed to categorize transactions of User with id='%d': %w", userID, err)
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,180 @@
+package categorization
+
+import (
+	"fmt"
+	"nerdmoney/pkg/transactions"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Engine decides how transactions are categorized, based on the rules of a user
+type Engine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	descriptionRegex *regexp.Regexp
+	merchant         string
+}
+
+// NewEngine prepares the rules, which have to be ordered by priority already
+func NewEngine(rules []Rule) (*Engine, error) {
+	engine := &Engine{rules: make([]compiledRule, 0, len(rules))}
+
+	for _, rule := range rules {
+		compiled := compiledRule{Rule: rule}
+
+		if rule.DescriptionRegex != nil {
+			descriptionRegex, err := regexp.Compile(*rule.DescriptionRegex)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid description regex of categorization rule with id='%d': %w", rule.ID, err)
+			}
+
+			compiled.descriptionRegex = descriptionRegex
+		}
+
+		if rule.Merchant != nil {
+			compiled.merchant = strings.ToLower(*rule.Merchant)
+		}
+
+		engine.rules = append(engine.rules, compiled)
+	}
+
+	return engine, nil
+}
+
+// Result is what the rules decided for a transaction. The first matching rule which sets a category
+// or a payee decides it, the tags of all matching rules are added.
+type Result struct {
+	CategoryID *int
+	Payee      *string
+	Tags       []string
+}
+
+func (e *Engine) Apply(transaction transactions.DbTransaction) Result {
+	var result Result
+
+	for _, rule := range e.rules {
+		if !rule.matches(transaction) {
+			continue
+		}
+
+		if result.CategoryID == nil {
+			result.CategoryID = rule.SetCategoryID
+		}
+
+		if result.Payee == nil {
+			result.Payee = rule.SetPayee
+		}
+
+		for _, tag := range rule.AddTags {
+			if !slices.Contains(result.Tags, tag) {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+
+	return result
+}
+
+// CountMatches returns how many of the transactions each rule matches, by rule id
+func (e *Engine) CountMatches(allTransactions []transactions.DbTransaction) map[int]int {
+	counts := map[int]int{}
+
+	for _, rule := range e.rules {
+		for _, transaction := range allTransactions {
+			if rule.matches(transaction) {
+				counts[rule.ID]++
+			}
+		}
+	}
+
+	return counts
+}
+
+func (r compiledRule) matches(transaction transactions.DbTransaction) bool {
+	if r.Merchant != nil {
+		merchant := transaction.Name
+
+		if transaction.MerchantName != nil {
+			merchant = *transaction.MerchantName
+		}
+
+		if !strings.Contains(strings.ToLower(merchant), r.merchant) {
+			return false
+		}
+	}
+
+	if r.descriptionRegex != nil && !r.descriptionRegex.MatchString(transaction.Name) {
+		return false
+	}
+
+	// Incoming money is positive for users, the provider's amounts are the other way around
+	amount := transaction.Amount.Neg()
+
+	if r.MinAmount.Valid && amount.LessThan(r.MinAmount.Decimal) {
+		return false
+	}
+
+	if r.MaxAmount.Valid && amount.GreaterThan(r.MaxAmount.Decimal) {
+		return false
+	}
+
+	if r.BankAccountID != nil && *r.BankAccountID != transaction.BankAccountID {
+		return false
+	}
+
+	if r.ProviderCategory != nil && (transaction.Category == nil || *transaction.Category != *r.ProviderCategory) {
+		return false
+	}
+
+	return true
+}
+
+// Change is what applying the rules changes about a transaction. Fields which stay the same are nil.
+type Change struct {
+	Transaction transactions.DbTransaction
+	CategoryID  *int
+	Payee       *string
+	AddTags     []string
+}
+
+func (c Change) IsEmpty() bool {
+	return c.CategoryID == nil && c.Payee == nil && len(c.AddTags) == 0
+}
+
+// Changes returns the transactions the rules would change, given the tags they have already.
+// Categories picked by the user are never changed, and neither are the payees of the transactions they categorized.
+func (e *Engine) Changes(allTransactions []transactions.DbTransaction, existingTags map[int64][]string) []Change {
+	var changes []Change
+
+	for _, transaction := range allTransactions {
+		result := e.Apply(transaction)
+		change := Change{Transaction: transaction}
+
+		userPickedCategory := transaction.CategorySource != nil && *transaction.CategorySource == transactions.CategorySourceUser
+
+		if result.CategoryID != nil && !userPickedCategory && (transaction.CategoryID == nil || *transaction.CategoryID != *result.CategoryID) {
+			change.CategoryID = result.CategoryID
+		}
+
+		if result.Payee != nil && !userPickedCategory && (transaction.Payee == nil || *transaction.Payee != *result.Payee) {
+			change.Payee = result.Payee
+		}
+
+		for _, tag := range result.Tags {
+			if !slices.Contains(existingTags[transaction.ID], tag) {
+				change.AddTags = append(change.AddTags, tag)
+			}
+		}
+
+		if !change.IsEmpty() {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,84 @@
+package categorization
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rule categorizes the transactions which match all of its conditions that are set.
+// Amounts are compared as shown to users, with incoming money being positive.
+type Rule struct {
+	ID       int
+	UserID   int
+	Name     string
+	Priority int
+
+	// Conditions
+	// Merchant has to be part of the merchant name, or the description if there is none, ignoring case
+	Merchant         *string
+	DescriptionRegex *string
+	MinAmount        decimal.NullDecimal
+	MaxAmount        decimal.NullDecimal
+	BankAccountID    *int
+	ProviderCategory *string
+
+	// Actions
+	SetCategoryID *int
+	AddTags       []string
+	SetPayee      *string
+
+	CreatedAt time.Time
+}
+
+type RuleWriteModel struct {
+	Name             string
+	Priority         int
+	Merchant         *string
+	DescriptionRegex *string
+	MinAmount        decimal.NullDecimal
+	MaxAmount        decimal.NullDecimal
+	BankAccountID    *int
+	ProviderCategory *string
+	SetCategoryID    *int
+	AddTags          []string
+	SetPayee         *string
+}
+
+var (
+	ErrRuleWithoutName       = errors.New("Give the rule a name")
+	ErrRuleWithoutCondition  = errors.New("A rule needs at least one condition")
+	ErrRuleWithoutAction     = errors.New("A rule needs to set a category, a payee or tags")
+	ErrInvalidRuleRegex      = errors.New("The description pattern is not a valid regular expression")
+	ErrInvalidRuleAmountSpan = errors.New("The minimum amount has to be less than the maximum amount")
+)
+
+// Validate returns the first problem with the rule, or nil if there is none
+func (wm RuleWriteModel) Validate() error {
+	if wm.Name == "" {
+		return ErrRuleWithoutName
+	}
+
+	if wm.Merchant == nil && wm.DescriptionRegex == nil && !wm.MinAmount.Valid && !wm.MaxAmount.Valid &&
+		wm.BankAccountID == nil && wm.ProviderCategory == nil {
+		return ErrRuleWithoutCondition
+	}
+
+	if wm.SetCategoryID == nil && wm.SetPayee == nil && len(wm.AddTags) == 0 {
+		return ErrRuleWithoutAction
+	}
+
+	if wm.DescriptionRegex != nil {
+		if _, err := regexp.Compile(*wm.DescriptionRegex); err != nil {
+			return ErrInvalidRuleRegex
+		}
+	}
+
+	if wm.MinAmount.Valid && wm.MaxAmount.Valid && wm.MinAmount.Decimal.GreaterThan(wm.MaxAmount.Decimal) {
+		return ErrInvalidRuleAmountSpan
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package categorization
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type RuleRepository interface {
+	// ListAll returns the user's rules in the order they are applied
+	ListAll(ctx context.Context, userID int) ([]Rule, error)
+	// Create saves a new rule. Accounts and categories of other users are not allowed in it.
+	Create(ctx context.Context, userID int, writeModel RuleWriteModel) (Rule, error)
+	Delete(ctx context.Context, userID int, id int) error
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) RuleRepository
+}
+
+type ruleRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewRuleRepository(pool *pgxpool.Pool, log echo.Logger) RuleRepository {
+	return &ruleRepositoryImpl{pool, log}
+}
+
+func (r *ruleRepositoryImpl) WithTx(tx pgx.Tx) RuleRepository {
+	return &ruleRepositoryImpl{tx, r.log}
+}
+
+const ruleColumns = `id, user_id, name, priority, merchant, description_regex, min_amount, max_amount, bank_account_id, provider_category,
+	set_category_id, add_tags, set_payee, created_at`
+
+func (r *ruleRepositoryImpl) ListAll(ctx context.Context, userID int) ([]Rule, error) {
+	r.log.Debugf("Attempting to list all categorization rules of User with id='%d'", userID)
+
+	query := `SELECT ` + ruleColumns + ` FROM categorization_rule WHERE user_id = $1 ORDER BY priority, id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+
+	if err != nil {
+		return []Rule{}, fmt.Errorf("Failed to list all categorization rules of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var rules []Rule
+
+	for rows.Next() {
+		rule, err := scanRule(rows)
+
+		if err != nil {
+			return []Rule{}, fmt.Errorf("Failed to read categorization rule row: %w", err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []Rule{}, fmt.Errorf("Failed to read categorization rule rows: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (r *ruleRepositoryImpl) Create(ctx context.Context, userID int, writeModel RuleWriteModel) (Rule, error) {
+	r.log.Debugf("Attempting to create a new categorization rule")
+
+	query := `
+	INSERT INTO categorization_rule (
+		user_id, name, priority, merchant, description_regex, min_amount, max_amount, bank_account_id, provider_category,
+		set_category_id, add_tags, set_payee
+	)
+	SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+	WHERE ($8::int IS NULL OR EXISTS (SELECT 1 FROM bank_account WHERE id = $8 AND user_id = $1))
+		AND ($10::int IS NULL OR EXISTS (SELECT 1 FROM category WHERE id = $10 AND user_id = $1))
+	RETURNING ` + ruleColumns
+
+	addTags := writeModel.AddTags
+
+	if addTags == nil {
+		addTags = []string{}
+	}
+
+	rule, err := scanRule(r.db.QueryRow(
+		ctx,
+		query,
+		userID,
+		writeModel.Name,
+		writeModel.Priority,
+		writeModel.Merchant,
+		writeModel.DescriptionRegex,
+		writeModel.MinAmount,
+		writeModel.MaxAmount,
+		writeModel.BankAccountID,
+		writeModel.ProviderCategory,
+		writeModel.SetCategoryID,
+		addTags,
+		writeModel.SetPayee,
+	))
+
+	if err != nil {
+		return Rule{}, fmt.Errorf("Failed to create a new categorization rule: %w", err)
+	}
+
+	r.log.Debugf("Created new categorization rule with id='%d'", rule.ID)
+
+	return rule, nil
+}
+
+func (r *ruleRepositoryImpl) Delete(ctx context.Context, userID int, id int) error {
+	r.log.Debugf("Attempting to delete categorization rule with id='%d'", id)
+
+	commandTag, err := r.db.Exec(ctx, `DELETE FROM categorization_rule WHERE id = $1 AND user_id = $2`, id, userID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete categorization rule with id='%d': %w", id, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("Failed to delete categorization rule with id='%d': %w", id, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func scanRule(row pgx.Row) (Rule, error) {
+	var rule Rule
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.UserID,
+		&rule.Name,
+		&rule.Priority,
+		&rule.Merchant,
+		&rule.DescriptionRegex,
+		&rule.MinAmount,
+		&rule.MaxAmount,
+		&rule.BankAccountID,
+		&rule.ProviderCategory,
+		&rule.SetCategoryID,
+		&rule.AddTags,
+		&rule.SetPayee,
+		&rule.CreatedAt,
+	)
+
+	return rule, err
+}
@@ -0,0 +1,405 @@
+package categorization
+
+import (
+	"context"
+	"errors"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/categories"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/users"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+func RegisterRuleRoutes(
+	e *echo.Echo,
+	ruleRepository RuleRepository,
+	transactionRepository transactions.TransactionRepository,
+	categoryRepository categories.CategoryRepository,
+	bankAccountRepository repositories.BankAccountRepository,
+) {
+
+	log := e.Logger
+	categorizer := NewCategorizer(ruleRepository, transactionRepository)
+
+	allTransactions := func(ctx context.Context, userID int) ([]transactions.DbTransaction, error) {
+		return transactions.ListAllPages(ctx, transactionRepository, transactions.TransactionFilter{UserID: userID})
+	}
+
+	loadView := func(ctx context.Context, userID int, form RuleForm) (rulesView, error) {
+		view := rulesView{Form: form}
+
+		rules, err := ruleRepository.ListAll(ctx, userID)
+
+		if err != nil {
+			return rulesView{}, err
+		}
+
+		engine, err := NewEngine(rules)
+
+		if err != nil {
+			return rulesView{}, err
+		}
+
+		userTransactions, err := allTransactions(ctx, userID)
+
+		if err != nil {
+			return rulesView{}, err
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, userID)
+
+		if err != nil {
+			return rulesView{}, err
+		}
+
+		view.Accounts, err = bankAccountRepository.ListAll(ctx, userID)
+
+		if err != nil {
+			return rulesView{}, err
+		}
+
+		view.ProviderCategories, err = transactionRepository.ListProviderCategories(ctx, userID)
+
+		if err != nil {
+			return rulesView{}, err
+		}
+
+		view.Rules = rules
+		view.MatchCounts = engine.CountMatches(userTransactions)
+		view.Categories = categories.NewTree(allCategories)
+
+		return view, nil
+	}
+
+	e.GET("/rules", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		view, err := loadView(c.Request().Context(), user.ID, RuleForm{})
+
+		if err != nil {
+			log.Errorf("Failed to load categorization rules: %v", err)
+			return c.String(500, "Something went wrong when listing rules...")
+		}
+
+		return layout.RenderPage(c, 200, RulesPage(view))
+	})
+
+	e.POST("/rules", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		form, writeModel := parseRuleForm(c)
+		status := 200
+
+		if !form.hasErrors() {
+			_, err := ruleRepository.Create(ctx, user.ID, writeModel)
+
+			if errors.Is(err, pgx.ErrNoRows) {
+				return c.String(404, "Account or category not found")
+			}
+
+			if err != nil {
+				log.Errorf("Failed to create categorization rule: %v", err)
+				return c.String(500, "Something went wrong when creating the rule...")
+			}
+
+			form = RuleForm{}
+		} else {
+			status = 422
+		}
+
+		view, err := loadView(ctx, user.ID, form)
+
+		if err != nil {
+			log.Errorf("Failed to load categorization rules: %v", err)
+			return c.String(500, "Something went wrong when listing rules...")
+		}
+
+		return layout.RenderComponent(c, status, RulesSection(view))
+	})
+
+	// How many transactions the rule in the form matches, updated while the user fills in the form
+	e.POST("/rules/match-count", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		form, writeModel := parseRuleForm(c)
+
+		if form.conditionError() != "" {
+			return layout.RenderComponent(c, 200, ruleMatchCount(-1))
+		}
+
+		engine, err := NewEngine([]Rule{ruleFromWriteModel(writeModel)})
+
+		if err != nil {
+			return layout.RenderComponent(c, 200, ruleMatchCount(-1))
+		}
+
+		userTransactions, err := allTransactions(c.Request().Context(), user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list transactions: %v", err)
+			return c.String(500, "Something went wrong when counting matching transactions...")
+		}
+
+		return layout.RenderComponent(c, 200, ruleMatchCount(engine.CountMatches(userTransactions)[0]))
+	})
+
+	e.DELETE("/rules/:id", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid rule id")
+		}
+
+		err = ruleRepository.Delete(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Rule not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to delete categorization rule: %v", err)
+			return c.String(500, "Something went wrong when deleting the rule...")
+		}
+
+		view, err := loadView(ctx, user.ID, RuleForm{})
+
+		if err != nil {
+			log.Errorf("Failed to load categorization rules: %v", err)
+			return c.String(500, "Something went wrong when listing rules...")
+		}
+
+		return layout.RenderComponent(c, 200, RulesSection(view))
+	})
+
+	// Dry run of applying the rules to all transactions
+	e.GET("/rules/apply", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		userTransactions, err := allTransactions(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list transactions: %v", err)
+			return c.String(500, "Something went wrong when previewing the rules...")
+		}
+
+		changes, err := categorizer.Preview(ctx, user.ID, userTransactions)
+
+		if err != nil {
+			log.Errorf("Failed to preview categorization rules: %v", err)
+			return c.String(500, "Something went wrong when previewing the rules...")
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list categories: %v", err)
+			return c.String(500, "Something went wrong when previewing the rules...")
+		}
+
+		return layout.RenderComponent(c, 200, RuleApplyPreview(changes, categories.NewTree(allCategories)))
+	})
+
+	e.POST("/rules/apply", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		userTransactions, err := allTransactions(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list transactions: %v", err)
+			return c.String(500, "Something went wrong when applying the rules...")
+		}
+
+		changes, err := categorizer.Categorize(ctx, user.ID, userTransactions)
+
+		if err != nil {
+			log.Errorf("Failed to apply categorization rules: %v", err)
+			return c.String(500, "Something went wrong when applying the rules...")
+		}
+
+		return layout.RenderComponent(c, 200, RuleApplyResult(len(changes)))
+	})
+}
+
+// RuleForm holds what the user entered into the new rule form, along with validation errors
+type RuleForm struct {
+	Name             string
+	Priority         string
+	Merchant         string
+	DescriptionRegex string
+	MinAmount        string
+	MaxAmount        string
+	Account          string
+	ProviderCategory string
+	Category         string
+	Tags             string
+	Payee            string
+
+	NameError             string
+	PriorityError         string
+	DescriptionRegexError string
+	AmountError           string
+	// Problems with the rule as a whole, e.g. a rule without conditions
+	Error string
+}
+
+func (f RuleForm) hasErrors() bool {
+	return f.NameError != "" || f.PriorityError != "" || f.conditionError() != "" || f.Error != ""
+}
+
+func (f RuleForm) conditionError() string {
+	if f.DescriptionRegexError != "" {
+		return f.DescriptionRegexError
+	}
+
+	return f.AmountError
+}
+
+func parseRuleForm(c echo.Context) (RuleForm, RuleWriteModel) {
+	form := RuleForm{
+		Name:             strings.TrimSpace(c.FormValue("name")),
+		Priority:         strings.TrimSpace(c.FormValue("priority")),
+		Merchant:         strings.TrimSpace(c.FormValue("merchant")),
+		DescriptionRegex: strings.TrimSpace(c.FormValue("description_regex")),
+		MinAmount:        strings.TrimSpace(c.FormValue("min_amount")),
+		MaxAmount:        strings.TrimSpace(c.FormValue("max_amount")),
+		Account:          c.FormValue("account"),
+		ProviderCategory: strings.TrimSpace(c.FormValue("provider_category")),
+		Category:         c.FormValue("category"),
+		Tags:             strings.TrimSpace(c.FormValue("tags")),
+		Payee:            strings.TrimSpace(c.FormValue("payee")),
+	}
+
+	writeModel := RuleWriteModel{
+		Name:             form.Name,
+		Merchant:         optionalString(form.Merchant),
+		DescriptionRegex: optionalString(form.DescriptionRegex),
+		ProviderCategory: optionalString(form.ProviderCategory),
+		SetPayee:         optionalString(form.Payee),
+		AddTags:          parseTags(form.Tags),
+	}
+
+	if form.Priority != "" {
+		priority, err := strconv.Atoi(form.Priority)
+
+		if err != nil {
+			form.PriorityError = "Enter a whole number"
+		}
+
+		writeModel.Priority = priority
+	}
+
+	if form.MinAmount != "" {
+		minAmount, err := decimal.NewFromString(form.MinAmount)
+
+		if err != nil {
+			form.AmountError = "Enter a valid amount"
+		}
+
+		writeModel.MinAmount = decimal.NullDecimal{Decimal: minAmount, Valid: err == nil}
+	}
+
+	if form.MaxAmount != "" {
+		maxAmount, err := decimal.NewFromString(form.MaxAmount)
+
+		if err != nil {
+			form.AmountError = "Enter a valid amount"
+		}
+
+		writeModel.MaxAmount = decimal.NullDecimal{Decimal: maxAmount, Valid: err == nil}
+	}
+
+	if bankAccountID, err := strconv.Atoi(form.Account); err == nil {
+		writeModel.BankAccountID = &bankAccountID
+	}
+
+	if categoryID, err := strconv.Atoi(form.Category); err == nil {
+		writeModel.SetCategoryID = &categoryID
+	}
+
+	if form.hasErrors() {
+		return form, writeModel
+	}
+
+	switch err := writeModel.Validate(); {
+	case errors.Is(err, ErrRuleWithoutName):
+		form.NameError = err.Error()
+	case errors.Is(err, ErrInvalidRuleRegex):
+		form.DescriptionRegexError = err.Error()
+	case errors.Is(err, ErrInvalidRuleAmountSpan):
+		form.AmountError = err.Error()
+	case err != nil:
+		form.Error = err.Error()
+	}
+
+	return form, writeModel
+}
+
+// ruleFromWriteModel is used to try out a rule before it is saved
+func ruleFromWriteModel(writeModel RuleWriteModel) Rule {
+	return Rule{
+		Name:             writeModel.Name,
+		Priority:         writeModel.Priority,
+		Merchant:         writeModel.Merchant,
+		DescriptionRegex: writeModel.DescriptionRegex,
+		MinAmount:        writeModel.MinAmount,
+		MaxAmount:        writeModel.MaxAmount,
+		BankAccountID:    writeModel.BankAccountID,
+		ProviderCategory: writeModel.ProviderCategory,
+		SetCategoryID:    writeModel.SetCategoryID,
+		AddTags:          writeModel.AddTags,
+		SetPayee:         writeModel.SetPayee,
+	}
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// parseTags splits a comma separated list of tags
+func parseTags(source string) []string {
+	var tags []string
+
+	for _, tag := range strings.Split(source, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+type rulesView struct {
+	Rules              []Rule
+	MatchCounts        map[int]int
+	Categories         categories.Tree
+	Accounts           []models.BankAccount
+	ProviderCategories []string
+	Form               RuleForm
+}
+
+func (v rulesView) accountName(bankAccountID int) string {
+	for _, account := range v.Accounts {
+		if account.ID == bankAccountID {
+			return account.Name
+		}
+	}
+
+	return "an unknown account"
+}
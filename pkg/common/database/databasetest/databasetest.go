@@ -0,0 +1,114 @@
+// Package databasetest sets up the Postgres database for tests which need one.
+package databasetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/categorization"
+	"nerdmoney/pkg/common/secrets"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/networth"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/unitofwork"
+	"nerdmoney/pkg/users"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/labstack/echo/v4"
+)
+
+// Open connects to the database in TEST_DATABASE_URL, drops everything in it and runs all migrations.
+// Tests calling it are skipped when the variable is not set. Never point it at a database with real data.
+func Open(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, databaseURL)
+
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(ctx, `DROP SCHEMA public CASCADE; CREATE SCHEMA public`); err != nil {
+		t.Fatalf("Failed to empty the test database: %v", err)
+	}
+
+	driver, err := pgx.WithInstance(stdlib.OpenDBFromPool(pool), &pgx.Config{})
+
+	if err != nil {
+		t.Fatalf("Could not create SQL migration driver: %v", err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance("file://"+migrationsDir(), "pgx", driver)
+
+	if err != nil {
+		t.Fatalf("Could not create migration client: %v", err)
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("Failed to run the migrations: %v", err)
+	}
+
+	return pool
+}
+
+// NewUnitOfWork wires all repositories to the pool like the app does
+func NewUnitOfWork(t testing.TB, pool *pgxpool.Pool) unitofwork.UnitOfWork {
+	t.Helper()
+
+	log := echo.New().Logger
+
+	keyring, err := secrets.NewKeyring(map[string][]byte{"test": make([]byte, 32)}, "test")
+
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+
+	return unitofwork.New(pool, unitofwork.Repos{
+		BankConnections:     repositories.NewBankConnectionRepository(pool, keyring, log),
+		BankAccounts:        repositories.NewBankAccountRepository(pool, log),
+		BankAccountNumbers:  repositories.NewBankAccountNumberRepository(pool, log),
+		Transactions:        transactions.NewTransactionRepository(pool, log),
+		SyncRuns:            transactions.NewSyncRunRepository(pool, log),
+		Imports:             transactions.NewImportRepository(pool, log),
+		BalanceSnapshots:    networth.NewBalanceSnapshotRepository(pool, log),
+		CategorizationRules: categorization.NewRuleRepository(pool, log),
+		ExchangeRates:       exchangerates.NewExchangeRateRepository(pool, log),
+	}, log)
+}
+
+// CreateUser adds a user who owns the data a test creates
+func CreateUser(t testing.TB, pool *pgxpool.Pool, email string) users.User {
+	t.Helper()
+
+	user, err := users.NewUserRepository(pool, echo.New().Logger).Create(context.Background(), email, "not a password hash")
+
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	return user
+}
+
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "..", "db", "migrations")
+}
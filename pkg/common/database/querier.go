@@ -0,0 +1,20 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Querier is implemented by both *pgxpool.Pool and pgx.Tx, so repositories can run
+// the same queries either directly on the pool or as part of a transaction.
+// Calling Begin on a pgx.Tx starts a savepoint, which lets repository methods that need
+// a transaction of their own be used inside a larger one.
+type Querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryBuilder collects the conditions and arguments of a query whose filters are only known at runtime.
+//
+//	var q database.QueryBuilder
+//	q.Where("user_id = " + q.Arg(userID))
+//	rows, err := db.Query(ctx, "SELECT ... FROM x"+q.WhereClause(), q.Args()...)
+type QueryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// Arg adds an argument to the query and returns its placeholder
+func (q *QueryBuilder) Arg(value any) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// Where adds a condition that all rows have to match. Use Arg to get placeholders for its values.
+func (q *QueryBuilder) Where(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+// WhereClause returns " WHERE " followed by all conditions, or nothing if there are none
+func (q *QueryBuilder) WhereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+func (q *QueryBuilder) Args() []any {
+	return q.args
+}
+
+// EscapeLike escapes the wildcards of a LIKE pattern, so that the value only matches itself
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
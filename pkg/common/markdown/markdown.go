@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern       = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	unorderedPattern     = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedPattern       = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	linkPattern          = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^\s)]+)\)`)
+	strongPattern        = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	emphasisPattern      = regexp.MustCompile(`\*(.+?)\*`)
+	strikethroughPattern = regexp.MustCompile(`~~(.+?)~~`)
+)
+
+// ToHTML renders the markdown users write into notes: paragraphs, headings, lists, emphasis, inline code and
+// links to http(s) URLs. Everything else is shown as text, HTML in the source is escaped.
+func ToHTML(source string) string {
+	var out strings.Builder
+	var paragraph []string
+	list := ""
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + strings.Join(paragraph, "<br/>") + "</p>")
+			paragraph = nil
+		}
+	}
+
+	closeList := func() {
+		if list != "" {
+			out.WriteString("</" + list + ">")
+			list = ""
+		}
+	}
+
+	openList := func(tag string) {
+		flushParagraph()
+
+		if list != tag {
+			closeList()
+			out.WriteString("<" + tag + ">")
+			list = tag
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			flushParagraph()
+			closeList()
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+			closeList()
+			out.WriteString(fmt.Sprintf("<h%d>%s</h%d>", len(match[1]), inline(match[2]), len(match[1])))
+			continue
+		}
+
+		if match := unorderedPattern.FindStringSubmatch(line); match != nil {
+			openList("ul")
+			out.WriteString("<li>" + inline(match[1]) + "</li>")
+			continue
+		}
+
+		if match := orderedPattern.FindStringSubmatch(line); match != nil {
+			openList("ol")
+			out.WriteString("<li>" + inline(match[1]) + "</li>")
+			continue
+		}
+
+		closeList()
+		paragraph = append(paragraph, inline(line))
+	}
+
+	flushParagraph()
+	closeList()
+
+	return out.String()
+}
+
+// inline renders the markup within a line. Text between backticks is code and left as it is.
+func inline(text string) string {
+	var out strings.Builder
+
+	for i, part := range strings.Split(text, "`") {
+		// An unmatched backtick is just a backtick
+		isCode := i%2 == 1 && i < strings.Count(text, "`")
+
+		switch {
+		case isCode:
+			out.WriteString("<code>" + html.EscapeString(part) + "</code>")
+		case i%2 == 1:
+			out.WriteString("`" + links(part))
+		default:
+			out.WriteString(links(part))
+		}
+	}
+
+	return out.String()
+}
+
+// links turns links into anchors, emphasis is applied to the text around and inside of them but not to the URLs
+func links(text string) string {
+	var out strings.Builder
+	last := 0
+
+	for _, match := range linkPattern.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(emphasis(text[last:match[0]]))
+
+		label, url := text[match[2]:match[3]], text[match[4]:match[5]]
+		out.WriteString(fmt.Sprintf(`<a href="%s" rel="noopener noreferrer nofollow">%s</a>`, html.EscapeString(url), emphasis(label)))
+
+		last = match[1]
+	}
+
+	out.WriteString(emphasis(text[last:]))
+
+	return out.String()
+}
+
+func emphasis(text string) string {
+	text = html.EscapeString(text)
+	text = strongPattern.ReplaceAllString(text, "<strong>$1</strong>")
+	text = emphasisPattern.ReplaceAllString(text, "<em>$1</em>")
+	text = strikethroughPattern.ReplaceAllString(text, "<del>$1</del>")
+
+	return text
+}
@@ -0,0 +1,82 @@
+// Package ratelimit throttles calls to external APIs.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket which allows a number of events per period. Up to that number of
+// events may happen at once, after that they are spread evenly over the period.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+// New creates a Limiter allowing events per period, starting with a full bucket.
+func New(events int, period time.Duration) *Limiter {
+	return &Limiter{
+		interval: period / time.Duration(events),
+		burst:    float64(events),
+		tokens:   float64(events),
+		last:     time.Now(),
+	}
+}
+
+// Allow reports whether an event may happen now, and takes its token if so. Unlike Wait it never blocks.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+
+	return true
+}
+
+// Wait blocks until an event is allowed or the context is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+
+	l.refill()
+
+	// Taking the token up front reserves a slot, even when it only becomes available later
+	l.tokens--
+	delay := time.Duration(-l.tokens * float64(l.interval))
+
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refill adds the tokens which accrued since the last event, l.mu has to be held
+func (l *Limiter) refill() {
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	l.last = now
+}
@@ -0,0 +1,229 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const dataKeySize = 32
+
+// Keyring does envelope encryption: every value is encrypted with its own random data key,
+// and the data key is encrypted with one of the master keys held by the keyring. Rotating
+// a master key only requires re-encrypting the data keys, see Rewrap.
+type Keyring struct {
+	keys        map[string]cipher.AEAD
+	activeKeyID string
+}
+
+// EncryptedValue is everything needed to decrypt a value, given the master key with KeyID.
+type EncryptedValue struct {
+	Ciphertext       string
+	EncryptedDataKey string
+	KeyID            string
+}
+
+// ParseKeyring reads master keys in the format "keyId1:base64Key1,keyId2:base64Key2".
+// Every key must be 32 bytes long. New values are encrypted with the activeKeyID key.
+func ParseKeyring(keysSpec string, activeKeyID string) (*Keyring, error) {
+	keys := map[string][]byte{}
+
+	for _, keySpec := range strings.Split(keysSpec, ",") {
+		keySpec = strings.TrimSpace(keySpec)
+
+		if keySpec == "" {
+			continue
+		}
+
+		keyID, encodedKey, found := strings.Cut(keySpec, ":")
+
+		if !found || keyID == "" {
+			return nil, fmt.Errorf("Invalid key '%s', expected format 'keyId:base64Key'", redact(keySpec))
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode key with id='%s': %w", keyID, err)
+		}
+
+		keys[keyID] = key
+	}
+
+	return NewKeyring(keys, activeKeyID)
+}
+
+func NewKeyring(keys map[string][]byte, activeKeyID string) (*Keyring, error) {
+	keyring := &Keyring{keys: map[string]cipher.AEAD{}, activeKeyID: activeKeyID}
+
+	for keyID, key := range keys {
+		aead, err := newAEAD(key)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key with id='%s': %w", keyID, err)
+		}
+
+		keyring.keys[keyID] = aead
+	}
+
+	if _, ok := keyring.keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("Active key with id='%s' is not in the keyring", activeKeyID)
+	}
+
+	return keyring, nil
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeKeyID
+}
+
+// Encrypt encrypts plaintext with a new data key wrapped by the active master key.
+func (k *Keyring) Encrypt(plaintext string) (EncryptedValue, error) {
+	dataKey := make([]byte, dataKeySize)
+
+	if _, err := rand.Read(dataKey); err != nil {
+		return EncryptedValue{}, fmt.Errorf("Failed to generate data key: %w", err)
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+
+	ciphertext, err := seal(dataAEAD, []byte(plaintext), nil)
+
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+
+	encryptedDataKey, err := seal(k.keys[k.activeKeyID], dataKey, []byte(k.activeKeyID))
+
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+
+	return EncryptedValue{
+		Ciphertext:       base64.StdEncoding.EncodeToString(ciphertext),
+		EncryptedDataKey: base64.StdEncoding.EncodeToString(encryptedDataKey),
+		KeyID:            k.activeKeyID,
+	}, nil
+}
+
+func (k *Keyring) Decrypt(value EncryptedValue) (string, error) {
+	dataKey, err := k.dataKey(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value.Ciphertext)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode ciphertext: %w", err)
+	}
+
+	plaintext, err := open(dataAEAD, ciphertext, nil)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to decrypt value: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// Rewrap re-encrypts the data key of value with the active master key. The ciphertext itself is unchanged.
+func (k *Keyring) Rewrap(value EncryptedValue) (EncryptedValue, error) {
+	if value.KeyID == k.activeKeyID {
+		return value, nil
+	}
+
+	dataKey, err := k.dataKey(value)
+
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+
+	encryptedDataKey, err := seal(k.keys[k.activeKeyID], dataKey, []byte(k.activeKeyID))
+
+	if err != nil {
+		return EncryptedValue{}, err
+	}
+
+	return EncryptedValue{
+		Ciphertext:       value.Ciphertext,
+		EncryptedDataKey: base64.StdEncoding.EncodeToString(encryptedDataKey),
+		KeyID:            k.activeKeyID,
+	}, nil
+}
+
+func (k *Keyring) dataKey(value EncryptedValue) ([]byte, error) {
+	masterAEAD, ok := k.keys[value.KeyID]
+
+	if !ok {
+		return nil, fmt.Errorf("Key with id='%s' is not in the keyring", value.KeyID)
+	}
+
+	encryptedDataKey, err := base64.StdEncoding.DecodeString(value.EncryptedDataKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode data key: %w", err)
+	}
+
+	dataKey, err := open(masterAEAD, encryptedDataKey, []byte(value.KeyID))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decrypt data key with key id='%s': %w", value.KeyID, err)
+	}
+
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("Key must be %d bytes long, got %d", dataKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal returns the nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("Failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("Ciphertext is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func redact(keySpec string) string {
+	keyID, _, _ := strings.Cut(keySpec, ":")
+	return keyID + ":" + Redacted
+}
@@ -0,0 +1,4 @@
+package secrets
+
+// Redacted is what secrets are replaced with in log output
+const Redacted = "<redacted>"
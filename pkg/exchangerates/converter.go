@@ -0,0 +1,61 @@
+package exchangerates
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Converter converts amounts between currencies with the rates of a user
+type Converter struct {
+	// Rates ordered by the day they are valid from, by "FROM/TO"
+	rates map[string][]ExchangeRate
+}
+
+// NewConverter expects the rates to be ordered by the day they are valid from, as ListAll returns them
+func NewConverter(rates []ExchangeRate) Converter {
+	converter := Converter{rates: map[string][]ExchangeRate{}}
+
+	for _, rate := range rates {
+		key := rate.FromCurrency + "/" + rate.ToCurrency
+		converter.rates[key] = append(converter.rates[key], rate)
+	}
+
+	return converter
+}
+
+// Convert returns the amount in the other currency, using the last rate valid on the given day. A rate in the
+// opposite direction is used if there is none in the right one. It returns false if there is no rate at all.
+func (c Converter) Convert(amount decimal.Decimal, fromCurrency, toCurrency string, on time.Time) (decimal.Decimal, bool) {
+	if fromCurrency == toCurrency {
+		return amount, true
+	}
+
+	if rate, ok := c.rateOn(fromCurrency, toCurrency, on); ok {
+		return amount.Mul(rate), true
+	}
+
+	if rate, ok := c.rateOn(toCurrency, fromCurrency, on); ok && !rate.IsZero() {
+		return amount.Div(rate), true
+	}
+
+	return decimal.Zero, false
+}
+
+func (c Converter) rateOn(fromCurrency, toCurrency string, on time.Time) (decimal.Decimal, bool) {
+	var found *ExchangeRate
+
+	for i, rate := range c.rates[fromCurrency+"/"+toCurrency] {
+		if rate.ValidOn.After(on) {
+			break
+		}
+
+		found = &c.rates[fromCurrency+"/"+toCurrency][i]
+	}
+
+	if found == nil {
+		return decimal.Zero, false
+	}
+
+	return found.Rate, true
+}
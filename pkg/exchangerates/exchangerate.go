@@ -0,0 +1,18 @@
+package exchangerates
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate says that 1 unit of FromCurrency is worth Rate units of ToCurrency, from ValidOn
+// until the next rate of the same currencies
+type ExchangeRate struct {
+	ID           int
+	UserID       int
+	FromCurrency string
+	ToCurrency   string
+	Rate         decimal.Decimal
+	ValidOn      time.Time
+}
@@ -0,0 +1,102 @@
+package exchangerates
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+type ExchangeRateRepository interface {
+	// ListAll returns the user's exchange rates ordered by the day they are valid from
+	ListAll(ctx context.Context, userID int) ([]ExchangeRate, error)
+	// Save adds a rate, or replaces the rate of the same currencies on the same day
+	Save(ctx context.Context, userID int, fromCurrency, toCurrency string, rate decimal.Decimal, validOn time.Time) (ExchangeRate, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) ExchangeRateRepository
+}
+
+type exchangeRateRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewExchangeRateRepository(pool *pgxpool.Pool, log echo.Logger) ExchangeRateRepository {
+	return &exchangeRateRepositoryImpl{pool, log}
+}
+
+func (r *exchangeRateRepositoryImpl) WithTx(tx pgx.Tx) ExchangeRateRepository {
+	return &exchangeRateRepositoryImpl{tx, r.log}
+}
+
+const exchangeRateColumns = `id, user_id, from_currency, to_currency, rate, valid_on`
+
+func (r *exchangeRateRepositoryImpl) ListAll(ctx context.Context, userID int) ([]ExchangeRate, error) {
+	r.log.Debugf("Attempting to list all exchange rates of User with id='%d'", userID)
+
+	query := `SELECT ` + exchangeRateColumns + ` FROM exchange_rate WHERE user_id = $1 ORDER BY valid_on, id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+
+	if err != nil {
+		return []ExchangeRate{}, fmt.Errorf("Failed to list all exchange rates of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var rates []ExchangeRate
+
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+
+		if err != nil {
+			return []ExchangeRate{}, fmt.Errorf("Failed to read exchange rate row: %w", err)
+		}
+
+		rates = append(rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []ExchangeRate{}, fmt.Errorf("Failed to read exchange rate rows: %w", err)
+	}
+
+	return rates, nil
+}
+
+func (r *exchangeRateRepositoryImpl) Save(ctx context.Context, userID int, fromCurrency, toCurrency string, rate decimal.Decimal, validOn time.Time) (ExchangeRate, error) {
+	r.log.Debugf("Attempting to save exchange rate from '%s' to '%s'", fromCurrency, toCurrency)
+
+	query := `
+	INSERT INTO exchange_rate (user_id, from_currency, to_currency, rate, valid_on)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id, from_currency, to_currency, valid_on) DO UPDATE SET rate = EXCLUDED.rate
+	RETURNING ` + exchangeRateColumns
+
+	exchangeRate, err := scanExchangeRate(r.db.QueryRow(ctx, query, userID, fromCurrency, toCurrency, rate, validOn))
+
+	if err != nil {
+		return ExchangeRate{}, fmt.Errorf("Failed to save exchange rate from '%s' to '%s': %w", fromCurrency, toCurrency, err)
+	}
+
+	return exchangeRate, nil
+}
+
+func scanExchangeRate(row pgx.Row) (ExchangeRate, error) {
+	var exchangeRate ExchangeRate
+
+	err := row.Scan(
+		&exchangeRate.ID,
+		&exchangeRate.UserID,
+		&exchangeRate.FromCurrency,
+		&exchangeRate.ToCurrency,
+		&exchangeRate.Rate,
+		&exchangeRate.ValidOn,
+	)
+
+	return exchangeRate, err
+}
@@ -0,0 +1,160 @@
+package exchangerates
+
+import (
+	"context"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/users"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func RegisterExchangeRateRoutes(e *echo.Echo, exchangeRateRepository ExchangeRateRepository, userRepository users.UserRepository) {
+
+	log := e.Logger
+
+	loadView := func(ctx context.Context, userID int, baseCurrency string, form ExchangeRateForm) (exchangeRatesView, error) {
+		rates, err := exchangeRateRepository.ListAll(ctx, userID)
+
+		if err != nil {
+			return exchangeRatesView{}, err
+		}
+
+		return exchangeRatesView{BaseCurrency: baseCurrency, Rates: rates, Form: form}, nil
+	}
+
+	e.GET("/exchange-rates", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		// Pages which are missing a rate link here with the currencies filled in
+		form := ExchangeRateForm{
+			FromCurrency: c.QueryParam("from"),
+			ToCurrency:   c.QueryParam("to"),
+			ValidOn:      time.Now().Format(time.DateOnly),
+		}
+
+		view, err := loadView(c.Request().Context(), user.ID, user.BaseCurrency, form)
+
+		if err != nil {
+			log.Errorf("Failed to list exchange rates: %v", err)
+			return c.String(500, "Something went wrong when listing exchange rates...")
+		}
+
+		return layout.RenderPage(c, 200, ExchangeRatesPage(view))
+	})
+
+	e.POST("/exchange-rates", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		form, rate, validOn := parseExchangeRateForm(c)
+		status := 200
+
+		if !form.hasErrors() {
+			if _, err := exchangeRateRepository.Save(ctx, user.ID, form.FromCurrency, form.ToCurrency, rate, validOn); err != nil {
+				log.Errorf("Failed to save exchange rate: %v", err)
+				return c.String(500, "Something went wrong when saving the exchange rate...")
+			}
+
+			form = ExchangeRateForm{ValidOn: form.ValidOn}
+		} else {
+			status = 422
+		}
+
+		view, err := loadView(ctx, user.ID, user.BaseCurrency, form)
+
+		if err != nil {
+			log.Errorf("Failed to list exchange rates: %v", err)
+			return c.String(500, "Something went wrong when listing exchange rates...")
+		}
+
+		return layout.RenderComponent(c, status, ExchangeRatesSection(view))
+	})
+
+	e.POST("/exchange-rates/base-currency", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		baseCurrency := strings.ToUpper(strings.TrimSpace(c.FormValue("base_currency")))
+		form := ExchangeRateForm{ValidOn: time.Now().Format(time.DateOnly)}
+		status := 200
+
+		if currencyCodePattern.MatchString(baseCurrency) {
+			if err := userRepository.UpdateBaseCurrency(ctx, user.ID, baseCurrency); err != nil {
+				log.Errorf("Failed to update base currency: %v", err)
+				return c.String(500, "Something went wrong when changing the base currency...")
+			}
+		} else {
+			form.BaseCurrencyError = "Enter a three letter currency code, e.g. USD"
+			baseCurrency = user.BaseCurrency
+			status = 422
+		}
+
+		view, err := loadView(ctx, user.ID, baseCurrency, form)
+
+		if err != nil {
+			log.Errorf("Failed to list exchange rates: %v", err)
+			return c.String(500, "Something went wrong when listing exchange rates...")
+		}
+
+		return layout.RenderComponent(c, status, ExchangeRatesSection(view))
+	})
+}
+
+type exchangeRatesView struct {
+	BaseCurrency string
+	Rates        []ExchangeRate
+	Form         ExchangeRateForm
+}
+
+// ExchangeRateForm holds what the user entered into the exchange rate forms, along with validation errors
+type ExchangeRateForm struct {
+	FromCurrency string
+	ToCurrency   string
+	Rate         string
+	ValidOn      string
+
+	CurrencyError     string
+	RateError         string
+	ValidOnError      string
+	BaseCurrencyError string
+}
+
+func (f ExchangeRateForm) hasErrors() bool {
+	return f.CurrencyError != "" || f.RateError != "" || f.ValidOnError != "" || f.BaseCurrencyError != ""
+}
+
+func parseExchangeRateForm(c echo.Context) (ExchangeRateForm, decimal.Decimal, time.Time) {
+	form := ExchangeRateForm{
+		FromCurrency: strings.ToUpper(strings.TrimSpace(c.FormValue("from_currency"))),
+		ToCurrency:   strings.ToUpper(strings.TrimSpace(c.FormValue("to_currency"))),
+		Rate:         strings.TrimSpace(c.FormValue("rate")),
+		ValidOn:      strings.TrimSpace(c.FormValue("valid_on")),
+	}
+
+	switch {
+	case !currencyCodePattern.MatchString(form.FromCurrency) || !currencyCodePattern.MatchString(form.ToCurrency):
+		form.CurrencyError = "Enter three letter currency codes, e.g. USD"
+	case form.FromCurrency == form.ToCurrency:
+		form.CurrencyError = "The currencies have to be different"
+	}
+
+	rate, err := decimal.NewFromString(form.Rate)
+
+	if err != nil || !rate.IsPositive() {
+		form.RateError = "Enter a rate above 0"
+	}
+
+	validOn, err := time.Parse(time.DateOnly, form.ValidOn)
+
+	if err != nil {
+		form.ValidOnError = "Enter a date"
+	}
+
+	return form, rate, validOn
+}
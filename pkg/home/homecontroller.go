@@ -3,13 +3,17 @@ package home
 import (
 	"nerdmoney/pkg/banking"
 	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/users"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterHomeRoutes(e *echo.Echo, plaidClient *banking.PlaidClient) {
+func RegisterHomeRoutes(e *echo.Echo, bankingProvider banking.BankingProvider) {
 	e.GET("/", func(c echo.Context) error {
-		linkTokenResponse, err := plaidClient.CreateLinkToken()
+		user := users.CurrentUser(c)
+
+		linkTokenResponse, err := bankingProvider.CreateLinkToken(c.Request().Context(), strconv.Itoa(user.ID))
 		if err != nil {
 			return c.String(500, "Something went wrong")
 		}
@@ -17,7 +21,7 @@ func RegisterHomeRoutes(e *echo.Echo, plaidClient *banking.PlaidClient) {
 		return layout.RenderPage(
 			c,
 			200,
-			HomePage(linkTokenResponse.LinkToken),
+			HomePage(user, linkTokenResponse.LinkToken),
 		)
 	})
 }
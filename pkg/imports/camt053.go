@@ -0,0 +1,300 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"nerdmoney/pkg/transactions"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID       string        `xml:"Id"`
+	IBAN     string        `xml:"Acct>Id>IBAN"`
+	Other    string        `xml:"Acct>Id>Othr>Id"`
+	Currency string        `xml:"Acct>Ccy"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtBalance struct {
+	Type        string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+	Date        camtDate   `xml:"Dt"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtDate is either a date or a date and time
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// camtStatus is a text up to version 2 of camt.053 and a code afterwards
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camtEntry struct {
+	Amount         camtAmount               `xml:"Amt"`
+	CreditDebit    string                   `xml:"CdtDbtInd"`
+	Status         camtStatus               `xml:"Sts"`
+	BookingDate    camtDate                 `xml:"BookgDt"`
+	ValueDate      camtDate                 `xml:"ValDt"`
+	Reference      string                   `xml:"AcctSvcrRef"`
+	Details        []camtTransactionDetails `xml:"NtryDtls>TxDtls"`
+	AdditionalInfo string                   `xml:"AddtlNtryInf"`
+}
+
+type camtTransactionDetails struct {
+	// Batch bookings state the amount of each transaction, in one of two places depending on the version
+	Amount            camtAmount `xml:"Amt"`
+	TransactionAmount camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	CreditDebit       string     `xml:"CdtDbtInd"`
+	EndToEndID        string     `xml:"Refs>EndToEndId"`
+
+	// Parties are named directly up to version 2 and as a party afterwards
+	DebtorName        string `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPartyName   string `xml:"RltdPties>Dbtr>Pty>Nm"`
+	DebtorIBAN        string `xml:"RltdPties>DbtrAcct>Id>IBAN"`
+	CreditorName      string `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPartyName string `xml:"RltdPties>Cdtr>Pty>Nm"`
+	CreditorIBAN      string `xml:"RltdPties>CdtrAcct>Id>IBAN"`
+
+	Unstructured      []string `xml:"RmtInf>Ustrd"`
+	CreditorReference string   `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AdditionalInfo    string   `xml:"AddtlTxInf"`
+}
+
+// ParseCamt053 reads ISO 20022 bank to customer statements. Entries which book several transactions at once
+// are read as their transactions when the statement breaks them down.
+func ParseCamt053(data []byte, currency string) (ParseResult, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		encoding, ok := Encodings[strings.ToLower(label)]
+
+		if !ok {
+			return nil, fmt.Errorf("The file is encoded in %s, which is not supported", label)
+		}
+
+		if encoding == nil {
+			return input, nil
+		}
+
+		return encoding.NewDecoder().Reader(input), nil
+	}
+
+	var document camtDocument
+
+	if err := decoder.Decode(&document); err != nil {
+		return ParseResult{}, errors.New("The file is not a camt.053 file")
+	}
+
+	if len(document.Statements) == 0 {
+		return ParseResult{}, errors.New("The file has no camt.053 statements")
+	}
+
+	var result ParseResult
+
+	for _, statement := range document.Statements {
+		statementCurrency := currency
+
+		if statement.Currency != "" {
+			statementCurrency = statement.Currency
+		}
+
+		summary := transactions.Statement{Account: statement.IBAN, Currency: statementCurrency, Total: decimal.Zero}
+
+		if summary.Account == "" {
+			summary.Account = statement.Other
+		}
+
+		for _, balance := range statement.Balances {
+			statementBalance, err := readCamtBalance(balance)
+
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Sprintf("Statement %s: %v", statement.ID, err))
+				continue
+			}
+
+			switch balance.Type {
+			// Banks state the opening balance either as such or as the closing balance of the previous statement
+			case "OPBD", "PRCD":
+				if summary.Opening == nil || balance.Type == "OPBD" {
+					summary.Opening = &statementBalance
+				}
+			case "CLBD":
+				summary.Closing = &statementBalance
+			}
+		}
+
+		for i, entry := range statement.Entries {
+			entryTransactions, err := readCamtEntry(entry, statementCurrency)
+
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Sprintf("Statement %s, entry %d: %v", statement.ID, i+1, err))
+				continue
+			}
+
+			for _, writeModel := range entryTransactions {
+				summary.Total = summary.Total.Add(writeModel.Amount)
+			}
+
+			result.Transactions = append(result.Transactions, entryTransactions...)
+		}
+
+		result.Statements = append(result.Statements, summary)
+	}
+
+	return result, nil
+}
+
+func readCamtBalance(balance camtBalance) (transactions.StatementBalance, error) {
+	date, err := parseCamtDate(balance.Date)
+
+	if err != nil {
+		return transactions.StatementBalance{}, err
+	}
+
+	amount, err := decimal.NewFromString(strings.TrimSpace(balance.Amount.Value))
+
+	if err != nil {
+		return transactions.StatementBalance{}, fmt.Errorf("'%s' is not an amount", balance.Amount.Value)
+	}
+
+	// Overdrawn accounts have a debit balance
+	if balance.CreditDebit == "DBIT" {
+		amount = amount.Neg()
+	}
+
+	return transactions.StatementBalance{Date: date, Amount: amount}, nil
+}
+
+func readCamtEntry(entry camtEntry, currency string) ([]transactions.DbTransactionWriteModel, error) {
+	bookingDate, bookingErr := parseCamtDate(entry.BookingDate)
+	valueDate, valueErr := parseCamtDate(entry.ValueDate)
+
+	switch {
+	case bookingErr != nil && valueErr != nil:
+		return nil, bookingErr
+	case bookingErr != nil:
+		bookingDate = valueDate
+	case valueErr != nil:
+		valueDate = bookingDate
+	}
+
+	// An entry with one transaction may leave out its amount, which is the amount of the entry then
+	details := entry.Details
+
+	if len(details) == 0 {
+		details = []camtTransactionDetails{{}}
+	}
+
+	var writeModels []transactions.DbTransactionWriteModel
+
+	for _, detail := range details {
+		amount, creditDebit := entry.Amount, entry.CreditDebit
+
+		if len(details) > 1 {
+			if amount = detail.TransactionAmount; amount.Value == "" {
+				amount = detail.Amount
+			}
+
+			if detail.CreditDebit != "" {
+				creditDebit = detail.CreditDebit
+			}
+		}
+
+		value, err := decimal.NewFromString(strings.TrimSpace(amount.Value))
+
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not an amount", amount.Value)
+		}
+
+		// Money leaving the account is positive
+		if creditDebit == "CRDT" {
+			value = value.Neg()
+		}
+
+		transactionCurrency := currency
+
+		if amount.Currency != "" {
+			transactionCurrency = amount.Currency
+		}
+
+		// The counterparty of a debit is the creditor and the other way around
+		counterpartyName := firstNonEmpty(detail.DebtorName, detail.DebtorPartyName)
+		counterpartyIBAN := detail.DebtorIBAN
+
+		if creditDebit == "DBIT" {
+			counterpartyName = firstNonEmpty(detail.CreditorName, detail.CreditorPartyName)
+			counterpartyIBAN = detail.CreditorIBAN
+		}
+
+		remittanceInfo := strings.Join(strings.Fields(strings.Join(detail.Unstructured, " ")), " ")
+
+		if remittanceInfo == "" {
+			remittanceInfo = detail.CreditorReference
+		}
+
+		writeModel := statementTransaction(
+			value,
+			transactionCurrency,
+			bookingDate,
+			valueDate,
+			counterpartyName,
+			counterpartyIBAN,
+			firstNonEmpty(remittanceInfo, detail.AdditionalInfo, entry.AdditionalInfo),
+		)
+
+		writeModel.Pending = firstNonEmpty(entry.Status.Code, strings.TrimSpace(entry.Status.Text)) == "PDNG"
+		writeModel.Raw = rawRecord(map[string]string{
+			"booking_date":         bookingDate.Format(time.DateOnly),
+			"value_date":           valueDate.Format(time.DateOnly),
+			"amount":               amount.Value,
+			"credit_debit":         creditDebit,
+			"account_servicer_ref": entry.Reference,
+			"end_to_end_id":        detail.EndToEndID,
+			"counterparty_name":    counterpartyName,
+			"counterparty_iban":    counterpartyIBAN,
+			"remittance_info":      remittanceInfo,
+			"additional_info":      firstNonEmpty(detail.AdditionalInfo, entry.AdditionalInfo),
+		})
+
+		writeModels = append(writeModels, writeModel)
+	}
+
+	return writeModels, nil
+}
+
+func parseCamtDate(date camtDate) (time.Time, error) {
+	value := strings.TrimSpace(firstNonEmpty(date.Date, date.DateTime))
+
+	// Times are left out like in the other formats
+	if len(value) > len(time.DateOnly) {
+		value = value[:len(time.DateOnly)]
+	}
+
+	parsed, err := time.Parse(time.DateOnly, value)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not a date", value)
+	}
+
+	return parsed, nil
+}
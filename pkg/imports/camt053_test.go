@@ -0,0 +1,81 @@
+package imports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCamt053(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string
+		want       []expectedTransaction
+		statements []expectedStatement
+		problems   []string
+	}{
+		{
+			name:    "version 2 with a batch booking and a pending entry",
+			fixture: "camt053_v02.xml",
+			want: []expectedTransaction{
+				{"2024-03-04", "150.00", "EUR", "Stadtwerke - Strom Maerz"},
+				{"2024-03-10", "-600.00", "EUR", "Alice - Rent share"},
+				{"2024-03-10", "-400.00", "EUR", "Bob - Rent share"},
+				{"2024-03-30", "37.65", "EUR", "Card payment Bakery"},
+			},
+			statements: []expectedStatement{
+				{"DE89370400440532013000", "EUR", "2024-03-01", "1000.00", "2024-03-31", "1812.35", "-812.35"},
+			},
+		},
+		{
+			name:    "version 8 with parties, creditor references and overdrawn balances",
+			fixture: "camt053_v08.xml",
+			want: []expectedTransaction{
+				{"2024-04-15", "520.00", "EUR", "Landlord - RF18539007547034"},
+				{"2024-05-28", "-100.00", "EUR", "Employer - Salary"},
+			},
+			statements: []expectedStatement{
+				{"0532013000", "EUR", "2024-04-01", "500.00", "2024-04-30", "-20.00", "520.00"},
+				{"0532013000", "EUR", "2024-05-01", "-20.00", "2024-05-31", "100.00", "-100.00"},
+			},
+			problems: []string{"Statement stmt-b, entry 2:"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParseCamt053(readFixture(t, test.fixture), "USD")
+
+			if err != nil {
+				t.Fatalf("ParseCamt053 failed: %v", err)
+			}
+
+			expectTransactions(t, result.Transactions, test.want)
+			expectStatements(t, result.Statements, test.statements)
+			expectProblems(t, result.Problems, test.problems)
+		})
+	}
+
+	if _, err := ParseCamt053(readFixture(t, "not_an_export.txt"), "EUR"); err == nil {
+		t.Errorf("Expected a file which is not XML to be rejected")
+	}
+
+	if _, err := ParseCamt053(readFixture(t, "statement_v2.ofx"), "EUR"); err == nil {
+		t.Errorf("Expected an XML file without statements to be rejected")
+	}
+}
+
+func TestParseCamt053Dates(t *testing.T) {
+	result, err := ParseCamt053(readFixture(t, "camt053_v02.xml"), "EUR")
+
+	if err != nil {
+		t.Fatalf("ParseCamt053 failed: %v", err)
+	}
+
+	if valueDate := result.Transactions[0].DateAuthorized.Format(time.DateOnly); valueDate != "2024-03-03" {
+		t.Errorf("Expected the value date to be the authorized date, got %s", valueDate)
+	}
+
+	if result.Transactions[0].Pending || !result.Transactions[3].Pending {
+		t.Errorf("Expected only the PDNG entry to be pending")
+	}
+}
@@ -0,0 +1,234 @@
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"nerdmoney/pkg/transactions"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// ParseCSV reads a bank's CSV export with the columns described by the profile. Rows whose date cannot be
+// read are reported as problems, which also covers the summary rows some banks append.
+func ParseCSV(data []byte, profile Profile, currency string) (ParseResult, error) {
+	text, err := decode(data, profile.Encoding)
+
+	if err != nil {
+		return ParseResult{}, err
+	}
+
+	for i := 0; i < profile.SkipLines; i++ {
+		_, rest, found := strings.Cut(text, "\n")
+
+		if !found {
+			return ParseResult{}, errors.New("The file has fewer lines than the profile skips")
+		}
+
+		text = rest
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = profile.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var header []string
+
+	if profile.HasHeader {
+		header, err = reader.Read()
+
+		if err != nil {
+			return ParseResult{}, errors.New("The file has no header")
+		}
+	}
+
+	columns, err := resolveColumns(profile, header)
+
+	if err != nil {
+		return ParseResult{}, err
+	}
+
+	var result ParseResult
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return ParseResult{}, fmt.Errorf("The file is not a valid CSV file: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		line += profile.SkipLines
+
+		if isBlank(record) {
+			continue
+		}
+
+		writeModel, err := columns.read(record, profile, currency)
+
+		if err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("Line %d: %v", line, err))
+			continue
+		}
+
+		raw := map[string]string{}
+
+		for i, value := range record {
+			if i < len(header) {
+				raw[header[i]] = value
+			} else {
+				raw[strconv.Itoa(i+1)] = value
+			}
+		}
+
+		writeModel.Raw = rawRecord(raw)
+		result.Transactions = append(result.Transactions, writeModel)
+	}
+
+	return result, nil
+}
+
+// csvColumns holds the index of each column of the profile, -1 if it has none
+type csvColumns struct {
+	date        int
+	description int
+	payee       int
+	amount      int
+	debit       int
+	credit      int
+	currency    int
+}
+
+func resolveColumns(profile Profile, header []string) (csvColumns, error) {
+	var resolveErr error
+
+	resolve := func(column *string) int {
+		if column == nil || resolveErr != nil {
+			return -1
+		}
+
+		if !profile.HasHeader {
+			position, err := strconv.Atoi(*column)
+
+			if err != nil || position < 1 {
+				resolveErr = fmt.Errorf("'%s' is not a column position", *column)
+				return -1
+			}
+
+			return position - 1
+		}
+
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(*column)) {
+				return i
+			}
+		}
+
+		resolveErr = fmt.Errorf("The file has no column '%s'", *column)
+
+		return -1
+	}
+
+	columns := csvColumns{
+		date:        resolve(&profile.DateColumn),
+		description: resolve(&profile.DescriptionColumn),
+		payee:       resolve(profile.PayeeColumn),
+		amount:      resolve(profile.AmountColumn),
+		debit:       resolve(profile.DebitColumn),
+		credit:      resolve(profile.CreditColumn),
+		currency:    resolve(profile.CurrencyColumn),
+	}
+
+	return columns, resolveErr
+}
+
+func (c csvColumns) read(record []string, profile Profile, currency string) (transactions.DbTransactionWriteModel, error) {
+	field := func(index int) string {
+		if index < 0 || index >= len(record) {
+			return ""
+		}
+
+		return strings.TrimSpace(record[index])
+	}
+
+	date, err := parseDate(field(c.date), profile.DateFormat)
+
+	if err != nil {
+		return transactions.DbTransactionWriteModel{}, fmt.Errorf("'%s' is not a date in the format %s", field(c.date), profile.DateFormat)
+	}
+
+	var amount decimal.Decimal
+
+	if c.amount >= 0 {
+		amount, err = parseAmount(field(c.amount), profile.DecimalComma)
+
+		if err != nil {
+			return transactions.DbTransactionWriteModel{}, err
+		}
+
+		// Transactions are stored with money leaving the account as positive amounts
+		if !profile.InvertAmounts {
+			amount = amount.Neg()
+		}
+	} else {
+		// Rows fill in either the debit or the credit
+		debit, credit := decimal.Zero, decimal.Zero
+
+		if value := field(c.debit); value != "" {
+			if debit, err = parseAmount(value, profile.DecimalComma); err != nil {
+				return transactions.DbTransactionWriteModel{}, err
+			}
+		}
+
+		if value := field(c.credit); value != "" {
+			if credit, err = parseAmount(value, profile.DecimalComma); err != nil {
+				return transactions.DbTransactionWriteModel{}, err
+			}
+		}
+
+		amount = debit.Abs().Sub(credit.Abs())
+	}
+
+	payee := field(c.payee)
+	description := field(c.description)
+
+	if description == "" {
+		description = payee
+	}
+
+	if description == "" {
+		return transactions.DbTransactionWriteModel{}, errors.New("The row has no description")
+	}
+
+	if rowCurrency := strings.ToUpper(field(c.currency)); rowCurrency != "" {
+		currency = rowCurrency
+	}
+
+	return transactions.DbTransactionWriteModel{
+		Amount:         amount,
+		Currency:       currency,
+		DateAuthorized: date,
+		DatePosted:     date,
+		Name:           description,
+		MerchantName:   optionalString(payee),
+	}, nil
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,130 @@
+package imports
+
+import "testing"
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		profile  Profile
+		want     []expectedTransaction
+		problems []string
+	}{
+		{
+			name:    "header with amount, payee and currency columns",
+			fixture: "header_amount.csv",
+			profile: Profile{
+				Delimiter:         ',',
+				Encoding:          "utf-8",
+				HasHeader:         true,
+				DateColumn:        "date",
+				DateFormat:        "YYYY-MM-DD",
+				DescriptionColumn: "Description",
+				PayeeColumn:       stringPointer("Payee"),
+				AmountColumn:      stringPointer("Amount"),
+				CurrencyColumn:    stringPointer("Currency"),
+			},
+			want: []expectedTransaction{
+				{"2024-01-15", "4.50", "USD", "Card payment 4711"},
+				{"2024-01-16", "-2500.00", "USD", "Salary January"},
+				{"2024-01-17", "100.00", "EUR", "Transfer to savings"},
+				{"2024-01-18", "1234.56", "USD", "Online order"},
+			},
+			// The summary row at the end
+			problems: []string{"Line 7:"},
+		},
+		{
+			name:    "skipped lines with debit and credit columns and decimal commas",
+			fixture: "debit_credit.csv",
+			profile: Profile{
+				Delimiter:         ';',
+				Encoding:          "utf-8",
+				SkipLines:         2,
+				HasHeader:         true,
+				DateColumn:        "Buchungstag",
+				DateFormat:        "DD.MM.YYYY",
+				DescriptionColumn: "Verwendungszweck",
+				DebitColumn:       stringPointer("Soll"),
+				CreditColumn:      stringPointer("Haben"),
+				DecimalComma:      true,
+			},
+			want: []expectedTransaction{
+				{"2024-01-15", "850.00", "USD", "Miete Januar"},
+				{"2024-01-16", "-2500.00", "USD", "Gehalt"},
+				{"2024-01-17", "-12.50", "USD", "Erstattung"},
+			},
+			problems: []string{"Line 7:"},
+		},
+		{
+			name:    "positions without a header in windows-1252 with inverted amounts",
+			fixture: "no_header_windows1252.csv",
+			profile: Profile{
+				Delimiter:         ',',
+				Encoding:          "windows-1252",
+				DateColumn:        "1",
+				DateFormat:        "DD/MM/YYYY",
+				DescriptionColumn: "2",
+				AmountColumn:      stringPointer("3"),
+				InvertAmounts:     true,
+			},
+			want: []expectedTransaction{
+				{"2024-01-03", "12.40", "USD", "Café Central"},
+				{"2024-01-04", "-5.00", "USD", "Refund"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParseCSV(readFixture(t, test.fixture), test.profile, "USD")
+
+			if err != nil {
+				t.Fatalf("ParseCSV failed: %v", err)
+			}
+
+			expectTransactions(t, result.Transactions, test.want)
+			expectProblems(t, result.Problems, test.problems)
+		})
+	}
+}
+
+func TestParseCSVRejectsProfilesWhichDoNotFit(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+	}{
+		{
+			name: "missing column",
+			profile: Profile{
+				Delimiter: ',', Encoding: "utf-8", HasHeader: true, DateColumn: "Date", DateFormat: "YYYY-MM-DD",
+				DescriptionColumn: "Memo", AmountColumn: stringPointer("Amount"),
+			},
+		},
+		{
+			name: "column name without a header",
+			profile: Profile{
+				Delimiter: ',', Encoding: "utf-8", DateColumn: "Date", DateFormat: "YYYY-MM-DD",
+				DescriptionColumn: "2", AmountColumn: stringPointer("3"),
+			},
+		},
+		{
+			name: "more skipped lines than the file has",
+			profile: Profile{
+				Delimiter: ',', Encoding: "utf-8", SkipLines: 50, HasHeader: true, DateColumn: "Date", DateFormat: "YYYY-MM-DD",
+				DescriptionColumn: "Description", AmountColumn: stringPointer("Amount"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseCSV(readFixture(t, "header_amount.csv"), test.profile, "USD"); err == nil {
+				t.Errorf("Expected the profile to be rejected")
+			}
+		})
+	}
+}
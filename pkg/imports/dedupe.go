@@ -0,0 +1,54 @@
+package imports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"nerdmoney/pkg/transactions"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Fingerprint identifies a transaction by what every file format has, the date, the amount and the description
+func Fingerprint(date time.Time, amount decimal.Decimal, description string) string {
+	return date.Format(time.DateOnly) + "|" + amount.StringFixed(2) + "|" + strings.Join(strings.Fields(strings.ToLower(description)), " ")
+}
+
+// Deduplicate leaves out the transactions which are in the account already. Exports of overlapping periods
+// repeat transactions, and a day may have several transactions with the same fingerprint, e.g. two coffees.
+// When the account has n transactions with a fingerprint, the first n of the file are duplicates.
+//
+// The new transactions get ids derived from their fingerprint and how many came before them, so that
+// confirming two previews of the same file never imports it twice.
+func Deduplicate(
+	bankAccountID int,
+	parsed []transactions.DbTransactionWriteModel,
+	existing []transactions.DbTransaction,
+) (newTransactions []transactions.DbTransactionWriteModel, duplicates []transactions.DbTransactionWriteModel) {
+	existingCounts := map[string]int{}
+
+	for _, transaction := range existing {
+		existingCounts[Fingerprint(transaction.DatePosted, transaction.Amount, transaction.Name)]++
+	}
+
+	occurrences := map[string]int{}
+
+	for _, writeModel := range parsed {
+		fingerprint := Fingerprint(writeModel.DatePosted, writeModel.Amount, writeModel.Name)
+		occurrence := occurrences[fingerprint]
+		occurrences[fingerprint]++
+
+		if occurrence < existingCounts[fingerprint] {
+			duplicates = append(duplicates, writeModel)
+			continue
+		}
+
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%d", bankAccountID, fingerprint, occurrence)))
+		writeModel.PlaidTransactionID = "import-" + hex.EncodeToString(hash[:])
+		newTransactions = append(newTransactions, writeModel)
+	}
+
+	return newTransactions, duplicates
+}
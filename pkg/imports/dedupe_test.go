@@ -0,0 +1,130 @@
+package imports
+
+import (
+	"testing"
+
+	"nerdmoney/pkg/transactions"
+)
+
+var exportProfile = Profile{
+	Delimiter:         ',',
+	Encoding:          "utf-8",
+	HasHeader:         true,
+	DateColumn:        "Date",
+	DateFormat:        "YYYY-MM-DD",
+	DescriptionColumn: "Description",
+	AmountColumn:      stringPointer("Amount"),
+}
+
+func parseExport(t *testing.T, fixture string) []transactions.DbTransactionWriteModel {
+	t.Helper()
+
+	result, err := ParseCSV(readFixture(t, fixture), exportProfile, "USD")
+
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", fixture, err)
+	}
+
+	return result.Transactions
+}
+
+// saved turns parsed transactions into the transactions an earlier import saved
+func saved(writeModels []transactions.DbTransactionWriteModel) []transactions.DbTransaction {
+	var savedTransactions []transactions.DbTransaction
+
+	for i, writeModel := range writeModels {
+		savedTransactions = append(savedTransactions, transactions.DbTransaction{
+			ID:         int64(i + 1),
+			Amount:     writeModel.Amount,
+			Currency:   writeModel.Currency,
+			DatePosted: writeModel.DatePosted,
+			Name:       writeModel.Name,
+		})
+	}
+
+	return savedTransactions
+}
+
+func TestDeduplicate(t *testing.T) {
+	tests := []struct {
+		name           string
+		fixture        string
+		existing       string
+		wantNew        []expectedTransaction
+		wantDuplicates int
+	}{
+		{
+			name:    "new account",
+			fixture: "export_january.csv",
+			wantNew: []expectedTransaction{
+				{"2024-01-02", "4.50", "USD", "Coffee Shop"},
+				{"2024-01-10", "4.50", "USD", "Coffee Shop"},
+				{"2024-01-12", "52.30", "USD", "Supermarket"},
+				{"2024-01-15", "-2500.00", "USD", "Salary"},
+			},
+		},
+		{
+			name:           "same export again",
+			fixture:        "export_january.csv",
+			existing:       "export_january.csv",
+			wantDuplicates: 4,
+		},
+		{
+			// The second coffee of January 10th is only in the newer export
+			name:     "overlapping exports with two coffees on the same day",
+			fixture:  "export_overlap.csv",
+			existing: "export_january.csv",
+			wantNew: []expectedTransaction{
+				{"2024-01-10", "4.50", "USD", "COFFEE  shop"},
+				{"2024-01-18", "850.00", "USD", "Rent"},
+				{"2024-01-20", "4.50", "USD", "Coffee Shop"},
+			},
+			wantDuplicates: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var existing []transactions.DbTransaction
+
+			if test.existing != "" {
+				existing = saved(parseExport(t, test.existing))
+			}
+
+			newTransactions, duplicates := Deduplicate(1, parseExport(t, test.fixture), existing)
+
+			expectTransactions(t, newTransactions, test.wantNew)
+
+			if len(duplicates) != test.wantDuplicates {
+				t.Errorf("Expected %d duplicates, got %d", test.wantDuplicates, len(duplicates))
+			}
+		})
+	}
+}
+
+func TestDeduplicateAssignsStableIDs(t *testing.T) {
+	parsed := parseExport(t, "export_overlap.csv")
+
+	first, _ := Deduplicate(1, parsed, nil)
+	second, _ := Deduplicate(1, parsed, nil)
+	otherAccount, _ := Deduplicate(2, parsed, nil)
+
+	ids := map[string]bool{}
+
+	for i := range first {
+		if first[i].PlaidTransactionID != second[i].PlaidTransactionID {
+			t.Errorf("Transaction %d: expected previews of the same file to get the same id", i)
+		}
+
+		if first[i].PlaidTransactionID == otherAccount[i].PlaidTransactionID {
+			t.Errorf("Transaction %d: expected other accounts to get other ids", i)
+		}
+
+		ids[first[i].PlaidTransactionID] = true
+	}
+
+	// Both coffees of January 10th have the same fingerprint
+	if len(ids) != len(parsed) {
+		t.Errorf("Expected %d distinct ids, got %d", len(parsed), len(ids))
+	}
+}
@@ -0,0 +1,148 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"nerdmoney/pkg/transactions"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ParseResult is what a parser read from a file. The transactions have no PlaidTransactionID until
+// Deduplicate assigns one.
+type ParseResult struct {
+	Transactions []transactions.DbTransactionWriteModel
+	// Records which could not be read, to show to the user
+	Problems []string
+	// The bank statements of camt.053 and MT940 files
+	Statements []transactions.Statement
+}
+
+// Options are what the parsers need besides the file
+type Options struct {
+	// Of the account, for files which do not name a currency
+	Currency string
+	// For CSV files
+	Profile Profile
+	// For QIF files, whose dates are either month or day first depending on where they were exported
+	DayFirst bool
+	// For QIF and MT940 files, which do not tell their encoding. The other formats do.
+	Encoding string
+}
+
+// Parse reads the transactions from a file. It fails when the file is not in the given format at all,
+// records it cannot read are reported as problems instead.
+func Parse(format transactions.ImportFormat, data []byte, options Options) (ParseResult, error) {
+	switch format {
+	case transactions.ImportFormatCSV:
+		return ParseCSV(data, options.Profile, options.Currency)
+	case transactions.ImportFormatOFX:
+		return ParseOFX(data, options.Currency)
+	case transactions.ImportFormatQIF:
+		return ParseQIF(data, options.Currency, options.DayFirst, options.Encoding)
+	case transactions.ImportFormatCamt053:
+		return ParseCamt053(data, options.Currency)
+	case transactions.ImportFormatMT940:
+		return ParseMT940(data, options.Currency, options.Encoding)
+	}
+
+	return ParseResult{}, fmt.Errorf("Unsupported import format '%s'", format)
+}
+
+// Encodings maps the encodings of bank exports to their decoders, nil for UTF-8
+var Encodings = map[string]encoding.Encoding{
+	"utf-8":        nil,
+	"windows-1250": charmap.Windows1250,
+	"windows-1252": charmap.Windows1252,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"iso-8859-2":   charmap.ISO8859_2,
+}
+
+// EncodingNames lists Encodings in the order they are offered
+var EncodingNames = []string{"utf-8", "windows-1250", "windows-1252", "iso-8859-1", "iso-8859-2"}
+
+// decode converts the file to UTF-8 and drops the byte order mark some programs write
+func decode(data []byte, encodingName string) (string, error) {
+	if decoder := Encodings[encodingName]; decoder != nil {
+		decoded, err := decoder.NewDecoder().Bytes(data)
+
+		if err != nil {
+			return "", fmt.Errorf("The file is not encoded in %s", encodingName)
+		}
+
+		data = decoded
+	}
+
+	return string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), nil
+}
+
+// parseAmount reads amounts the way banks write them, e.g. "1,234.56", "-1 234,56 PLN" or "+12.5". Without
+// decimalComma, a comma is taken as the decimal separator when it is the last separator and not followed by
+// exactly three digits.
+func parseAmount(value string, decimalComma bool) (decimal.Decimal, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || r == '-' || r == '+' || r == '.' || r == ',' {
+			return r
+		}
+
+		return -1
+	}, value)
+
+	if !decimalComma {
+		lastComma := strings.LastIndex(cleaned, ",")
+		decimalComma = lastComma >= 0 && lastComma > strings.LastIndex(cleaned, ".") && len(cleaned)-lastComma-1 != 3
+	}
+
+	if decimalComma {
+		cleaned = strings.ReplaceAll(strings.ReplaceAll(cleaned, ".", ""), ",", ".")
+	} else {
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+
+	amount, err := decimal.NewFromString(strings.TrimPrefix(cleaned, "+"))
+
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("'%s' is not an amount", value)
+	}
+
+	return amount, nil
+}
+
+// describe combines the name and the memo of a record, which some banks repeat in both
+func describe(name string, memo string) string {
+	name = strings.TrimSpace(name)
+	memo = strings.TrimSpace(memo)
+
+	switch {
+	case name == "":
+		return memo
+	case memo == "" || strings.Contains(strings.ToLower(name), strings.ToLower(memo)):
+		return name
+	}
+
+	return name + " - " + memo
+}
+
+// rawRecord keeps the record as it was in the file, like the provider's payload of synced transactions
+func rawRecord(record map[string]string) []byte {
+	raw, err := json.Marshal(record)
+
+	if err != nil {
+		return nil
+	}
+
+	return raw
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
@@ -0,0 +1,102 @@
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nerdmoney/pkg/transactions"
+)
+
+// expectedTransaction is what a test checks about a parsed transaction. Amounts use the app's sign, money
+// leaving the account is positive.
+type expectedTransaction struct {
+	date     string
+	amount   string
+	currency string
+	name     string
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture '%s': %v", name, err)
+	}
+
+	return data
+}
+
+func expectTransactions(t *testing.T, got []transactions.DbTransactionWriteModel, want []expectedTransaction) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d transactions, got %d: %+v", len(want), len(got), got)
+	}
+
+	for i, expected := range want {
+		transaction := got[i]
+		actual := expectedTransaction{
+			date:     transaction.DatePosted.Format(time.DateOnly),
+			amount:   transaction.Amount.StringFixed(2),
+			currency: transaction.Currency,
+			name:     transaction.Name,
+		}
+
+		if actual != expected {
+			t.Errorf("Transaction %d: expected %+v, got %+v", i, expected, actual)
+		}
+	}
+}
+
+func expectProblems(t *testing.T, got []string, wantPrefixes []string) {
+	t.Helper()
+
+	if len(got) != len(wantPrefixes) {
+		t.Fatalf("Expected %d problems, got %d: %v", len(wantPrefixes), len(got), got)
+	}
+
+	for i, prefix := range wantPrefixes {
+		if !strings.HasPrefix(got[i], prefix) {
+			t.Errorf("Expected problem %d to start with '%s', got '%s'", i, prefix, got[i])
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value        string
+		decimalComma bool
+		want         string
+	}{
+		{"1,234.56", false, "1234.56"},
+		{"-1 234,56 PLN", false, "-1234.56"},
+		{"+12.5", false, "12.5"},
+		{"12,5", false, "12.5"},
+		{"1,234", false, "1234"},
+		{"1.234", true, "1234"},
+		{"2.500,00", true, "2500"},
+		{"$ -0.99", false, "-0.99"},
+	}
+
+	for _, test := range tests {
+		amount, err := parseAmount(test.value, test.decimalComma)
+
+		if err != nil {
+			t.Errorf("parseAmount(%q, %v) failed: %v", test.value, test.decimalComma, err)
+			continue
+		}
+
+		if amount.String() != test.want {
+			t.Errorf("parseAmount(%q, %v) = %s, want %s", test.value, test.decimalComma, amount, test.want)
+		}
+	}
+
+	if _, err := parseAmount("n/a", false); err == nil {
+		t.Errorf("Expected 'n/a' to not be an amount")
+	}
+}
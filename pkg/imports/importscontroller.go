@@ -0,0 +1,692 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/categorization"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/transfers"
+	"nerdmoney/pkg/unitofwork"
+	"nerdmoney/pkg/users"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Statements of a few years fit easily
+const maxImportFileSize = 5 << 20
+
+const recentImportsLimit = 10
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Account types which make sense for accounts of banks Plaid does not support
+var manualAccountTypes = []models.AccountType{models.Depository, models.Credit, models.Loan, models.Other}
+
+func RegisterImportRoutes(e *echo.Echo, unitOfWork unitofwork.UnitOfWork, profileRepository ProfileRepository) {
+
+	log := e.Logger
+
+	loadView := func(ctx context.Context, userID int) (importsView, error) {
+		bankAccounts, err := unitOfWork.Repos().BankAccounts.ListAll(ctx, userID)
+
+		if err != nil {
+			return importsView{}, err
+		}
+
+		profiles, err := profileRepository.ListAll(ctx, userID)
+
+		if err != nil {
+			return importsView{}, err
+		}
+
+		recentImports, err := unitOfWork.Repos().Imports.ListRecent(ctx, userID, recentImportsLimit)
+
+		if err != nil {
+			return importsView{}, err
+		}
+
+		view := importsView{
+			Profiles:      profiles,
+			RecentImports: recentImports,
+			AccountForm:   AccountForm{AccountType: string(models.Depository)},
+			ProfileForm:   newProfileForm(),
+			UploadForm:    newUploadForm(),
+		}
+
+		for _, bankAccount := range bankAccounts {
+			if bankAccount.IsManual() {
+				view.Accounts = append(view.Accounts, bankAccount)
+			}
+		}
+
+		return view, nil
+	}
+
+	e.GET("/imports", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		view, err := loadView(c.Request().Context(), user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to load imports: %v", err)
+			return c.String(500, "Something went wrong when listing imports...")
+		}
+
+		return layout.RenderPage(c, 200, ImportsPage(view))
+	})
+
+	e.POST("/imports/accounts", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		form := AccountForm{
+			Name:        strings.TrimSpace(c.FormValue("name")),
+			AccountType: c.FormValue("account_type"),
+			Currency:    strings.ToUpper(strings.TrimSpace(c.FormValue("currency"))),
+		}
+
+		accountType, err := models.ParseAccountType(form.AccountType)
+
+		if form.Name == "" {
+			form.NameError = "Enter a name"
+		}
+
+		if err != nil {
+			form.AccountTypeError = "Pick an account type"
+		}
+
+		if !currencyCodePattern.MatchString(form.Currency) {
+			form.CurrencyError = "Enter a three letter currency code, e.g. PLN"
+		}
+
+		status := 200
+
+		if !form.hasErrors() {
+			writeModel := models.ManualBankAccountWriteModel{UserID: user.ID, Name: form.Name, AccountType: accountType, Currency: form.Currency}
+
+			if _, err := unitOfWork.Repos().BankAccounts.CreateManual(ctx, writeModel); err != nil {
+				log.Errorf("Failed to create manual bank account: %v", err)
+				return c.String(500, "Something went wrong when adding the account...")
+			}
+		} else {
+			status = 422
+		}
+
+		view, err := loadView(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to load imports: %v", err)
+			return c.String(500, "Something went wrong when listing imports...")
+		}
+
+		if form.hasErrors() {
+			view.AccountForm = form
+		}
+
+		return layout.RenderComponent(c, status, ImportsSection(view))
+	})
+
+	e.POST("/imports/profiles", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		form, writeModel := parseProfileForm(c)
+		status := 200
+
+		if !form.hasErrors() {
+			if _, err := profileRepository.Save(ctx, user.ID, writeModel); err != nil {
+				log.Errorf("Failed to save import profile: %v", err)
+				return c.String(500, "Something went wrong when saving the profile...")
+			}
+		} else {
+			status = 422
+		}
+
+		view, err := loadView(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to load imports: %v", err)
+			return c.String(500, "Something went wrong when listing imports...")
+		}
+
+		if form.hasErrors() {
+			view.ProfileForm = form
+		}
+
+		return layout.RenderComponent(c, status, ImportsSection(view))
+	})
+
+	e.DELETE("/imports/profiles/:id", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			return c.String(400, "Invalid profile id")
+		}
+
+		err = profileRepository.Delete(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Profile not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to delete import profile: %v", err)
+			return c.String(500, "Something went wrong when deleting the profile...")
+		}
+
+		view, err := loadView(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to load imports: %v", err)
+			return c.String(500, "Something went wrong when listing imports...")
+		}
+
+		return layout.RenderComponent(c, 200, ImportsSection(view))
+	})
+
+	// Reads the file and shows what would be imported. Nothing is imported until the preview is confirmed.
+	e.POST("/imports", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		view, err := loadView(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to load imports: %v", err)
+			return c.String(500, "Something went wrong when listing imports...")
+		}
+
+		// Leaves some room for the rest of the multipart body
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxImportFileSize+1<<20)
+
+		if _, err := c.MultipartForm(); err != nil {
+			view.UploadForm.FileError = fmt.Sprintf("Pick a file of up to %d MB", maxImportFileSize>>20)
+			return layout.RenderComponent(c, 422, ImportsSection(view))
+		}
+
+		form := UploadForm{
+			Account:   c.FormValue("account"),
+			Format:    c.FormValue("format"),
+			Profile:   c.FormValue("profile"),
+			DateOrder: c.FormValue("date_order"),
+			Encoding:  c.FormValue("encoding"),
+		}
+
+		var bankAccount *models.BankAccount
+
+		for i, account := range view.Accounts {
+			if strconv.Itoa(account.ID) == form.Account {
+				bankAccount = &view.Accounts[i]
+			}
+		}
+
+		if bankAccount == nil {
+			form.AccountError = "Pick an account"
+		}
+
+		format := transactions.ImportFormat(form.Format)
+		options := Options{DayFirst: form.DateOrder == "dmy", Encoding: form.Encoding}
+
+		if _, ok := Encodings[form.Encoding]; !ok {
+			form.EncodingError = "Pick an encoding"
+		}
+
+		switch format {
+		case transactions.ImportFormatCSV:
+			profileID, err := strconv.Atoi(form.Profile)
+
+			if err != nil {
+				form.ProfileError = "Pick the profile of your bank's CSV files"
+				break
+			}
+
+			options.Profile, err = profileRepository.FindByID(ctx, user.ID, profileID)
+
+			if errors.Is(err, pgx.ErrNoRows) {
+				form.ProfileError = "Pick the profile of your bank's CSV files"
+			} else if err != nil {
+				log.Errorf("Failed to find import profile: %v", err)
+				return c.String(500, "Something went wrong when reading the file...")
+			}
+		case transactions.ImportFormatOFX, transactions.ImportFormatQIF, transactions.ImportFormatCamt053, transactions.ImportFormatMT940:
+		default:
+			form.FormatError = "Pick a format"
+		}
+
+		fileHeader, err := c.FormFile("file")
+
+		if err != nil || fileHeader.Size > maxImportFileSize {
+			form.FileError = fmt.Sprintf("Pick a file of up to %d MB", maxImportFileSize>>20)
+		}
+
+		if form.hasErrors() {
+			view.UploadForm = form
+			return layout.RenderComponent(c, 422, ImportsSection(view))
+		}
+
+		data, err := readFile(fileHeader)
+
+		if err != nil {
+			log.Errorf("Failed to read uploaded file: %v", err)
+			return c.String(500, "Something went wrong when reading the file...")
+		}
+
+		options.Currency = bankAccount.Currency
+
+		result, err := Parse(format, data, options)
+
+		if err != nil {
+			form.FileError = err.Error()
+			view.UploadForm = form
+			return layout.RenderComponent(c, 422, ImportsSection(view))
+		}
+
+		existing, err := unitOfWork.Repos().Transactions.ListAllForAccount(ctx, user.ID, bankAccount.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list transactions of bank account: %v", err)
+			return c.String(500, "Something went wrong when reading the file...")
+		}
+
+		newTransactions, duplicates := Deduplicate(bankAccount.ID, result.Transactions, existing)
+
+		transactionImport, err := unitOfWork.Repos().Imports.Create(ctx, user.ID, transactions.ImportWriteModel{
+			BankAccountID:  bankAccount.ID,
+			FileName:       fileHeader.Filename,
+			Format:         format,
+			PendingRows:    newTransactions,
+			Statements:     result.Statements,
+			DuplicateCount: len(duplicates),
+		})
+
+		if err != nil {
+			log.Errorf("Failed to record import: %v", err)
+			return c.String(500, "Something went wrong when reading the file...")
+		}
+
+		view.UploadForm = form
+		view.Preview = &importPreview{
+			Import:          transactionImport,
+			AccountName:     bankAccount.Name,
+			NewTransactions: newTransactions,
+			Duplicates:      duplicates,
+			Problems:        result.Problems,
+		}
+
+		if len(result.Statements) > 0 {
+			view.Preview.Mismatches = Reconcile(result.Statements, *bankAccount)
+		}
+
+		return layout.RenderComponent(c, 200, ImportsSection(view))
+	})
+
+	e.POST("/imports/:id/confirm", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid import id")
+		}
+
+		var committedImport transactions.Import
+		var committedBalance *models.BankAccount
+
+		err = unitOfWork.WithTx(ctx, func(tx unitofwork.Repos) error {
+			transactionImport, err := tx.Imports.FindPendingForUpdate(ctx, user.ID, id)
+
+			if err != nil {
+				return err
+			}
+
+			// Another import into the account may have been confirmed since the preview
+			existing, err := tx.Transactions.ListAllForAccount(ctx, user.ID, transactionImport.BankAccountID)
+
+			if err != nil {
+				return err
+			}
+
+			newTransactions, _ := Deduplicate(transactionImport.BankAccountID, transactionImport.PendingRows, existing)
+
+			importedTransactions, err := tx.Transactions.ImportAll(ctx, user.ID, transactionImport.BankAccountID, newTransactions)
+
+			if err != nil {
+				return err
+			}
+
+			if len(importedTransactions) > 0 {
+				categorizer := categorization.NewCategorizer(tx.CategorizationRules, tx.Transactions)
+
+				if _, err := categorizer.Categorize(ctx, user.ID, importedTransactions); err != nil {
+					return err
+				}
+
+				matcher := transfers.NewMatcher(tx.Transactions, tx.BankAccounts, tx.ExchangeRates)
+
+				if _, err := matcher.MatchSince(ctx, user.ID, earliestDatePosted(importedTransactions)); err != nil {
+					return err
+				}
+			}
+
+			// Statement files tell the balance of the account
+			if len(transactionImport.Statements) > 0 {
+				bankAccount, err := tx.BankAccounts.FindByID(ctx, user.ID, transactionImport.BankAccountID)
+
+				if err != nil {
+					return err
+				}
+
+				if balance, ok := ClosingBalance(transactionImport.Statements, bankAccount); ok {
+					updatedAccount, err := tx.BankAccounts.UpdateManualBalance(ctx, user.ID, bankAccount.ID, balance.Amount, balance.Date)
+
+					if err != nil {
+						return err
+					}
+
+					if err := tx.BalanceSnapshots.RecordAll(ctx, []models.BankAccount{updatedAccount}); err != nil {
+						return err
+					}
+
+					committedBalance = &updatedAccount
+				}
+			}
+
+			duplicateCount := transactionImport.DuplicateCount + len(transactionImport.PendingRows) - len(importedTransactions)
+
+			committedImport, err = tx.Imports.MarkCommitted(ctx, transactionImport.ID, len(importedTransactions), duplicateCount)
+
+			return err
+		})
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Import not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to commit import: %v", err)
+			return c.String(500, "Something went wrong when importing the transactions...")
+		}
+
+		view, err := loadView(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to load imports: %v", err)
+			return c.String(500, "Something went wrong when listing imports...")
+		}
+
+		view.Committed = &committedImport
+		view.CommittedBalance = committedBalance
+
+		return layout.RenderComponent(c, 200, ImportsSection(view))
+	})
+
+	e.DELETE("/imports/:id", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid import id")
+		}
+
+		err = unitOfWork.Repos().Imports.DeletePending(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Import not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to delete import: %v", err)
+			return c.String(500, "Something went wrong when cancelling the import...")
+		}
+
+		view, err := loadView(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to load imports: %v", err)
+			return c.String(500, "Something went wrong when listing imports...")
+		}
+
+		return layout.RenderComponent(c, 200, ImportsSection(view))
+	})
+}
+
+type importsView struct {
+	// Manual accounts only, the others are synced
+	Accounts      []models.BankAccount
+	Profiles      []Profile
+	RecentImports []transactions.Import
+	AccountForm   AccountForm
+	ProfileForm   ProfileForm
+	UploadForm    UploadForm
+	// Set after a file was read, until it is confirmed or cancelled
+	Preview *importPreview
+	// Set right after an import was confirmed
+	Committed *transactions.Import
+	// The account, when the import updated its balance
+	CommittedBalance *models.BankAccount
+}
+
+func (v importsView) accountName(bankAccountID int) string {
+	for _, account := range v.Accounts {
+		if account.ID == bankAccountID {
+			return account.Name
+		}
+	}
+
+	return ""
+}
+
+type importPreview struct {
+	Import          transactions.Import
+	AccountName     string
+	NewTransactions []transactions.DbTransactionWriteModel
+	// Transactions of the file which are in the account already
+	Duplicates []transactions.DbTransactionWriteModel
+	// Records of the file which could not be read
+	Problems []string
+	// Where the statements of the file do not add up or do not agree with the balance of the account
+	Mismatches []string
+}
+
+// AccountForm holds what the user entered into the form for a new manual account, along with validation errors
+type AccountForm struct {
+	Name        string
+	AccountType string
+	Currency    string
+
+	NameError        string
+	AccountTypeError string
+	CurrencyError    string
+}
+
+func (f AccountForm) hasErrors() bool {
+	return f.NameError != "" || f.AccountTypeError != "" || f.CurrencyError != ""
+}
+
+// UploadForm holds what the user picked for a file to import, along with validation errors
+type UploadForm struct {
+	Account string
+	Format  string
+	Profile string
+	// mdy or dmy, for QIF files
+	DateOrder string
+	// For QIF and MT940 files
+	Encoding string
+
+	AccountError  string
+	FormatError   string
+	ProfileError  string
+	EncodingError string
+	FileError     string
+}
+
+func newUploadForm() UploadForm {
+	return UploadForm{Format: string(transactions.ImportFormatCSV), DateOrder: "mdy", Encoding: "utf-8"}
+}
+
+func (f UploadForm) hasErrors() bool {
+	return f.AccountError != "" || f.FormatError != "" || f.ProfileError != "" || f.EncodingError != "" || f.FileError != ""
+}
+
+// ProfileForm holds what the user entered into the profile form, along with validation errors
+type ProfileForm struct {
+	Name              string
+	Delimiter         string
+	Encoding          string
+	SkipLines         string
+	HasHeader         bool
+	DateColumn        string
+	DateFormat        string
+	DescriptionColumn string
+	PayeeColumn       string
+	AmountColumn      string
+	DebitColumn       string
+	CreditColumn      string
+	CurrencyColumn    string
+	DecimalComma      bool
+	InvertAmounts     bool
+
+	NameError      string
+	DelimiterError string
+	SkipLinesError string
+	DateError      string
+	ColumnsError   string
+	AmountError    string
+	EncodingError  string
+}
+
+func newProfileForm() ProfileForm {
+	return ProfileForm{Delimiter: ",", Encoding: "utf-8", SkipLines: "0", HasHeader: true, DateFormat: DateFormatNames[0]}
+}
+
+func (f ProfileForm) hasErrors() bool {
+	return f.NameError != "" || f.DelimiterError != "" || f.SkipLinesError != "" || f.DateError != "" ||
+		f.ColumnsError != "" || f.AmountError != "" || f.EncodingError != ""
+}
+
+func parseProfileForm(c echo.Context) (ProfileForm, ProfileWriteModel) {
+	form := ProfileForm{
+		Name:              strings.TrimSpace(c.FormValue("name")),
+		Delimiter:         c.FormValue("delimiter"),
+		Encoding:          c.FormValue("encoding"),
+		SkipLines:         strings.TrimSpace(c.FormValue("skip_lines")),
+		HasHeader:         c.FormValue("has_header") == "1",
+		DateColumn:        strings.TrimSpace(c.FormValue("date_column")),
+		DateFormat:        c.FormValue("date_format"),
+		DescriptionColumn: strings.TrimSpace(c.FormValue("description_column")),
+		PayeeColumn:       strings.TrimSpace(c.FormValue("payee_column")),
+		AmountColumn:      strings.TrimSpace(c.FormValue("amount_column")),
+		DebitColumn:       strings.TrimSpace(c.FormValue("debit_column")),
+		CreditColumn:      strings.TrimSpace(c.FormValue("credit_column")),
+		CurrencyColumn:    strings.TrimSpace(c.FormValue("currency_column")),
+		DecimalComma:      c.FormValue("decimal_comma") == "1",
+		InvertAmounts:     c.FormValue("invert_amounts") == "1",
+	}
+
+	if form.Name == "" {
+		form.NameError = "Enter a name, e.g. the name of the bank"
+	}
+
+	// Tabs cannot be typed into the form
+	delimiter := []rune(strings.ReplaceAll(form.Delimiter, `\t`, "\t"))
+
+	if len(delimiter) != 1 || delimiter[0] == '"' || delimiter[0] == '\n' || delimiter[0] == '\r' {
+		form.DelimiterError = `Enter a single character, or \t for tabs`
+	}
+
+	if _, ok := Encodings[form.Encoding]; !ok {
+		form.EncodingError = "Pick an encoding"
+	}
+
+	skipLines, err := strconv.Atoi(form.SkipLines)
+
+	if err != nil || skipLines < 0 {
+		form.SkipLinesError = "Enter a number of lines"
+	}
+
+	if _, ok := DateFormats[form.DateFormat]; !ok || form.DateColumn == "" {
+		form.DateError = "Enter the date column and pick its format"
+	}
+
+	if form.DescriptionColumn == "" {
+		form.ColumnsError = "Enter the description column"
+	}
+
+	hasDebitAndCredit := form.DebitColumn != "" && form.CreditColumn != ""
+
+	if (form.AmountColumn == "") == !hasDebitAndCredit {
+		form.AmountError = "Enter either the amount column or both the debit and the credit column"
+	}
+
+	// Files without a header name their columns by position
+	if !form.HasHeader && form.ColumnsError == "" {
+		for _, column := range []string{form.DateColumn, form.DescriptionColumn, form.PayeeColumn, form.AmountColumn, form.DebitColumn, form.CreditColumn, form.CurrencyColumn} {
+			if position, err := strconv.Atoi(column); column != "" && (err != nil || position < 1) {
+				form.ColumnsError = "Enter columns by their position, starting at 1, for files without a header"
+			}
+		}
+	}
+
+	if form.hasErrors() {
+		return form, ProfileWriteModel{}
+	}
+
+	return form, ProfileWriteModel{
+		Name:              form.Name,
+		Delimiter:         delimiter[0],
+		Encoding:          form.Encoding,
+		SkipLines:         skipLines,
+		HasHeader:         form.HasHeader,
+		DateColumn:        form.DateColumn,
+		DateFormat:        form.DateFormat,
+		DescriptionColumn: form.DescriptionColumn,
+		PayeeColumn:       optionalString(form.PayeeColumn),
+		AmountColumn:      optionalString(form.AmountColumn),
+		DebitColumn:       optionalString(form.DebitColumn),
+		CreditColumn:      optionalString(form.CreditColumn),
+		CurrencyColumn:    optionalString(form.CurrencyColumn),
+		DecimalComma:      form.DecimalComma,
+		InvertAmounts:     form.InvertAmounts,
+	}
+}
+
+func readFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+func earliestDatePosted(importedTransactions []transactions.DbTransaction) time.Time {
+	earliest := importedTransactions[0].DatePosted
+
+	for _, transaction := range importedTransactions[1:] {
+		if transaction.DatePosted.Before(earliest) {
+			earliest = transaction.DatePosted
+		}
+	}
+
+	return earliest
+}
@@ -0,0 +1,342 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/transactions"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var mt940FieldPattern = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+// :61: statement lines, e.g. "2401150115D12,50NTRFNONREF//B4A15" followed by optional supplementary details
+var mt940StatementLinePattern = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)[A-Z]?(\d+,\d*)([A-Z][A-Z0-9]{3})`)
+
+// :60F:, :60M:, :62F: and :62M: balances, e.g. "C240115EUR1234,56"
+var mt940BalancePattern = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}\d{2}[A-Z0-9]{10,30}$`)
+
+// The keys SEPA transfers put before the parts of the remittance information, e.g. "EREF+" or "SVWZ+"
+var sepaKeyPattern = regexp.MustCompile(`\s*[A-Z]{4}\+.*$`)
+
+// Keywords of structured :86: fields in the SWIFT style, e.g. "/NAME/J. Doe/REMI/Invoice 12"
+var mt940Keywords = map[string]bool{
+	"TRTP": true, "IBAN": true, "BIC": true, "NAME": true, "REMI": true, "EREF": true, "CNTP": true, "MARF": true,
+	"CSID": true, "ORDP": true, "BENM": true, "ADDR": true, "PURP": true, "ULTC": true, "ULTD": true, "RTRN": true,
+	"ISDT": true, "USTD": true, "STRD": true, "SVCL": true, "PREF": true,
+}
+
+type mt940Field struct {
+	tag   string
+	value string
+}
+
+// ParseMT940 reads SWIFT MT940 statements. Each message becomes a statement, including the parts of statements
+// split across several messages, which open and close with intermediate balances.
+func ParseMT940(data []byte, currency string, encodingName string) (ParseResult, error) {
+	text, err := decode(data, encodingName)
+
+	if err != nil {
+		return ParseResult{}, err
+	}
+
+	fields := mt940Fields(text)
+
+	// Some banks put their own fields before the first statement
+	for len(fields) > 0 && fields[0].tag != "20" {
+		fields = fields[1:]
+	}
+
+	if len(fields) == 0 {
+		return ParseResult{}, errors.New("The file is not an MT940 file")
+	}
+
+	var result ParseResult
+	var statement *transactions.Statement
+	statementLines := 0
+
+	finishStatement := func() {
+		if statement != nil {
+			result.Statements = append(result.Statements, *statement)
+		}
+	}
+
+	for i, field := range fields {
+		switch field.tag {
+		case "20":
+			finishStatement()
+			statement = &transactions.Statement{Currency: currency, Total: decimal.Zero}
+		case "25":
+			statement.Account = strings.TrimSpace(field.value)
+		case "60F", "60M", "62F", "62M":
+			balance, balanceCurrency, err := parseMT940Balance(field.value)
+
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Sprintf("Statement of %s: %v", statement.Account, err))
+				continue
+			}
+
+			statement.Currency = balanceCurrency
+
+			if strings.HasPrefix(field.tag, "60") {
+				statement.Opening = &balance
+			} else {
+				statement.Closing = &balance
+			}
+		case "61":
+			statementLines++
+
+			// The information to the account owner belongs to the statement line before it
+			information := ""
+
+			if i+1 < len(fields) && fields[i+1].tag == "86" {
+				information = fields[i+1].value
+			}
+
+			writeModel, err := readMT940Transaction(field.value, information, statement.Currency)
+
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Sprintf("Statement line %d: %v", statementLines, err))
+				continue
+			}
+
+			statement.Total = statement.Total.Add(writeModel.Amount)
+			result.Transactions = append(result.Transactions, writeModel)
+		}
+	}
+
+	finishStatement()
+
+	return result, nil
+}
+
+// mt940Fields splits the messages into their fields. Lines which do not start a field continue the one before,
+// the headers and trailers of SWIFT messages are skipped.
+func mt940Fields(text string) []mt940Field {
+	var fields []mt940Field
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, "\r ")
+
+		if _, body, found := strings.Cut(line, "{4:"); found {
+			line = body
+		}
+
+		if line == "" || line == "-" || strings.HasPrefix(line, "-}") || strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		if match := mt940FieldPattern.FindStringSubmatch(line); match != nil {
+			fields = append(fields, mt940Field{tag: match[1], value: line[len(match[0]):]})
+			continue
+		}
+
+		if len(fields) > 0 {
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+
+	return fields
+}
+
+func parseMT940Balance(value string) (transactions.StatementBalance, string, error) {
+	match := mt940BalancePattern.FindStringSubmatch(value)
+
+	if match == nil {
+		return transactions.StatementBalance{}, "", fmt.Errorf("'%s' is not a balance", value)
+	}
+
+	date, err := time.Parse("060102", match[2])
+
+	if err != nil {
+		return transactions.StatementBalance{}, "", fmt.Errorf("'%s' is not a balance", value)
+	}
+
+	amount, err := parseAmount(match[4], true)
+
+	if err != nil {
+		return transactions.StatementBalance{}, "", err
+	}
+
+	if match[1] == "D" {
+		amount = amount.Neg()
+	}
+
+	return transactions.StatementBalance{Date: date, Amount: amount}, match[3], nil
+}
+
+func readMT940Transaction(statementLine string, information string, currency string) (transactions.DbTransactionWriteModel, error) {
+	match := mt940StatementLinePattern.FindStringSubmatch(statementLine)
+
+	if match == nil {
+		return transactions.DbTransactionWriteModel{}, fmt.Errorf("'%s' is not a statement line", strings.SplitN(statementLine, "\n", 2)[0])
+	}
+
+	valueDate, err := time.Parse("060102", match[1])
+
+	if err != nil {
+		return transactions.DbTransactionWriteModel{}, fmt.Errorf("'%s' is not a date", match[1])
+	}
+
+	bookingDate := valueDate
+
+	// The booking date has no year, it may be in the year before or after the value date
+	if match[2] != "" {
+		bookingDate, err = time.Parse("20060102", valueDate.Format("2006")+match[2])
+
+		if err != nil {
+			return transactions.DbTransactionWriteModel{}, fmt.Errorf("'%s' is not a date", match[2])
+		}
+
+		switch {
+		case bookingDate.Sub(valueDate) > 180*24*time.Hour:
+			bookingDate = bookingDate.AddDate(-1, 0, 0)
+		case valueDate.Sub(bookingDate) > 180*24*time.Hour:
+			bookingDate = bookingDate.AddDate(1, 0, 0)
+		}
+	}
+
+	amount, err := parseAmount(match[4], true)
+
+	if err != nil {
+		return transactions.DbTransactionWriteModel{}, err
+	}
+
+	// Debits and reversed credits take money out of the account, which is positive
+	if match[3] == "C" || match[3] == "RD" {
+		amount = amount.Neg()
+	}
+
+	counterpartyName, counterpartyIBAN, remittanceInfo := parseMT940Information(information)
+
+	writeModel := statementTransaction(amount, currency, bookingDate, valueDate, counterpartyName, counterpartyIBAN, remittanceInfo)
+
+	if writeModel.Name == "" {
+		writeModel.Name = strings.TrimSpace(statementLine[len(match[0]):])
+	}
+
+	writeModel.Raw = rawRecord(map[string]string{"61": statementLine, "86": information})
+
+	return writeModel, nil
+}
+
+// parseMT940Information reads the counterparty and the remittance information from a :86: field. Banks write
+// it as subfields like "166?20Invoice 12?32J. Doe?38DE89...", with keywords like "/NAME/J. Doe/REMI/Invoice 12",
+// or as free text.
+func parseMT940Information(information string) (name string, iban string, remittanceInfo string) {
+	if len(information) > 4 && isDigits(information[:3]) && strings.ContainsRune("?~^", rune(information[3])) {
+		return parseMT940Subfields(strings.ReplaceAll(information, "\n", ""), information[3])
+	}
+
+	if strings.HasPrefix(information, "/") {
+		return parseMT940Keywords(strings.ReplaceAll(information, "\n", ""))
+	}
+
+	return "", "", strings.Join(strings.Fields(information), " ")
+}
+
+// parseMT940Subfields reads the subfields used by German and Polish banks. 20 to 29 and 60 to 63 hold the
+// remittance information, 32 and 33 the name of the counterparty and 31 or 38 its account. Polish banks name
+// the counterparty in 27 and 28 when they do not use 32 and 33.
+func parseMT940Subfields(information string, separator byte) (string, string, string) {
+	subfields := map[string]string{}
+	var remittanceParts []string
+
+	for _, part := range strings.Split(information, string(separator))[1:] {
+		if len(part) < 2 {
+			continue
+		}
+
+		code, value := part[:2], part[2:]
+		subfields[code] += value
+
+		if (code >= "20" && code <= "29") || (code >= "60" && code <= "63") {
+			remittanceParts = append(remittanceParts, value)
+		}
+	}
+
+	name := strings.TrimSpace(subfields["32"] + subfields["33"])
+
+	if name == "" && subfields["27"] != "" {
+		name = strings.TrimSpace(subfields["27"] + subfields["28"])
+		remittanceParts = nil
+
+		for _, code := range []string{"20", "21", "22", "23", "24", "25", "26"} {
+			remittanceParts = append(remittanceParts, subfields[code])
+		}
+	}
+
+	iban := strings.ReplaceAll(subfields["38"], " ", "")
+
+	if account := strings.ReplaceAll(subfields["31"], " ", ""); iban == "" && ibanPattern.MatchString(account) {
+		iban = account
+	}
+
+	remittanceInfo := strings.Join(strings.Fields(strings.Join(remittanceParts, "")), " ")
+
+	// SEPA transfers prefix the parts of the remittance information, the purpose follows SVWZ+
+	if _, purpose, found := strings.Cut(remittanceInfo, "SVWZ+"); found {
+		remittanceInfo = strings.TrimSpace(sepaKeyPattern.ReplaceAllString(purpose, ""))
+	}
+
+	return name, iban, remittanceInfo
+}
+
+// parseMT940Keywords reads the keywords used by Dutch banks among others. The counterparty keyword CNTP
+// holds its account, BIC, name and city.
+func parseMT940Keywords(information string) (string, string, string) {
+	values := map[string]string{}
+	key := ""
+
+	for _, token := range strings.Split(information, "/") {
+		if mt940Keywords[token] {
+			key = token
+
+			if _, seen := values[key]; !seen {
+				values[key] = ""
+			}
+
+			continue
+		}
+
+		if key == "" {
+			continue
+		}
+
+		if values[key] != "" || token == "" {
+			values[key] += "/"
+		}
+
+		values[key] += token
+	}
+
+	counterparty := strings.Split(values["CNTP"], "/")
+	counterpartyPart := func(index int) string {
+		if index < len(counterparty) {
+			return strings.TrimSpace(counterparty[index])
+		}
+
+		return ""
+	}
+
+	name := firstNonEmpty(strings.Trim(values["NAME"], "/ "), counterpartyPart(2))
+	iban := firstNonEmpty(strings.Trim(values["IBAN"], "/ "), counterpartyPart(0))
+	remittanceInfo := firstNonEmpty(strings.Trim(values["REMI"], "/ "), strings.Trim(values["USTD"], "/ "))
+
+	return name, iban, strings.Join(strings.Fields(remittanceInfo), " ")
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return value != ""
+}
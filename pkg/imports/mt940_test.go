@@ -0,0 +1,74 @@
+package imports
+
+import "testing"
+
+func TestParseMT940(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string
+		want       []expectedTransaction
+		statements []expectedStatement
+		problems   []string
+	}{
+		{
+			// D and RC take money out of the account, C and RD bring it in
+			name:    "subfields with debits, credits and reversals",
+			fixture: "mt940_subfields.sta",
+			want: []expectedTransaction{
+				{"2024-03-01", "150.00", "EUR", "Stadtwerke - Strom Maerz"},
+				{"2024-03-05", "-2500.00", "EUR", "ACME GmbH - Gehalt Maerz"},
+				{"2024-03-07", "-10.00", "EUR", "Rueckbuchung Gutschrift"},
+				{"2024-03-08", "12.50", "EUR", "Storno Lastschrift"},
+			},
+			statements: []expectedStatement{
+				{"37040044/0532013000", "EUR", "2024-03-01", "1000.00", "2024-03-31", "3347.50", "-2347.50"},
+			},
+		},
+		{
+			name:    "keywords across two messages",
+			fixture: "mt940_keywords.sta",
+			want: []expectedTransaction{
+				{"2024-04-02", "25.00", "EUR", "J. Jansen - Factuur 12"},
+				{"2024-04-03", "-100.00", "EUR", "Werkgever BV - Salaris april"},
+				{"2024-05-02", "5.00", "EUR", "Bankkosten"},
+			},
+			statements: []expectedStatement{
+				{"NL91ABNA0417164300", "EUR", "2024-04-01", "-50.00", "2024-04-30", "25.00", "-75.00"},
+				{"NL91ABNA0417164300", "EUR", "2024-05-01", "25.00", "2024-05-31", "30.00", "5.00"},
+			},
+			problems: []string{"Statement line 4:"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParseMT940(readFixture(t, test.fixture), "USD", "utf-8")
+
+			if err != nil {
+				t.Fatalf("ParseMT940 failed: %v", err)
+			}
+
+			expectTransactions(t, result.Transactions, test.want)
+			expectStatements(t, result.Statements, test.statements)
+			expectProblems(t, result.Problems, test.problems)
+		})
+	}
+
+	if _, err := ParseMT940(readFixture(t, "not_an_export.txt"), "EUR", "utf-8"); err == nil {
+		t.Errorf("Expected a file without :20: to be rejected")
+	}
+}
+
+func TestParseMT940Counterparty(t *testing.T) {
+	result, err := ParseMT940(readFixture(t, "mt940_subfields.sta"), "EUR", "utf-8")
+
+	if err != nil {
+		t.Fatalf("ParseMT940 failed: %v", err)
+	}
+
+	counterparties := result.Transactions[0].Counterparties
+
+	if len(counterparties) != 1 || counterparties[0].IBAN == nil || *counterparties[0].IBAN != "DE02120300000000202051" {
+		t.Errorf("Expected the IBAN of ?38 as the counterparty's, got %+v", counterparties)
+	}
+}
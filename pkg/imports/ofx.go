@@ -0,0 +1,163 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"nerdmoney/pkg/transactions"
+	"strings"
+	"time"
+)
+
+// ParseOFX reads OFX and QFX files. Version 1 files are SGML which leaves out the closing tags of values,
+// version 2 files are XML. Both are read the same way, value by value, without validating the structure.
+func ParseOFX(data []byte, currency string) (ParseResult, error) {
+	encodingName := "utf-8"
+
+	// Version 1 headers name the code page of the file
+	if header, _, found := strings.Cut(string(data), "<"); found && strings.Contains(strings.ReplaceAll(header, " ", ""), "CHARSET:1252") {
+		encodingName = "windows-1252"
+	}
+
+	text, err := decode(data, encodingName)
+
+	if err != nil {
+		return ParseResult{}, err
+	}
+
+	if !strings.Contains(strings.ToUpper(text), "<OFX>") {
+		return ParseResult{}, errors.New("The file is not an OFX file")
+	}
+
+	var result ParseResult
+	var record map[string]string
+	statementCurrency := currency
+	count := 0
+
+	for _, element := range ofxElements(text) {
+		switch element.name {
+		case "STMTTRN":
+			record = map[string]string{}
+		case "/STMTTRN":
+			if record == nil {
+				continue
+			}
+
+			count++
+
+			writeModel, err := readOFXTransaction(record, statementCurrency)
+
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Sprintf("Transaction %d: %v", count, err))
+			} else {
+				result.Transactions = append(result.Transactions, writeModel)
+			}
+
+			record = nil
+		case "CURDEF":
+			statementCurrency = strings.ToUpper(element.value)
+		default:
+			if record != nil && element.value != "" && !strings.HasPrefix(element.name, "/") {
+				if _, seen := record[element.name]; !seen {
+					record[element.name] = element.value
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+type ofxElement struct {
+	name string
+	// The text up to the next tag
+	value string
+}
+
+func ofxElements(text string) []ofxElement {
+	var elements []ofxElement
+
+	for {
+		start := strings.IndexByte(text, '<')
+
+		if start < 0 {
+			return elements
+		}
+
+		end := strings.IndexByte(text[start:], '>')
+
+		if end < 0 {
+			return elements
+		}
+
+		name := strings.ToUpper(strings.TrimSpace(text[start+1 : start+end]))
+		text = text[start+end+1:]
+
+		value := text
+
+		if next := strings.IndexByte(text, '<'); next >= 0 {
+			value = text[:next]
+		}
+
+		// Skips the XML declaration and the processing instruction of version 2 headers
+		if strings.HasPrefix(name, "?") || strings.HasPrefix(name, "!") {
+			continue
+		}
+
+		elements = append(elements, ofxElement{name: name, value: html.UnescapeString(strings.TrimSpace(value))})
+	}
+}
+
+func readOFXTransaction(record map[string]string, currency string) (transactions.DbTransactionWriteModel, error) {
+	datePosted, err := parseOFXDate(record["DTPOSTED"])
+
+	if err != nil {
+		return transactions.DbTransactionWriteModel{}, err
+	}
+
+	dateAuthorized := datePosted
+
+	if record["DTUSER"] != "" {
+		if dateAuthorized, err = parseOFXDate(record["DTUSER"]); err != nil {
+			return transactions.DbTransactionWriteModel{}, err
+		}
+	}
+
+	amount, err := parseAmount(record["TRNAMT"], false)
+
+	if err != nil {
+		return transactions.DbTransactionWriteModel{}, err
+	}
+
+	description := describe(record["NAME"], record["MEMO"])
+
+	if description == "" {
+		description = record["TRNTYPE"]
+	}
+
+	return transactions.DbTransactionWriteModel{
+		// Debits are negative in OFX files
+		Amount:         amount.Neg(),
+		Currency:       currency,
+		DateAuthorized: dateAuthorized,
+		DatePosted:     datePosted,
+		Name:           description,
+		Raw:            rawRecord(record),
+	}, nil
+}
+
+// parseOFXDate reads the day of OFX dates, e.g. "20240115" or "20240115120000.000[-5:EST]". Banks which only
+// know the day fill in an arbitrary time, so the time is left out.
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("'%s' is not a date", value)
+	}
+
+	date, err := time.Parse("20060102", value[:8])
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not a date", value)
+	}
+
+	return date, nil
+}
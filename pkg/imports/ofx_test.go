@@ -0,0 +1,63 @@
+package imports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOFX(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		want     []expectedTransaction
+		problems []string
+	}{
+		{
+			name:    "version 1 SGML bank statement",
+			fixture: "statement_v1.ofx",
+			want: []expectedTransaction{
+				{"2024-01-15", "42.10", "USD", "GROCERY STORE"},
+				{"2024-01-20", "-1500.00", "USD", "PAYROLL - January & bonus"},
+				{"2024-01-31", "2.50", "USD", "SRVCHG"},
+			},
+			problems: []string{"Transaction 3:"},
+		},
+		{
+			name:    "version 2 XML credit card statement",
+			fixture: "statement_v2.ofx",
+			want: []expectedTransaction{
+				{"2024-02-05", "19.99", "EUR", "Streaming Service"},
+				{"2024-02-10", "-200.00", "EUR", "Payment - Thank you"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParseOFX(readFixture(t, test.fixture), "GBP")
+
+			if err != nil {
+				t.Fatalf("ParseOFX failed: %v", err)
+			}
+
+			expectTransactions(t, result.Transactions, test.want)
+			expectProblems(t, result.Problems, test.problems)
+		})
+	}
+
+	if _, err := ParseOFX(readFixture(t, "not_an_export.txt"), "GBP"); err == nil {
+		t.Errorf("Expected a file without <OFX> to be rejected")
+	}
+}
+
+func TestParseOFXKeepsTheUserDate(t *testing.T) {
+	result, err := ParseOFX(readFixture(t, "statement_v1.ofx"), "USD")
+
+	if err != nil {
+		t.Fatalf("ParseOFX failed: %v", err)
+	}
+
+	if authorized := result.Transactions[0].DateAuthorized.Format(time.DateOnly); authorized != "2024-01-14" {
+		t.Errorf("Expected DTUSER to be the authorized date, got %s", authorized)
+	}
+}
@@ -0,0 +1,74 @@
+package imports
+
+import "time"
+
+// Profile describes the CSV export of a bank. Columns are named by their header, or by their position
+// starting at 1 when the file has no header.
+type Profile struct {
+	ID     int
+	UserID int
+	Name   string
+
+	Delimiter rune
+	// One of Encodings
+	Encoding string
+	// Lines before the header, e.g. account details some banks put at the top
+	SkipLines int
+	HasHeader bool
+
+	DateColumn string
+	// One of DateFormats
+	DateFormat        string
+	DescriptionColumn string
+	PayeeColumn       *string
+	// Either AmountColumn or both DebitColumn and CreditColumn are set
+	AmountColumn   *string
+	DebitColumn    *string
+	CreditColumn   *string
+	CurrencyColumn *string
+	DecimalComma   bool
+	// For exports which show money leaving the account as positive amounts
+	InvertAmounts bool
+}
+
+type ProfileWriteModel struct {
+	Name              string
+	Delimiter         rune
+	Encoding          string
+	SkipLines         int
+	HasHeader         bool
+	DateColumn        string
+	DateFormat        string
+	DescriptionColumn string
+	PayeeColumn       *string
+	AmountColumn      *string
+	DebitColumn       *string
+	CreditColumn      *string
+	CurrencyColumn    *string
+	DecimalComma      bool
+	InvertAmounts     bool
+}
+
+// DateFormats maps the date formats users pick from to their Go layouts
+var DateFormats = map[string]string{
+	"YYYY-MM-DD": "2006-01-02",
+	"YYYY/MM/DD": "2006/01/02",
+	"DD.MM.YYYY": "02.01.2006",
+	"DD/MM/YYYY": "02/01/2006",
+	"DD-MM-YYYY": "02-01-2006",
+	"MM/DD/YYYY": "01/02/2006",
+}
+
+// DateFormatNames lists DateFormats in the order they are offered
+var DateFormatNames = []string{"YYYY-MM-DD", "YYYY/MM/DD", "DD.MM.YYYY", "DD/MM/YYYY", "DD-MM-YYYY", "MM/DD/YYYY"}
+
+// parseDate also accepts dates followed by a time, which some banks add to every row
+func parseDate(value string, dateFormat string) (time.Time, error) {
+	layout := DateFormats[dateFormat]
+
+	if len(value) > len(layout) {
+		value = value[:len(layout)]
+	}
+
+	return time.Parse(layout, value)
+}
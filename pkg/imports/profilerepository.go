@@ -0,0 +1,176 @@
+package imports
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type ProfileRepository interface {
+	ListAll(ctx context.Context, userID int) ([]Profile, error)
+	FindByID(ctx context.Context, userID int, id int) (Profile, error)
+	// Save creates a profile, or replaces the user's profile with the same name
+	Save(ctx context.Context, userID int, writeModel ProfileWriteModel) (Profile, error)
+	Delete(ctx context.Context, userID int, id int) error
+}
+
+type profileRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewProfileRepository(pool *pgxpool.Pool, log echo.Logger) ProfileRepository {
+	return &profileRepositoryImpl{pool, log}
+}
+
+const profileColumns = `id, user_id, name, delimiter, encoding, skip_lines, has_header, date_column, date_format, description_column, payee_column,
+	amount_column, debit_column, credit_column, currency_column, decimal_comma, invert_amounts`
+
+func (r *profileRepositoryImpl) ListAll(ctx context.Context, userID int) ([]Profile, error) {
+	r.log.Debugf("Attempting to list all import profiles of User with id='%d'", userID)
+
+	rows, err := r.db.Query(ctx, `SELECT `+profileColumns+` FROM import_profile WHERE user_id = $1 ORDER BY name`, userID)
+
+	if err != nil {
+		return []Profile{}, fmt.Errorf("Failed to list all import profiles of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var profiles []Profile
+
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+
+		if err != nil {
+			return []Profile{}, fmt.Errorf("Failed to read import profile row: %w", err)
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []Profile{}, fmt.Errorf("Failed to read import profile rows: %w", err)
+	}
+
+	return profiles, nil
+}
+
+func (r *profileRepositoryImpl) FindByID(ctx context.Context, userID int, id int) (Profile, error) {
+	r.log.Debugf("Attempting to find import profile with id='%d'", id)
+
+	profile, err := scanProfile(r.db.QueryRow(ctx, `SELECT `+profileColumns+` FROM import_profile WHERE id = $1 AND user_id = $2`, id, userID))
+
+	if err != nil {
+		return Profile{}, fmt.Errorf("Failed to find import profile with id='%d': %w", id, err)
+	}
+
+	return profile, nil
+}
+
+func (r *profileRepositoryImpl) Save(ctx context.Context, userID int, writeModel ProfileWriteModel) (Profile, error) {
+	r.log.Debugf("Attempting to save import profile '%s'", writeModel.Name)
+
+	query := `
+	INSERT INTO import_profile (
+		user_id, name, delimiter, encoding, skip_lines, has_header, date_column, date_format, description_column, payee_column,
+		amount_column, debit_column, credit_column, currency_column, decimal_comma, invert_amounts
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	ON CONFLICT (user_id, name) DO UPDATE SET
+		delimiter = EXCLUDED.delimiter,
+		encoding = EXCLUDED.encoding,
+		skip_lines = EXCLUDED.skip_lines,
+		has_header = EXCLUDED.has_header,
+		date_column = EXCLUDED.date_column,
+		date_format = EXCLUDED.date_format,
+		description_column = EXCLUDED.description_column,
+		payee_column = EXCLUDED.payee_column,
+		amount_column = EXCLUDED.amount_column,
+		debit_column = EXCLUDED.debit_column,
+		credit_column = EXCLUDED.credit_column,
+		currency_column = EXCLUDED.currency_column,
+		decimal_comma = EXCLUDED.decimal_comma,
+		invert_amounts = EXCLUDED.invert_amounts
+	RETURNING ` + profileColumns
+
+	profile, err := scanProfile(r.db.QueryRow(
+		ctx,
+		query,
+		userID,
+		writeModel.Name,
+		string(writeModel.Delimiter),
+		writeModel.Encoding,
+		writeModel.SkipLines,
+		writeModel.HasHeader,
+		writeModel.DateColumn,
+		writeModel.DateFormat,
+		writeModel.DescriptionColumn,
+		writeModel.PayeeColumn,
+		writeModel.AmountColumn,
+		writeModel.DebitColumn,
+		writeModel.CreditColumn,
+		writeModel.CurrencyColumn,
+		writeModel.DecimalComma,
+		writeModel.InvertAmounts,
+	))
+
+	if err != nil {
+		return Profile{}, fmt.Errorf("Failed to save import profile '%s': %w", writeModel.Name, err)
+	}
+
+	return profile, nil
+}
+
+func (r *profileRepositoryImpl) Delete(ctx context.Context, userID int, id int) error {
+	r.log.Debugf("Attempting to delete import profile with id='%d'", id)
+
+	commandTag, err := r.db.Exec(ctx, `DELETE FROM import_profile WHERE id = $1 AND user_id = $2`, id, userID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete import profile with id='%d': %w", id, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("Failed to delete import profile with id='%d': %w", id, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func scanProfile(row pgx.Row) (Profile, error) {
+	var profile Profile
+	var delimiter string
+
+	err := row.Scan(
+		&profile.ID,
+		&profile.UserID,
+		&profile.Name,
+		&delimiter,
+		&profile.Encoding,
+		&profile.SkipLines,
+		&profile.HasHeader,
+		&profile.DateColumn,
+		&profile.DateFormat,
+		&profile.DescriptionColumn,
+		&profile.PayeeColumn,
+		&profile.AmountColumn,
+		&profile.DebitColumn,
+		&profile.CreditColumn,
+		&profile.CurrencyColumn,
+		&profile.DecimalComma,
+		&profile.InvertAmounts,
+	)
+
+	if err != nil {
+		return Profile{}, err
+	}
+
+	profile.Delimiter = []rune(delimiter)[0]
+
+	return profile, nil
+}
@@ -0,0 +1,171 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/transactions"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sections of QIF files which hold transactions of bank, cash, credit card and asset or liability accounts
+var qifTransactionSections = map[string]bool{
+	"!type:bank":  true,
+	"!type:cash":  true,
+	"!type:ccard": true,
+	"!type:oth a": true,
+	"!type:oth l": true,
+}
+
+// ParseQIF reads the transactions of QIF files. Records of other sections, like account lists, categories
+// and investments, are skipped. Splits are not imported, only the total of the transaction.
+func ParseQIF(data []byte, currency string, dayFirst bool, encodingName string) (ParseResult, error) {
+	text, err := decode(data, encodingName)
+
+	if err != nil {
+		return ParseResult{}, err
+	}
+
+	var result ParseResult
+	// Files without a header have transactions only
+	inTransactions := true
+	recordLine := 0
+	record := map[string]string{}
+	readRecord := false
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case strings.HasPrefix(line, "!"):
+			section := strings.ToLower(strings.TrimSpace(line))
+
+			// Options such as !Option:AutoSwitch do not start a section
+			if !strings.HasPrefix(section, "!option") && !strings.HasPrefix(section, "!clear") {
+				inTransactions = qifTransactionSections[section]
+			}
+
+			readRecord = true
+		case line[0] == '^':
+			if inTransactions && len(record) > 0 {
+				writeModel, err := readQIFTransaction(record, currency, dayFirst)
+
+				if err != nil {
+					result.Problems = append(result.Problems, fmt.Sprintf("Line %d: %v", recordLine, err))
+				} else {
+					result.Transactions = append(result.Transactions, writeModel)
+				}
+			}
+
+			record = map[string]string{}
+			readRecord = true
+		default:
+			code := string(line[0])
+
+			if len(record) == 0 {
+				recordLine = i + 1
+			}
+
+			// Split lines repeat, the first of each is kept in the raw record
+			if _, seen := record[code]; !seen {
+				record[code] = strings.TrimSpace(line[1:])
+			}
+		}
+	}
+
+	if !readRecord {
+		return ParseResult{}, errors.New("The file is not a QIF file")
+	}
+
+	return result, nil
+}
+
+func readQIFTransaction(record map[string]string, currency string, dayFirst bool) (transactions.DbTransactionWriteModel, error) {
+	date, err := parseQIFDate(record["D"], dayFirst)
+
+	if err != nil {
+		return transactions.DbTransactionWriteModel{}, err
+	}
+
+	amountValue := record["T"]
+
+	if amountValue == "" {
+		amountValue = record["U"]
+	}
+
+	amount, err := parseAmount(amountValue, false)
+
+	if err != nil {
+		return transactions.DbTransactionWriteModel{}, err
+	}
+
+	description := describe(record["P"], record["M"])
+
+	if description == "" {
+		return transactions.DbTransactionWriteModel{}, errors.New("The transaction has no payee or memo")
+	}
+
+	return transactions.DbTransactionWriteModel{
+		// Payments are negative in QIF files
+		Amount:         amount.Neg(),
+		Currency:       currency,
+		DateAuthorized: date,
+		DatePosted:     date,
+		Name:           description,
+		MerchantName:   optionalString(record["P"]),
+		Raw:            rawRecord(record),
+	}, nil
+}
+
+// parseQIFDate reads the dates programs write into QIF files, e.g. "2024-01-15", "1/15/2024", "1/15'24",
+// "15.01.2024" or "15/01/24". Two digit years before 70 are in the 2000s.
+func parseQIFDate(value string, dayFirst bool) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '/' || r == '.' || r == '-' || r == '\'' || r == ' '
+	})
+
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("'%s' is not a date", value)
+	}
+
+	numbers := make([]int, 3)
+
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+
+		if err != nil {
+			return time.Time{}, fmt.Errorf("'%s' is not a date", value)
+		}
+
+		numbers[i] = number
+	}
+
+	month, day, year := numbers[0], numbers[1], numbers[2]
+
+	if dayFirst {
+		month, day = day, month
+	}
+
+	switch {
+	case year < 70:
+		year += 2000
+	case year < 100:
+		year += 1900
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+
+	// time.Date normalizes days past the end of the month, e.g. when the order of day and month is wrong
+	if date.Day() != day || int(date.Month()) != month {
+		return time.Time{}, fmt.Errorf("'%s' is not a date", value)
+	}
+
+	return date, nil
+}
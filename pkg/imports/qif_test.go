@@ -0,0 +1,84 @@
+package imports
+
+import "testing"
+
+func TestParseQIF(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		dayFirst bool
+		want     []expectedTransaction
+		problems []string
+	}{
+		{
+			name:    "month first with splits and an investment section",
+			fixture: "month_first.qif",
+			want: []expectedTransaction{
+				{"2024-01-15", "4.50", "USD", "Coffee Shop - Latte"},
+				{"2024-01-16", "-2500.00", "USD", "ACME Corp"},
+				{"2024-01-18", "60.00", "USD", "Supermarket"},
+			},
+			// February 30th
+			problems: []string{"Line 11:"},
+		},
+		{
+			name:     "day first after an account list",
+			fixture:  "day_first.qif",
+			dayFirst: true,
+			want: []expectedTransaction{
+				{"2024-01-15", "850.00", "USD", "Landlord - Rent January"},
+				{"2024-01-16", "-12.50", "USD", "Interest"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParseQIF(readFixture(t, test.fixture), "USD", test.dayFirst, "utf-8")
+
+			if err != nil {
+				t.Fatalf("ParseQIF failed: %v", err)
+			}
+
+			expectTransactions(t, result.Transactions, test.want)
+			expectProblems(t, result.Problems, test.problems)
+		})
+	}
+
+	if _, err := ParseQIF(readFixture(t, "not_an_export.txt"), "USD", false, "utf-8"); err == nil {
+		t.Errorf("Expected a file without records to be rejected")
+	}
+}
+
+func TestParseQIFDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		dayFirst bool
+		want     string
+	}{
+		{"2024-01-15", false, "2024-01-15"},
+		{"1/15/2024", false, "2024-01-15"},
+		{"1/15'24", false, "2024-01-15"},
+		{"12/31/99", false, "1999-12-31"},
+		{"15.01.2024", true, "2024-01-15"},
+		{"15/01/24", true, "2024-01-15"},
+	}
+
+	for _, test := range tests {
+		date, err := parseQIFDate(test.value, test.dayFirst)
+
+		if err != nil {
+			t.Errorf("parseQIFDate(%q, %v) failed: %v", test.value, test.dayFirst, err)
+			continue
+		}
+
+		if got := date.Format("2006-01-02"); got != test.want {
+			t.Errorf("parseQIFDate(%q, %v) = %s, want %s", test.value, test.dayFirst, got, test.want)
+		}
+	}
+
+	// Day first dates read month first do not exist
+	if _, err := parseQIFDate("15/01/2024", false); err == nil {
+		t.Errorf("Expected 15/01/2024 to be rejected when the month comes first")
+	}
+}
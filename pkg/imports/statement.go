@@ -0,0 +1,221 @@
+package imports
+
+import (
+	"fmt"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/transactions"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// statementTransaction maps a transaction of a camt.053 or MT940 statement. The booking date is when the
+// bank posted it, the value date when the money actually moved.
+func statementTransaction(
+	amount decimal.Decimal,
+	currency string,
+	bookingDate time.Time,
+	valueDate time.Time,
+	counterpartyName string,
+	counterpartyIBAN string,
+	remittanceInfo string,
+) transactions.DbTransactionWriteModel {
+	writeModel := transactions.DbTransactionWriteModel{
+		Amount:         amount,
+		Currency:       currency,
+		DateAuthorized: valueDate,
+		DatePosted:     bookingDate,
+		Name:           firstNonEmpty(describe(counterpartyName, remittanceInfo), counterpartyIBAN),
+		MerchantName:   optionalString(counterpartyName),
+	}
+
+	// Statements do not tell what kind of counterparty it is
+	if counterpartyName != "" || counterpartyIBAN != "" {
+		writeModel.Counterparties = []transactions.Counterparty{{Name: counterpartyName, IBAN: optionalString(counterpartyIBAN)}}
+	}
+
+	return writeModel
+}
+
+// Reconcile checks that every statement adds up from its opening to its closing balance, that the statements
+// follow on each other, and that they agree with the balance stored with the account. It returns the mismatches.
+func Reconcile(statements []transactions.Statement, bankAccount models.BankAccount) []string {
+	statements = sortStatements(statements)
+
+	var mismatches []string
+
+	for i, statement := range statements {
+		if statement.Currency != bankAccount.Currency {
+			mismatches = append(mismatches, fmt.Sprintf("The statement %s is in %s, the account in %s", describeStatement(statement), statement.Currency, bankAccount.Currency))
+		}
+
+		if statement.Opening == nil || statement.Closing == nil {
+			mismatches = append(mismatches, fmt.Sprintf("The statement %s states no opening or closing balance to check it against", describeStatement(statement)))
+		} else if expected := statement.Opening.Amount.Sub(statement.Total); !expected.Equal(statement.Closing.Amount) {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"The statement %s does not add up, its opening balance of %s and the transactions make %s but it closes with %s",
+				describeStatement(statement),
+				statement.Opening.Amount.StringFixed(2),
+				expected.StringFixed(2),
+				statement.Closing.Amount.StringFixed(2),
+			))
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		previous := statements[i-1]
+
+		if previous.Closing != nil && statement.Opening != nil && !previous.Closing.Amount.Equal(statement.Opening.Amount) {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"Transactions are missing between the statement closing with %s on %s and the statement opening with %s on %s",
+				previous.Closing.Amount.StringFixed(2),
+				previous.Closing.Date.Format(time.DateOnly),
+				statement.Opening.Amount.StringFixed(2),
+				statement.Opening.Date.Format(time.DateOnly),
+			))
+		}
+	}
+
+	if mismatch := reconcileStoredBalance(statements, bankAccount); mismatch != "" {
+		mismatches = append(mismatches, mismatch)
+	}
+
+	return mismatches
+}
+
+// reconcileStoredBalance compares the balance stored with the account to the balances of the statements on
+// the same day. Statements which start later have to open with the stored balance, or transactions are
+// missing in between. Older statements cannot be checked against it.
+func reconcileStoredBalance(statements []transactions.Statement, bankAccount models.BankAccount) string {
+	if !bankAccount.CurrentBalance.Valid || bankAccount.BalanceUpdatedAt == nil || len(statements) == 0 {
+		return ""
+	}
+
+	storedOn := bankAccount.BalanceUpdatedAt.UTC().Format(time.DateOnly)
+	stored := statementAmount(bankAccount.CurrentBalance.Decimal, bankAccount.AccountType)
+
+	var balancesOnSameDay []transactions.StatementBalance
+
+	for _, statement := range statements {
+		for _, balance := range []*transactions.StatementBalance{statement.Opening, statement.Closing} {
+			if balance != nil && balance.Date.Format(time.DateOnly) == storedOn {
+				balancesOnSameDay = append(balancesOnSameDay, *balance)
+			}
+		}
+	}
+
+	if len(balancesOnSameDay) > 0 {
+		for _, balance := range balancesOnSameDay {
+			if balance.Amount.Equal(stored) {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf(
+			"The account's balance on %s is %s, but the statement shows %s",
+			storedOn,
+			stored.StringFixed(2),
+			balancesOnSameDay[0].Amount.StringFixed(2),
+		)
+	}
+
+	opening := statements[0].Opening
+
+	if opening != nil && opening.Date.Format(time.DateOnly) > storedOn && !opening.Amount.Equal(stored) {
+		return fmt.Sprintf(
+			"The account's balance on %s is %s, but the statement opens with %s on %s, transactions in between may be missing",
+			storedOn,
+			stored.StringFixed(2),
+			opening.Amount.StringFixed(2),
+			opening.Date.Format(time.DateOnly),
+		)
+	}
+
+	return ""
+}
+
+// ClosingBalance returns the balance of the account at the close of the latest statement, as it is stored with
+// accounts. It returns false when the statements state none or the stored balance is more recent.
+func ClosingBalance(statements []transactions.Statement, bankAccount models.BankAccount) (transactions.StatementBalance, bool) {
+	statements = sortStatements(statements)
+
+	if len(statements) == 0 {
+		return transactions.StatementBalance{}, false
+	}
+
+	latest := statements[len(statements)-1]
+
+	if latest.Closing == nil || latest.Currency != bankAccount.Currency {
+		return transactions.StatementBalance{}, false
+	}
+
+	if bankAccount.BalanceUpdatedAt != nil && latest.Closing.Date.Format(time.DateOnly) < bankAccount.BalanceUpdatedAt.UTC().Format(time.DateOnly) {
+		return transactions.StatementBalance{}, false
+	}
+
+	return transactions.StatementBalance{
+		Date:   latest.Closing.Date,
+		Amount: statementAmount(latest.Closing.Amount, bankAccount.AccountType),
+	}, true
+}
+
+// statementAmount converts between statement balances and stored balances, which are positive for the amount
+// owed on credit cards and loans
+func statementAmount(amount decimal.Decimal, accountType models.AccountType) decimal.Decimal {
+	if accountType == models.Credit || accountType == models.Loan {
+		return amount.Neg()
+	}
+
+	return amount
+}
+
+// sortStatements orders the statements by the day they close, or open if they state no closing balance
+func sortStatements(statements []transactions.Statement) []transactions.Statement {
+	sorted := make([]transactions.Statement, len(statements))
+	copy(sorted, statements)
+
+	statementDate := func(statement transactions.Statement) time.Time {
+		if statement.Closing != nil {
+			return statement.Closing.Date
+		}
+
+		if statement.Opening != nil {
+			return statement.Opening.Date
+		}
+
+		return time.Time{}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return statementDate(sorted[i]).Before(statementDate(sorted[j]))
+	})
+
+	return sorted
+}
+
+func describeStatement(statement transactions.Statement) string {
+	description := "of " + statement.Account
+
+	if statement.Account == "" {
+		description = "without an account"
+	}
+
+	if statement.Closing != nil {
+		description += " closing on " + statement.Closing.Date.Format(time.DateOnly)
+	}
+
+	return description
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
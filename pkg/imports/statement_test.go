@@ -0,0 +1,271 @@
+package imports
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/transactions"
+
+	"github.com/shopspring/decimal"
+)
+
+// expectedStatement is what a test checks about a parsed statement, with empty dates for balances it has not
+type expectedStatement struct {
+	account     string
+	currency    string
+	openingDate string
+	opening     string
+	closingDate string
+	closing     string
+	total       string
+}
+
+func expectStatements(t *testing.T, got []transactions.Statement, want []expectedStatement) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d statements, got %d: %+v", len(want), len(got), got)
+	}
+
+	for i, expected := range want {
+		statement := got[i]
+		actual := expectedStatement{account: statement.Account, currency: statement.Currency, total: statement.Total.StringFixed(2)}
+
+		if statement.Opening != nil {
+			actual.openingDate = statement.Opening.Date.Format(time.DateOnly)
+			actual.opening = statement.Opening.Amount.StringFixed(2)
+		}
+
+		if statement.Closing != nil {
+			actual.closingDate = statement.Closing.Date.Format(time.DateOnly)
+			actual.closing = statement.Closing.Amount.StringFixed(2)
+		}
+
+		if actual != expected {
+			t.Errorf("Statement %d: expected %+v, got %+v", i, expected, actual)
+		}
+	}
+}
+
+func parseStatements(t *testing.T, fixture string) []transactions.Statement {
+	t.Helper()
+
+	var result ParseResult
+	var err error
+
+	if strings.HasSuffix(fixture, ".sta") {
+		result, err = ParseMT940(readFixture(t, fixture), "EUR", "utf-8")
+	} else {
+		result, err = ParseCamt053(readFixture(t, fixture), "EUR")
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", fixture, err)
+	}
+
+	return result.Statements
+}
+
+func bankAccount(accountType models.AccountType, currency string, balance string, updatedOn string) models.BankAccount {
+	account := models.BankAccount{AccountType: accountType, Currency: currency}
+
+	if balance != "" {
+		updatedAt, _ := time.Parse(time.DateOnly, updatedOn)
+		account.CurrentBalance = decimal.NewNullDecimal(decimal.RequireFromString(balance))
+		account.BalanceUpdatedAt = &updatedAt
+	}
+
+	return account
+}
+
+func statementBalance(date string, amount string) *transactions.StatementBalance {
+	parsed, _ := time.Parse(time.DateOnly, date)
+	return &transactions.StatementBalance{Date: parsed, Amount: decimal.RequireFromString(amount)}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		statements  []transactions.Statement
+		bankAccount models.BankAccount
+		// Each mismatch has to contain its text, in order
+		want []string
+	}{
+		{
+			name:        "statement which adds up",
+			fixture:     "camt053_v02.xml",
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+		},
+		{
+			name:        "consecutive statements which add up",
+			fixture:     "mt940_subfields.sta",
+			bankAccount: bankAccount(models.Depository, "EUR", "3347.50", "2024-03-31"),
+		},
+		{
+			name:        "statement which does not add up",
+			fixture:     "mt940_keywords.sta",
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+			want:        []string{"does not add up, its opening balance of 25.00 and the transactions make 20.00 but it closes with 30.00"},
+		},
+		{
+			name:        "second of two statements does not add up",
+			fixture:     "camt053_v08.xml",
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+			want:        []string{"The statement of 0532013000 closing on 2024-05-31 does not add up"},
+		},
+		{
+			name:        "statement in another currency",
+			fixture:     "camt053_v02.xml",
+			bankAccount: bankAccount(models.Depository, "USD", "", ""),
+			want:        []string{"is in EUR, the account in USD"},
+		},
+		{
+			name: "statements with a gap between them",
+			statements: []transactions.Statement{
+				{Account: "gap", Currency: "EUR", Opening: statementBalance("2024-02-01", "90"), Closing: statementBalance("2024-02-29", "90"), Total: decimal.Zero},
+				{Account: "gap", Currency: "EUR", Opening: statementBalance("2024-01-01", "100"), Closing: statementBalance("2024-01-31", "100"), Total: decimal.Zero},
+			},
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+			want:        []string{"Transactions are missing between the statement closing with 100.00 on 2024-01-31 and the statement opening with 90.00 on 2024-02-01"},
+		},
+		{
+			name: "statement without balances",
+			statements: []transactions.Statement{
+				{Account: "", Currency: "EUR", Total: decimal.NewFromInt(5)},
+			},
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+			want:        []string{"The statement without an account states no opening or closing balance"},
+		},
+		{
+			name:        "stored balance differs on the same day",
+			fixture:     "mt940_subfields.sta",
+			bankAccount: bankAccount(models.Depository, "EUR", "3000", "2024-03-31"),
+			want:        []string{"The account's balance on 2024-03-31 is 3000.00, but the statement shows 3347.50"},
+		},
+		{
+			name:        "stored balance before the statement differs from its opening balance",
+			fixture:     "mt940_subfields.sta",
+			bankAccount: bankAccount(models.Depository, "EUR", "900", "2024-02-15"),
+			want:        []string{"transactions in between may be missing"},
+		},
+		{
+			name:        "stored balance after the statement cannot be checked",
+			fixture:     "mt940_subfields.sta",
+			bankAccount: bankAccount(models.Depository, "EUR", "5", "2024-06-01"),
+		},
+		{
+			// Credit cards store the amount owed, statements show it as a debit balance
+			name:        "credit card owing the closing balance",
+			fixture:     "camt053_v08.xml",
+			bankAccount: bankAccount(models.Credit, "EUR", "20", "2024-04-30"),
+			want:        []string{"closing on 2024-05-31 does not add up"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statements := test.statements
+
+			if test.fixture != "" {
+				statements = parseStatements(t, test.fixture)
+			}
+
+			mismatches := Reconcile(statements, test.bankAccount)
+
+			if len(mismatches) != len(test.want) {
+				t.Fatalf("Expected %d mismatches, got %d: %v", len(test.want), len(mismatches), mismatches)
+			}
+
+			for i, text := range test.want {
+				if !strings.Contains(mismatches[i], text) {
+					t.Errorf("Expected mismatch %d to contain '%s', got '%s'", i, text, mismatches[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClosingBalance(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		statements  []transactions.Statement
+		bankAccount models.BankAccount
+		wantDate    string
+		wantAmount  string
+		wantOK      bool
+	}{
+		{
+			name:        "latest closing balance",
+			fixture:     "mt940_keywords.sta",
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+			wantDate:    "2024-05-31",
+			wantAmount:  "30.00",
+			wantOK:      true,
+		},
+		{
+			name:        "overdrawn credit card is stored as owed",
+			fixture:     "camt053_v08.xml",
+			bankAccount: bankAccount(models.Credit, "EUR", "", ""),
+			wantDate:    "2024-05-31",
+			wantAmount:  "-100.00",
+			wantOK:      true,
+		},
+		{
+			name:        "stored balance on the same day is replaced",
+			fixture:     "camt053_v02.xml",
+			bankAccount: bankAccount(models.Depository, "EUR", "1800", "2024-03-31"),
+			wantDate:    "2024-03-31",
+			wantAmount:  "1812.35",
+			wantOK:      true,
+		},
+		{
+			name:        "stored balance is more recent",
+			fixture:     "camt053_v02.xml",
+			bankAccount: bankAccount(models.Depository, "EUR", "1800", "2024-04-02"),
+		},
+		{
+			name:        "statement in another currency",
+			fixture:     "camt053_v02.xml",
+			bankAccount: bankAccount(models.Depository, "USD", "", ""),
+		},
+		{
+			name: "statement without a closing balance",
+			statements: []transactions.Statement{
+				{Account: "open", Currency: "EUR", Opening: statementBalance("2024-01-01", "10"), Total: decimal.Zero},
+			},
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+		},
+		{
+			name:        "no statements",
+			bankAccount: bankAccount(models.Depository, "EUR", "", ""),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statements := test.statements
+
+			if test.fixture != "" {
+				statements = parseStatements(t, test.fixture)
+			}
+
+			balance, ok := ClosingBalance(statements, test.bankAccount)
+
+			if ok != test.wantOK {
+				t.Fatalf("Expected ok=%v, got %v with %+v", test.wantOK, ok, balance)
+			}
+
+			if !ok {
+				return
+			}
+
+			if date := balance.Date.Format(time.DateOnly); date != test.wantDate || balance.Amount.StringFixed(2) != test.wantAmount {
+				t.Errorf("Expected %s on %s, got %s on %s", test.wantAmount, test.wantDate, balance.Amount.StringFixed(2), date)
+			}
+		})
+	}
+}
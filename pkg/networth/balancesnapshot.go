@@ -0,0 +1,19 @@
+package networth
+
+import (
+	"nerdmoney/pkg/accounts/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BalanceSnapshot is the balance of a bank account at some point in time
+type BalanceSnapshot struct {
+	ID               int64
+	BankAccountID    int
+	AccountType      models.AccountType
+	CurrentBalance   decimal.NullDecimal
+	AvailableBalance decimal.NullDecimal
+	Currency         string
+	TakenAt          time.Time
+}
@@ -0,0 +1,125 @@
+package networth
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/common/database"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type BalanceSnapshotRepository interface {
+	// RecordAll saves the current balances of the bank accounts as new snapshots
+	RecordAll(ctx context.Context, bankAccounts []models.BankAccount) error
+	// ListAllForUser returns the snapshots of all accounts of the user taken since the given time, ordered by
+	// when they were taken. The last snapshot of every account taken before that time is included too, so
+	// that balances which did not change during the period are known.
+	ListAllForUser(ctx context.Context, userID int, since time.Time) ([]BalanceSnapshot, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) BalanceSnapshotRepository
+}
+
+type balanceSnapshotRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewBalanceSnapshotRepository(pool *pgxpool.Pool, log echo.Logger) BalanceSnapshotRepository {
+	return &balanceSnapshotRepositoryImpl{pool, log}
+}
+
+func (r *balanceSnapshotRepositoryImpl) WithTx(tx pgx.Tx) BalanceSnapshotRepository {
+	return &balanceSnapshotRepositoryImpl{tx, r.log}
+}
+
+func (r *balanceSnapshotRepositoryImpl) RecordAll(ctx context.Context, bankAccounts []models.BankAccount) error {
+	r.log.Debugf("Attempting to record balance snapshots of %d bank accounts", len(bankAccounts))
+
+	if len(bankAccounts) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO balance_snapshot (bank_account_id, current_balance, available_balance, currency) VALUES ($1, $2, $3, $4)`
+
+	batch := &pgx.Batch{}
+
+	for _, bankAccount := range bankAccounts {
+		batch.Queue(query, bankAccount.ID, bankAccount.CurrentBalance, bankAccount.AvailableBalance, bankAccount.Currency)
+	}
+
+	if err := r.db.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("Failed to record balance snapshots: %w", err)
+	}
+
+	return nil
+}
+
+func (r *balanceSnapshotRepositoryImpl) ListAllForUser(ctx context.Context, userID int, since time.Time) ([]BalanceSnapshot, error) {
+	r.log.Debugf("Attempting to list balance snapshots of User with id='%d' since %s", userID, since)
+
+	query := `
+	SELECT * FROM (
+		SELECT DISTINCT ON (balance_snapshot.bank_account_id)
+			balance_snapshot.id, balance_snapshot.bank_account_id, bank_account.account_type, balance_snapshot.current_balance,
+			balance_snapshot.available_balance, balance_snapshot.currency, balance_snapshot.taken_at
+		FROM balance_snapshot
+		JOIN bank_account ON bank_account.id = balance_snapshot.bank_account_id
+		WHERE bank_account.user_id = $1 AND balance_snapshot.taken_at < $2
+		ORDER BY balance_snapshot.bank_account_id, balance_snapshot.taken_at DESC
+	) AS before_since
+	UNION ALL
+	SELECT
+		balance_snapshot.id, balance_snapshot.bank_account_id, bank_account.account_type, balance_snapshot.current_balance,
+		balance_snapshot.available_balance, balance_snapshot.currency, balance_snapshot.taken_at
+	FROM balance_snapshot
+	JOIN bank_account ON bank_account.id = balance_snapshot.bank_account_id
+	WHERE bank_account.user_id = $1 AND balance_snapshot.taken_at >= $2
+	ORDER BY taken_at, id`
+
+	rows, err := r.db.Query(ctx, query, userID, since)
+
+	if err != nil {
+		return []BalanceSnapshot{}, fmt.Errorf("Failed to list balance snapshots of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var snapshots []BalanceSnapshot
+
+	for rows.Next() {
+		var snapshot BalanceSnapshot
+		var accountTypeStr string
+
+		err := rows.Scan(
+			&snapshot.ID,
+			&snapshot.BankAccountID,
+			&accountTypeStr,
+			&snapshot.CurrentBalance,
+			&snapshot.AvailableBalance,
+			&snapshot.Currency,
+			&snapshot.TakenAt,
+		)
+
+		if err != nil {
+			return []BalanceSnapshot{}, fmt.Errorf("Failed to read balance snapshot row: %w", err)
+		}
+
+		snapshot.AccountType, err = models.ParseAccountType(accountTypeStr)
+
+		if err != nil {
+			return []BalanceSnapshot{}, fmt.Errorf("Failed to parse account type of balance snapshot with id='%d': %w", snapshot.ID, err)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []BalanceSnapshot{}, fmt.Errorf("Failed to read rows when trying to list balance snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,119 @@
+package networth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	chartWidth   = 600
+	chartHeight  = 200
+	chartPadding = 10
+)
+
+// A TimeRange is one of the periods the net worth chart can show
+type TimeRange string
+
+const (
+	OneMonth    TimeRange = "1M"
+	ThreeMonths TimeRange = "3M"
+	OneYear     TimeRange = "1Y"
+	All         TimeRange = "All"
+)
+
+var timeRanges = []TimeRange{OneMonth, ThreeMonths, OneYear, All}
+
+// ParseTimeRange returns ThreeMonths for unknown ranges
+func ParseTimeRange(source string) TimeRange {
+	for _, timeRange := range timeRanges {
+		if string(timeRange) == source {
+			return timeRange
+		}
+	}
+
+	return ThreeMonths
+}
+
+// Since returns when the range starts if it ends at `now`, or the zero time for All
+func (tr TimeRange) Since(now time.Time) time.Time {
+	switch tr {
+	case OneMonth:
+		return startOfDay(now.AddDate(0, -1, 0))
+	case ThreeMonths:
+		return startOfDay(now.AddDate(0, -3, 0))
+	case OneYear:
+		return startOfDay(now.AddDate(-1, 0, 0))
+	default:
+		return time.Time{}
+	}
+}
+
+// lineChart holds everything needed to draw a series as an SVG polyline
+type lineChart struct {
+	Currency string
+	// Polyline points in SVG coordinates, "x1,y1 x2,y2 ..."
+	Points    string
+	Latest    decimal.Decimal
+	Min       decimal.Decimal
+	Max       decimal.Decimal
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// newLineChart scales the series to fit the chart. Every currency gets a chart of its own, as their
+// values can not be compared without converting them first.
+func newLineChart(series Series) lineChart {
+	chart := lineChart{Currency: series.Currency}
+
+	if len(series.Points) == 0 {
+		return chart
+	}
+
+	chart.Min = series.Points[0].Value
+	chart.Max = series.Points[0].Value
+
+	for _, point := range series.Points {
+		chart.Min = decimal.Min(chart.Min, point.Value)
+		chart.Max = decimal.Max(chart.Max, point.Value)
+	}
+
+	chart.Latest = series.Points[len(series.Points)-1].Value
+	chart.StartDate = series.Points[0].Date
+	chart.EndDate = series.Points[len(series.Points)-1].Date
+
+	minValue := chart.Min.InexactFloat64()
+	valueRange := chart.Max.InexactFloat64() - minValue
+	innerWidth := float64(chartWidth - 2*chartPadding)
+	innerHeight := float64(chartHeight - 2*chartPadding)
+
+	coordinates := make([]string, 0, len(series.Points))
+
+	for i, point := range series.Points {
+		x := float64(chartPadding)
+
+		if len(series.Points) > 1 {
+			x += innerWidth * float64(i) / float64(len(series.Points)-1)
+		}
+
+		// A flat line is drawn through the middle
+		y := float64(chartPadding) + innerHeight/2
+
+		if valueRange > 0 {
+			y = float64(chartPadding) + innerHeight*(1-(point.Value.InexactFloat64()-minValue)/valueRange)
+		}
+
+		coordinates = append(coordinates, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+
+	// A single point is drawn as a short horizontal line, a polyline needs two points to be visible
+	if len(coordinates) == 1 {
+		coordinates = append(coordinates, fmt.Sprintf("%d,%s", chartWidth-chartPadding, strings.Split(coordinates[0], ",")[1]))
+	}
+
+	chart.Points = strings.Join(coordinates, " ")
+
+	return chart
+}
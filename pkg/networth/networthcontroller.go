@@ -0,0 +1,30 @@
+package networth
+
+import (
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/users"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterNetWorthRoutes(e *echo.Echo, balanceSnapshotRepository BalanceSnapshotRepository) {
+
+	log := e.Logger
+
+	e.GET("/net-worth", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		timeRange := ParseTimeRange(c.QueryParam("range"))
+		now := time.Now()
+		since := timeRange.Since(now)
+
+		snapshots, err := balanceSnapshotRepository.ListAllForUser(c.Request().Context(), user.ID, since)
+
+		if err != nil {
+			log.Errorf("Failed to list balance snapshots: %v", err)
+			return c.String(500, "Something went wrong when loading your net worth...")
+		}
+
+		return layout.RenderComponent(c, 200, NetWorth(timeRange, ComputeSeries(snapshots, since, now)))
+	})
+}
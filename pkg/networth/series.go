@@ -0,0 +1,89 @@
+package networth
+
+import (
+	"nerdmoney/pkg/accounts/models"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Point is the net worth at the end of a day
+type Point struct {
+	Date  time.Time
+	Value decimal.Decimal
+}
+
+// Series is the net worth over time of all accounts in one currency
+type Series struct {
+	Currency string
+	Points   []Point
+}
+
+// ComputeSeries turns balance snapshots ordered by when they were taken into one net worth series per currency,
+// with a point for every day from `from` until `to`. An account keeps its last known balance on days without
+// a snapshot. The series start at the first snapshot when `from` is zero or earlier than any snapshot.
+func ComputeSeries(snapshots []BalanceSnapshot, from, to time.Time) []Series {
+	if len(snapshots) == 0 {
+		return []Series{}
+	}
+
+	start := startOfDay(from)
+	firstDay := startOfDay(snapshots[0].TakenAt)
+
+	if start.Before(firstDay) {
+		start = firstDay
+	}
+
+	latestByAccount := map[int]BalanceSnapshot{}
+	pointsByCurrency := map[string][]Point{}
+	next := 0
+
+	for day := start; !day.After(to); day = day.AddDate(0, 0, 1) {
+		endOfDay := day.AddDate(0, 0, 1)
+
+		for next < len(snapshots) && snapshots[next].TakenAt.Before(endOfDay) {
+			latestByAccount[snapshots[next].BankAccountID] = snapshots[next]
+			next++
+		}
+
+		totals := map[string]decimal.Decimal{}
+
+		for _, snapshot := range latestByAccount {
+			totals[snapshot.Currency] = totals[snapshot.Currency].Add(netWorthContribution(snapshot))
+		}
+
+		for currency, total := range totals {
+			pointsByCurrency[currency] = append(pointsByCurrency[currency], Point{Date: day, Value: total})
+		}
+	}
+
+	allSeries := make([]Series, 0, len(pointsByCurrency))
+
+	for currency, points := range pointsByCurrency {
+		allSeries = append(allSeries, Series{Currency: currency, Points: points})
+	}
+
+	sort.Slice(allSeries, func(i, j int) bool { return allSeries[i].Currency < allSeries[j].Currency })
+
+	return allSeries
+}
+
+// Balances of credit cards and loans are what we owe, everything else is what we own
+func netWorthContribution(snapshot BalanceSnapshot) decimal.Decimal {
+	if !snapshot.CurrentBalance.Valid {
+		return decimal.Zero
+	}
+
+	switch snapshot.AccountType {
+	case models.Credit, models.Loan:
+		return snapshot.CurrentBalance.Decimal.Neg()
+	default:
+		return snapshot.CurrentBalance.Decimal
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
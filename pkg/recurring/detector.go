@@ -0,0 +1,227 @@
+package recurring
+
+import (
+	"math"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/transactions"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// Amounts of a series may differ from their median by this share, e.g. because of a price increase
+var amountTolerance = decimal.NewFromFloat(0.2)
+
+// Share of the intervals between the transactions of a series which have to match its frequency
+const regularIntervalShare = 0.75
+
+type seriesKey struct {
+	bankAccountID int
+	currency      string
+	merchant      string
+}
+
+// Detect finds recurring series in the history of outgoing transactions: transactions to the same merchant from
+// the same account, with similar amounts and at a regular interval. The series are ordered by their next date.
+func Detect(allTransactions []transactions.DbTransaction) []Series {
+	groups := map[seriesKey][]transactions.DbTransaction{}
+	var keys []seriesKey
+
+	for _, transaction := range allTransactions {
+		// Paying off a credit card every month is not a subscription
+		if transaction.Pending || transaction.IsTransfer() || !transaction.Amount.IsPositive() {
+			continue
+		}
+
+		key := seriesKey{transaction.BankAccountID, transaction.Currency, merchantKey(transaction)}
+
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		groups[key] = append(groups[key], transaction)
+	}
+
+	var detected []Series
+
+	for _, key := range keys {
+		group := groups[key]
+
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].DatePosted.Equal(group[j].DatePosted) {
+				return group[i].ID < group[j].ID
+			}
+
+			return group[i].DatePosted.Before(group[j].DatePosted)
+		})
+
+		group = withSimilarAmounts(group)
+
+		if frequency, ok := detectFrequency(group); ok {
+			detected = append(detected, newSeries(group[len(group)-1].DisplayName(), frequency, group))
+		}
+	}
+
+	sortByNextDate(detected)
+
+	return detected
+}
+
+// WithProviderStreams marks the detected series which the provider found as well and adds the active series only
+// the provider found. Streams are matched to series by their transactions.
+func WithProviderStreams(detected []Series, streams []banking.RecurringStream, allTransactions []transactions.DbTransaction) []Series {
+	transactionsByPlaidID := map[string]transactions.DbTransaction{}
+
+	for _, transaction := range allTransactions {
+		transactionsByPlaidID[transaction.PlaidTransactionID] = transaction
+	}
+
+	seriesByPlaidID := map[string]int{}
+
+	for i, series := range detected {
+		for _, transaction := range series.Transactions {
+			seriesByPlaidID[transaction.PlaidTransactionID] = i
+		}
+	}
+
+	for _, stream := range streams {
+		matched := false
+
+		for _, plaidTransactionID := range stream.ProviderTransactionIDs {
+			if i, ok := seriesByPlaidID[plaidTransactionID]; ok {
+				detected[i].ConfirmedByProvider = true
+				matched = true
+			}
+		}
+
+		frequency, knownFrequency := frequencyFromProvider(stream.Frequency)
+
+		if matched || !stream.Active || !knownFrequency {
+			continue
+		}
+
+		var streamTransactions []transactions.DbTransaction
+
+		for _, plaidTransactionID := range stream.ProviderTransactionIDs {
+			if transaction, ok := transactionsByPlaidID[plaidTransactionID]; ok {
+				streamTransactions = append(streamTransactions, transaction)
+			}
+		}
+
+		// Transactions which were not synced yet, e.g. older than the synced history
+		if len(streamTransactions) == 0 {
+			continue
+		}
+
+		sort.Slice(streamTransactions, func(i, j int) bool {
+			return streamTransactions[i].DatePosted.Before(streamTransactions[j].DatePosted)
+		})
+
+		name := stream.Description
+
+		if stream.MerchantName != nil {
+			name = *stream.MerchantName
+		}
+
+		series := newSeries(name, frequency, streamTransactions)
+		series.ConfirmedByProvider = true
+
+		detected = append(detected, series)
+	}
+
+	sortByNextDate(detected)
+
+	return detected
+}
+
+// merchantKey identifies the merchant of a transaction. Descriptions often contain changing numbers, e.g. dates or
+// order numbers, which are left out.
+func merchantKey(transaction transactions.DbTransaction) string {
+	if transaction.MerchantName != nil {
+		return strings.ToLower(*transaction.MerchantName)
+	}
+
+	withoutDigits := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			return unicode.ToLower(r)
+		}
+
+		return ' '
+	}, transaction.Name)
+
+	return strings.Join(strings.Fields(withoutDigits), " ")
+}
+
+// withSimilarAmounts leaves out transactions whose amount is far from the median, e.g. a one-off purchase at
+// the same merchant as a subscription
+func withSimilarAmounts(group []transactions.DbTransaction) []transactions.DbTransaction {
+	amounts := make([]decimal.Decimal, 0, len(group))
+
+	for _, transaction := range group {
+		amounts = append(amounts, transaction.Amount)
+	}
+
+	sort.Slice(amounts, func(i, j int) bool { return amounts[i].LessThan(amounts[j]) })
+
+	median := amounts[len(amounts)/2]
+	maxDifference := median.Mul(amountTolerance)
+
+	var similar []transactions.DbTransaction
+
+	for _, transaction := range group {
+		if transaction.Amount.Sub(median).Abs().LessThanOrEqual(maxDifference) {
+			similar = append(similar, transaction)
+		}
+	}
+
+	return similar
+}
+
+// detectFrequency looks for the frequency which most of the intervals between the transactions match
+func detectFrequency(group []transactions.DbTransaction) (Frequency, bool) {
+	if len(group) < 2 {
+		return "", false
+	}
+
+	intervals := make([]int, 0, len(group)-1)
+
+	for i := 1; i < len(group); i++ {
+		intervals = append(intervals, int(math.Round(group[i].DatePosted.Sub(group[i-1].DatePosted).Hours()/24)))
+	}
+
+	required := int(math.Ceil(float64(len(intervals)) * regularIntervalShare))
+
+	for _, frequency := range detectedFrequencies {
+		if len(group) < frequency.minOccurrences() {
+			continue
+		}
+
+		matching := 0
+
+		for _, interval := range intervals {
+			if abs(interval-frequency.Days()) <= frequency.Tolerance() {
+				matching++
+			}
+		}
+
+		if matching >= required {
+			return frequency, true
+		}
+	}
+
+	return "", false
+}
+
+func sortByNextDate(series []Series) {
+	sort.SliceStable(series, func(i, j int) bool { return series[i].NextDate.Before(series[j].NextDate) })
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
@@ -0,0 +1,104 @@
+package recurring
+
+import (
+	"time"
+)
+
+// Frequency is how often the transactions of a recurring series happen
+type Frequency string
+
+const (
+	FrequencyWeekly      Frequency = "weekly"
+	FrequencyBiweekly    Frequency = "biweekly"
+	FrequencySemiMonthly Frequency = "semi-monthly"
+	FrequencyMonthly     Frequency = "monthly"
+	FrequencyAnnually    Frequency = "annually"
+)
+
+// detectedFrequencies are the frequencies the detector looks for, semi-monthly series are only known from the provider
+var detectedFrequencies = []Frequency{FrequencyWeekly, FrequencyBiweekly, FrequencyMonthly, FrequencyAnnually}
+
+// Days is the usual number of days between two transactions
+func (f Frequency) Days() int {
+	switch f {
+	case FrequencyWeekly:
+		return 7
+	case FrequencyBiweekly:
+		return 14
+	case FrequencySemiMonthly:
+		return 15
+	case FrequencyAnnually:
+		return 365
+	default:
+		return 30
+	}
+}
+
+// Tolerance is how many days a transaction may be early or late, e.g. because of weekends and shorter months
+func (f Frequency) Tolerance() int {
+	switch f {
+	case FrequencyWeekly:
+		return 2
+	case FrequencyBiweekly, FrequencySemiMonthly:
+		return 3
+	case FrequencyAnnually:
+		return 15
+	default:
+		return 5
+	}
+}
+
+// PerYear is the number of transactions in a year
+func (f Frequency) PerYear() int64 {
+	switch f {
+	case FrequencyWeekly:
+		return 52
+	case FrequencyBiweekly:
+		return 26
+	case FrequencySemiMonthly:
+		return 24
+	case FrequencyAnnually:
+		return 1
+	default:
+		return 12
+	}
+}
+
+// Next returns the day of the transaction after the one on the given day
+func (f Frequency) Next(date time.Time) time.Time {
+	switch f {
+	case FrequencyMonthly:
+		return date.AddDate(0, 1, 0)
+	case FrequencyAnnually:
+		return date.AddDate(1, 0, 0)
+	default:
+		return date.AddDate(0, 0, f.Days())
+	}
+}
+
+// minOccurrences is how many transactions it takes before a series is considered recurring
+func (f Frequency) minOccurrences() int {
+	if f == FrequencyAnnually {
+		return 2
+	}
+
+	return 3
+}
+
+// frequencyFromProvider maps the provider's frequencies, it returns false for unknown ones
+func frequencyFromProvider(frequency string) (Frequency, bool) {
+	switch frequency {
+	case "WEEKLY":
+		return FrequencyWeekly, true
+	case "BIWEEKLY":
+		return FrequencyBiweekly, true
+	case "SEMI_MONTHLY":
+		return FrequencySemiMonthly, true
+	case "MONTHLY":
+		return FrequencyMonthly, true
+	case "ANNUALLY":
+		return FrequencyAnnually, true
+	default:
+		return "", false
+	}
+}
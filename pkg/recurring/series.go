@@ -0,0 +1,83 @@
+package recurring
+
+import (
+	"nerdmoney/pkg/transactions"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// How many transactions before the most recent one are looked at for price changes
+const priceChangeWindow = 3
+
+// Series is a recurring outgoing payment, e.g. a subscription
+type Series struct {
+	Name          string
+	BankAccountID int
+	Currency      string
+	Frequency     Frequency
+	// Oldest first. Positive amounts are money leaving the account.
+	Transactions []transactions.DbTransaction
+	// When the next transaction is expected
+	NextDate time.Time
+	// Whether the provider found the series as well
+	ConfirmedByProvider bool
+}
+
+func (s Series) last() transactions.DbTransaction {
+	return s.Transactions[len(s.Transactions)-1]
+}
+
+// LastAmount is the amount of the most recent transaction, which is also the amount expected next
+func (s Series) LastAmount() decimal.Decimal {
+	return s.last().Amount
+}
+
+func (s Series) LastDate() time.Time {
+	return s.last().DatePosted
+}
+
+// PreviousAmount is the last amount different from the current one within the recent transactions, or the current
+// amount if it did not change lately
+func (s Series) PreviousAmount() decimal.Decimal {
+	for i := len(s.Transactions) - 2; i >= max(0, len(s.Transactions)-1-priceChangeWindow); i-- {
+		if !s.Transactions[i].Amount.Equal(s.LastAmount()) {
+			return s.Transactions[i].Amount
+		}
+	}
+
+	return s.LastAmount()
+}
+
+// PriceIncreased tells whether the amount went up lately
+func (s Series) PriceIncreased() bool {
+	return s.LastAmount().GreaterThan(s.PreviousAmount())
+}
+
+// YearlyCost is the cost of a year at the current price
+func (s Series) YearlyCost() decimal.Decimal {
+	return s.LastAmount().Mul(decimal.NewFromInt(s.Frequency.PerYear()))
+}
+
+// Missed tells whether the next transaction is overdue
+func (s Series) Missed(now time.Time) bool {
+	return now.After(s.NextDate.AddDate(0, 0, s.Frequency.Tolerance()))
+}
+
+// Active tells whether the series is likely to continue. A single missed payment does not end a series.
+func (s Series) Active(now time.Time) bool {
+	return !now.After(s.Frequency.Next(s.NextDate).AddDate(0, 0, s.Frequency.Tolerance()))
+}
+
+func newSeries(name string, frequency Frequency, seriesTransactions []transactions.DbTransaction) Series {
+	last := seriesTransactions[len(seriesTransactions)-1]
+
+	return Series{
+		Name:          name,
+		BankAccountID: last.BankAccountID,
+		Currency:      last.Currency,
+		Frequency:     frequency,
+		Transactions:  seriesTransactions,
+		NextDate:      frequency.Next(last.DatePosted),
+	}
+}
@@ -0,0 +1,152 @@
+package recurring
+
+import (
+	"context"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/unitofwork"
+	"nerdmoney/pkg/users"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+// Annual series need two transactions, a bit more than two years of history covers them
+const historyMonths = 26
+
+func RegisterSubscriptionRoutes(
+	e *echo.Echo,
+	bankingProvider banking.BankingProvider,
+	unitOfWork unitofwork.UnitOfWork,
+	exchangeRateRepository exchangerates.ExchangeRateRepository,
+) {
+
+	log := e.Logger
+
+	// The provider's series are only an additional signal, connections it fails for are left out
+	providerStreams := func(ctx context.Context, userID int, bankAccounts []models.BankAccount) []banking.RecurringStream {
+		connections, err := unitOfWork.Repos().BankConnections.ListAllForUser(ctx, userID)
+
+		if err != nil {
+			log.Errorf("Failed to list bank connections: %v", err)
+			return []banking.RecurringStream{}
+		}
+
+		var streams []banking.RecurringStream
+
+		for _, connection := range connections {
+			if connection.Disabled || connection.LoginRequired {
+				continue
+			}
+
+			var providerAccountIDs []string
+
+			for _, bankAccount := range bankAccounts {
+				if !bankAccount.IsManual() && *bankAccount.BankConnectionID == connection.ID {
+					providerAccountIDs = append(providerAccountIDs, *bankAccount.PlaidAccountId)
+				}
+			}
+
+			if len(providerAccountIDs) == 0 {
+				continue
+			}
+
+			connectionStreams, err := bankingProvider.RecurringTransactions(ctx, connection.AccessToken, providerAccountIDs)
+
+			if err != nil {
+				log.Errorf("Failed to get recurring transactions of bank connection with id='%d': %v", connection.ID, err)
+				continue
+			}
+
+			streams = append(streams, connectionStreams...)
+		}
+
+		return streams
+	}
+
+	e.GET("/subscriptions", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+		now := time.Now()
+
+		from := now.AddDate(0, -historyMonths, 0)
+
+		userTransactions, err := transactions.ListAllPages(ctx, unitOfWork.Repos().Transactions, transactions.TransactionFilter{UserID: user.ID, From: &from})
+
+		if err != nil {
+			log.Errorf("Failed to list transactions: %v", err)
+			return c.String(500, "Something went wrong when listing subscriptions...")
+		}
+
+		bankAccounts, err := unitOfWork.Repos().BankAccounts.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list bank accounts: %v", err)
+			return c.String(500, "Something went wrong when listing subscriptions...")
+		}
+
+		rates, err := exchangeRateRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list exchange rates: %v", err)
+			return c.String(500, "Something went wrong when listing subscriptions...")
+		}
+
+		allSeries := WithProviderStreams(Detect(userTransactions), providerStreams(ctx, user.ID, bankAccounts), userTransactions)
+
+		view := subscriptionsView{
+			Now:          now,
+			BaseCurrency: user.BaseCurrency,
+			YearlyCost:   decimal.Zero,
+			AccountNames: map[int]string{},
+		}
+
+		for _, bankAccount := range bankAccounts {
+			view.AccountNames[bankAccount.ID] = bankAccount.Name
+		}
+
+		converter := exchangerates.NewConverter(rates)
+		missingRates := map[string]bool{}
+
+		for _, series := range allSeries {
+			if !series.Active(now) {
+				continue
+			}
+
+			view.Subscriptions = append(view.Subscriptions, series)
+
+			yearlyCost, ok := converter.Convert(series.YearlyCost(), series.Currency, user.BaseCurrency, now)
+
+			if !ok {
+				missingRates[series.Currency] = true
+				continue
+			}
+
+			view.YearlyCost = view.YearlyCost.Add(yearlyCost)
+		}
+
+		for currency := range missingRates {
+			view.MissingRates = append(view.MissingRates, currency)
+		}
+
+		sort.Strings(view.MissingRates)
+
+		return layout.RenderPage(c, 200, SubscriptionsPage(view))
+	})
+}
+
+type subscriptionsView struct {
+	Now           time.Time
+	Subscriptions []Series
+	BaseCurrency  string
+	// Of all subscriptions, in the base currency
+	YearlyCost decimal.Decimal
+	// Currencies of subscriptions left out of the yearly cost
+	MissingRates []string
+	AccountNames map[int]string
+}
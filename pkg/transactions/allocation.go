@@ -0,0 +1,93 @@
+package transactions
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+// A split needs at least this many allocations, fewer would just be the transaction itself
+const minAllocations = 2
+
+// Decimal places of the amounts of allocations, as stored in transaction_allocation
+const allocationAmountPlaces = 3
+
+var ErrTooFewAllocations = errors.New("A split needs at least two parts")
+var ErrAllocationsSumMismatch = errors.New("The parts have to add up to the amount of the transaction")
+
+// Allocation is a part of a split transaction with its own category. Reports and budgets use the allocations of a
+// split transaction instead of the transaction.
+type Allocation struct {
+	ID            int64
+	TransactionID int64
+	// Positive amounts are money leaving the account, like the amount of the transaction
+	Amount     decimal.Decimal
+	CategoryID *int
+	Notes      *string
+}
+
+type AllocationWriteModel struct {
+	Amount     decimal.Decimal
+	CategoryID *int
+	Notes      *string
+}
+
+// ValidateSplit checks that the allocations add up to the amount of the transaction
+func ValidateSplit(transaction DbTransaction, allocations []AllocationWriteModel) error {
+	if len(allocations) < minAllocations {
+		return ErrTooFewAllocations
+	}
+
+	if !AllocationsTotal(allocations).Equal(transaction.Amount) {
+		return ErrAllocationsSumMismatch
+	}
+
+	return nil
+}
+
+func AllocationsTotal(allocations []AllocationWriteModel) decimal.Decimal {
+	total := decimal.Zero
+
+	for _, allocation := range allocations {
+		total = total.Add(allocation.Amount)
+	}
+
+	return total
+}
+
+// SplitMatches tells whether the allocations still add up to the amount of the transaction. A sync may change the
+// amount after the transaction was split, e.g. when a tip is added.
+func SplitMatches(transaction DbTransaction, allocations []Allocation) bool {
+	total := decimal.Zero
+
+	for _, allocation := range allocations {
+		total = total.Add(allocation.Amount)
+	}
+
+	return total.Equal(transaction.Amount)
+}
+
+// scaleAllocationAmounts scales the amounts of a split of from to add up to to, keeping their shares. The last
+// amount takes the rounding difference, so that they add up exactly.
+func scaleAllocationAmounts(amounts []decimal.Decimal, from decimal.Decimal, to decimal.Decimal) []decimal.Decimal {
+	scaled := make([]decimal.Decimal, 0, len(amounts))
+	remaining := to
+
+	for i, amount := range amounts {
+		share := remaining
+
+		// A split of nothing has no shares, the last amount takes all of it
+		if i < len(amounts)-1 {
+			share = decimal.Zero
+
+			if !from.IsZero() {
+				share = amount.Mul(to).Div(from).Round(allocationAmountPlaces)
+			}
+		}
+
+		remaining = remaining.Sub(share)
+		scaled = append(scaled, share)
+	}
+
+	return scaled
+}
@@ -0,0 +1,94 @@
+package transactions
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func amounts(values ...string) []decimal.Decimal {
+	var parsed []decimal.Decimal
+
+	for _, value := range values {
+		parsed = append(parsed, decimal.RequireFromString(value))
+	}
+
+	return parsed
+}
+
+func TestValidateSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  string
+		parts   []string
+		wantErr error
+	}{
+		{"parts add up", "100.00", []string{"60.00", "40.00"}, nil},
+		{"parts of incoming money add up", "-100", []string{"-30", "-70"}, nil},
+		{"parts add up with more places", "10", []string{"3.333", "6.667"}, nil},
+		{"parts do not add up", "100.00", []string{"60.00", "39.99"}, ErrAllocationsSumMismatch},
+		{"parts of the wrong sign", "100", []string{"150", "-50", "0"}, nil},
+		{"single part", "100.00", []string{"100.00"}, ErrTooFewAllocations},
+		{"no parts", "100.00", nil, ErrTooFewAllocations},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var allocations []AllocationWriteModel
+
+			for _, part := range amounts(test.parts...) {
+				allocations = append(allocations, AllocationWriteModel{Amount: part})
+			}
+
+			err := ValidateSplit(DbTransaction{Amount: decimal.RequireFromString(test.amount)}, allocations)
+
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestScaleAllocationAmounts(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		in   []string
+		want []string
+	}{
+		{"unchanged amount", "50", "50", []string{"20", "30"}, []string{"20", "30"}},
+		{"tip added", "40.00", "48.00", []string{"30.00", "10.00"}, []string{"36", "12"}},
+		// 10 / 3 does not divide evenly, the last part takes what is left
+		{"remainder goes to the last part", "30", "10", []string{"10", "10", "10"}, []string{"3.333", "3.333", "3.334"}},
+		{"remainder with a smaller first part", "3", "1", []string{"1", "2"}, []string{"0.333", "0.667"}},
+		{"incoming money", "-100", "-90", []string{"-25", "-75"}, []string{"-22.5", "-67.5"}},
+		{"split of nothing", "0", "12", []string{"0", "0"}, []string{"0", "12"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			to := decimal.RequireFromString(test.to)
+			scaled := scaleAllocationAmounts(amounts(test.in...), decimal.RequireFromString(test.from), to)
+
+			if len(scaled) != len(test.want) {
+				t.Fatalf("Expected %d amounts, got %v", len(test.want), scaled)
+			}
+
+			total := decimal.Zero
+
+			for i, want := range amounts(test.want...) {
+				if !scaled[i].Equal(want) {
+					t.Errorf("Part %d: expected %s, got %s", i, want, scaled[i])
+				}
+
+				total = total.Add(scaled[i])
+			}
+
+			if !total.Equal(to) {
+				t.Errorf("Expected the parts to add up to %s, got %s", to, total)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package transactions
+
+import "time"
+
+// Attachment is a file attached to a transaction, e.g. a receipt. The content is kept in a blob store.
+type Attachment struct {
+	ID            int64
+	TransactionID int64
+	BlobKey       string
+	FileName      string
+	ContentType   string
+	Size          int64
+	CreatedAt     time.Time
+}
+
+type AttachmentWriteModel struct {
+	BlobKey     string
+	FileName    string
+	ContentType string
+	Size        int64
+}
@@ -0,0 +1,50 @@
+// Every label with a data-attachment-drop attribute accepts files dropped onto it. They are put into the file
+// input inside of the label, whose form uploads them on change.
+const initDropZone = (dropZone: HTMLElement) => {
+  // The script is included with every transaction panel, so make sure each drop zone is only set up once
+  if (dropZone.dataset.attachmentDropReady) {
+    return;
+  }
+
+  dropZone.dataset.attachmentDropReady = "true";
+
+  const input = dropZone.querySelector<HTMLInputElement>("input[type=file]");
+
+  if (!input) {
+    return;
+  }
+
+  const highlight = (active: boolean) => {
+    dropZone.classList.toggle("border-sky-400", active);
+    dropZone.classList.toggle("bg-sky-100", active);
+  };
+
+  dropZone.addEventListener("dragover", (event) => {
+    event.preventDefault();
+    highlight(true);
+  });
+
+  dropZone.addEventListener("dragleave", () => highlight(false));
+
+  dropZone.addEventListener("drop", (event) => {
+    event.preventDefault();
+    highlight(false);
+
+    if (!event.dataTransfer || event.dataTransfer.files.length === 0) {
+      return;
+    }
+
+    input.files = event.dataTransfer.files;
+    input.dispatchEvent(new Event("change", { bubbles: true }));
+  });
+}
+
+const initDropZones = () => {
+  document.querySelectorAll<HTMLElement>("[data-attachment-drop]").forEach(initDropZone);
+}
+
+if (document.readyState === "loading") {
+  document.addEventListener("DOMContentLoaded", initDropZones);
+} else {
+  initDropZones();
+}
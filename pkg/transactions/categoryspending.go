@@ -0,0 +1,17 @@
+package transactions
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CategorySpending is the sum of the transactions of one category in one month and currency. Money leaving the
+// account counts as positive, so refunds lower the spending.
+type CategorySpending struct {
+	CategoryID int
+	// The first day of the month
+	Month    time.Time
+	Currency string
+	Amount   decimal.Decimal
+}
@@ -7,26 +7,126 @@ import (
 )
 
 type DbTransaction struct {
-	ID                 int64
-	PlaidTransactionID string
-	BankAccountID      int
-	Amount             decimal.Decimal
-	Currency           string
-	DateAuthorized     time.Time
-	DateTimeAuthorized *time.Time
-	DatePosted         time.Time
-	DateTimePosted     *time.Time
-	NextCursor         string
+	ID                   int64
+	PlaidTransactionID   string
+	BankAccountID        int
+	Amount               decimal.Decimal
+	Currency             string
+	DateAuthorized       time.Time
+	DateTimeAuthorized   *time.Time
+	DatePosted           time.Time
+	DateTimePosted       *time.Time
+	Name                 string
+	MerchantName         *string
+	Category             *string
+	CategoryDetailed     *string
+	Pending              bool
+	PendingTransactionID *string
+	PaymentChannel       *string
+	MerchantEntityID     *string
+	LogoURL              *string
+	Website              *string
+	Location             *Location
+	Counterparties       []Counterparty
+	// Set by the user or by a categorization rule, a sync never changes them
+	CategoryID     *int
+	CategorySource *CategorySource
+	Payee          *string
+	Notes          *string
+	// The other side of a transfer between the user's own accounts
+	TransferTransactionID *int64
+	TransferSource        *TransferSource
+}
+
+// IsTransfer tells whether the transaction moved money between the user's own accounts. Transfers are neither
+// income nor spending.
+func (t DbTransaction) IsTransfer() bool {
+	return t.TransferTransactionID != nil
+}
+
+// DisplayName returns the payee given by a rule, the merchant or the description, whichever is known
+func (t DbTransaction) DisplayName() string {
+	if t.Payee != nil {
+		return *t.Payee
+	}
+
+	if t.MerchantName != nil {
+		return *t.MerchantName
+	}
+
+	return t.Name
+}
+
+// CategorySource tells who picked the category of a transaction
+type CategorySource string
+
+const (
+	CategorySourceUser CategorySource = "user"
+	CategorySourceRule CategorySource = "rule"
+)
+
+// TransferSource tells who paired the two sides of a transfer
+type TransferSource string
+
+const (
+	TransferSourceMatch TransferSource = "match"
+	// The user linked or unlinked the transaction themselves, the matcher leaves it alone
+	TransferSourceUser TransferSource = "user"
+)
+
+// CategorizationUpdate is what categorization rules change about a transaction. Nil fields are left as they are.
+type CategorizationUpdate struct {
+	// Both are only set if the user did not pick a category themselves
+	CategoryID *int
+	Payee      *string
+	AddTags    []string
 }
 
 type DbTransactionWriteModel struct {
-	PlaidTransactionID string
-	PlaidAccountID     string
-	Amount             decimal.Decimal
-	Currency           string
-	DateAuthorized     time.Time
-	DateTimeAuthorized *time.Time
-	DatePosted         time.Time
-	DateTimePosted     *time.Time
-	NextCursor         string
+	PlaidTransactionID   string
+	PlaidAccountID       string
+	Amount               decimal.Decimal
+	Currency             string
+	DateAuthorized       time.Time
+	DateTimeAuthorized   *time.Time
+	DatePosted           time.Time
+	DateTimePosted       *time.Time
+	Name                 string
+	MerchantName         *string
+	Category             *string
+	CategoryDetailed     *string
+	Pending              bool
+	PendingTransactionID *string
+	PaymentChannel       *string
+	MerchantEntityID     *string
+	LogoURL              *string
+	Website              *string
+	Location             *Location
+	Counterparties       []Counterparty
+	// The provider's payload as JSON
+	Raw []byte
+}
+
+// Location is stored as JSON. Most transactions only have a city, if anything at all.
+type Location struct {
+	Address     *string  `json:"address,omitempty"`
+	City        *string  `json:"city,omitempty"`
+	Region      *string  `json:"region,omitempty"`
+	PostalCode  *string  `json:"postal_code,omitempty"`
+	Country     *string  `json:"country,omitempty"`
+	Lat         *float64 `json:"lat,omitempty"`
+	Lon         *float64 `json:"lon,omitempty"`
+	StoreNumber *string  `json:"store_number,omitempty"`
+}
+
+// Counterparty is stored as JSON with the other counterparties of the transaction
+type Counterparty struct {
+	Name            string  `json:"name"`
+	Type            string  `json:"type"`
+	EntityID        *string `json:"entity_id,omitempty"`
+	Website         *string `json:"website,omitempty"`
+	LogoURL         *string `json:"logo_url,omitempty"`
+	ConfidenceLevel *string `json:"confidence_level,omitempty"`
+	// Of the other account of bank transfers read from statement files
+	IBAN *string `json:"iban,omitempty"`
 }
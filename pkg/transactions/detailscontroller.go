@@ -0,0 +1,456 @@
+package transactions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"nerdmoney/pkg/blobstore"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/users"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxAttachmentSize = 10 << 20
+	// How many files can be dropped at once
+	maxAttachmentsPerUpload = 5
+	maxNotesLength          = 10000
+	maxTagLength            = 255
+)
+
+// Receipts are photos or PDFs. The type is sniffed from the content, browsers report whatever the file name says.
+var attachmentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+func RegisterTransactionDetailRoutes(e *echo.Echo, transactionRepository TransactionRepository, blobStore blobstore.BlobStore) {
+
+	log := e.Logger
+
+	renderDetails := func(c echo.Context, status int, transaction DbTransaction, form DetailsForm) error {
+		ctx := c.Request().Context()
+
+		attachments, err := transactionRepository.ListAttachments(ctx, []int64{transaction.ID})
+
+		if err != nil {
+			log.Errorf("Failed to list attachments: %v", err)
+			return c.String(500, "Something went wrong when loading the transaction...")
+		}
+
+		tagNames, err := transactionRepository.ListTagNames(ctx, users.CurrentUser(c).ID)
+
+		if err != nil {
+			log.Errorf("Failed to list tags: %v", err)
+			return c.String(500, "Something went wrong when loading the transaction...")
+		}
+
+		view := detailsView{Transaction: transaction, Form: form, TagNames: tagNames, Attachments: attachments[transaction.ID]}
+
+		return layout.RenderComponent(c, status, TransactionDetails(view))
+	}
+
+	renderAttachments := func(c echo.Context, status int, transactionID int64, attachmentErrors []string) error {
+		attachments, err := transactionRepository.ListAttachments(c.Request().Context(), []int64{transactionID})
+
+		if err != nil {
+			log.Errorf("Failed to list attachments: %v", err)
+			return c.String(500, "Something went wrong when loading the attachments...")
+		}
+
+		return layout.RenderComponent(c, status, attachmentList(transactionID, attachments[transactionID], attachmentErrors))
+	}
+
+	e.GET("/transactions/:id/details", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		transaction, err := transactionRepository.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find transaction: %v", err)
+			return c.String(500, "Something went wrong when loading the transaction...")
+		}
+
+		tags, err := transactionRepository.ListTags(ctx, []int64{transaction.ID})
+
+		if err != nil {
+			log.Errorf("Failed to list tags of transaction: %v", err)
+			return c.String(500, "Something went wrong when loading the transaction...")
+		}
+
+		return renderDetails(c, 200, transaction, newDetailsForm(transaction, tags[transaction.ID]))
+	})
+
+	e.POST("/transactions/:id/details", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		transaction, err := transactionRepository.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find transaction: %v", err)
+			return c.String(500, "Something went wrong when saving the transaction...")
+		}
+
+		form, notes, tags := parseDetailsForm(c)
+
+		if form.TagsError != "" || form.NotesError != "" {
+			return renderDetails(c, 422, transaction, form)
+		}
+
+		err = transactionRepository.UpdateNotesAndTags(ctx, user.ID, transaction.ID, notes, tags)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to update notes and tags of transaction: %v", err)
+			return c.String(500, "Something went wrong when saving the transaction...")
+		}
+
+		transaction.Notes = notes
+		form.Saved = true
+
+		c.Response().Header().Set("HX-Trigger", TransactionsChangedEvent)
+
+		return renderDetails(c, 200, transaction, form)
+	})
+
+	e.POST("/transactions/:id/attachments", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		transaction, err := transactionRepository.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find transaction: %v", err)
+			return c.String(500, "Something went wrong when uploading the files...")
+		}
+
+		// Leaves some room for the rest of the multipart body
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxAttachmentsPerUpload*maxAttachmentSize+1<<20)
+
+		multipartForm, err := c.MultipartForm()
+
+		if err != nil {
+			return renderAttachments(c, 422, transaction.ID, []string{
+				fmt.Sprintf("Upload at most %d files of up to %d MB each", maxAttachmentsPerUpload, maxAttachmentSize>>20),
+			})
+		}
+
+		files := multipartForm.File["files"]
+
+		if len(files) > maxAttachmentsPerUpload {
+			return renderAttachments(c, 422, transaction.ID, []string{fmt.Sprintf("Upload at most %d files at once", maxAttachmentsPerUpload)})
+		}
+
+		var attachmentErrors []string
+
+		for _, file := range files {
+			if err := attachFile(c, transactionRepository, blobStore, user.ID, transaction.ID, file); err != nil {
+				var uploadErr attachmentError
+
+				if !errors.As(err, &uploadErr) {
+					log.Errorf("Failed to attach file to transaction: %v", err)
+					uploadErr = attachmentError(fmt.Sprintf("Something went wrong when uploading %s", file.Filename))
+				}
+
+				attachmentErrors = append(attachmentErrors, string(uploadErr))
+			}
+		}
+
+		status := 200
+
+		if len(attachmentErrors) > 0 {
+			status = 422
+		}
+
+		// Uploads that worked are kept even when others failed
+		c.Response().Header().Set("HX-Trigger", TransactionsChangedEvent)
+
+		return renderAttachments(c, status, transaction.ID, attachmentErrors)
+	})
+
+	e.GET("/attachments/:id", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid attachment id")
+		}
+
+		attachment, err := transactionRepository.FindAttachment(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Attachment not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find attachment: %v", err)
+			return c.String(500, "Something went wrong when downloading the attachment...")
+		}
+
+		content, err := blobStore.Get(ctx, attachment.BlobKey)
+
+		if errors.Is(err, blobstore.ErrBlobNotFound) {
+			log.Warnf("Blob of Attachment with id='%d' is missing", attachment.ID)
+			return c.String(404, "Attachment not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to download attachment: %v", err)
+			return c.String(502, "Something went wrong when downloading the attachment...")
+		}
+
+		defer content.Close()
+
+		c.Response().Header().Set("Content-Disposition", "inline; filename*=UTF-8''"+url.PathEscape(attachment.FileName))
+		c.Response().Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+
+		return c.Stream(200, attachment.ContentType, content)
+	})
+
+	e.DELETE("/attachments/:id", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid attachment id")
+		}
+
+		attachment, err := transactionRepository.DeleteAttachment(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Attachment not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to delete attachment: %v", err)
+			return c.String(500, "Something went wrong when deleting the attachment...")
+		}
+
+		// The attachment is gone for the user either way, a blob left behind only takes up space
+		if err := blobStore.Delete(ctx, attachment.BlobKey); err != nil {
+			log.Errorf("Failed to delete blob of Attachment with id='%d': %v", attachment.ID, err)
+		}
+
+		c.Response().Header().Set("HX-Trigger", TransactionsChangedEvent)
+
+		return renderAttachments(c, 200, attachment.TransactionID, nil)
+	})
+}
+
+// attachmentError is a problem with an uploaded file which is shown to the user
+type attachmentError string
+
+func (e attachmentError) Error() string {
+	return string(e)
+}
+
+// attachFile stores the uploaded file in the blob store and attaches it to the transaction
+func attachFile(
+	c echo.Context,
+	transactionRepository TransactionRepository,
+	blobStore blobstore.BlobStore,
+	userID int,
+	transactionID int64,
+	file *multipart.FileHeader,
+) error {
+	ctx := c.Request().Context()
+
+	if file.Size > maxAttachmentSize {
+		return attachmentError(fmt.Sprintf("%s is larger than %d MB", file.Filename, maxAttachmentSize>>20))
+	}
+
+	content, err := file.Open()
+
+	if err != nil {
+		return fmt.Errorf("Failed to open uploaded file: %w", err)
+	}
+
+	defer content.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("Failed to read uploaded file: %w", err)
+	}
+
+	contentType := http.DetectContentType(head[:n])
+
+	if !attachmentContentTypes[contentType] {
+		return attachmentError(fmt.Sprintf("%s is not an image or a PDF", file.Filename))
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("Failed to read uploaded file: %w", err)
+	}
+
+	blobKey, err := newAttachmentBlobKey(userID, transactionID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := blobStore.Put(ctx, blobKey, contentType, content); err != nil {
+		return err
+	}
+
+	_, err = transactionRepository.AddAttachment(ctx, userID, transactionID, AttachmentWriteModel{
+		BlobKey:     blobKey,
+		FileName:    attachmentFileName(file.Filename),
+		ContentType: contentType,
+		Size:        file.Size,
+	})
+
+	if err != nil {
+		if err := blobStore.Delete(ctx, blobKey); err != nil {
+			c.Logger().Errorf("Failed to delete blob of failed upload: %v", err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Blob keys are random, so that they do not give away anything about the file
+func newAttachmentBlobKey(userID int, transactionID int64) (string, error) {
+	random := make([]byte, 16)
+
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("Failed to generate blob key: %w", err)
+	}
+
+	return fmt.Sprintf("attachments/%d/%d/%s", userID, transactionID, hex.EncodeToString(random)), nil
+}
+
+// attachmentFileName keeps the base name of the uploaded file, cut to fit into the database
+func attachmentFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+
+	return name
+}
+
+type detailsView struct {
+	Transaction DbTransaction
+	Form        DetailsForm
+	// Suggestions for the tags input
+	TagNames    []string
+	Attachments []Attachment
+}
+
+// DetailsForm holds the notes and tags the user entered, along with validation errors
+type DetailsForm struct {
+	// Comma separated
+	Tags       string
+	TagsError  string
+	Notes      string
+	NotesError string
+	Saved      bool
+}
+
+func newDetailsForm(transaction DbTransaction, tags []string) DetailsForm {
+	form := DetailsForm{Tags: strings.Join(tags, ", ")}
+
+	if transaction.Notes != nil {
+		form.Notes = *transaction.Notes
+	}
+
+	return form
+}
+
+func parseDetailsForm(c echo.Context) (DetailsForm, *string, []string) {
+	form := DetailsForm{Tags: c.FormValue("tags"), Notes: strings.TrimSpace(c.FormValue("notes"))}
+
+	var tags []string
+	seen := map[string]bool{}
+
+	for _, tag := range strings.Split(form.Tags, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			form.TagsError = fmt.Sprintf("Tags can be at most %d characters long", maxTagLength)
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	if utf8.RuneCountInString(form.Notes) > maxNotesLength {
+		form.NotesError = fmt.Sprintf("Notes can be at most %d characters long", maxNotesLength)
+	}
+
+	var notes *string
+
+	if form.Notes != "" {
+		notes = &form.Notes
+	}
+
+	return form, notes, tags
+}
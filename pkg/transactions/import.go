@@ -0,0 +1,72 @@
+package transactions
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ImportFormat is the kind of file transactions are imported from
+type ImportFormat string
+
+const (
+	ImportFormatCSV ImportFormat = "csv"
+	// QFX files are OFX files as well
+	ImportFormatOFX ImportFormat = "ofx"
+	ImportFormatQIF ImportFormat = "qif"
+	// ISO 20022 bank to customer statements
+	ImportFormatCamt053 ImportFormat = "camt053"
+	ImportFormatMT940   ImportFormat = "mt940"
+)
+
+type ImportStatus string
+
+const (
+	// The user has not confirmed the preview yet
+	ImportStatusPending   ImportStatus = "pending"
+	ImportStatusCommitted ImportStatus = "committed"
+)
+
+// Import is a file the user uploaded to import the transactions of a manual bank account
+type Import struct {
+	ID            int64
+	BankAccountID int
+	FileName      string
+	Format        ImportFormat
+	Status        ImportStatus
+	// The transactions read from the file, until the import is committed
+	PendingRows []DbTransactionWriteModel
+	// The bank statements of camt.053 and MT940 files
+	Statements     []Statement
+	ImportedCount  int
+	DuplicateCount int
+	CreatedAt      time.Time
+	CommittedAt    *time.Time
+}
+
+type ImportWriteModel struct {
+	BankAccountID int
+	FileName      string
+	Format        ImportFormat
+	PendingRows   []DbTransactionWriteModel
+	Statements    []Statement
+	// Transactions of the file which were imported before
+	DuplicateCount int
+}
+
+// Statement is a bank statement of a camt.053 or MT940 file, with the balances it states
+type Statement struct {
+	// The account as the bank names it, e.g. its IBAN
+	Account  string            `json:"account"`
+	Currency string            `json:"currency"`
+	Opening  *StatementBalance `json:"opening,omitempty"`
+	Closing  *StatementBalance `json:"closing,omitempty"`
+	// Of the transactions read from the statement, with money leaving the account as positive amounts
+	Total decimal.Decimal `json:"total"`
+}
+
+// StatementBalance is positive when the account holds money, as banks show it
+type StatementBalance struct {
+	Date   time.Time       `json:"date"`
+	Amount decimal.Decimal `json:"amount"`
+}
@@ -0,0 +1,224 @@
+package transactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type ImportRepository interface {
+	// Create records a pending import. It returns pgx.ErrNoRows unless the bank account is a manual account of the user.
+	Create(ctx context.Context, userID int, writeModel ImportWriteModel) (Import, error)
+	// FindPendingForUpdate locks the pending import until the end of the database transaction, so that it is
+	// committed only once
+	FindPendingForUpdate(ctx context.Context, userID int, id int64) (Import, error)
+	// MarkCommitted drops the pending rows and records how many transactions were imported and how many were
+	// left out as duplicates
+	MarkCommitted(ctx context.Context, id int64, importedCount int, duplicateCount int) (Import, error)
+	// DeletePending discards an import the user did not confirm
+	DeletePending(ctx context.Context, userID int, id int64) error
+	ListRecent(ctx context.Context, userID int, limit int) ([]Import, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) ImportRepository
+}
+
+type importRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewImportRepository(pool *pgxpool.Pool, log echo.Logger) ImportRepository {
+	return &importRepositoryImpl{pool, log}
+}
+
+func (r *importRepositoryImpl) WithTx(tx pgx.Tx) ImportRepository {
+	return &importRepositoryImpl{tx, r.log}
+}
+
+const importColumns = `id, bank_account_id, file_name, format, status, pending_rows, statements, imported_count, duplicate_count, created_at, committed_at`
+
+// Imports belong to the owner of their bank account
+const importOwnedByUser = `bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)`
+
+func (r *importRepositoryImpl) Create(ctx context.Context, userID int, writeModel ImportWriteModel) (Import, error) {
+	r.log.Debugf("Attempting to record an import of %d transactions into BankAccount with id='%d'", len(writeModel.PendingRows), writeModel.BankAccountID)
+
+	pendingRows, err := json.Marshal(writeModel.PendingRows)
+
+	if err != nil {
+		return Import{}, fmt.Errorf("Failed to encode imported transactions: %w", err)
+	}
+
+	// Only statement files have statements
+	var statements any
+
+	if len(writeModel.Statements) > 0 {
+		encodedStatements, err := json.Marshal(writeModel.Statements)
+
+		if err != nil {
+			return Import{}, fmt.Errorf("Failed to encode imported statements: %w", err)
+		}
+
+		statements = json.RawMessage(encodedStatements)
+	}
+
+	query := `
+	INSERT INTO transaction_import (bank_account_id, file_name, format, pending_rows, statements, duplicate_count)
+	SELECT $1, $3, $4, $5, $6, $7
+	WHERE EXISTS (SELECT 1 FROM bank_account WHERE id = $1 AND user_id = $2 AND bank_connection_id IS NULL)
+	RETURNING ` + importColumns
+
+	transactionImport, err := scanImport(r.db.QueryRow(
+		ctx,
+		query,
+		writeModel.BankAccountID,
+		userID,
+		writeModel.FileName,
+		writeModel.Format,
+		json.RawMessage(pendingRows),
+		statements,
+		writeModel.DuplicateCount,
+	))
+
+	if err != nil {
+		return Import{}, fmt.Errorf("Failed to record import into BankAccount with id='%d': %w", writeModel.BankAccountID, err)
+	}
+
+	return transactionImport, nil
+}
+
+func (r *importRepositoryImpl) FindPendingForUpdate(ctx context.Context, userID int, id int64) (Import, error) {
+	r.log.Debugf("Attempting to find pending Import with id='%d'", id)
+
+	query := `
+	SELECT ` + importColumns + ` FROM transaction_import
+	WHERE id = $1 AND status = 'pending' AND ` + importOwnedByUser + `
+	FOR UPDATE`
+
+	transactionImport, err := scanImport(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return Import{}, fmt.Errorf("Failed to find pending Import with id='%d': %w", id, err)
+	}
+
+	return transactionImport, nil
+}
+
+func (r *importRepositoryImpl) MarkCommitted(ctx context.Context, id int64, importedCount int, duplicateCount int) (Import, error) {
+	r.log.Debugf("Attempting to mark Import with id='%d' as committed", id)
+
+	query := `
+	UPDATE transaction_import SET
+		status = 'committed',
+		pending_rows = NULL,
+		imported_count = $2,
+		duplicate_count = $3,
+		committed_at = now()
+	WHERE id = $1
+	RETURNING ` + importColumns
+
+	transactionImport, err := scanImport(r.db.QueryRow(ctx, query, id, importedCount, duplicateCount))
+
+	if err != nil {
+		return Import{}, fmt.Errorf("Failed to mark Import with id='%d' as committed: %w", id, err)
+	}
+
+	return transactionImport, nil
+}
+
+func (r *importRepositoryImpl) DeletePending(ctx context.Context, userID int, id int64) error {
+	r.log.Debugf("Attempting to delete pending Import with id='%d'", id)
+
+	commandTag, err := r.db.Exec(ctx, `DELETE FROM transaction_import WHERE id = $1 AND status = 'pending' AND `+importOwnedByUser, id, userID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete pending Import with id='%d': %w", id, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("Failed to delete pending Import with id='%d': %w", id, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *importRepositoryImpl) ListRecent(ctx context.Context, userID int, limit int) ([]Import, error) {
+	r.log.Debugf("Attempting to list recent imports of User with id='%d'", userID)
+
+	// The pending rows are only needed to commit an import
+	query := `
+	SELECT id, bank_account_id, file_name, format, status, NULL::jsonb, statements, imported_count, duplicate_count, created_at, committed_at
+	FROM transaction_import
+	WHERE status = 'committed' AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $1)
+	ORDER BY committed_at DESC, id DESC
+	LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+
+	if err != nil {
+		return []Import{}, fmt.Errorf("Failed to list recent imports of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var imports []Import
+
+	for rows.Next() {
+		transactionImport, err := scanImport(rows)
+
+		if err != nil {
+			return []Import{}, fmt.Errorf("Failed to read import row: %w", err)
+		}
+
+		imports = append(imports, transactionImport)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []Import{}, fmt.Errorf("Failed to read import rows: %w", err)
+	}
+
+	return imports, nil
+}
+
+func scanImport(row pgx.Row) (Import, error) {
+	var transactionImport Import
+	var pendingRows []byte
+	var statements []byte
+
+	err := row.Scan(
+		&transactionImport.ID,
+		&transactionImport.BankAccountID,
+		&transactionImport.FileName,
+		&transactionImport.Format,
+		&transactionImport.Status,
+		&pendingRows,
+		&statements,
+		&transactionImport.ImportedCount,
+		&transactionImport.DuplicateCount,
+		&transactionImport.CreatedAt,
+		&transactionImport.CommittedAt,
+	)
+
+	if err != nil {
+		return Import{}, err
+	}
+
+	if pendingRows != nil {
+		if err := json.Unmarshal(pendingRows, &transactionImport.PendingRows); err != nil {
+			return Import{}, fmt.Errorf("Failed to decode pending rows of Import with id='%d': %w", transactionImport.ID, err)
+		}
+	}
+
+	if statements != nil {
+		if err := json.Unmarshal(statements, &transactionImport.Statements); err != nil {
+			return Import{}, fmt.Errorf("Failed to decode statements of Import with id='%d': %w", transactionImport.ID, err)
+		}
+	}
+
+	return transactionImport, nil
+}
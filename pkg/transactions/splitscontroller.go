@@ -0,0 +1,314 @@
+package transactions
+
+import (
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/categories"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/users"
+	"strconv"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+// The transactions page reloads its table on this event
+const TransactionsChangedEvent = "transactions-changed"
+
+func RegisterSplitRoutes(e *echo.Echo, transactionRepository TransactionRepository, categoryRepository categories.CategoryRepository) {
+
+	log := e.Logger
+
+	e.GET("/transactions/:id/split", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		transaction, err := transactionRepository.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find transaction: %v", err)
+			return c.String(500, "Something went wrong when loading the split...")
+		}
+
+		allocations, err := transactionRepository.ListAllocations(ctx, []int64{id})
+
+		if err != nil {
+			log.Errorf("Failed to list allocations: %v", err)
+			return c.String(500, "Something went wrong when loading the split...")
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list categories: %v", err)
+			return c.String(500, "Something went wrong when loading the split...")
+		}
+
+		view := splitView{
+			Transaction: transaction,
+			Form:        newSplitForm(transaction, allocations[id]),
+			Categories:  categories.NewTree(allCategories),
+		}
+
+		return layout.RenderComponent(c, 200, SplitTransactionForm(view))
+	})
+
+	e.POST("/transactions/:id/split", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		transaction, err := transactionRepository.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find transaction: %v", err)
+			return c.String(500, "Something went wrong when splitting the transaction...")
+		}
+
+		form, allocations, err := parseSplitForm(c, transaction)
+
+		if err != nil {
+			return c.String(400, err.Error())
+		}
+
+		status := 200
+
+		// Adding a part only renders the form with another row
+		if c.FormValue("action") == "add" {
+			form.Rows = append(form.Rows, SplitFormRow{})
+			form.TotalError = ""
+			form.clearAmountErrors()
+		} else if form.hasErrors() {
+			status = 422
+		} else {
+			_, err := transactionRepository.ReplaceAllocations(ctx, user.ID, id, allocations)
+
+			if errors.Is(err, pgx.ErrNoRows) {
+				return c.String(404, "Transaction or category not found")
+			}
+
+			// The amount of the transaction was changed by a sync in the meantime
+			if errors.Is(err, ErrAllocationsSumMismatch) {
+				form.TotalError = err.Error()
+				status = 422
+			} else if err != nil {
+				log.Errorf("Failed to split transaction: %v", err)
+				return c.String(500, "Something went wrong when splitting the transaction...")
+			} else {
+				c.Response().Header().Set("HX-Trigger", TransactionsChangedEvent)
+
+				// Closes the form
+				return layout.RenderComponent(c, 200, templ.NopComponent)
+			}
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list categories: %v", err)
+			return c.String(500, "Something went wrong when splitting the transaction...")
+		}
+
+		view := splitView{Transaction: transaction, Form: form, Categories: categories.NewTree(allCategories)}
+
+		return layout.RenderComponent(c, status, SplitTransactionForm(view))
+	})
+
+	e.DELETE("/transactions/:id/split", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		_, err = transactionRepository.ReplaceAllocations(c.Request().Context(), user.ID, id, nil)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to undo split of transaction: %v", err)
+			return c.String(500, "Something went wrong when undoing the split...")
+		}
+
+		c.Response().Header().Set("HX-Trigger", TransactionsChangedEvent)
+
+		return layout.RenderComponent(c, 200, templ.NopComponent)
+	})
+}
+
+type splitView struct {
+	Transaction DbTransaction
+	Form        SplitForm
+	Categories  categories.Tree
+}
+
+// SplitForm holds the parts the user entered into the split form, along with validation errors. Amounts are
+// as shown to users, with incoming money being positive.
+type SplitForm struct {
+	Rows []SplitFormRow
+	// What the rows add up to, with an error if that is not the amount of the transaction
+	Total      string
+	TotalError string
+}
+
+type SplitFormRow struct {
+	Amount      string
+	Category    string
+	Notes       string
+	AmountError string
+}
+
+func (f SplitForm) hasErrors() bool {
+	if f.TotalError != "" {
+		return true
+	}
+
+	for _, row := range f.Rows {
+		if row.AmountError != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *SplitForm) clearAmountErrors() {
+	for i := range f.Rows {
+		f.Rows[i].AmountError = ""
+	}
+}
+
+// newSplitForm fills the form with the allocations, or suggests splitting off a part of an unsplit transaction
+func newSplitForm(transaction DbTransaction, allocations []Allocation) SplitForm {
+	var form SplitForm
+
+	for _, allocation := range allocations {
+		row := SplitFormRow{Amount: allocation.Amount.Neg().StringFixed(2)}
+
+		if allocation.CategoryID != nil {
+			row.Category = strconv.Itoa(*allocation.CategoryID)
+		}
+
+		if allocation.Notes != nil {
+			row.Notes = *allocation.Notes
+		}
+
+		form.Rows = append(form.Rows, row)
+	}
+
+	if len(form.Rows) == 0 {
+		first := SplitFormRow{Amount: transaction.Amount.Neg().StringFixed(2)}
+
+		if transaction.CategoryID != nil {
+			first.Category = strconv.Itoa(*transaction.CategoryID)
+		}
+
+		form.Rows = []SplitFormRow{first, {}}
+	}
+
+	form.Total = sumOfRows(form.Rows).StringFixed(2)
+
+	return form
+}
+
+// parseSplitForm reads the rows of the form, rows left completely empty are dropped
+func parseSplitForm(c echo.Context, transaction DbTransaction) (SplitForm, []AllocationWriteModel, error) {
+	params, err := c.FormParams()
+
+	if err != nil {
+		return SplitForm{}, nil, fmt.Errorf("Invalid split form")
+	}
+
+	amounts, categoryIDs, notes := params["amount"], params["category"], params["notes"]
+
+	if len(categoryIDs) != len(amounts) || len(notes) != len(amounts) {
+		return SplitForm{}, nil, fmt.Errorf("Every part needs an amount, a category and notes")
+	}
+
+	var form SplitForm
+	var allocations []AllocationWriteModel
+
+	for i := range amounts {
+		row := SplitFormRow{
+			Amount:   strings.TrimSpace(amounts[i]),
+			Category: categoryIDs[i],
+			Notes:    strings.TrimSpace(notes[i]),
+		}
+
+		if row.Amount == "" && row.Category == "" && row.Notes == "" {
+			continue
+		}
+
+		var allocation AllocationWriteModel
+
+		amount, err := decimal.NewFromString(row.Amount)
+
+		if err != nil {
+			row.AmountError = "Enter an amount"
+		} else if amount.IsZero() {
+			row.AmountError = "Enter an amount other than 0"
+		}
+
+		allocation.Amount = amount.Neg()
+
+		if categoryID, err := strconv.Atoi(row.Category); err == nil {
+			allocation.CategoryID = &categoryID
+		}
+
+		if row.Notes != "" {
+			allocation.Notes = &row.Notes
+		}
+
+		form.Rows = append(form.Rows, row)
+		allocations = append(allocations, allocation)
+	}
+
+	form.Total = sumOfRows(form.Rows).StringFixed(2)
+
+	switch err := ValidateSplit(transaction, allocations); {
+	case errors.Is(err, ErrTooFewAllocations):
+		form.TotalError = err.Error()
+	case errors.Is(err, ErrAllocationsSumMismatch):
+		form.TotalError = fmt.Sprintf("The parts add up to %s, but the transaction is %s", form.Total, transaction.Amount.Neg().StringFixed(2))
+	}
+
+	return form, allocations, nil
+}
+
+func sumOfRows(rows []SplitFormRow) decimal.Decimal {
+	total := decimal.Zero
+
+	for _, row := range rows {
+		if amount, err := decimal.NewFromString(row.Amount); err == nil {
+			total = total.Add(amount)
+		}
+	}
+
+	return total
+}
@@ -0,0 +1,14 @@
+package transactions
+
+import "time"
+
+type SyncRun struct {
+	ID               int64
+	BankConnectionID int
+	StartedAt        time.Time
+	FinishedAt       *time.Time
+	AddedCount       int
+	ModifiedCount    int
+	RemovedCount     int
+	Error            *string
+}
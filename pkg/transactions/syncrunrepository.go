@@ -0,0 +1,148 @@
+package transactions
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type SyncRunRepository interface {
+	Start(ctx context.Context, bankConnectionID int) (SyncRun, error)
+	Finish(ctx context.Context, run SyncRun) (SyncRun, error)
+	ListRecentForConnection(ctx context.Context, bankConnectionID int, limit int) ([]SyncRun, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) SyncRunRepository
+}
+
+type syncRunRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewSyncRunRepository(pool *pgxpool.Pool, log echo.Logger) SyncRunRepository {
+	return &syncRunRepositoryImpl{pool, log}
+}
+
+func (r *syncRunRepositoryImpl) WithTx(tx pgx.Tx) SyncRunRepository {
+	return &syncRunRepositoryImpl{tx, r.log}
+}
+
+const syncRunColumns = `id, bank_connection_id, started_at, finished_at, added_count, modified_count, removed_count, error`
+
+func (r *syncRunRepositoryImpl) Start(ctx context.Context, bankConnectionID int) (SyncRun, error) {
+	r.log.Debugf("Recording start of a transaction sync for bank connection with id='%d'", bankConnectionID)
+
+	query := `
+	INSERT INTO transaction_sync_run (bank_connection_id, started_at)
+	VALUES ($1, $2)
+	RETURNING ` + syncRunColumns
+
+	var run SyncRun
+
+	err := r.db.QueryRow(ctx, query, bankConnectionID, time.Now()).Scan(
+		&run.ID,
+		&run.BankConnectionID,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.AddedCount,
+		&run.ModifiedCount,
+		&run.RemovedCount,
+		&run.Error,
+	)
+
+	if err != nil {
+		return SyncRun{}, fmt.Errorf("Failed to record start of a transaction sync for bank connection with id='%d': %w", bankConnectionID, err)
+	}
+
+	return run, nil
+}
+
+func (r *syncRunRepositoryImpl) Finish(ctx context.Context, run SyncRun) (SyncRun, error) {
+	r.log.Debugf("Recording end of transaction sync run with id='%d'", run.ID)
+
+	query := `
+	UPDATE transaction_sync_run
+	SET finished_at = $2, added_count = $3, modified_count = $4, removed_count = $5, error = $6
+	WHERE id = $1
+	RETURNING ` + syncRunColumns
+
+	finishedAt := time.Now()
+
+	if run.FinishedAt != nil {
+		finishedAt = *run.FinishedAt
+	}
+
+	var finishedRun SyncRun
+
+	err := r.db.QueryRow(
+		ctx,
+		query,
+		run.ID,
+		finishedAt,
+		run.AddedCount,
+		run.ModifiedCount,
+		run.RemovedCount,
+		run.Error,
+	).Scan(
+		&finishedRun.ID,
+		&finishedRun.BankConnectionID,
+		&finishedRun.StartedAt,
+		&finishedRun.FinishedAt,
+		&finishedRun.AddedCount,
+		&finishedRun.ModifiedCount,
+		&finishedRun.RemovedCount,
+		&finishedRun.Error,
+	)
+
+	if err != nil {
+		return SyncRun{}, fmt.Errorf("Failed to record end of transaction sync run with id='%d': %w", run.ID, err)
+	}
+
+	return finishedRun, nil
+}
+
+func (r *syncRunRepositoryImpl) ListRecentForConnection(ctx context.Context, bankConnectionID int, limit int) ([]SyncRun, error) {
+	query := `SELECT ` + syncRunColumns + ` FROM transaction_sync_run WHERE bank_connection_id = $1 ORDER BY started_at DESC LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, bankConnectionID, limit)
+
+	if err != nil {
+		return []SyncRun{}, fmt.Errorf("Failed to list sync runs for bank connection with id='%d': %w", bankConnectionID, err)
+	}
+
+	defer rows.Close()
+
+	var runs []SyncRun
+
+	for rows.Next() {
+		var run SyncRun
+
+		err := rows.Scan(
+			&run.ID,
+			&run.BankConnectionID,
+			&run.StartedAt,
+			&run.FinishedAt,
+			&run.AddedCount,
+			&run.ModifiedCount,
+			&run.RemovedCount,
+			&run.Error,
+		)
+
+		if err != nil {
+			return []SyncRun{}, fmt.Errorf("Failed to read sync run row: %w", err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []SyncRun{}, fmt.Errorf("Failed to read sync run rows: %w", err)
+	}
+
+	return runs, nil
+}
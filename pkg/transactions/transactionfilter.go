@@ -0,0 +1,143 @@
+package transactions
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type SortColumn string
+
+const (
+	SortByDate   SortColumn = "date"
+	SortByAmount SortColumn = "amount"
+	SortByName   SortColumn = "name"
+)
+
+func ParseSortColumn(source string) (SortColumn, error) {
+	switch source {
+	case "date":
+		return SortByDate, nil
+	case "amount":
+		return SortByAmount, nil
+	case "name":
+		return SortByName, nil
+	default:
+		return "", fmt.Errorf("Invalid SortColumn: '%s'", source)
+	}
+}
+
+// sqlExpression returns what is sorted by and how cursor values are cast to compare with it.
+// Amounts are sorted as shown to users, with incoming money being positive.
+func (sc SortColumn) sqlExpression() (string, string) {
+	switch sc {
+	case SortByAmount:
+		return "-amount", "numeric"
+	case SortByName:
+		return "COALESCE(payee, merchant_name, name)", "text"
+	default:
+		return "date_posted", "date"
+	}
+}
+
+// cursorValue returns the value of the transaction in this column, as stored in a TransactionCursor
+func (sc SortColumn) cursorValue(transaction DbTransaction) string {
+	switch sc {
+	case SortByAmount:
+		return transaction.Amount.Neg().String()
+	case SortByName:
+		return transaction.DisplayName()
+	default:
+		return transaction.DatePosted.Format(time.DateOnly)
+	}
+}
+
+// parseCursorValue checks that a cursor value can be compared with this column. Cursors come from URLs, so the
+// value may have been sorted by another column or tampered with.
+func (sc SortColumn) parseCursorValue(value string) error {
+	switch sc {
+	case SortByAmount:
+		_, err := decimal.NewFromString(value)
+		return err
+	case SortByName:
+		// Postgres text cannot hold NUL characters
+		if strings.ContainsRune(value, 0) {
+			return errors.New("Names cannot contain NUL characters")
+		}
+
+		return nil
+	default:
+		_, err := time.Parse(time.DateOnly, value)
+		return err
+	}
+}
+
+// TransactionFilter selects a page of a user's transactions. Zero values match everything.
+type TransactionFilter struct {
+	UserID        int
+	BankAccountID *int
+	// Inclusive range of the posted date
+	From *time.Time
+	To   *time.Time
+	// Inclusive range of the amount as shown to users, with incoming money being positive
+	MinAmount decimal.NullDecimal
+	MaxAmount decimal.NullDecimal
+	// Includes the categories inside of it
+	CategoryID *int
+	// Only transactions with a tag of this name
+	Tag string
+	// Only spending of at least this amount without any attachment, transfers do not need receipts
+	MissingReceiptAbove decimal.NullDecimal
+	// Matched against the name, the merchant name and the payee, ignoring case
+	Search     string
+	SortBy     SortColumn
+	Descending bool
+	// Only transactions sorted after this one are returned
+	After *TransactionCursor
+	Limit int
+}
+
+// TransactionPage is one page of transactions. NextCursor is nil on the last page.
+type TransactionPage struct {
+	Transactions []DbTransaction
+	NextCursor   *TransactionCursor
+}
+
+// TransactionCursor points at the last transaction of a page: the value it was sorted by and its id,
+// which breaks ties between transactions with the same value.
+type TransactionCursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// Encode returns the cursor in a form that is safe to use in URLs
+func (tc TransactionCursor) Encode() string {
+	encoded, _ := json.Marshal(tc)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// ParseTransactionCursor decodes a cursor of a page which was sorted by sortBy
+func ParseTransactionCursor(source string, sortBy SortColumn) (TransactionCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(source)
+
+	if err != nil {
+		return TransactionCursor{}, fmt.Errorf("Invalid TransactionCursor: %w", err)
+	}
+
+	var cursor TransactionCursor
+
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return TransactionCursor{}, fmt.Errorf("Invalid TransactionCursor: %w", err)
+	}
+
+	if err := sortBy.parseCursorValue(cursor.Value); err != nil {
+		return TransactionCursor{}, fmt.Errorf("Invalid TransactionCursor value '%s' for SortColumn '%s': %w", cursor.Value, sortBy, err)
+	}
+
+	return cursor, nil
+}
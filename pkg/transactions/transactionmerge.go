@@ -0,0 +1,24 @@
+package transactions
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TransactionMerge records a pending transaction that was replaced by its posted transaction,
+// along with the user's edits which were carried over.
+type TransactionMerge struct {
+	ID                        int64
+	PostedTransactionID       int64
+	PendingPlaidTransactionID string
+	PendingAmount             decimal.Decimal
+	PendingDatePosted         time.Time
+	PendingName               string
+	CategoryID                *int
+	CategorySource            *CategorySource
+	Payee                     *string
+	Notes                     *string
+	Tags                      []string
+	MergedAt                  time.Time
+}
@@ -1,7 +1,1488 @@
 package transactions
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+var ErrTransferWithinAccount = errors.New("Both sides of a transfer have to be in different accounts")
+var ErrTransferDecided = errors.New("The transaction is already part of a transfer or was unlinked by the user")
+
 type TransactionRepository interface {
-	ListAllForAccount(bankAccountID int) ([]DbTransaction, error)
-	ListAll() ([]DbTransaction, error)
-	SaveAll([]DbTransactionWriteModel) ([]DbTransaction, error)
+	ListAllForAccount(ctx context.Context, userID int, bankAccountID int) ([]DbTransaction, error)
+	FindByID(ctx context.Context, userID int, id int64) (DbTransaction, error)
+	// ListTransactions returns the page of the user's transactions selected by the filter
+	ListTransactions(ctx context.Context, filter TransactionFilter) (TransactionPage, error)
+	// ListProviderCategories returns every category the provider assigned to the user's transactions
+	ListProviderCategories(ctx context.Context, userID int) ([]string, error)
+	// SumSpendingByCategory sums the user's categorized transactions posted in [from, to) by category, month and
+	// currency. Split transactions count with their allocations, as long as these add up to the amount of the transaction.
+	// Transfers between the user's own accounts are left out.
+	SumSpendingByCategory(ctx context.Context, userID int, from time.Time, to time.Time) ([]CategorySpending, error)
+	// ListTags returns the names of the tags of each of the transactions
+	ListTags(ctx context.Context, transactionIDs []int64) (map[int64][]string, error)
+	// ListAllocations returns the allocations of each of the transactions which are split, in their order
+	ListAllocations(ctx context.Context, transactionIDs []int64) (map[int64][]Allocation, error)
+	// ReplaceAllocations splits the transaction into the allocations, or undoes the split if there are none. It returns
+	// ErrAllocationsSumMismatch if they do not add up to the amount of the transaction and pgx.ErrNoRows if the
+	// transaction or a category does not belong to the user.
+	ReplaceAllocations(ctx context.Context, userID int, transactionID int64, allocations []AllocationWriteModel) ([]Allocation, error)
+	// ListTagNames returns the names of all tags of the user
+	ListTagNames(ctx context.Context, userID int) ([]string, error)
+	// UpdateNotesAndTags replaces the notes and the tags of the user's transaction. Tags are created on first use.
+	UpdateNotesAndTags(ctx context.Context, userID int, transactionID int64, notes *string, tags []string) error
+	// ListAttachments returns the attachments of each of the transactions, oldest first
+	ListAttachments(ctx context.Context, transactionIDs []int64) (map[int64][]Attachment, error)
+	// AddAttachment returns pgx.ErrNoRows if the transaction does not belong to the user
+	AddAttachment(ctx context.Context, userID int, transactionID int64, writeModel AttachmentWriteModel) (Attachment, error)
+	FindAttachment(ctx context.Context, userID int, id int64) (Attachment, error)
+	// DeleteAttachment returns the deleted attachment, whose blob is left for the caller to delete
+	DeleteAttachment(ctx context.Context, userID int, id int64) (Attachment, error)
+	// UpdateCategorization applies what categorization rules decided for the transaction. The category and the payee
+	// of transactions the user categorized themselves are kept.
+	UpdateCategorization(ctx context.Context, transactionID int64, update CategorizationUpdate) error
+	SaveAll(ctx context.Context, writeModels []DbTransactionWriteModel) ([]DbTransaction, error)
+	// ImportAll inserts transactions read from a file into a manual bank account of the user. Transactions which
+	// were imported before are skipped. It returns pgx.ErrNoRows unless the bank account is a manual account of the user.
+	ImportAll(ctx context.Context, userID int, bankAccountID int, writeModels []DbTransactionWriteModel) ([]DbTransaction, error)
+	DeleteAllByPlaidTransactionID(ctx context.Context, plaidTransactionIDs []string) (int, error)
+	// AdoptProviderIDs gives saved transactions the ids the provider uses for them now. A bank which is linked again
+	// gets new transaction ids, so the transactions of relinked accounts are matched by date, amount and name instead.
+	// Each saved transaction adopts at most one id. It returns the number of transactions which adopted a new id.
+	AdoptProviderIDs(ctx context.Context, writeModels []DbTransactionWriteModel) (int, error)
+	// LinkTransfer pairs two transactions of the user as the sides of a transfer, replacing their previous pairs.
+	// Links made by the matcher return ErrTransferDecided instead of replacing anything or touching transactions
+	// the user linked or unlinked.
+	LinkTransfer(ctx context.Context, userID int, transactionID int64, otherTransactionID int64, source TransferSource) error
+	// UnlinkTransfer splits up the transfer of the transaction and keeps the matcher from pairing its sides again
+	UnlinkTransfer(ctx context.Context, userID int, transactionID int64) error
+	// MergePending retires the pending transactions which the given posted transactions replace. The
+	// category, payee, notes and tags move over to the posted transaction, unless it was edited already, and every
+	// merge is recorded in transaction_merge. Attachments always move over. Splits move over as well, scaled to
+	// the posted amount if it differs.
+	MergePending(ctx context.Context, postedTransactions []DbTransaction) ([]TransactionMerge, error)
+	// WithTx returns a copy of the repository which runs all queries in tx
+	WithTx(tx pgx.Tx) TransactionRepository
+}
+
+type transactionRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewTransactionRepository(pool *pgxpool.Pool, log echo.Logger) TransactionRepository {
+	return &transactionRepositoryImpl{pool, log}
+}
+
+func (r *transactionRepositoryImpl) WithTx(tx pgx.Tx) TransactionRepository {
+	return &transactionRepositoryImpl{tx, r.log}
+}
+
+const transactionColumns = `id, plaid_transaction_id, bank_account_id, amount, currency, date_authorized, date_time_authorized, date_posted, date_time_posted, name, merchant_name, category,
+	category_detailed, pending, pending_transaction_id, payment_channel, merchant_entity_id, logo_url, website, location, counterparties,
+	category_id, category_source, payee, notes, transfer_transaction_id, transfer_source`
+
+func (r *transactionRepositoryImpl) ListAllForAccount(ctx context.Context, userID int, bankAccountID int) ([]DbTransaction, error) {
+	r.log.Debugf("Attempting to list all transactions for bank account with id='%d'", bankAccountID)
+
+	query := `
+	SELECT ` + transactionColumns + ` FROM transaction
+	WHERE bank_account_id = $1 AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)
+	ORDER BY date_posted DESC, id DESC`
+
+	rows, err := r.db.Query(ctx, query, bankAccountID, userID)
+
+	if err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to list transactions for bank account with id='%d': %w", bankAccountID, err)
+	}
+
+	return collectTransactions(rows)
+}
+
+func (r *transactionRepositoryImpl) FindByID(ctx context.Context, userID int, id int64) (DbTransaction, error) {
+	r.log.Debugf("Attempting to find Transaction with id='%d'", id)
+
+	query := `
+	SELECT ` + transactionColumns + ` FROM transaction
+	WHERE id = $1 AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)`
+
+	transaction, err := scanTransaction(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return DbTransaction{}, fmt.Errorf("Failed to find Transaction with id='%d': %w", id, err)
+	}
+
+	return transaction, nil
+}
+
+func (r *transactionRepositoryImpl) ListTransactions(ctx context.Context, filter TransactionFilter) (TransactionPage, error) {
+	r.log.Debugf("Attempting to list transactions of User with id='%d' matching %+v", filter.UserID, filter)
+
+	var q database.QueryBuilder
+
+	q.Where("bank_account_id IN (SELECT id FROM bank_account WHERE user_id = " + q.Arg(filter.UserID) + ")")
+
+	if filter.BankAccountID != nil {
+		q.Where("bank_account_id = " + q.Arg(*filter.BankAccountID))
+	}
+
+	if filter.From != nil {
+		q.Where("date_posted >= " + q.Arg(*filter.From))
+	}
+
+	if filter.To != nil {
+		q.Where("date_posted <= " + q.Arg(*filter.To))
+	}
+
+	if filter.MinAmount.Valid {
+		q.Where("-amount >= " + q.Arg(filter.MinAmount.Decimal))
+	}
+
+	if filter.MaxAmount.Valid {
+		q.Where("-amount <= " + q.Arg(filter.MaxAmount.Decimal))
+	}
+
+	// Split transactions match the categories of their allocations as well
+	if filter.CategoryID != nil {
+		subcategories := `(
+			WITH RECURSIVE subcategory AS (
+				SELECT id FROM category WHERE id = ` + q.Arg(*filter.CategoryID) + `
+				UNION ALL
+				SELECT category.id FROM category JOIN subcategory ON category.parent_id = subcategory.id
+			)
+			SELECT id FROM subcategory
+		)`
+
+		q.Where(`(category_id IN ` + subcategories + ` OR id IN (
+			SELECT transaction_id FROM transaction_allocation WHERE category_id IN ` + subcategories + `
+		))`)
+	}
+
+	if filter.Tag != "" {
+		q.Where(`id IN (
+			SELECT transaction_tag.transaction_id FROM transaction_tag
+			JOIN tag ON tag.id = transaction_tag.tag_id
+			WHERE tag.name = ` + q.Arg(filter.Tag) + `
+		)`)
+	}
+
+	// Money coming in and transfers between the user's own accounts come without receipts
+	if filter.MissingReceiptAbove.Valid {
+		q.Where("amount >= " + q.Arg(filter.MissingReceiptAbove.Decimal))
+		q.Where("transfer_transaction_id IS NULL")
+		q.Where("NOT EXISTS (SELECT 1 FROM transaction_attachment WHERE transaction_attachment.transaction_id = transaction.id)")
+	}
+
+	if filter.Search != "" {
+		pattern := q.Arg("%" + database.EscapeLike(filter.Search) + "%")
+		q.Where("(name ILIKE " + pattern + " OR merchant_name ILIKE " + pattern + " OR payee ILIKE " + pattern + ")")
+	}
+
+	sortExpression, sortType := filter.SortBy.sqlExpression()
+	direction, comparison := "ASC", ">"
+
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		q.Where(fmt.Sprintf("(%s, id) %s (%s::%s, %s)", sortExpression, comparison, q.Arg(filter.After.Value), sortType, q.Arg(filter.After.ID)))
+	}
+
+	limit := filter.Limit
+
+	if limit <= 0 {
+		limit = 50
+	}
+
+	// One more than asked for tells us whether there is another page
+	query := `SELECT ` + transactionColumns + ` FROM transaction` + q.WhereClause() +
+		fmt.Sprintf(` ORDER BY %s %s, id %s LIMIT %s`, sortExpression, direction, direction, q.Arg(limit+1))
+
+	rows, err := r.db.Query(ctx, query, q.Args()...)
+
+	if err != nil {
+		return TransactionPage{}, fmt.Errorf("Failed to list transactions of User with id='%d': %w", filter.UserID, err)
+	}
+
+	transactions, err := collectTransactions(rows)
+
+	if err != nil {
+		return TransactionPage{}, err
+	}
+
+	page := TransactionPage{Transactions: transactions}
+
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = &TransactionCursor{Value: filter.SortBy.cursorValue(last), ID: last.ID}
+	}
+
+	return page, nil
+}
+
+func (r *transactionRepositoryImpl) ListProviderCategories(ctx context.Context, userID int) ([]string, error) {
+	r.log.Debugf("Attempting to list provider categories of transactions of User with id='%d'", userID)
+
+	query := `
+	SELECT DISTINCT category FROM transaction
+	WHERE category IS NOT NULL AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $1)
+	ORDER BY category`
+
+	rows, err := r.db.Query(ctx, query, userID)
+
+	if err != nil {
+		return []string{}, fmt.Errorf("Failed to list provider categories of transactions of User with id='%d': %w", userID, err)
+	}
+
+	categories, err := pgx.CollectRows(rows, pgx.RowTo[string])
+
+	if err != nil {
+		return []string{}, fmt.Errorf("Failed to read provider category rows: %w", err)
+	}
+
+	return categories, nil
+}
+
+func (r *transactionRepositoryImpl) SumSpendingByCategory(ctx context.Context, userID int, from time.Time, to time.Time) ([]CategorySpending, error) {
+	r.log.Debugf("Attempting to sum spending by category of User with id='%d'", userID)
+
+	query := `
+	WITH user_transaction AS (
+		SELECT id, category_id, date_posted, currency, amount FROM transaction
+		WHERE transfer_transaction_id IS NULL
+			AND date_posted >= $2 AND date_posted < $3
+			AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $1)
+	),
+	-- Splits which no longer add up to the amount of their transaction are ignored until the user fixes them
+	matching_split AS (
+		SELECT user_transaction.id FROM user_transaction
+		JOIN transaction_allocation ON transaction_allocation.transaction_id = user_transaction.id
+		GROUP BY user_transaction.id, user_transaction.amount
+		HAVING SUM(transaction_allocation.amount) = user_transaction.amount
+	),
+	spending AS (
+		SELECT transaction_allocation.category_id, user_transaction.date_posted, user_transaction.currency, transaction_allocation.amount
+		FROM user_transaction
+		JOIN transaction_allocation ON transaction_allocation.transaction_id = user_transaction.id
+		WHERE user_transaction.id IN (SELECT id FROM matching_split)
+		UNION ALL
+		SELECT category_id, date_posted, currency, amount FROM user_transaction
+		WHERE id NOT IN (SELECT id FROM matching_split)
+	)
+	SELECT category_id, date_trunc('month', date_posted)::date AS month, currency, SUM(amount)
+	FROM spending
+	WHERE category_id IS NOT NULL
+	GROUP BY category_id, month, currency
+	ORDER BY month, category_id, currency`
+
+	rows, err := r.db.Query(ctx, query, userID, from, to)
+
+	if err != nil {
+		return []CategorySpending{}, fmt.Errorf("Failed to sum spending by category of User with id='%d': %w", userID, err)
+	}
+
+	defer rows.Close()
+
+	var spendings []CategorySpending
+
+	for rows.Next() {
+		var spending CategorySpending
+
+		if err := rows.Scan(&spending.CategoryID, &spending.Month, &spending.Currency, &spending.Amount); err != nil {
+			return []CategorySpending{}, fmt.Errorf("Failed to read category spending row: %w", err)
+		}
+
+		spendings = append(spendings, spending)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []CategorySpending{}, fmt.Errorf("Failed to read category spending rows: %w", err)
+	}
+
+	return spendings, nil
+}
+
+func (r *transactionRepositoryImpl) ListTags(ctx context.Context, transactionIDs []int64) (map[int64][]string, error) {
+	r.log.Debugf("Attempting to list tags of %d transactions", len(transactionIDs))
+
+	query := `
+	SELECT transaction_tag.transaction_id, tag.name FROM transaction_tag
+	JOIN tag ON tag.id = transaction_tag.tag_id
+	WHERE transaction_tag.transaction_id = ANY($1)
+	ORDER BY tag.name`
+
+	rows, err := r.db.Query(ctx, query, transactionIDs)
+
+	if err != nil {
+		return map[int64][]string{}, fmt.Errorf("Failed to list tags of transactions: %w", err)
+	}
+
+	defer rows.Close()
+
+	tags := map[int64][]string{}
+
+	for rows.Next() {
+		var transactionID int64
+		var name string
+
+		if err := rows.Scan(&transactionID, &name); err != nil {
+			return map[int64][]string{}, fmt.Errorf("Failed to read transaction tag row: %w", err)
+		}
+
+		tags[transactionID] = append(tags[transactionID], name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return map[int64][]string{}, fmt.Errorf("Failed to read transaction tag rows: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (r *transactionRepositoryImpl) ListAllocations(ctx context.Context, transactionIDs []int64) (map[int64][]Allocation, error) {
+	r.log.Debugf("Attempting to list allocations of %d transactions", len(transactionIDs))
+
+	query := `
+	SELECT ` + allocationColumns + ` FROM transaction_allocation
+	WHERE transaction_id = ANY($1)
+	ORDER BY transaction_id, position`
+
+	rows, err := r.db.Query(ctx, query, transactionIDs)
+
+	if err != nil {
+		return map[int64][]Allocation{}, fmt.Errorf("Failed to list allocations of transactions: %w", err)
+	}
+
+	allocations, err := collectAllocations(rows)
+
+	if err != nil {
+		return map[int64][]Allocation{}, err
+	}
+
+	allocationsByTransaction := map[int64][]Allocation{}
+
+	for _, allocation := range allocations {
+		allocationsByTransaction[allocation.TransactionID] = append(allocationsByTransaction[allocation.TransactionID], allocation)
+	}
+
+	return allocationsByTransaction, nil
+}
+
+func (r *transactionRepositoryImpl) ReplaceAllocations(ctx context.Context, userID int, transactionID int64, allocations []AllocationWriteModel) ([]Allocation, error) {
+	r.log.Debugf("Attempting to split Transaction with id='%d' into %d allocations", transactionID, len(allocations))
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return []Allocation{}, fmt.Errorf("Failed to start database transaction when splitting a transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	// A sync changing the amount at the same time has to wait
+	transaction, err := scanTransaction(tx.QueryRow(
+		ctx,
+		`SELECT `+transactionColumns+` FROM transaction
+		WHERE id = $1 AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)
+		FOR UPDATE`,
+		transactionID,
+		userID,
+	))
+
+	if err != nil {
+		return []Allocation{}, fmt.Errorf("Failed to find Transaction with id='%d': %w", transactionID, err)
+	}
+
+	if len(allocations) > 0 {
+		if err := ValidateSplit(transaction, allocations); err != nil {
+			return []Allocation{}, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM transaction_allocation WHERE transaction_id = $1`, transactionID); err != nil {
+		return []Allocation{}, fmt.Errorf("Failed to delete allocations of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	savedAllocations := make([]Allocation, 0, len(allocations))
+
+	for position, allocation := range allocations {
+		// The category has to belong to the same user
+		saved, err := scanAllocation(tx.QueryRow(
+			ctx,
+			`INSERT INTO transaction_allocation (transaction_id, position, amount, category_id, notes)
+			SELECT $1, $2, $3, $4, $5
+			WHERE $4::int IS NULL OR EXISTS (SELECT 1 FROM category WHERE id = $4 AND user_id = $6)
+			RETURNING `+allocationColumns,
+			transactionID,
+			position,
+			allocation.Amount,
+			allocation.CategoryID,
+			allocation.Notes,
+			userID,
+		))
+
+		if err != nil {
+			return []Allocation{}, fmt.Errorf("Failed to save allocation of Transaction with id='%d': %w", transactionID, err)
+		}
+
+		savedAllocations = append(savedAllocations, saved)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return []Allocation{}, fmt.Errorf("Failed to commit allocations of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	return savedAllocations, nil
+}
+
+func (r *transactionRepositoryImpl) ListTagNames(ctx context.Context, userID int) ([]string, error) {
+	r.log.Debugf("Attempting to list tags of User with id='%d'", userID)
+
+	rows, err := r.db.Query(ctx, `SELECT name FROM tag WHERE user_id = $1 ORDER BY name`, userID)
+
+	if err != nil {
+		return []string{}, fmt.Errorf("Failed to list tags of User with id='%d': %w", userID, err)
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+
+	if err != nil {
+		return []string{}, fmt.Errorf("Failed to read tag rows: %w", err)
+	}
+
+	return names, nil
+}
+
+func (r *transactionRepositoryImpl) UpdateNotesAndTags(ctx context.Context, userID int, transactionID int64, notes *string, tags []string) error {
+	r.log.Debugf("Attempting to update notes and tags of Transaction with id='%d'", transactionID)
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to start database transaction when updating notes of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	commandTag, err := tx.Exec(ctx, `
+	UPDATE transaction SET notes = $3
+	WHERE id = $1 AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)`,
+		transactionID,
+		userID,
+		notes,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update notes of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("Failed to find Transaction with id='%d': %w", transactionID, pgx.ErrNoRows)
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO tag (user_id, name)
+	SELECT $1, unnest($2::text[])
+	ON CONFLICT (user_id, name) DO NOTHING`,
+		userID,
+		tags,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create tags of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+	DELETE FROM transaction_tag
+	WHERE transaction_id = $1 AND tag_id NOT IN (SELECT id FROM tag WHERE user_id = $2 AND name = ANY($3))`,
+		transactionID,
+		userID,
+		tags,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to remove tags of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO transaction_tag (transaction_id, tag_id)
+	SELECT $1, id FROM tag WHERE user_id = $2 AND name = ANY($3)
+	ON CONFLICT DO NOTHING`,
+		transactionID,
+		userID,
+		tags,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to tag Transaction with id='%d': %w", transactionID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("Failed to commit notes and tags of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	return nil
+}
+
+func (r *transactionRepositoryImpl) ListAttachments(ctx context.Context, transactionIDs []int64) (map[int64][]Attachment, error) {
+	r.log.Debugf("Attempting to list attachments of %d transactions", len(transactionIDs))
+
+	query := `
+	SELECT ` + attachmentColumns + ` FROM transaction_attachment
+	WHERE transaction_id = ANY($1)
+	ORDER BY transaction_id, created_at, id`
+
+	rows, err := r.db.Query(ctx, query, transactionIDs)
+
+	if err != nil {
+		return map[int64][]Attachment{}, fmt.Errorf("Failed to list attachments of transactions: %w", err)
+	}
+
+	defer rows.Close()
+
+	attachments := map[int64][]Attachment{}
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+
+		if err != nil {
+			return map[int64][]Attachment{}, fmt.Errorf("Failed to read attachment row: %w", err)
+		}
+
+		attachments[attachment.TransactionID] = append(attachments[attachment.TransactionID], attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return map[int64][]Attachment{}, fmt.Errorf("Failed to read attachment rows: %w", err)
+	}
+
+	return attachments, nil
+}
+
+func (r *transactionRepositoryImpl) AddAttachment(ctx context.Context, userID int, transactionID int64, writeModel AttachmentWriteModel) (Attachment, error) {
+	r.log.Debugf("Attempting to attach '%s' to Transaction with id='%d'", writeModel.FileName, transactionID)
+
+	query := `
+	INSERT INTO transaction_attachment (transaction_id, blob_key, file_name, content_type, size)
+	SELECT $1, $3, $4, $5, $6
+	WHERE EXISTS (
+		SELECT 1 FROM transaction
+		WHERE id = $1 AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)
+	)
+	RETURNING ` + attachmentColumns
+
+	attachment, err := scanAttachment(r.db.QueryRow(
+		ctx,
+		query,
+		transactionID,
+		userID,
+		writeModel.BlobKey,
+		writeModel.FileName,
+		writeModel.ContentType,
+		writeModel.Size,
+	))
+
+	if err != nil {
+		return Attachment{}, fmt.Errorf("Failed to attach file to Transaction with id='%d': %w", transactionID, err)
+	}
+
+	return attachment, nil
+}
+
+func (r *transactionRepositoryImpl) FindAttachment(ctx context.Context, userID int, id int64) (Attachment, error) {
+	r.log.Debugf("Attempting to find Attachment with id='%d'", id)
+
+	query := `
+	SELECT ` + attachmentColumns + ` FROM transaction_attachment
+	WHERE id = $1 AND transaction_id IN (
+		SELECT transaction.id FROM transaction
+		JOIN bank_account ON bank_account.id = transaction.bank_account_id
+		WHERE bank_account.user_id = $2
+	)`
+
+	attachment, err := scanAttachment(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return Attachment{}, fmt.Errorf("Failed to find Attachment with id='%d': %w", id, err)
+	}
+
+	return attachment, nil
+}
+
+func (r *transactionRepositoryImpl) DeleteAttachment(ctx context.Context, userID int, id int64) (Attachment, error) {
+	r.log.Debugf("Attempting to delete Attachment with id='%d'", id)
+
+	query := `
+	DELETE FROM transaction_attachment
+	WHERE id = $1 AND transaction_id IN (
+		SELECT transaction.id FROM transaction
+		JOIN bank_account ON bank_account.id = transaction.bank_account_id
+		WHERE bank_account.user_id = $2
+	)
+	RETURNING ` + attachmentColumns
+
+	attachment, err := scanAttachment(r.db.QueryRow(ctx, query, id, userID))
+
+	if err != nil {
+		return Attachment{}, fmt.Errorf("Failed to delete Attachment with id='%d': %w", id, err)
+	}
+
+	return attachment, nil
+}
+
+func (r *transactionRepositoryImpl) UpdateCategorization(ctx context.Context, transactionID int64, update CategorizationUpdate) error {
+	r.log.Debugf("Attempting to update categorization of transaction with id='%d'", transactionID)
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to start database transaction when updating categorization of transaction with id='%d': %w", transactionID, err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+	UPDATE transaction SET
+		category_id = CASE WHEN $2::int IS NULL OR category_source = 'user' THEN category_id ELSE $2 END,
+		category_source = CASE WHEN $2::int IS NULL OR category_source = 'user' THEN category_source ELSE 'rule' END,
+		payee = CASE WHEN $3::text IS NULL OR category_source = 'user' THEN payee ELSE $3 END
+	WHERE id = $1`,
+		transactionID,
+		update.CategoryID,
+		update.Payee,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update categorization of transaction with id='%d': %w", transactionID, err)
+	}
+
+	if len(update.AddTags) > 0 {
+		// Tags belong to the owner of the transaction and are created on first use
+		_, err = tx.Exec(ctx, `
+		INSERT INTO tag (user_id, name)
+		SELECT bank_account.user_id, unnest($2::text[]) FROM transaction
+		JOIN bank_account ON bank_account.id = transaction.bank_account_id
+		WHERE transaction.id = $1
+		ON CONFLICT (user_id, name) DO NOTHING`,
+			transactionID,
+			update.AddTags,
+		)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create tags of transaction with id='%d': %w", transactionID, err)
+		}
+
+		_, err = tx.Exec(ctx, `
+		INSERT INTO transaction_tag (transaction_id, tag_id)
+		SELECT $1, tag.id FROM tag
+		JOIN bank_account ON bank_account.user_id = tag.user_id
+		JOIN transaction ON transaction.bank_account_id = bank_account.id
+		WHERE transaction.id = $1 AND tag.name = ANY($2)
+		ON CONFLICT DO NOTHING`,
+			transactionID,
+			update.AddTags,
+		)
+
+		if err != nil {
+			return fmt.Errorf("Failed to tag transaction with id='%d': %w", transactionID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("Failed to commit categorization of transaction with id='%d': %w", transactionID, err)
+	}
+
+	return nil
+}
+
+// SaveAll inserts the given transactions in a single batch. Transactions that were already saved
+// (matched by plaid_transaction_id) are updated in place. The PlaidAccountID of every write model
+// is resolved to the id of the matching bank_account row.
+func (r *transactionRepositoryImpl) SaveAll(ctx context.Context, writeModels []DbTransactionWriteModel) ([]DbTransaction, error) {
+	r.log.Debugf("Attempting to save %d transactions", len(writeModels))
+
+	if len(writeModels) == 0 {
+		return []DbTransaction{}, nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to start database transaction when saving transactions: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	savedTransactions, err := saveAll(ctx, tx, writeModels)
+
+	if err != nil {
+		return []DbTransaction{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to commit saved transactions: %w", err)
+	}
+
+	r.log.Debugf("Saved %d transactions", len(savedTransactions))
+
+	return savedTransactions, nil
+}
+
+func (r *transactionRepositoryImpl) ImportAll(ctx context.Context, userID int, bankAccountID int, writeModels []DbTransactionWriteModel) ([]DbTransaction, error) {
+	r.log.Debugf("Attempting to import %d transactions into BankAccount with id='%d'", len(writeModels), bankAccountID)
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to start database transaction when importing transactions: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var manualAccountID int
+
+	err = tx.QueryRow(
+		ctx,
+		`SELECT id FROM bank_account WHERE id = $1 AND user_id = $2 AND bank_connection_id IS NULL FOR UPDATE`,
+		bankAccountID,
+		userID,
+	).Scan(&manualAccountID)
+
+	if err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to find manual BankAccount with id='%d': %w", bankAccountID, err)
+	}
+
+	query := `
+	INSERT INTO transaction (
+		plaid_transaction_id, bank_account_id, amount, currency, date_authorized, date_time_authorized, date_posted, date_time_posted,
+		name, merchant_name, pending, counterparties, raw
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE($12::jsonb, '[]'), $13)
+	ON CONFLICT (plaid_transaction_id) DO NOTHING
+	RETURNING ` + transactionColumns
+
+	batch := &pgx.Batch{}
+
+	for _, writeModel := range writeModels {
+		batch.Queue(
+			query,
+			writeModel.PlaidTransactionID,
+			bankAccountID,
+			writeModel.Amount,
+			writeModel.Currency,
+			writeModel.DateAuthorized,
+			writeModel.DateTimeAuthorized,
+			writeModel.DatePosted,
+			writeModel.DateTimePosted,
+			writeModel.Name,
+			writeModel.MerchantName,
+			writeModel.Pending,
+			writeModel.Counterparties,
+			rawJSON(writeModel.Raw),
+		)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+
+	importedTransactions := make([]DbTransaction, 0, len(writeModels))
+
+	for _, writeModel := range writeModels {
+		transaction, err := scanTransaction(results.QueryRow())
+
+		// Imported before
+		if err == pgx.ErrNoRows {
+			continue
+		}
+
+		if err != nil {
+			results.Close()
+			return []DbTransaction{}, fmt.Errorf("Failed to import transaction with plaid_transaction_id='%s': %w", writeModel.PlaidTransactionID, err)
+		}
+
+		importedTransactions = append(importedTransactions, transaction)
+	}
+
+	if err := results.Close(); err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to import transactions: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to commit imported transactions: %w", err)
+	}
+
+	r.log.Debugf("Imported %d transactions into BankAccount with id='%d'", len(importedTransactions), bankAccountID)
+
+	return importedTransactions, nil
+}
+
+func (r *transactionRepositoryImpl) DeleteAllByPlaidTransactionID(ctx context.Context, plaidTransactionIDs []string) (int, error) {
+	r.log.Debugf("Attempting to delete %d transactions", len(plaidTransactionIDs))
+
+	if len(plaidTransactionIDs) == 0 {
+		return 0, nil
+	}
+
+	commandTag, err := r.db.Exec(ctx, `DELETE FROM transaction WHERE plaid_transaction_id = ANY($1)`, plaidTransactionIDs)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to delete transactions: %w", err)
+	}
+
+	return int(commandTag.RowsAffected()), nil
+}
+
+func (r *transactionRepositoryImpl) AdoptProviderIDs(ctx context.Context, writeModels []DbTransactionWriteModel) (int, error) {
+	r.log.Debugf("Attempting to adopt the provider ids of %d transactions", len(writeModels))
+
+	if len(writeModels) == 0 {
+		return 0, nil
+	}
+
+	type transactionKey struct {
+		plaidAccountID string
+		datePosted     string
+		amount         string
+		name           string
+	}
+
+	keyOf := func(plaidAccountID string, datePosted time.Time, amount decimal.Decimal, name string) transactionKey {
+		return transactionKey{plaidAccountID, datePosted.Format(time.DateOnly), amount.String(), name}
+	}
+
+	plaidAccountIDs := make([]string, 0, len(writeModels))
+	plaidTransactionIDs := make([]string, 0, len(writeModels))
+
+	for _, writeModel := range writeModels {
+		plaidAccountIDs = append(plaidAccountIDs, writeModel.PlaidAccountID)
+		plaidTransactionIDs = append(plaidTransactionIDs, writeModel.PlaidTransactionID)
+	}
+
+	query := `
+	SELECT t.id, ba.plaid_account_id, t.date_posted, t.amount, t.name
+	FROM transaction t
+	JOIN bank_account ba ON ba.id = t.bank_account_id
+	WHERE ba.plaid_account_id = ANY($1) AND NOT (t.plaid_transaction_id = ANY($2))
+	ORDER BY t.id`
+
+	rows, err := r.db.Query(ctx, query, plaidAccountIDs, plaidTransactionIDs)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to list transactions whose provider ids could be adopted: %w", err)
+	}
+
+	savedIDsByKey := map[transactionKey][]int64{}
+
+	for rows.Next() {
+		var id int64
+		var plaidAccountID string
+		var datePosted time.Time
+		var amount decimal.Decimal
+		var name string
+
+		if err := rows.Scan(&id, &plaidAccountID, &datePosted, &amount, &name); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("Failed to scan transaction whose provider id could be adopted: %w", err)
+		}
+
+		key := keyOf(plaidAccountID, datePosted, amount, name)
+		savedIDsByKey[key] = append(savedIDsByKey[key], id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("Failed to list transactions whose provider ids could be adopted: %w", err)
+	}
+
+	adopted := 0
+
+	for _, writeModel := range writeModels {
+		key := keyOf(writeModel.PlaidAccountID, writeModel.DatePosted, writeModel.Amount, writeModel.Name)
+		savedIDs := savedIDsByKey[key]
+
+		if len(savedIDs) == 0 {
+			continue
+		}
+
+		savedIDsByKey[key] = savedIDs[1:]
+
+		_, err := r.db.Exec(ctx, `UPDATE transaction SET plaid_transaction_id = $2 WHERE id = $1`, savedIDs[0], writeModel.PlaidTransactionID)
+
+		if err != nil {
+			return adopted, fmt.Errorf("Failed to adopt provider id of Transaction with id='%d': %w", savedIDs[0], err)
+		}
+
+		adopted++
+	}
+
+	return adopted, nil
+}
+
+func (r *transactionRepositoryImpl) LinkTransfer(ctx context.Context, userID int, transactionID int64, otherTransactionID int64, source TransferSource) error {
+	r.log.Debugf("Attempting to link Transactions with id='%d' and id='%d' as a transfer", transactionID, otherTransactionID)
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to start database transaction when linking a transfer: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT bank_account_id, transfer_transaction_id, transfer_source FROM transaction
+		WHERE id = ANY($1) AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)
+		FOR UPDATE`,
+		[]int64{transactionID, otherTransactionID},
+		userID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to lock Transactions with id='%d' and id='%d': %w", transactionID, otherTransactionID, err)
+	}
+
+	bankAccountIDs := map[int]bool{}
+	found := 0
+
+	for rows.Next() {
+		var bankAccountID int
+		var transferTransactionID *int64
+		var transferSource *TransferSource
+
+		if err := rows.Scan(&bankAccountID, &transferTransactionID, &transferSource); err != nil {
+			rows.Close()
+			return fmt.Errorf("Failed to read transaction row: %w", err)
+		}
+
+		if source == TransferSourceMatch && (transferTransactionID != nil || (transferSource != nil && *transferSource == TransferSourceUser)) {
+			rows.Close()
+			return ErrTransferDecided
+		}
+
+		bankAccountIDs[bankAccountID] = true
+		found++
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Failed to read transaction rows: %w", err)
+	}
+
+	if found != 2 {
+		return fmt.Errorf("Failed to link Transactions with id='%d' and id='%d' as a transfer: %w", transactionID, otherTransactionID, pgx.ErrNoRows)
+	}
+
+	if len(bankAccountIDs) != 2 {
+		return ErrTransferWithinAccount
+	}
+
+	// The previous other sides are free to be matched again
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE transaction SET transfer_transaction_id = NULL, transfer_source = NULL
+		WHERE transfer_transaction_id = ANY($1) AND id <> ALL($1)`,
+		[]int64{transactionID, otherTransactionID},
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to unlink previous transfers of Transactions with id='%d' and id='%d': %w", transactionID, otherTransactionID, err)
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE transaction SET
+			transfer_transaction_id = CASE WHEN id = $1 THEN $2::bigint ELSE $1::bigint END,
+			transfer_source = $3
+		WHERE id IN ($1, $2)`,
+		transactionID,
+		otherTransactionID,
+		source,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to link Transactions with id='%d' and id='%d' as a transfer: %w", transactionID, otherTransactionID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("Failed to commit transfer of Transactions with id='%d' and id='%d': %w", transactionID, otherTransactionID, err)
+	}
+
+	return nil
+}
+
+func (r *transactionRepositoryImpl) UnlinkTransfer(ctx context.Context, userID int, transactionID int64) error {
+	r.log.Debugf("Attempting to unlink the transfer of Transaction with id='%d'", transactionID)
+
+	query := `
+	UPDATE transaction SET transfer_transaction_id = NULL, transfer_source = $3
+	WHERE (id = $1 OR transfer_transaction_id = $1)
+		AND bank_account_id IN (SELECT id FROM bank_account WHERE user_id = $2)`
+
+	commandTag, err := r.db.Exec(ctx, query, transactionID, userID, TransferSourceUser)
+
+	if err != nil {
+		return fmt.Errorf("Failed to unlink the transfer of Transaction with id='%d': %w", transactionID, err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("Failed to unlink the transfer of Transaction with id='%d': %w", transactionID, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *transactionRepositoryImpl) MergePending(ctx context.Context, postedTransactions []DbTransaction) ([]TransactionMerge, error) {
+	r.log.Debugf("Attempting to merge pending transactions into %d transactions", len(postedTransactions))
+
+	tx, err := r.db.Begin(ctx)
+
+	if err != nil {
+		return []TransactionMerge{}, fmt.Errorf("Failed to start database transaction when merging pending transactions: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var merges []TransactionMerge
+
+	for _, posted := range postedTransactions {
+		if posted.Pending || posted.PendingTransactionID == nil {
+			continue
+		}
+
+		merge, err := mergePending(ctx, tx, posted)
+
+		// We never saw the pending transaction, or it was merged before
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+
+		if err != nil {
+			return []TransactionMerge{}, fmt.Errorf("Failed to merge pending transaction with plaid_transaction_id='%s' into transaction with id='%d': %w", *posted.PendingTransactionID, posted.ID, err)
+		}
+
+		merges = append(merges, merge)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return []TransactionMerge{}, fmt.Errorf("Failed to commit merged pending transactions: %w", err)
+	}
+
+	return merges, nil
+}
+
+func mergePending(ctx context.Context, tx pgx.Tx, posted DbTransaction) (TransactionMerge, error) {
+	merge := TransactionMerge{PostedTransactionID: posted.ID}
+	var pendingID int64
+
+	err := tx.QueryRow(ctx, `
+	SELECT pending.id, pending.plaid_transaction_id, pending.amount, pending.date_posted, pending.name,
+		pending.category_id, pending.category_source, pending.payee, pending.notes,
+		ARRAY(
+			SELECT tag.name FROM transaction_tag
+			JOIN tag ON tag.id = transaction_tag.tag_id
+			WHERE transaction_tag.transaction_id = pending.id
+			ORDER BY tag.name
+		)
+	FROM transaction AS pending
+	WHERE pending.plaid_transaction_id = $1 AND pending.bank_account_id = $2
+	FOR UPDATE`,
+		*posted.PendingTransactionID,
+		posted.BankAccountID,
+	).Scan(
+		&pendingID,
+		&merge.PendingPlaidTransactionID,
+		&merge.PendingAmount,
+		&merge.PendingDatePosted,
+		&merge.PendingName,
+		&merge.CategoryID,
+		&merge.CategorySource,
+		&merge.Payee,
+		&merge.Notes,
+		&merge.Tags,
+	)
+
+	if err != nil {
+		return TransactionMerge{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE transaction SET
+		category_id = CASE WHEN category_source IS NULL THEN $2 ELSE category_id END,
+		category_source = COALESCE(category_source, $3),
+		payee = COALESCE(payee, $4),
+		notes = COALESCE(notes, $5)
+	WHERE id = $1`,
+		posted.ID,
+		merge.CategoryID,
+		merge.CategorySource,
+		merge.Payee,
+		merge.Notes,
+	)
+
+	if err != nil {
+		return TransactionMerge{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO transaction_tag (transaction_id, tag_id)
+	SELECT $1, tag_id FROM transaction_tag WHERE transaction_id = $2
+	ON CONFLICT DO NOTHING`,
+		posted.ID,
+		pendingID,
+	)
+
+	if err != nil {
+		return TransactionMerge{}, err
+	}
+
+	err = tx.QueryRow(ctx, `
+	INSERT INTO transaction_merge (
+		posted_transaction_id, pending_plaid_transaction_id, pending_amount, pending_date_posted, pending_name,
+		category_id, category_source, payee, notes, tags
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id, merged_at`,
+		merge.PostedTransactionID,
+		merge.PendingPlaidTransactionID,
+		merge.PendingAmount,
+		merge.PendingDatePosted,
+		merge.PendingName,
+		merge.CategoryID,
+		merge.CategorySource,
+		merge.Payee,
+		merge.Notes,
+		merge.Tags,
+	).Scan(&merge.ID, &merge.MergedAt)
+
+	if err != nil {
+		return TransactionMerge{}, err
+	}
+
+	// Receipts attached to the pending transaction belong to the posted one
+	_, err = tx.Exec(ctx, `UPDATE transaction_attachment SET transaction_id = $1 WHERE transaction_id = $2`, posted.ID, pendingID)
+
+	if err != nil {
+		return TransactionMerge{}, err
+	}
+
+	// Splits of the pending transaction move over, unless the posted transaction was split already
+	commandTag, err := tx.Exec(ctx, `
+	UPDATE transaction_allocation SET transaction_id = $1
+	WHERE transaction_id = $2 AND NOT EXISTS (SELECT 1 FROM transaction_allocation WHERE transaction_id = $1)`,
+		posted.ID,
+		pendingID,
+	)
+
+	if err != nil {
+		return TransactionMerge{}, err
+	}
+
+	// The posted amount may differ from the pending one, e.g. when a tip was added
+	if commandTag.RowsAffected() > 0 && !posted.Amount.Equal(merge.PendingAmount) {
+		if err := scaleAllocations(ctx, tx, posted.ID, merge.PendingAmount, posted.Amount); err != nil {
+			return TransactionMerge{}, err
+		}
+	}
+
+	// The provider removes the pending transaction as well, usually in the same sync. Until then it would
+	// be counted twice.
+	if _, err := tx.Exec(ctx, `DELETE FROM transaction WHERE id = $1`, pendingID); err != nil {
+		return TransactionMerge{}, err
+	}
+
+	return merge, nil
+}
+
+// scaleAllocations scales the allocations of the transaction from the amount they were split from to its amount
+func scaleAllocations(ctx context.Context, tx pgx.Tx, transactionID int64, from decimal.Decimal, to decimal.Decimal) error {
+	rows, err := tx.Query(ctx, `SELECT id, amount FROM transaction_allocation WHERE transaction_id = $1 ORDER BY id`, transactionID)
+
+	if err != nil {
+		return err
+	}
+
+	type allocationAmount struct {
+		id     int64
+		amount decimal.Decimal
+	}
+
+	allocations, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (allocationAmount, error) {
+		var allocation allocationAmount
+		err := row.Scan(&allocation.id, &allocation.amount)
+		return allocation, err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	amounts := make([]decimal.Decimal, 0, len(allocations))
+
+	for _, allocation := range allocations {
+		amounts = append(amounts, allocation.amount)
+	}
+
+	for i, scaled := range scaleAllocationAmounts(amounts, from, to) {
+		if _, err := tx.Exec(ctx, `UPDATE transaction_allocation SET amount = $2 WHERE id = $1`, allocations[i].id, scaled); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func saveAll(ctx context.Context, tx pgx.Tx, writeModels []DbTransactionWriteModel) ([]DbTransaction, error) {
+	if len(writeModels) == 0 {
+		return []DbTransaction{}, nil
+	}
+
+	query := `
+	INSERT INTO transaction (
+		plaid_transaction_id, bank_account_id, amount, currency, date_authorized, date_time_authorized, date_posted, date_time_posted,
+		name, merchant_name, category, category_detailed, pending, pending_transaction_id, payment_channel, merchant_entity_id,
+		logo_url, website, location, counterparties, raw
+	)
+	SELECT $1, bank_account.id, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, COALESCE($20::jsonb, '[]'), $21
+	FROM bank_account
+	WHERE bank_account.plaid_account_id = $2
+	ON CONFLICT (plaid_transaction_id) DO UPDATE SET
+		bank_account_id = EXCLUDED.bank_account_id,
+		amount = EXCLUDED.amount,
+		currency = EXCLUDED.currency,
+		date_authorized = EXCLUDED.date_authorized,
+		date_time_authorized = EXCLUDED.date_time_authorized,
+		date_posted = EXCLUDED.date_posted,
+		date_time_posted = EXCLUDED.date_time_posted,
+		name = EXCLUDED.name,
+		merchant_name = EXCLUDED.merchant_name,
+		category = EXCLUDED.category,
+		category_detailed = EXCLUDED.category_detailed,
+		pending = EXCLUDED.pending,
+		pending_transaction_id = EXCLUDED.pending_transaction_id,
+		payment_channel = EXCLUDED.payment_channel,
+		merchant_entity_id = EXCLUDED.merchant_entity_id,
+		logo_url = EXCLUDED.logo_url,
+		website = EXCLUDED.website,
+		location = EXCLUDED.location,
+		counterparties = EXCLUDED.counterparties,
+		raw = EXCLUDED.raw
+	RETURNING ` + transactionColumns
+
+	batch := &pgx.Batch{}
+
+	for _, writeModel := range writeModels {
+		batch.Queue(
+			query,
+			writeModel.PlaidTransactionID,
+			writeModel.PlaidAccountID,
+			writeModel.Amount,
+			writeModel.Currency,
+			writeModel.DateAuthorized,
+			writeModel.DateTimeAuthorized,
+			writeModel.DatePosted,
+			writeModel.DateTimePosted,
+			writeModel.Name,
+			writeModel.MerchantName,
+			writeModel.Category,
+			writeModel.CategoryDetailed,
+			writeModel.Pending,
+			writeModel.PendingTransactionID,
+			writeModel.PaymentChannel,
+			writeModel.MerchantEntityID,
+			writeModel.LogoURL,
+			writeModel.Website,
+			writeModel.Location,
+			writeModel.Counterparties,
+			rawJSON(writeModel.Raw),
+		)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+
+	savedTransactions := make([]DbTransaction, 0, len(writeModels))
+
+	for _, writeModel := range writeModels {
+		transaction, err := scanTransaction(results.QueryRow())
+
+		if err == pgx.ErrNoRows {
+			results.Close()
+			return []DbTransaction{}, fmt.Errorf("Failed to save transaction with plaid_transaction_id='%s': no bank account with plaid_account_id='%s'", writeModel.PlaidTransactionID, writeModel.PlaidAccountID)
+		}
+
+		if err != nil {
+			results.Close()
+			return []DbTransaction{}, fmt.Errorf("Failed to save transaction with plaid_transaction_id='%s': %w", writeModel.PlaidTransactionID, err)
+		}
+
+		savedTransactions = append(savedTransactions, transaction)
+	}
+
+	if err := results.Close(); err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to save transactions: %w", err)
+	}
+
+	return savedTransactions, nil
+}
+
+// rawJSON makes sure the payload is sent as JSON rather than as bytea, and as NULL when there is none
+func rawJSON(raw []byte) any {
+	if raw == nil {
+		return nil
+	}
+
+	return json.RawMessage(raw)
+}
+
+func scanTransaction(row pgx.Row) (DbTransaction, error) {
+	var transaction DbTransaction
+
+	err := row.Scan(
+		&transaction.ID,
+		&transaction.PlaidTransactionID,
+		&transaction.BankAccountID,
+		&transaction.Amount,
+		&transaction.Currency,
+		&transaction.DateAuthorized,
+		&transaction.DateTimeAuthorized,
+		&transaction.DatePosted,
+		&transaction.DateTimePosted,
+		&transaction.Name,
+		&transaction.MerchantName,
+		&transaction.Category,
+		&transaction.CategoryDetailed,
+		&transaction.Pending,
+		&transaction.PendingTransactionID,
+		&transaction.PaymentChannel,
+		&transaction.MerchantEntityID,
+		&transaction.LogoURL,
+		&transaction.Website,
+		&transaction.Location,
+		&transaction.Counterparties,
+		&transaction.CategoryID,
+		&transaction.CategorySource,
+		&transaction.Payee,
+		&transaction.Notes,
+		&transaction.TransferTransactionID,
+		&transaction.TransferSource,
+	)
+
+	return transaction, err
+}
+
+const allocationColumns = `id, transaction_id, amount, category_id, notes`
+
+func scanAllocation(row pgx.Row) (Allocation, error) {
+	var allocation Allocation
+
+	err := row.Scan(
+		&allocation.ID,
+		&allocation.TransactionID,
+		&allocation.Amount,
+		&allocation.CategoryID,
+		&allocation.Notes,
+	)
+
+	return allocation, err
+}
+
+const attachmentColumns = `id, transaction_id, blob_key, file_name, content_type, size, created_at`
+
+func scanAttachment(row pgx.Row) (Attachment, error) {
+	var attachment Attachment
+
+	err := row.Scan(
+		&attachment.ID,
+		&attachment.TransactionID,
+		&attachment.BlobKey,
+		&attachment.FileName,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.CreatedAt,
+	)
+
+	return attachment, err
+}
+
+func collectAllocations(rows pgx.Rows) ([]Allocation, error) {
+	defer rows.Close()
+
+	var allocations []Allocation
+
+	for rows.Next() {
+		allocation, err := scanAllocation(rows)
+
+		if err != nil {
+			return []Allocation{}, fmt.Errorf("Failed to read allocation row: %w", err)
+		}
+
+		allocations = append(allocations, allocation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []Allocation{}, fmt.Errorf("Failed to read allocation rows: %w", err)
+	}
+
+	return allocations, nil
+}
+
+func collectTransactions(rows pgx.Rows) ([]DbTransaction, error) {
+	defer rows.Close()
+
+	var allTransactions []DbTransaction
+
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+
+		if err != nil {
+			return []DbTransaction{}, fmt.Errorf("Failed to read transaction row: %w", err)
+		}
+
+		allTransactions = append(allTransactions, transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []DbTransaction{}, fmt.Errorf("Failed to read transaction rows: %w", err)
+	}
+
+	return allTransactions, nil
+}
+
+// ListAllPages returns every transaction matching the filter, fetching them page by page
+func ListAllPages(ctx context.Context, repository TransactionRepository, filter TransactionFilter) ([]DbTransaction, error) {
+	var allTransactions []DbTransaction
+
+	filter.After = nil
+
+	if filter.Limit <= 0 {
+		filter.Limit = 1000
+	}
+
+	for {
+		page, err := repository.ListTransactions(ctx, filter)
+
+		if err != nil {
+			return []DbTransaction{}, err
+		}
+
+		allTransactions = append(allTransactions, page.Transactions...)
+
+		if page.NextCursor == nil {
+			return allTransactions, nil
+		}
+
+		filter.After = page.NextCursor
+	}
 }
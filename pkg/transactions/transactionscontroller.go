@@ -0,0 +1,301 @@
+package transactions
+
+import (
+	"fmt"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/categories"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/users"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+)
+
+const transactionPageSize = 50
+
+func RegisterTransactionRoutes(
+	e *echo.Echo,
+	transactionRepository TransactionRepository,
+	bankAccountRepository repositories.BankAccountRepository,
+	categoryRepository categories.CategoryRepository,
+	// Spending from this amount on is listed by the missing receipt filter when it has no attachment
+	missingReceiptAbove decimal.Decimal,
+) {
+
+	log := e.Logger
+
+	e.GET("/transactions", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+		query := parseTransactionListQuery(c)
+		filter := query.filter(user.ID, missingReceiptAbove)
+
+		if after := c.QueryParam("after"); after != "" {
+			cursor, err := ParseTransactionCursor(after, filter.SortBy)
+
+			if err != nil {
+				return c.String(400, "Invalid page cursor")
+			}
+
+			filter.After = &cursor
+		}
+
+		page, err := transactionRepository.ListTransactions(ctx, filter)
+
+		if err != nil {
+			log.Errorf("Failed to list transactions: %v", err)
+			return c.String(500, "Something went wrong when listing transactions...")
+		}
+
+		bankAccounts, err := bankAccountRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list bank accounts: %v", err)
+			return c.String(500, "Something went wrong when listing transactions...")
+		}
+
+		allCategories, err := categoryRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list categories: %v", err)
+			return c.String(500, "Something went wrong when listing transactions...")
+		}
+
+		ids := make([]int64, 0, len(page.Transactions))
+
+		for _, transaction := range page.Transactions {
+			ids = append(ids, transaction.ID)
+		}
+
+		allocations, err := transactionRepository.ListAllocations(ctx, ids)
+
+		if err != nil {
+			log.Errorf("Failed to list allocations: %v", err)
+			return c.String(500, "Something went wrong when listing transactions...")
+		}
+
+		tags, err := transactionRepository.ListTags(ctx, ids)
+
+		if err != nil {
+			log.Errorf("Failed to list tags: %v", err)
+			return c.String(500, "Something went wrong when listing transactions...")
+		}
+
+		attachments, err := transactionRepository.ListAttachments(ctx, ids)
+
+		if err != nil {
+			log.Errorf("Failed to list attachments: %v", err)
+			return c.String(500, "Something went wrong when listing transactions...")
+		}
+
+		tagNames, err := transactionRepository.ListTagNames(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list tags: %v", err)
+			return c.String(500, "Something went wrong when listing transactions...")
+		}
+
+		view := transactionListView{
+			Query:               query,
+			Accounts:            bankAccounts,
+			Categories:          categories.NewTree(allCategories),
+			Page:                page,
+			Allocations:         allocations,
+			Tags:                tags,
+			Attachments:         attachments,
+			TagNames:            tagNames,
+			MissingReceiptAbove: missingReceiptAbove,
+		}
+
+		// The next page of the infinite scroll
+		if filter.After != nil {
+			return layout.RenderComponent(c, 200, transactionRows(view))
+		}
+
+		// Filters and sorting replace the table, the page stays as it is
+		if c.Request().Header.Get("HX-Request") == "true" && c.Request().Header.Get("HX-History-Restore-Request") != "true" {
+			return layout.RenderComponent(c, 200, TransactionTable(view))
+		}
+
+		return layout.RenderPage(c, 200, TransactionsPage(view))
+	})
+}
+
+// transactionListQuery holds the filters and sorting of the transaction list as they appear in the URL,
+// so that they can be put back into the form and the links of the page.
+type transactionListQuery struct {
+	Account   string
+	From      string
+	To        string
+	MinAmount string
+	MaxAmount string
+	Category  string
+	Tag       string
+	Search    string
+	// Set to "1" to only list spending without a receipt
+	MissingReceipt string
+	Sort           SortColumn
+	Direction      string
+}
+
+func parseTransactionListQuery(c echo.Context) transactionListQuery {
+	query := transactionListQuery{
+		Account:        c.QueryParam("account"),
+		From:           c.QueryParam("from"),
+		To:             c.QueryParam("to"),
+		MinAmount:      c.QueryParam("min"),
+		MaxAmount:      c.QueryParam("max"),
+		Category:       c.QueryParam("category"),
+		Tag:            c.QueryParam("tag"),
+		Search:         c.QueryParam("search"),
+		MissingReceipt: c.QueryParam("missing_receipt"),
+		Sort:           SortByDate,
+		Direction:      "desc",
+	}
+
+	if sortColumn, err := ParseSortColumn(c.QueryParam("sort")); err == nil {
+		query.Sort = sortColumn
+	}
+
+	if c.QueryParam("dir") == "asc" {
+		query.Direction = "asc"
+	}
+
+	return query
+}
+
+// filter turns the query into a TransactionFilter. Values that can not be parsed are ignored, the
+// inputs of the form only allow valid ones anyway.
+func (q transactionListQuery) filter(userID int, missingReceiptAbove decimal.Decimal) TransactionFilter {
+	filter := TransactionFilter{
+		UserID:     userID,
+		Tag:        q.Tag,
+		Search:     q.Search,
+		SortBy:     q.Sort,
+		Descending: q.Direction == "desc",
+		Limit:      transactionPageSize,
+	}
+
+	if bankAccountID, err := strconv.Atoi(q.Account); err == nil {
+		filter.BankAccountID = &bankAccountID
+	}
+
+	if from, err := time.Parse(time.DateOnly, q.From); err == nil {
+		filter.From = &from
+	}
+
+	if to, err := time.Parse(time.DateOnly, q.To); err == nil {
+		filter.To = &to
+	}
+
+	if minAmount, err := decimal.NewFromString(q.MinAmount); err == nil {
+		filter.MinAmount = decimal.NewNullDecimal(minAmount)
+	}
+
+	if maxAmount, err := decimal.NewFromString(q.MaxAmount); err == nil {
+		filter.MaxAmount = decimal.NewNullDecimal(maxAmount)
+	}
+
+	if categoryID, err := strconv.Atoi(q.Category); err == nil {
+		filter.CategoryID = &categoryID
+	}
+
+	if q.MissingReceipt == "1" {
+		filter.MissingReceiptAbove = decimal.NewNullDecimal(missingReceiptAbove)
+	}
+
+	return filter
+}
+
+func (q transactionListQuery) values() url.Values {
+	values := url.Values{}
+
+	for name, value := range map[string]string{
+		"account":         q.Account,
+		"from":            q.From,
+		"to":              q.To,
+		"min":             q.MinAmount,
+		"max":             q.MaxAmount,
+		"category":        q.Category,
+		"tag":             q.Tag,
+		"search":          q.Search,
+		"missing_receipt": q.MissingReceipt,
+		"sort":            string(q.Sort),
+		"dir":             q.Direction,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+
+	return values
+}
+
+// nextPageUrl returns the url of the page after the cursor with the same filters and sorting
+func (q transactionListQuery) nextPageUrl(cursor TransactionCursor) string {
+	values := q.values()
+	values.Set("after", cursor.Encode())
+
+	return "/transactions?" + values.Encode()
+}
+
+// sortUrl returns the url of the list sorted by the column. Sorting by the current column again
+// reverses the direction.
+func (q transactionListQuery) sortUrl(column SortColumn) string {
+	sorted := q
+	sorted.Sort = column
+
+	switch {
+	case q.Sort == column && q.Direction == "desc":
+		sorted.Direction = "asc"
+	case q.Sort == column:
+		sorted.Direction = "desc"
+	case column == SortByName:
+		sorted.Direction = "asc"
+	default:
+		sorted.Direction = "desc"
+	}
+
+	return "/transactions?" + sorted.values().Encode()
+}
+
+func (q transactionListQuery) sortIndicator(column SortColumn) string {
+	if q.Sort != column {
+		return ""
+	}
+
+	if q.Direction == "desc" {
+		return " ↓"
+	}
+
+	return " ↑"
+}
+
+type transactionListView struct {
+	Query      transactionListQuery
+	Accounts   []models.BankAccount
+	Categories categories.Tree
+	Page       TransactionPage
+	// The parts of the split transactions on the page
+	Allocations map[int64][]Allocation
+	// The tags and the receipts shown with each transaction on the page
+	Tags        map[int64][]string
+	Attachments map[int64][]Attachment
+	// Suggestions for the tag filter
+	TagNames            []string
+	MissingReceiptAbove decimal.Decimal
+}
+
+func (v transactionListView) accountName(bankAccountID int) string {
+	for _, account := range v.Accounts {
+		if account.ID == bankAccountID {
+			return account.Name
+		}
+	}
+
+	return fmt.Sprintf("Account %d", bankAccountID)
+}
@@ -0,0 +1,89 @@
+package transactionsync
+
+import (
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/transactions"
+)
+
+func toWriteModels(bankTransactions []banking.Transaction) []transactions.DbTransactionWriteModel {
+	writeModels := make([]transactions.DbTransactionWriteModel, 0, len(bankTransactions))
+
+	for _, bankTransaction := range bankTransactions {
+		writeModels = append(writeModels, toWriteModel(bankTransaction))
+	}
+
+	return writeModels
+}
+
+func toWriteModel(bankTransaction banking.Transaction) transactions.DbTransactionWriteModel {
+	// Providers only set the authorized date when it differs from the posted one
+	dateAuthorized := bankTransaction.Date
+
+	if bankTransaction.AuthorizedDate != nil {
+		dateAuthorized = *bankTransaction.AuthorizedDate
+	}
+
+	var paymentChannel *string
+
+	if bankTransaction.PaymentChannel != "" {
+		paymentChannel = &bankTransaction.PaymentChannel
+	}
+
+	return transactions.DbTransactionWriteModel{
+		PlaidTransactionID:   bankTransaction.ProviderTransactionID,
+		PlaidAccountID:       bankTransaction.ProviderAccountID,
+		Amount:               bankTransaction.Amount,
+		Currency:             bankTransaction.Currency,
+		DateAuthorized:       dateAuthorized,
+		DateTimeAuthorized:   bankTransaction.AuthorizedDatetime,
+		DatePosted:           bankTransaction.Date,
+		DateTimePosted:       bankTransaction.Datetime,
+		Name:                 bankTransaction.Name,
+		MerchantName:         bankTransaction.MerchantName,
+		Category:             bankTransaction.Category,
+		CategoryDetailed:     bankTransaction.CategoryDetailed,
+		Pending:              bankTransaction.Pending,
+		PendingTransactionID: bankTransaction.PendingTransactionID,
+		PaymentChannel:       paymentChannel,
+		MerchantEntityID:     bankTransaction.MerchantEntityID,
+		LogoURL:              bankTransaction.LogoURL,
+		Website:              bankTransaction.Website,
+		Location:             toLocation(bankTransaction.Location),
+		Counterparties:       toCounterparties(bankTransaction.Counterparties),
+		Raw:                  bankTransaction.Raw,
+	}
+}
+
+func toLocation(location *banking.TransactionLocation) *transactions.Location {
+	if location == nil {
+		return nil
+	}
+
+	return &transactions.Location{
+		Address:     location.Address,
+		City:        location.City,
+		Region:      location.Region,
+		PostalCode:  location.PostalCode,
+		Country:     location.Country,
+		Lat:         location.Lat,
+		Lon:         location.Lon,
+		StoreNumber: location.StoreNumber,
+	}
+}
+
+func toCounterparties(bankCounterparties []banking.Counterparty) []transactions.Counterparty {
+	counterparties := make([]transactions.Counterparty, 0, len(bankCounterparties))
+
+	for _, counterparty := range bankCounterparties {
+		counterparties = append(counterparties, transactions.Counterparty{
+			Name:            counterparty.Name,
+			Type:            counterparty.Type,
+			EntityID:        counterparty.EntityID,
+			Website:         counterparty.Website,
+			LogoURL:         counterparty.LogoURL,
+			ConfidenceLevel: counterparty.ConfidenceLevel,
+		})
+	}
+
+	return counterparties
+}
@@ -0,0 +1,324 @@
+package transactionsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/categorization"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/transfers"
+	"nerdmoney/pkg/unitofwork"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrSyncInProgress = errors.New("A transaction sync is already in progress for this bank connection")
+
+// Syncer pulls transaction updates from the banking provider for a bank connection, starting at the cursor
+// stored with the connection, and applies them to the transaction table.
+type Syncer struct {
+	bankingProvider banking.BankingProvider
+	unitOfWork      unitofwork.UnitOfWork
+	log             echo.Logger
+
+	mu         sync.Mutex
+	inProgress map[int]bool
+}
+
+func NewSyncer(
+	bankingProvider banking.BankingProvider,
+	unitOfWork unitofwork.UnitOfWork,
+	log echo.Logger,
+) *Syncer {
+	return &Syncer{
+		bankingProvider: bankingProvider,
+		unitOfWork:      unitOfWork,
+		log:             log,
+		inProgress:      map[int]bool{},
+	}
+}
+
+// SyncAll syncs every bank connection one after another. A failure of one connection
+// does not stop the others from being synced.
+func (s *Syncer) SyncAll(ctx context.Context) error {
+	connections, err := s.unitOfWork.Repos().BankConnections.ListAll(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to list bank connections to sync: %w", err)
+	}
+
+	var syncErrors []error
+
+	for _, connection := range connections {
+		if ctx.Err() != nil {
+			syncErrors = append(syncErrors, ctx.Err())
+			break
+		}
+
+		// Providers reject syncs for connections that need user action, there is no point in trying
+		if connection.Disabled || connection.LoginRequired {
+			s.log.Debugf("Skipping transaction sync for bank connection with id='%d' which needs user action", connection.ID)
+			continue
+		}
+
+		if _, err := s.SyncConnection(ctx, connection); err != nil && !errors.Is(err, ErrSyncInProgress) {
+			syncErrors = append(syncErrors, err)
+		}
+	}
+
+	return errors.Join(syncErrors...)
+}
+
+// SyncConnection runs a single sync for the given connection and records it in the sync run history.
+func (s *Syncer) SyncConnection(ctx context.Context, connection models.BankConnection) (transactions.SyncRun, error) {
+	if !s.lock(connection.ID) {
+		return transactions.SyncRun{}, ErrSyncInProgress
+	}
+
+	defer s.unlock(connection.ID)
+
+	s.log.Infof("Starting transaction sync for bank connection with id='%d'", connection.ID)
+
+	run, err := s.unitOfWork.Repos().SyncRuns.Start(ctx, connection.ID)
+
+	if err != nil {
+		return transactions.SyncRun{}, err
+	}
+
+	syncErr := s.sync(ctx, connection, &run)
+
+	if syncErr != nil {
+		errorMessage := syncErr.Error()
+		run.Error = &errorMessage
+	}
+
+	// The run is recorded even when the sync was cancelled
+	finishedRun, err := s.unitOfWork.Repos().SyncRuns.Finish(context.WithoutCancel(ctx), run)
+
+	if err != nil {
+		s.log.Errorf("Failed to record sync run result for bank connection with id='%d': %v", connection.ID, err)
+		finishedRun = run
+	}
+
+	if syncErr != nil {
+		return finishedRun, fmt.Errorf("Failed to sync transactions for bank connection with id='%d': %w", connection.ID, syncErr)
+	}
+
+	// Balances are not part of a sync response. The cached ones are free to fetch and updated by the
+	// provider whenever it pulls new transactions, so they are as recent as the transactions we just got.
+	if err := s.saveBalances(ctx, connection); err != nil {
+		s.log.Errorf("Failed to save balances after transaction sync for bank connection with id='%d': %v", connection.ID, err)
+	}
+
+	s.log.Infof(
+		"Finished transaction sync for bank connection with id='%d': added=%d, modified=%d, removed=%d",
+		connection.ID,
+		finishedRun.AddedCount,
+		finishedRun.ModifiedCount,
+		finishedRun.RemovedCount,
+	)
+
+	return finishedRun, nil
+}
+
+func (s *Syncer) sync(ctx context.Context, connection models.BankConnection, run *transactions.SyncRun) error {
+	response, err := s.bankingProvider.Transactions(
+		ctx,
+		banking.GetTransactionsRequest{Cursor: connection.TransactionsCursor},
+		connection.AccessToken,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to fetch transactions from the banking provider: %w", err)
+	}
+
+	added := toWriteModels(response.Added)
+	modified := toWriteModels(response.Modified)
+
+	var merges []transactions.TransactionMerge
+	var transfersFound []transfers.Pair
+
+	var adoptedCount int
+
+	// The cursor must only move forward together with the changes it covers
+	err = s.unitOfWork.WithTx(ctx, func(tx unitofwork.Repos) error {
+		// The first sync of a bank which was linked again returns the history we have already, under new ids
+		if connection.TransactionsCursor == nil {
+			adoptedCount, err = tx.Transactions.AdoptProviderIDs(ctx, added)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		savedTransactions, err := tx.Transactions.SaveAll(ctx, append(added, modified...))
+
+		if err != nil {
+			return err
+		}
+
+		// Posted transactions take over the edits of the pending transactions they replace
+		merges, err = tx.Transactions.MergePending(ctx, savedTransactions)
+
+		if err != nil {
+			return err
+		}
+
+		// Connections linked before users existed have no owner whose rules could be applied
+		if connection.UserID != nil {
+			// Rules see the edits merged over from the pending transactions, so they do not overwrite them
+			savedTransactions, err = reloadMerged(ctx, tx.Transactions, *connection.UserID, savedTransactions, merges)
+
+			if err != nil {
+				return err
+			}
+
+			categorizer := categorization.NewCategorizer(tx.CategorizationRules, tx.Transactions)
+
+			if _, err := categorizer.Categorize(ctx, *connection.UserID, savedTransactions); err != nil {
+				return err
+			}
+
+			if len(savedTransactions) > 0 {
+				matcher := transfers.NewMatcher(tx.Transactions, tx.BankAccounts, tx.ExchangeRates)
+
+				transfersFound, err = matcher.MatchSince(ctx, *connection.UserID, earliestDatePosted(savedTransactions))
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := tx.Transactions.DeleteAllByPlaidTransactionID(ctx, response.Removed); err != nil {
+			return err
+		}
+
+		return tx.BankConnections.UpdateTransactionsCursor(ctx, connection.ID, response.NextCursor)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if adoptedCount > 0 {
+		s.log.Infof("Matched %d transactions to transactions saved before bank connection with id='%d' was linked", adoptedCount, connection.ID)
+	}
+
+	if len(merges) > 0 {
+		s.log.Infof("Merged %d pending transactions into their posted transactions for bank connection with id='%d'", len(merges), connection.ID)
+	}
+
+	if len(transfersFound) > 0 {
+		s.log.Infof("Linked %d transfers for bank connection with id='%d'", len(transfersFound), connection.ID)
+	}
+
+	run.AddedCount = len(added)
+	run.ModifiedCount = len(modified)
+	run.RemovedCount = len(response.Removed)
+
+	return nil
+}
+
+// reloadMerged replaces the saved transactions which pending transactions were merged into with their current state
+func reloadMerged(
+	ctx context.Context,
+	transactionRepository transactions.TransactionRepository,
+	userID int,
+	savedTransactions []transactions.DbTransaction,
+	merges []transactions.TransactionMerge,
+) ([]transactions.DbTransaction, error) {
+	if len(merges) == 0 {
+		return savedTransactions, nil
+	}
+
+	merged := map[int64]bool{}
+
+	for _, merge := range merges {
+		merged[merge.PostedTransactionID] = true
+	}
+
+	reloaded := make([]transactions.DbTransaction, 0, len(savedTransactions))
+
+	for _, transaction := range savedTransactions {
+		if merged[transaction.ID] {
+			current, err := transactionRepository.FindByID(ctx, userID, transaction.ID)
+
+			if err != nil {
+				return []transactions.DbTransaction{}, err
+			}
+
+			transaction = current
+		}
+
+		reloaded = append(reloaded, transaction)
+	}
+
+	return reloaded, nil
+}
+
+func earliestDatePosted(savedTransactions []transactions.DbTransaction) time.Time {
+	earliest := savedTransactions[0].DatePosted
+
+	for _, transaction := range savedTransactions[1:] {
+		if transaction.DatePosted.Before(earliest) {
+			earliest = transaction.DatePosted
+		}
+	}
+
+	return earliest
+}
+
+func (s *Syncer) saveBalances(ctx context.Context, connection models.BankConnection) error {
+	accountsResponse, err := s.bankingProvider.Accounts(ctx, connection.AccessToken)
+
+	if err != nil {
+		return fmt.Errorf("Failed to fetch accounts from the banking provider: %w", err)
+	}
+
+	return s.unitOfWork.WithTx(ctx, func(tx unitofwork.Repos) error {
+		var updatedAccounts []models.BankAccount
+
+		for _, account := range accountsResponse.Accounts {
+			updatedAccount, err := tx.BankAccounts.UpdateBalances(ctx, account.ProviderAccountID, account.CurrentBalance, account.AvailableBalance)
+
+			// The user did not share this account with us when linking the bank
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			updatedAccounts = append(updatedAccounts, updatedAccount)
+		}
+
+		return tx.BalanceSnapshots.RecordAll(ctx, updatedAccounts)
+	})
+}
+
+func (s *Syncer) lock(bankConnectionID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inProgress[bankConnectionID] {
+		return false
+	}
+
+	s.inProgress[bankConnectionID] = true
+
+	return true
+}
+
+func (s *Syncer) unlock(bankConnectionID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inProgress, bankConnectionID)
+}
@@ -0,0 +1,28 @@
+package transactionsync
+
+import (
+	"context"
+	"time"
+)
+
+// RunScheduled syncs all bank connections right away and then once every interval,
+// until the context is cancelled.
+func (s *Syncer) RunScheduled(ctx context.Context, interval time.Duration) {
+	s.log.Infof("Scheduling transaction sync every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SyncAll(ctx); err != nil {
+			s.log.Errorf("Scheduled transaction sync failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			s.log.Infof("Stopping scheduled transaction sync")
+			return
+		case <-ticker.C:
+		}
+	}
+}
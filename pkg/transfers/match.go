@@ -0,0 +1,252 @@
+package transfers
+
+import (
+	"math"
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/transactions"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Transfers between banks take a few days, the sides are at most this many days apart
+const matchDateWindow = 5
+
+// Without a hint in the descriptions, the sides have to be at most this many days apart
+const unhintedDateWindow = 2
+
+// Rates of the user rarely are the rates the bank used, cross currency amounts may differ by this share
+var fxTolerance = decimal.NewFromFloat(0.03)
+
+// Words banks use in the descriptions of transfers and credit card payments
+var transferKeywords = []string{"transfer", "xfer", "payment", "autopay", "pmt", "withdrawal", "deposit"}
+
+// Pair is a transfer from one of the user's accounts to another
+type Pair struct {
+	Outflow transactions.DbTransaction
+	Inflow  transactions.DbTransaction
+}
+
+type candidatePair struct {
+	Pair
+	score float64
+}
+
+// Match pairs outgoing with incoming transactions of the user's other accounts which look like the two sides of a
+// transfer: the same amount, converted for transfers between currencies, a few days apart and ideally descriptions
+// hinting at a transfer. Every transaction is part of at most one pair, the closest matches win.
+func Match(allTransactions []transactions.DbTransaction, bankAccounts []models.BankAccount, converter exchangerates.Converter) []Pair {
+	accountsByID := map[int]models.BankAccount{}
+
+	for _, bankAccount := range bankAccounts {
+		accountsByID[bankAccount.ID] = bankAccount
+	}
+
+	var outflows, inflows []transactions.DbTransaction
+
+	for _, transaction := range allTransactions {
+		if !matchable(transaction) {
+			continue
+		}
+
+		if transaction.Amount.IsPositive() {
+			outflows = append(outflows, transaction)
+		} else if transaction.Amount.IsNegative() {
+			inflows = append(inflows, transaction)
+		}
+	}
+
+	var candidates []candidatePair
+
+	for _, outflow := range outflows {
+		for _, inflow := range inflows {
+			if outflow.BankAccountID == inflow.BankAccountID {
+				continue
+			}
+
+			days := daysApart(outflow.DatePosted, inflow.DatePosted)
+
+			if days > matchDateWindow {
+				continue
+			}
+
+			amountDifference, ok := relativeAmountDifference(outflow, inflow, converter)
+
+			if !ok {
+				continue
+			}
+
+			if outflow.Currency == inflow.Currency && !amountDifference.IsZero() {
+				continue
+			}
+
+			if amountDifference.GreaterThan(fxTolerance) {
+				continue
+			}
+
+			hints := hintCount(outflow, inflow, accountsByID)
+
+			if hints == 0 && days > unhintedDateWindow {
+				continue
+			}
+
+			difference, _ := amountDifference.Float64()
+
+			candidates = append(candidates, candidatePair{
+				Pair:  Pair{Outflow: outflow, Inflow: inflow},
+				score: float64(days) - 2*float64(hints) + 100*difference,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score < candidates[j].score
+		}
+
+		if candidates[i].Outflow.ID != candidates[j].Outflow.ID {
+			return candidates[i].Outflow.ID < candidates[j].Outflow.ID
+		}
+
+		return candidates[i].Inflow.ID < candidates[j].Inflow.ID
+	})
+
+	paired := map[int64]bool{}
+	var pairs []Pair
+
+	for _, candidate := range candidates {
+		if paired[candidate.Outflow.ID] || paired[candidate.Inflow.ID] {
+			continue
+		}
+
+		paired[candidate.Outflow.ID] = true
+		paired[candidate.Inflow.ID] = true
+
+		pairs = append(pairs, candidate.Pair)
+	}
+
+	return pairs
+}
+
+// Candidates returns the transactions the user could link with the transaction as the other side of a transfer,
+// the most likely first. Transactions which are a side of another transfer already are left out.
+func Candidates(transaction transactions.DbTransaction, allTransactions []transactions.DbTransaction, converter exchangerates.Converter) []transactions.DbTransaction {
+	type candidate struct {
+		transaction transactions.DbTransaction
+		difference  decimal.Decimal
+		days        int
+	}
+
+	var candidates []candidate
+
+	for _, other := range allTransactions {
+		if other.ID == transaction.ID || other.BankAccountID == transaction.BankAccountID {
+			continue
+		}
+
+		if other.IsTransfer() && *other.TransferTransactionID != transaction.ID {
+			continue
+		}
+
+		if other.Amount.Sign() == transaction.Amount.Sign() {
+			continue
+		}
+
+		outflow, inflow := transaction, other
+
+		if transaction.Amount.IsNegative() {
+			outflow, inflow = other, transaction
+		}
+
+		difference, ok := relativeAmountDifference(outflow, inflow, converter)
+
+		// Without a rate the amounts can't be compared, the user may still know better
+		if !ok {
+			difference = decimal.NewFromInt(1)
+		}
+
+		candidates = append(candidates, candidate{other, difference, daysApart(transaction.DatePosted, other.DatePosted)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if !candidates[i].difference.Equal(candidates[j].difference) {
+			return candidates[i].difference.LessThan(candidates[j].difference)
+		}
+
+		return candidates[i].days < candidates[j].days
+	})
+
+	result := make([]transactions.DbTransaction, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		result = append(result, candidate.transaction)
+	}
+
+	return result
+}
+
+// matchable tells whether the matcher may pair the transaction. Transactions the user linked or unlinked
+// themselves are left alone.
+func matchable(transaction transactions.DbTransaction) bool {
+	if transaction.Pending || transaction.IsTransfer() {
+		return false
+	}
+
+	return transaction.TransferSource == nil || *transaction.TransferSource != transactions.TransferSourceUser
+}
+
+// relativeAmountDifference compares the amount leaving one account with the amount arriving in the other, relative
+// to the amount leaving. It returns false if there is no rate between the currencies.
+func relativeAmountDifference(outflow, inflow transactions.DbTransaction, converter exchangerates.Converter) (decimal.Decimal, bool) {
+	arrived, ok := converter.Convert(inflow.Amount.Neg(), inflow.Currency, outflow.Currency, outflow.DatePosted)
+
+	if !ok || outflow.Amount.IsZero() {
+		return decimal.Zero, false
+	}
+
+	return arrived.Sub(outflow.Amount).Abs().Div(outflow.Amount.Abs()), true
+}
+
+// hintCount counts the hints in the descriptions that the transactions are a transfer: words like "transfer" and
+// mentions of the other account's name or mask
+func hintCount(outflow, inflow transactions.DbTransaction, accountsByID map[int]models.BankAccount) int {
+	hints := 0
+
+	for _, transaction := range []transactions.DbTransaction{outflow, inflow} {
+		description := strings.ToLower(transaction.Name)
+
+		for _, keyword := range transferKeywords {
+			if strings.Contains(description, keyword) {
+				hints++
+				break
+			}
+		}
+	}
+
+	if mentionsAccount(outflow, accountsByID[inflow.BankAccountID]) {
+		hints++
+	}
+
+	if mentionsAccount(inflow, accountsByID[outflow.BankAccountID]) {
+		hints++
+	}
+
+	return hints
+}
+
+func mentionsAccount(transaction transactions.DbTransaction, bankAccount models.BankAccount) bool {
+	description := strings.ToLower(transaction.Name)
+
+	if bankAccount.Mask != nil && *bankAccount.Mask != "" && strings.Contains(description, *bankAccount.Mask) {
+		return true
+	}
+
+	return bankAccount.Name != "" && strings.Contains(description, strings.ToLower(bankAccount.Name))
+}
+
+func daysApart(a, b time.Time) int {
+	return int(math.Abs(math.Round(a.Sub(b).Hours() / 24)))
+}
@@ -0,0 +1,237 @@
+package transfers
+
+import (
+	"nerdmoney/pkg/accounts/models"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/transactions"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var testAccounts = []models.BankAccount{
+	{ID: 1, Name: "Checking", Currency: "USD"},
+	{ID: 2, Name: "Savings", Currency: "USD"},
+	{ID: 3, Name: "Euro account", Currency: "EUR"},
+	{ID: 4, Name: "Pound account", Currency: "GBP"},
+}
+
+var testConverter = exchangerates.NewConverter([]exchangerates.ExchangeRate{
+	{FromCurrency: "USD", ToCurrency: "EUR", Rate: decimal.RequireFromString("0.9"), ValidOn: day(1).AddDate(0, -1, 0)},
+})
+
+func day(n int) time.Time {
+	return time.Date(2024, time.March, n, 0, 0, 0, 0, time.UTC)
+}
+
+func transaction(id int64, bankAccountID int, amount string, currency string, posted int, name string) transactions.DbTransaction {
+	return transactions.DbTransaction{
+		ID:            id,
+		BankAccountID: bankAccountID,
+		Amount:        decimal.RequireFromString(amount),
+		Currency:      currency,
+		DatePosted:    day(posted),
+		Name:          name,
+	}
+}
+
+func linkedTo(t transactions.DbTransaction, otherID int64, source transactions.TransferSource) transactions.DbTransaction {
+	t.TransferTransactionID = &otherID
+	t.TransferSource = &source
+	return t
+}
+
+func TestMatch(t *testing.T) {
+	pending := transaction(2, 2, "-100", "USD", 1, "ACME")
+	pending.Pending = true
+
+	unlinked := transaction(2, 2, "-100", "USD", 1, "ACME")
+	userSource := transactions.TransferSourceUser
+	unlinked.TransferSource = &userSource
+
+	tests := []struct {
+		name         string
+		transactions []transactions.DbTransaction
+		// Outflow and inflow ID of each expected pair
+		want [][2]int64
+	}{
+		{
+			name:         "same amount on the same day",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 2, "-100", "USD", 1, "ACME")},
+			want:         [][2]int64{{1, 2}},
+		},
+		{
+			name:         "same currency amounts have to be equal",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 2, "-99.99", "USD", 1, "ACME")},
+		},
+		{
+			name:         "unhinted sides two days apart",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 2, "-100", "USD", 3, "ACME")},
+			want:         [][2]int64{{1, 2}},
+		},
+		{
+			name:         "unhinted sides three days apart",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 2, "-100", "USD", 4, "ACME")},
+		},
+		{
+			name:         "hinted sides three days apart",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "Online transfer"), transaction(2, 2, "-100", "USD", 4, "ACME")},
+			want:         [][2]int64{{1, 2}},
+		},
+		{
+			name:         "hinted sides outside the date window",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "Online transfer"), transaction(2, 2, "-100", "USD", 7, "Transfer from Checking")},
+		},
+		{
+			// 91.50 EUR are 101.67 USD at the user's rate, 1.7% off
+			name:         "cross currency within the tolerance",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 3, "-91.50", "EUR", 2, "ACME")},
+			want:         [][2]int64{{1, 2}},
+		},
+		{
+			name:         "cross currency from the other side",
+			transactions: []transactions.DbTransaction{transaction(1, 3, "90", "EUR", 1, "ACME"), transaction(2, 1, "-102", "USD", 1, "ACME")},
+			want:         [][2]int64{{1, 2}},
+		},
+		{
+			// 95 EUR are 105.56 USD, 5.6% off
+			name:         "cross currency beyond the tolerance",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 3, "-95", "EUR", 1, "ACME")},
+		},
+		{
+			name:         "cross currency without a rate",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 4, "-80", "GBP", 1, "ACME")},
+		},
+		{
+			name:         "same account",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), transaction(2, 1, "-100", "USD", 1, "ACME")},
+		},
+		{
+			name:         "pending transaction",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), pending},
+		},
+		{
+			name: "transaction which is a transfer already",
+			transactions: []transactions.DbTransaction{
+				transaction(1, 1, "100", "USD", 1, "ACME"),
+				linkedTo(transaction(2, 2, "-100", "USD", 1, "ACME"), 3, transactions.TransferSourceMatch),
+			},
+		},
+		{
+			name:         "transaction the user unlinked",
+			transactions: []transactions.DbTransaction{transaction(1, 1, "100", "USD", 1, "ACME"), unlinked},
+		},
+		{
+			name: "closest inflow wins",
+			transactions: []transactions.DbTransaction{
+				transaction(1, 1, "100", "USD", 1, "ACME"),
+				transaction(2, 2, "-100", "USD", 2, "ACME"),
+				transaction(3, 2, "-100", "USD", 1, "ACME"),
+			},
+			want: [][2]int64{{1, 3}},
+		},
+		{
+			name: "each transaction is paired once",
+			transactions: []transactions.DbTransaction{
+				transaction(1, 1, "100", "USD", 1, "ACME"),
+				transaction(2, 1, "100", "USD", 3, "ACME"),
+				transaction(3, 2, "-100", "USD", 2, "ACME"),
+				transaction(4, 2, "-100", "USD", 3, "ACME"),
+			},
+			want: [][2]int64{{2, 4}, {1, 3}},
+		},
+		{
+			name: "hinted inflow wins over a closer one",
+			transactions: []transactions.DbTransaction{
+				transaction(1, 1, "100", "USD", 1, "ACME"),
+				transaction(2, 2, "-100", "USD", 1, "ACME"),
+				transaction(3, 2, "-100", "USD", 3, "Transfer from Checking"),
+			},
+			want: [][2]int64{{1, 3}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got [][2]int64
+
+			for _, pair := range Match(test.transactions, testAccounts, testConverter) {
+				got = append(got, [2]int64{pair.Outflow.ID, pair.Inflow.ID})
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected pairs %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	outflow := transaction(1, 1, "100", "USD", 1, "ACME")
+
+	tests := []struct {
+		name         string
+		transaction  transactions.DbTransaction
+		transactions []transactions.DbTransaction
+		want         []int64
+	}{
+		{
+			name:        "closest amount first, then closest day",
+			transaction: outflow,
+			transactions: []transactions.DbTransaction{
+				outflow,
+				transaction(2, 2, "-100", "USD", 3, "ACME"),
+				transaction(3, 2, "-100", "USD", 1, "ACME"),
+				transaction(4, 3, "-91.50", "EUR", 1, "ACME"),
+				transaction(5, 2, "-120", "USD", 1, "ACME"),
+				// Without a rate the amounts can't be compared, which puts it last
+				transaction(6, 4, "-80", "GBP", 1, "ACME"),
+			},
+			want: []int64{3, 2, 4, 5, 6},
+		},
+		{
+			name:        "inflow looking for its outflow",
+			transaction: transaction(1, 2, "-100", "USD", 1, "ACME"),
+			transactions: []transactions.DbTransaction{
+				transaction(2, 1, "100", "USD", 2, "ACME"),
+				transaction(3, 3, "90", "EUR", 1, "ACME"),
+			},
+			want: []int64{3, 2},
+		},
+		{
+			name:        "same account, same sign and other transfers are left out",
+			transaction: outflow,
+			transactions: []transactions.DbTransaction{
+				transaction(2, 1, "-100", "USD", 1, "ACME"),
+				transaction(3, 2, "50", "USD", 1, "ACME"),
+				linkedTo(transaction(4, 2, "-100", "USD", 1, "ACME"), 9, transactions.TransferSourceMatch),
+				transaction(5, 2, "-100", "USD", 1, "ACME"),
+			},
+			want: []int64{5},
+		},
+		{
+			name:        "current counterpart is kept",
+			transaction: linkedTo(outflow, 2, transactions.TransferSourceUser),
+			transactions: []transactions.DbTransaction{
+				linkedTo(transaction(2, 2, "-100", "USD", 1, "ACME"), 1, transactions.TransferSourceUser),
+			},
+			want: []int64{2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []int64{}
+
+			for _, candidate := range Candidates(test.transaction, test.transactions, testConverter) {
+				got = append(got, candidate.ID)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected candidates %v, got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package transfers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/transactions"
+	"time"
+)
+
+// Matcher links the transfers between the accounts of a user
+type Matcher struct {
+	transactionRepository  transactions.TransactionRepository
+	bankAccountRepository  repositories.BankAccountRepository
+	exchangeRateRepository exchangerates.ExchangeRateRepository
+}
+
+// NewMatcher works with repositories bound to a transaction as well, e.g. during a sync
+func NewMatcher(
+	transactionRepository transactions.TransactionRepository,
+	bankAccountRepository repositories.BankAccountRepository,
+	exchangeRateRepository exchangerates.ExchangeRateRepository,
+) *Matcher {
+	return &Matcher{transactionRepository, bankAccountRepository, exchangeRateRepository}
+}
+
+// MatchSince links the transfers among the user's transactions posted on or after since and returns them
+func (m *Matcher) MatchSince(ctx context.Context, userID int, since time.Time) ([]Pair, error) {
+	// The other side may be posted a few days before
+	from := since.AddDate(0, 0, -matchDateWindow)
+
+	recentTransactions, err := transactions.ListAllPages(ctx, m.transactionRepository, transactions.TransactionFilter{UserID: userID, From: &from})
+
+	if err != nil {
+		return []Pair{}, err
+	}
+
+	bankAccounts, err := m.bankAccountRepository.ListAll(ctx, userID)
+
+	if err != nil {
+		return []Pair{}, err
+	}
+
+	rates, err := m.exchangeRateRepository.ListAll(ctx, userID)
+
+	if err != nil {
+		return []Pair{}, err
+	}
+
+	var linked []Pair
+
+	for _, pair := range Match(recentTransactions, bankAccounts, exchangerates.NewConverter(rates)) {
+		if pair.Outflow.DatePosted.Before(since) && pair.Inflow.DatePosted.Before(since) {
+			continue
+		}
+
+		err := m.transactionRepository.LinkTransfer(ctx, userID, pair.Outflow.ID, pair.Inflow.ID, transactions.TransferSourceMatch)
+
+		// Linked concurrently, e.g. by the user
+		if errors.Is(err, transactions.ErrTransferDecided) {
+			continue
+		}
+
+		if err != nil {
+			return []Pair{}, fmt.Errorf("Failed to link transfers of User with id='%d': %w", userID, err)
+		}
+
+		linked = append(linked, pair)
+	}
+
+	return linked, nil
+}
@@ -0,0 +1,174 @@
+package transfers
+
+import (
+	"errors"
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/common/layout"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/transactions"
+	"nerdmoney/pkg/users"
+	"strconv"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// How far apart the sides of a transfer the user links may be
+const candidateDateWindow = 10
+
+// How far back "Find transfers" looks
+const matchHistoryYears = 2
+
+func RegisterTransferRoutes(
+	e *echo.Echo,
+	transactionRepository transactions.TransactionRepository,
+	bankAccountRepository repositories.BankAccountRepository,
+	exchangeRateRepository exchangerates.ExchangeRateRepository,
+) {
+
+	log := e.Logger
+	matcher := NewMatcher(transactionRepository, bankAccountRepository, exchangeRateRepository)
+
+	e.GET("/transactions/:id/transfer-candidates", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+		ctx := c.Request().Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		transaction, err := transactionRepository.FindByID(ctx, user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to find transaction: %v", err)
+			return c.String(500, "Something went wrong when looking for the other side of the transfer...")
+		}
+
+		from := transaction.DatePosted.AddDate(0, 0, -candidateDateWindow)
+		to := transaction.DatePosted.AddDate(0, 0, candidateDateWindow)
+
+		nearbyTransactions, err := transactions.ListAllPages(ctx, transactionRepository, transactions.TransactionFilter{UserID: user.ID, From: &from, To: &to})
+
+		if err != nil {
+			log.Errorf("Failed to list transactions: %v", err)
+			return c.String(500, "Something went wrong when looking for the other side of the transfer...")
+		}
+
+		bankAccounts, err := bankAccountRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list bank accounts: %v", err)
+			return c.String(500, "Something went wrong when looking for the other side of the transfer...")
+		}
+
+		rates, err := exchangeRateRepository.ListAll(ctx, user.ID)
+
+		if err != nil {
+			log.Errorf("Failed to list exchange rates: %v", err)
+			return c.String(500, "Something went wrong when looking for the other side of the transfer...")
+		}
+
+		view := transferCandidatesView{
+			Transaction:  transaction,
+			Candidates:   Candidates(transaction, nearbyTransactions, exchangerates.NewConverter(rates)),
+			AccountNames: map[int]string{},
+		}
+
+		for _, bankAccount := range bankAccounts {
+			view.AccountNames[bankAccount.ID] = bankAccount.Name
+		}
+
+		return layout.RenderComponent(c, 200, TransferCandidates(view))
+	})
+
+	e.POST("/transactions/:id/transfer", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		otherID, err := strconv.ParseInt(c.FormValue("other"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid id of the other transaction")
+		}
+
+		err = transactionRepository.LinkTransfer(c.Request().Context(), user.ID, id, otherID, transactions.TransferSourceUser)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if errors.Is(err, transactions.ErrTransferWithinAccount) {
+			return c.String(422, err.Error())
+		}
+
+		if err != nil {
+			log.Errorf("Failed to link transfer: %v", err)
+			return c.String(500, "Something went wrong when linking the transfer...")
+		}
+
+		c.Response().Header().Set("HX-Trigger", transactions.TransactionsChangedEvent)
+
+		// Closes the list of candidates
+		return layout.RenderComponent(c, 200, templ.NopComponent)
+	})
+
+	e.DELETE("/transactions/:id/transfer", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+		if err != nil {
+			return c.String(400, "Invalid transaction id")
+		}
+
+		err = transactionRepository.UnlinkTransfer(c.Request().Context(), user.ID, id)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.String(404, "Transaction not found")
+		}
+
+		if err != nil {
+			log.Errorf("Failed to unlink transfer: %v", err)
+			return c.String(500, "Something went wrong when unlinking the transfer...")
+		}
+
+		c.Response().Header().Set("HX-Trigger", transactions.TransactionsChangedEvent)
+
+		return c.NoContent(204)
+	})
+
+	// Syncs only match new transactions, this matches the history as well
+	e.POST("/transfers/match", func(c echo.Context) error {
+		user := users.CurrentUser(c)
+
+		pairs, err := matcher.MatchSince(c.Request().Context(), user.ID, time.Now().AddDate(-matchHistoryYears, 0, 0))
+
+		if err != nil {
+			log.Errorf("Failed to match transfers: %v", err)
+			return c.String(500, "Something went wrong when looking for transfers...")
+		}
+
+		c.Response().Header().Set("HX-Trigger", transactions.TransactionsChangedEvent)
+
+		return layout.RenderComponent(c, 200, MatchResult(len(pairs)))
+	})
+}
+
+type transferCandidatesView struct {
+	Transaction  transactions.DbTransaction
+	Candidates   []transactions.DbTransaction
+	AccountNames map[int]string
+}
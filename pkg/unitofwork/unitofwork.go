@@ -0,0 +1,88 @@
+package unitofwork
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/categorization"
+	"nerdmoney/pkg/exchangerates"
+	"nerdmoney/pkg/networth"
+	"nerdmoney/pkg/transactions"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// Repos groups all repositories. Depending on where it comes from, every repository in it
+// either runs its queries directly on the pool or as part of the same database transaction.
+type Repos struct {
+	BankConnections     repositories.BankConnectionRepository
+	BankAccounts        repositories.BankAccountRepository
+	BankAccountNumbers  repositories.BankAccountNumberRepository
+	Transactions        transactions.TransactionRepository
+	SyncRuns            transactions.SyncRunRepository
+	Imports             transactions.ImportRepository
+	BalanceSnapshots    networth.BalanceSnapshotRepository
+	CategorizationRules categorization.RuleRepository
+	ExchangeRates       exchangerates.ExchangeRateRepository
+}
+
+type UnitOfWork interface {
+	// Repos returns repositories that are not bound to a transaction.
+	Repos() Repos
+	// WithTx runs fn with repositories bound to a new database transaction. The transaction
+	// is committed when fn returns nil and rolled back when it returns an error or panics.
+	WithTx(ctx context.Context, fn func(tx Repos) error) error
+}
+
+type unitOfWorkImpl struct {
+	pool  *pgxpool.Pool
+	repos Repos
+	log   echo.Logger
+}
+
+func New(pool *pgxpool.Pool, repos Repos, log echo.Logger) UnitOfWork {
+	return &unitOfWorkImpl{pool, repos, log}
+}
+
+func (u *unitOfWorkImpl) Repos() Repos {
+	return u.repos
+}
+
+func (u *unitOfWorkImpl) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	u.log.Debugf("Starting transaction...")
+
+	tx, err := u.pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to start transaction: %w", err)
+	}
+
+	// A no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	err = fn(Repos{
+		BankConnections:     u.repos.BankConnections.WithTx(tx),
+		BankAccounts:        u.repos.BankAccounts.WithTx(tx),
+		BankAccountNumbers:  u.repos.BankAccountNumbers.WithTx(tx),
+		Transactions:        u.repos.Transactions.WithTx(tx),
+		SyncRuns:            u.repos.SyncRuns.WithTx(tx),
+		Imports:             u.repos.Imports.WithTx(tx),
+		BalanceSnapshots:    u.repos.BalanceSnapshots.WithTx(tx),
+		CategorizationRules: u.repos.CategorizationRules.WithTx(tx),
+		ExchangeRates:       u.repos.ExchangeRates.WithTx(tx),
+	})
+
+	if err != nil {
+		u.log.Debugf("Rolling back transaction...")
+		return err
+	}
+
+	u.log.Debugf("Comitting transaction...")
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("Failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters as recommended by RFC 9106, section 4
+const (
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// HashPassword hashes the password with argon2id and a random salt. The result is encoded in the
+// PHC string format, e.g. $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>, so the parameters can be
+// changed later without breaking existing hashes.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("Failed to generate password salt: %w", err)
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		argon2Memory,
+		argon2Time,
+		argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+// VerifyPassword reports whether password matches a hash created by HashPassword
+func VerifyPassword(password, encodedHash string) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, fmt.Errorf("Unsupported password hash format")
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("Unsupported argon2 version '%s'", parts[2])
+	}
+
+	var memory, time uint32
+	var threads uint8
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, fmt.Errorf("Invalid argon2 parameters '%s': %w", parts[3], err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return false, fmt.Errorf("Invalid password salt: %w", err)
+	}
+
+	expectedHash, err := base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil {
+		return false, fmt.Errorf("Invalid password hash: %w", err)
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(expectedHash)))
+
+	return subtle.ConstantTimeCompare(hash, expectedHash) == 1, nil
+}
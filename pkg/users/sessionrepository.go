@@ -0,0 +1,79 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// SessionRepository stores sessions by the hash of their token, see hashSessionToken
+type SessionRepository interface {
+	Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	// FindUser returns the owner of the session, unless the session does not exist or has expired
+	FindUser(ctx context.Context, tokenHash string) (User, error)
+	Delete(ctx context.Context, tokenHash string) error
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+type sessionRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewSessionRepository(pool *pgxpool.Pool, log echo.Logger) SessionRepository {
+	return &sessionRepositoryImpl{pool, log}
+}
+
+func (r *sessionRepositoryImpl) Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	r.log.Debugf("Attempting to create a new session for User with id='%d'", userID)
+
+	query := `INSERT INTO user_session (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+
+	if _, err := r.db.Exec(ctx, query, userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("Failed to create a new session for User with id='%d': %w", userID, err)
+	}
+
+	return nil
+}
+
+func (r *sessionRepositoryImpl) FindUser(ctx context.Context, tokenHash string) (User, error) {
+	query := `
+	SELECT users.id, users.email, users.password_hash, users.created_at, users.base_currency
+	FROM user_session
+	JOIN users ON users.id = user_session.user_id
+	WHERE user_session.token_hash = $1 AND user_session.expires_at > now()`
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, tokenHash))
+
+	if err != nil {
+		return User{}, fmt.Errorf("Failed to find session: %w", err)
+	}
+
+	return user, nil
+}
+
+func (r *sessionRepositoryImpl) Delete(ctx context.Context, tokenHash string) error {
+	r.log.Debugf("Attempting to delete a session")
+
+	if _, err := r.db.Exec(ctx, `DELETE FROM user_session WHERE token_hash = $1`, tokenHash); err != nil {
+		return fmt.Errorf("Failed to delete session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *sessionRepositoryImpl) DeleteExpired(ctx context.Context) (int, error) {
+	r.log.Debugf("Attempting to delete expired sessions")
+
+	commandTag, err := r.db.Exec(ctx, `DELETE FROM user_session WHERE expires_at <= now()`)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to delete expired sessions: %w", err)
+	}
+
+	return int(commandTag.RowsAffected()), nil
+}
@@ -0,0 +1,108 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	sessionCookieName = "session"
+	sessionDuration   = 30 * 24 * time.Hour
+	// Key of the logged in User in the echo context
+	userContextKey = "user"
+)
+
+// Paths which can be visited without a session. Plaid webhooks are authenticated by their signature.
+var publicPathPrefixes = []string{"/login", "/register", "/assets/", "/webhooks/plaid"}
+
+func newSessionToken() (string, error) {
+	token := make([]byte, 32)
+
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("Failed to generate session token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashSessionToken is used to look up sessions, so a leaked database does not leak usable sessions
+func hashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func setSessionCookie(c echo.Context, token string, expiresAt time.Time, secure bool) {
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(c echo.Context, secure bool) {
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// RequireSession rejects requests without a valid session cookie, except for the public paths.
+// Page requests are redirected to the login page, htmx requests get an HX-Redirect header instead.
+// The logged in User is available to handlers through CurrentUser.
+func RequireSession(sessionRepository SessionRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, prefix := range publicPathPrefixes {
+				if strings.HasPrefix(c.Request().URL.Path, prefix) {
+					return next(c)
+				}
+			}
+
+			cookie, err := c.Cookie(sessionCookieName)
+
+			if err == nil && cookie.Value != "" {
+				user, err := sessionRepository.FindUser(c.Request().Context(), hashSessionToken(cookie.Value))
+
+				if err == nil {
+					c.Set(userContextKey, user)
+					return next(c)
+				}
+
+				c.Logger().Debugf("Rejecting session: %v", err)
+			}
+
+			if c.Request().Header.Get("HX-Request") == "true" {
+				c.Response().Header().Set("HX-Redirect", "/login")
+				return c.NoContent(http.StatusUnauthorized)
+			}
+
+			if c.Request().Method != http.MethodGet {
+				return c.String(http.StatusUnauthorized, "Log in to continue")
+			}
+
+			return c.Redirect(http.StatusSeeOther, "/login")
+		}
+	}
+}
+
+// CurrentUser returns the User who sent the request. It may only be called from handlers behind RequireSession.
+func CurrentUser(c echo.Context) User {
+	return c.Get(userContextKey).(User)
+}
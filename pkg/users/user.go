@@ -0,0 +1,25 @@
+package users
+
+import (
+	"fmt"
+	"nerdmoney/pkg/common/secrets"
+	"time"
+)
+
+type User struct {
+	ID           int
+	Email        string
+	PasswordHash string
+	CreatedAt    time.Time
+	// Reports and budgets convert all amounts into this currency
+	BaseCurrency string
+}
+
+// String keeps the password hash out of log output
+func (u User) String() string {
+	return fmt.Sprintf("{ID:%d Email:%s PasswordHash:%s CreatedAt:%v BaseCurrency:%s}", u.ID, u.Email, secrets.Redacted, u.CreatedAt, u.BaseCurrency)
+}
+
+func (u User) GoString() string {
+	return u.String()
+}
@@ -0,0 +1,93 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nerdmoney/pkg/common/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrEmailTaken = errors.New("A user with this email already exists")
+
+type UserRepository interface {
+	// Create returns ErrEmailTaken if the email is already registered
+	Create(ctx context.Context, email string, passwordHash string) (User, error)
+	FindByEmail(ctx context.Context, email string) (User, error)
+	UpdateBaseCurrency(ctx context.Context, userID int, baseCurrency string) error
+}
+
+type userRepositoryImpl struct {
+	db  database.Querier
+	log echo.Logger
+}
+
+func NewUserRepository(pool *pgxpool.Pool, log echo.Logger) UserRepository {
+	return &userRepositoryImpl{pool, log}
+}
+
+const userColumns = `id, email, password_hash, created_at, base_currency`
+
+func (r *userRepositoryImpl) Create(ctx context.Context, email string, passwordHash string) (User, error) {
+	r.log.Debugf("Attempting to create a new User")
+
+	query := `INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING ` + userColumns
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, email, passwordHash))
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return User{}, ErrEmailTaken
+	}
+
+	if err != nil {
+		return User{}, fmt.Errorf("Failed to create a new User: %w", err)
+	}
+
+	r.log.Debugf("Created new User with id='%d'", user.ID)
+
+	return user, nil
+}
+
+func (r *userRepositoryImpl) FindByEmail(ctx context.Context, email string) (User, error) {
+	r.log.Debugf("Attempting to find User by email")
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, email))
+
+	if err != nil {
+		return User{}, fmt.Errorf("Failed to find User by email: %w", err)
+	}
+
+	return user, nil
+}
+
+func (r *userRepositoryImpl) UpdateBaseCurrency(ctx context.Context, userID int, baseCurrency string) error {
+	r.log.Debugf("Attempting to update base currency of User with id='%d'", userID)
+
+	if _, err := r.db.Exec(ctx, `UPDATE users SET base_currency = $2 WHERE id = $1`, userID, baseCurrency); err != nil {
+		return fmt.Errorf("Failed to update base currency of User with id='%d': %w", userID, err)
+	}
+
+	return nil
+}
+
+func scanUser(row pgx.Row) (User, error) {
+	var user User
+
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.BaseCurrency,
+	)
+
+	return user, err
+}
@@ -0,0 +1,159 @@
+package users
+
+import (
+	"errors"
+	"nerdmoney/pkg/common/layout"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	minPasswordLength = 12
+	// argon2 happily hashes megabytes, don't let anyone make us do that
+	maxPasswordLength = 1024
+)
+
+// RegisterUserRoutes registers the login, registration and logout routes.
+// Set secureCookies unless the app is served over plain HTTP, e.g. during development.
+func RegisterUserRoutes(e *echo.Echo, userRepository UserRepository, sessionRepository SessionRepository, secureCookies bool) {
+	log := e.Logger
+
+	// Compared against when the email is unknown, so that logging in takes as long as with a known email
+	dummyPasswordHash, err := HashPassword("correct horse battery staple")
+
+	if err != nil {
+		log.Fatalf("Failed to hash dummy password: %v", err)
+	}
+
+	startSession := func(c echo.Context, user User) error {
+		token, err := newSessionToken()
+
+		if err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(sessionDuration)
+
+		if err := sessionRepository.Create(c.Request().Context(), user.ID, hashSessionToken(token), expiresAt); err != nil {
+			return err
+		}
+
+		setSessionCookie(c, token, expiresAt, secureCookies)
+
+		return nil
+	}
+
+	e.GET("/login", func(c echo.Context) error {
+		return layout.RenderPage(c, 200, LoginPage(AuthForm{}))
+	})
+
+	e.POST("/login", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		form := AuthForm{Email: normalizeEmail(c.FormValue("email"))}
+		password := c.FormValue("password")
+
+		if len(password) > maxPasswordLength {
+			form.PasswordError = "Invalid email or password"
+			return layout.RenderPage(c, 422, LoginPage(form))
+		}
+
+		user, err := userRepository.FindByEmail(ctx, form.Email)
+		passwordHash := user.PasswordHash
+
+		if err != nil {
+			passwordHash = dummyPasswordHash
+		}
+
+		matches, verifyErr := VerifyPassword(password, passwordHash)
+
+		if verifyErr != nil {
+			log.Errorf("Failed to verify password: %v", verifyErr)
+			return c.String(500, "Something went wrong when logging in...")
+		}
+
+		if err != nil || !matches {
+			form.PasswordError = "Invalid email or password"
+			return layout.RenderPage(c, 422, LoginPage(form))
+		}
+
+		if err := startSession(c, user); err != nil {
+			log.Errorf("Failed to start session: %v", err)
+			return c.String(500, "Something went wrong when logging in...")
+		}
+
+		if _, err := sessionRepository.DeleteExpired(ctx); err != nil {
+			log.Warnf("Failed to clean up expired sessions: %v", err)
+		}
+
+		return c.Redirect(http.StatusSeeOther, "/")
+	})
+
+	e.GET("/register", func(c echo.Context) error {
+		return layout.RenderPage(c, 200, RegisterPage(AuthForm{}))
+	})
+
+	e.POST("/register", func(c echo.Context) error {
+		form := AuthForm{Email: normalizeEmail(c.FormValue("email"))}
+		password := c.FormValue("password")
+
+		if _, err := mail.ParseAddress(form.Email); err != nil || len(form.Email) > 255 {
+			form.EmailError = "Enter a valid email address"
+		}
+
+		if len(password) < minPasswordLength {
+			form.PasswordError = "Use at least 12 characters"
+		} else if len(password) > maxPasswordLength {
+			form.PasswordError = "Use at most 1024 characters"
+		}
+
+		if form.EmailError != "" || form.PasswordError != "" {
+			return layout.RenderPage(c, 422, RegisterPage(form))
+		}
+
+		passwordHash, err := HashPassword(password)
+
+		if err != nil {
+			log.Errorf("Failed to hash password: %v", err)
+			return c.String(500, "Something went wrong when creating your account...")
+		}
+
+		user, err := userRepository.Create(c.Request().Context(), form.Email, passwordHash)
+
+		if errors.Is(err, ErrEmailTaken) {
+			form.EmailError = "This email is already registered"
+			return layout.RenderPage(c, 409, RegisterPage(form))
+		}
+
+		if err != nil {
+			log.Errorf("Failed to create user: %v", err)
+			return c.String(500, "Something went wrong when creating your account...")
+		}
+
+		if err := startSession(c, user); err != nil {
+			log.Errorf("Failed to start session: %v", err)
+			return c.String(500, "Something went wrong when creating your account...")
+		}
+
+		return c.Redirect(http.StatusSeeOther, "/")
+	})
+
+	e.POST("/logout", func(c echo.Context) error {
+		if cookie, err := c.Cookie(sessionCookieName); err == nil {
+			if err := sessionRepository.Delete(c.Request().Context(), hashSessionToken(cookie.Value)); err != nil {
+				log.Errorf("Failed to delete session: %v", err)
+			}
+		}
+
+		clearSessionCookie(c, secureCookies)
+
+		return c.Redirect(http.StatusSeeOther, "/login")
+	})
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
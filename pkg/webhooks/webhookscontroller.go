@@ -0,0 +1,128 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"nerdmoney/pkg/accounts/repositories"
+	"nerdmoney/pkg/banking"
+	"nerdmoney/pkg/transactionsync"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Plaid webhook bodies are small, anything bigger than this is not coming from Plaid
+const maxWebhookBodySize = 1 << 20
+
+// Upper bound for a sync started by a webhook, which outlives the webhook request
+const webhookSyncTimeout = 5 * time.Minute
+
+// PENDING_DISCONNECT states no time, Plaid disconnects the item within this window
+const pendingDisconnectWindow = 7 * 24 * time.Hour
+
+type plaidWebhook struct {
+	WebhookType           string     `json:"webhook_type"`
+	WebhookCode           string     `json:"webhook_code"`
+	ItemID                string     `json:"item_id"`
+	ConsentExpirationTime *time.Time `json:"consent_expiration_time"`
+	Error                 *struct {
+		ErrorCode string `json:"error_code"`
+	} `json:"error"`
+}
+
+func RegisterWebhookRoutes(e *echo.Echo, verifier *banking.PlaidWebhookVerifier, bankConnectionRepository repositories.BankConnectionRepository, syncer *transactionsync.Syncer) {
+
+	log := e.Logger
+
+	e.POST("/webhooks/plaid", func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodySize))
+
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Failed to read webhook body")
+		}
+
+		if err := verifier.Verify(ctx, c.Request().Header.Get("Plaid-Verification"), body); err != nil {
+			log.Warnf("Rejected Plaid webhook: %v", err)
+			return c.String(http.StatusUnauthorized, "Invalid webhook signature")
+		}
+
+		var webhook plaidWebhook
+
+		if err := json.Unmarshal(body, &webhook); err != nil {
+			return c.String(http.StatusBadRequest, "Malformed webhook body")
+		}
+
+		log.Infof("Received Plaid webhook %s/%s for item '%s'", webhook.WebhookType, webhook.WebhookCode, webhook.ItemID)
+
+		if webhook.ItemID == "" {
+			return c.NoContent(http.StatusOK)
+		}
+
+		connection, err := bankConnectionRepository.FindByPlaidItemID(ctx, webhook.ItemID)
+
+		// Unknown items are acknowledged, otherwise Plaid keeps retrying the delivery
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Warnf("Ignoring Plaid webhook for unknown item '%s'", webhook.ItemID)
+			return c.NoContent(http.StatusOK)
+		}
+
+		// Anything else is answered with an error, so that Plaid delivers the webhook again later
+		if err != nil {
+			log.Errorf("Failed to find bank connection for Plaid webhook %s/%s: %v", webhook.WebhookType, webhook.WebhookCode, err)
+			return c.String(http.StatusInternalServerError, "Failed to handle webhook")
+		}
+
+		switch {
+		case webhook.WebhookType == "TRANSACTIONS" && webhook.WebhookCode == "SYNC_UPDATES_AVAILABLE" && (connection.Disabled || connection.LoginRequired):
+			// Providers reject syncs for connections that need user action, like the scheduled sync skips them
+			log.Debugf("Skipping webhook triggered transaction sync for bank connection with id='%d' which needs user action", connection.ID)
+
+		case webhook.WebhookType == "TRANSACTIONS" && webhook.WebhookCode == "SYNC_UPDATES_AVAILABLE":
+			// Plaid expects a response within a few seconds, the sync itself runs in the background
+			go func() {
+				syncCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookSyncTimeout)
+				defer cancel()
+
+				if _, err := syncer.SyncConnection(syncCtx, connection); err != nil {
+					log.Errorf("Webhook triggered transaction sync failed: %v", err)
+				}
+			}()
+
+		case webhook.WebhookType == "ITEM" && webhook.WebhookCode == "ERROR" && webhook.Error != nil && webhook.Error.ErrorCode == "ITEM_LOGIN_REQUIRED":
+			err = bankConnectionRepository.UpdateLoginRequired(ctx, connection.ID, true)
+
+		// The item keeps working until then. The recorded expiry asks the user to reconnect, while syncs go on.
+		case webhook.WebhookType == "ITEM" && (webhook.WebhookCode == "PENDING_EXPIRATION" || webhook.WebhookCode == "PENDING_DISCONNECT"):
+			expiresAt := webhook.ConsentExpirationTime
+
+			if expiresAt == nil {
+				disconnectAt := time.Now().Add(pendingDisconnectWindow)
+				expiresAt = &disconnectAt
+			}
+
+			err = bankConnectionRepository.UpdateConsentExpiration(ctx, connection.ID, expiresAt)
+
+		case webhook.WebhookType == "ITEM" && webhook.WebhookCode == "LOGIN_REPAIRED":
+			err = bankConnectionRepository.UpdateLoginRequired(ctx, connection.ID, false)
+
+		case webhook.WebhookType == "ITEM" && webhook.WebhookCode == "USER_PERMISSION_REVOKED":
+			err = bankConnectionRepository.Disable(ctx, connection.ID)
+
+		default:
+			log.Debugf("Ignoring unhandled Plaid webhook %s/%s", webhook.WebhookType, webhook.WebhookCode)
+		}
+
+		if err != nil {
+			log.Errorf("Failed to handle Plaid webhook %s/%s: %v", webhook.WebhookType, webhook.WebhookCode, err)
+			return c.String(http.StatusInternalServerError, "Failed to handle webhook")
+		}
+
+		return c.NoContent(http.StatusOK)
+	})
+}